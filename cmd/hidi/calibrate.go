@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// calibrationMinTravel is a minimal fraction of reported axis range that has to be covered
+// by observed values, axes not moved enough are not calibrated
+const calibrationMinTravel = 0.2
+
+type axisObservation struct {
+	subHandler string
+	code       evdev.EvCode
+	info       evdev.AbsInfo
+	rest       int32
+	min, max   int32
+}
+
+// runCalibration guides user through calibration of all analog axes of a picked device
+// and writes observed ranges into the user device config
+func runCalibration() error {
+	err := updateHIDIConfiguration()
+	if err != nil {
+		fmt.Printf("configuration upkeep task failed: %s\n", err)
+	}
+
+	fmt.Printf("collecting devices...\n")
+	devices := make(SortabeDevices, 0)
+	for _, d := range collectDevices(time.Second) {
+		if d.DeviceType != input.JoystickDevice && d.DeviceType != input.KeyboardDevice {
+			continue
+		}
+		devices = append(devices, d)
+	}
+	sort.Sort(devices)
+
+	dev, err := pickDevice(devices, "calibrate")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("calibrating: %s\n", dev.String())
+
+	wg := sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer func() {
+		cancel()
+		wg.Wait()
+	}()
+
+	configs, err := config.LoadDeviceConfigs(ctx, &wg)
+	if err != nil {
+		return fmt.Errorf("loading device configs failed: %w", err)
+	}
+	dc, err := configs.FindConfig(dev.ID, dev.DeviceType)
+	if err != nil {
+		return fmt.Errorf("finding device config failed: %w", err)
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+
+	fmt.Printf("Leave all sticks and triggers at rest and press Enter\n")
+	_, _ = stdin.ReadString('\n')
+
+	axes, err := restPositions(dev)
+	if err != nil {
+		return err
+	}
+	if len(axes) == 0 {
+		return errors.New("device has no analog axes to calibrate")
+	}
+
+	events, err := dev.ProcessEvents(ctx, false, 0)
+	if err != nil {
+		return fmt.Errorf("listening on device events failed: %w", err)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ie := range events {
+			if ie.Event.Type != evdev.EV_ABS {
+				continue
+			}
+			axis, ok := axes[ie.Source.DeviceInfo.Event()][ie.Event.Code]
+			if !ok {
+				continue
+			}
+			if ie.Event.Value < axis.min {
+				axis.min = ie.Event.Value
+			}
+			if ie.Event.Value > axis.max {
+				axis.max = ie.Event.Value
+			}
+		}
+	}()
+
+	fmt.Printf("Move every stick and trigger through its full range a few times, then press Enter\n")
+	_, _ = stdin.ReadString('\n')
+	cancel()
+	wg.Wait()
+
+	calibration := make(map[string]map[evdev.EvCode]config.Calibration)
+	for _, handlerAxes := range axes {
+		for _, axis := range handlerAxes {
+			span := float64(axis.info.Maximum) - float64(axis.info.Minimum)
+			if span <= 0 || float64(axis.max-axis.min) < span*calibrationMinTravel {
+				fmt.Printf("- [%s] %s: not moved, skipping\n", axis.subHandler, evdev.ABSToString[axis.code])
+				continue
+			}
+
+			c := config.Calibration{Min: &axis.min, Max: &axis.max}
+			// axes resting well inside of its range are considered centered ones (sticks),
+			// triggers and pedals rest at one of its ends
+			margin := int32(float64(axis.max-axis.min) * 0.25)
+			if axis.rest > axis.min+margin && axis.rest < axis.max-margin {
+				c.Center = &axis.rest
+			}
+
+			if _, ok := calibration[axis.subHandler]; !ok {
+				calibration[axis.subHandler] = make(map[evdev.EvCode]config.Calibration)
+			}
+			calibration[axis.subHandler][axis.code] = c
+
+			if c.Center != nil {
+				fmt.Printf("- [%s] %s: min: %d, max: %d, center: %d\n", axis.subHandler, evdev.ABSToString[axis.code], axis.min, axis.max, axis.rest)
+			} else {
+				fmt.Printf("- [%s] %s: min: %d, max: %d\n", axis.subHandler, evdev.ABSToString[axis.code], axis.min, axis.max)
+			}
+		}
+	}
+
+	if len(calibration) == 0 {
+		return errors.New("no axis has been moved, nothing to save")
+	}
+
+	path, err := config.SaveCalibration(dc, *dev, calibration)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("calibration saved to \"%s\"\n", path)
+	return nil
+}
+
+// pickDevice listens on all given devices and returns the one that reported button press first,
+// purpose completes the prompt, e.g. "calibrate"
+func pickDevice(devices []input.Device, purpose string) (*input.Device, error) {
+	if len(devices) == 0 {
+		return nil, errors.New("no devices found")
+	}
+
+	var picked = make(chan int)
+	wg := sync.WaitGroup{}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var listening int
+	for i, d := range devices {
+		events, err := d.ProcessEvents(ctx, false, time.Millisecond*10)
+		if err != nil {
+			fmt.Printf("device %d: %s (warning: failed to monitor device for events)\n", i, d.String())
+			continue
+		}
+		fmt.Printf("device %d: %s (listening on this device)\n", i, d.String())
+		listening++
+
+		wg.Add(1)
+		go func(events <-chan *input.InputEvent, i int) {
+			defer wg.Done()
+			for ev := range events {
+				if ev.Event.Type != evdev.EV_KEY || ev.Event.Value != 1 {
+					continue
+				}
+				select {
+				case picked <- i:
+				case <-ctx.Done():
+				}
+			}
+		}(events, i)
+	}
+
+	if listening == 0 {
+		return nil, errors.New("failed to monitor any device for events")
+	}
+
+	// all listeners exit when their devices are disconnected
+	var exited = make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(exited)
+	}()
+
+	fmt.Printf("Press any button on the device that you want to %s\n", purpose)
+	select {
+	case i := <-picked:
+		cancel()
+		<-exited
+		return &devices[i], nil
+	case <-exited:
+		return nil, errors.New("all monitored devices have been disconnected")
+	}
+}
+
+// restPositions reads current values of all device axes, accelerometers are skipped as their
+// range is not affected by wear
+func restPositions(dev *input.Device) (map[string]map[evdev.EvCode]*axisObservation, error) {
+	axes := make(map[string]map[evdev.EvCode]*axisObservation)
+
+	for _, h := range dev.Handlers {
+		d, err := evdev.Open(h.DeviceInfo.EventPath())
+		if err != nil {
+			return nil, fmt.Errorf("opening \"%s\" handler failed: %w", h.DeviceInfo.Name, err)
+		}
+
+		var accelerometer bool
+		for _, p := range d.Properties() {
+			if p == evdev.INPUT_PROP_ACCELEROMETER {
+				accelerometer = true
+			}
+		}
+
+		absInfos, err := d.AbsInfos()
+		_ = d.Close()
+		if err != nil || accelerometer {
+			continue
+		}
+
+		handlerAxes := make(map[evdev.EvCode]*axisObservation)
+		for code, info := range absInfos {
+			// hat switches and multitouch slots are not continuous axes
+			if code >= evdev.ABS_HAT0X && code <= evdev.ABS_HAT3Y || code >= evdev.ABS_MT_SLOT {
+				continue
+			}
+			handlerAxes[code] = &axisObservation{
+				subHandler: h.Name,
+				code:       code,
+				info:       info,
+				rest:       info.Value,
+				min:        info.Value,
+				max:        info.Value,
+			}
+		}
+		axes[h.DeviceInfo.Event()] = handlerAxes
+	}
+
+	return axes, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+)
+
+// runCheck validates given device configs, or all factory and user configs when none are given,
+// problems are printed to out. Effective configs with inheritance resolved are printed too when requested.
+// Returns false when any of configs contains errors.
+func runCheck(out io.Writer, files []string, printResolved bool) bool {
+	if len(files) == 0 {
+		err := updateHIDIConfiguration()
+		if err != nil {
+			fmt.Fprintf(out, "configuration upkeep task failed: %s\n", err)
+		}
+		files, err = config.ConfigFiles()
+		if err != nil {
+			fmt.Fprintf(out, "failed to list configs: %s\n", err)
+			return false
+		}
+	}
+
+	if printResolved {
+		for _, file := range files {
+			data, err := config.ResolveFile(file)
+			if err != nil {
+				continue // reported by linter
+			}
+			fmt.Fprintf(out, "# %s\n%s\n\n", file, strings.TrimRight(string(data), "\n"))
+		}
+	}
+
+	var errs, warnings int
+	for _, p := range config.Lint(files) {
+		fmt.Fprintln(out, p.String())
+		if p.Error {
+			errs++
+		} else {
+			warnings++
+		}
+	}
+
+	fmt.Fprintf(out, "%d configs checked, %d errors, %d warnings\n", len(files), errs, warnings)
+	return errs == 0
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	valid := filepath.Join(dir, "valid.toml")
+	broken := filepath.Join(dir, "broken.toml")
+
+	assert.NoError(t, os.WriteFile(valid, []byte("collision_mode = \"off\"\n[identifier]\n[defaults]\n  channel = 1\n  mapping = \"Default\"\n[[mapping]]\n  name = \"Default\"\n"), 0644))
+	assert.NoError(t, os.WriteFile(broken, []byte("collision_mode = \"sometimes\"\n"), 0644))
+
+	out := &bytes.Buffer{}
+	assert.True(t, runCheck(out, []string{valid}, false))
+	assert.Equal(t, "1 configs checked, 0 errors, 0 warnings\n", out.String())
+
+	out.Reset()
+	assert.False(t, runCheck(out, []string{valid, broken}, false))
+	assert.Contains(t, out.String(), broken+":1: error: ")
+	assert.Contains(t, out.String(), "2 configs checked, 1 errors, 0 warnings\n")
+}
@@ -15,6 +15,8 @@ import (
 
 	"github.com/gethiox/HIDI/internal/pkg/input"
 	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
 	"github.com/pelletier/go-toml/v2"
 )
 
@@ -25,7 +27,38 @@ type HIDI struct {
 }
 
 type HIDIConfig struct {
-	HIDI HIDI
+	HIDI   HIDI
+	MidiIn []config.MidiInRule // global midi input rules, applied to all devices
+	Thru   *midi.Thru          // midi input forwarding to midi output, nil if disabled
+	Clock  *Clock              // internal midi clock, nil if disabled
+	Looper *Looper             // phrase looper, nil if disabled
+}
+
+type Clock struct {
+	Tempo     float64 // BPM
+	TempoStep float64 // BPM change of tempo_up and tempo_down actions
+}
+
+type ClockRaw struct {
+	Enabled   bool    `toml:"enabled"`
+	BPM       float64 `toml:"bpm"`
+	TempoStep float64 `toml:"tempo_step"`
+}
+
+type Looper struct {
+	BarTicks int // clock ticks per bar loop length is rounded to, 0 for free-running loops
+}
+
+type LooperRaw struct {
+	Enabled     bool `toml:"enabled"`
+	Quantize    bool `toml:"quantize"`
+	BeatsPerBar int  `toml:"beats_per_bar"`
+}
+
+type ThruRaw struct {
+	Enabled  bool           `toml:"enabled"`
+	Channels map[string]int `toml:"channels"` // source: destination channel (1-16)
+	Drop     []string       `toml:"drop"`
 }
 
 type HIDIConfigRaw struct {
@@ -36,6 +69,10 @@ type HIDIConfigRaw struct {
 		LogViewRate         int `toml:"log_view_rate"`
 		LogBufferSize       int `toml:"log_buffer_size"`
 	} `toml:"HIDI"`
+	MidiIn []config.TOMLMidiInRule `toml:"midi_in"`
+	Thru   ThruRaw                 `toml:"thru"`
+	Clock  ClockRaw                `toml:"clock"`
+	Looper LooperRaw               `toml:"looper"`
 }
 
 func LoadHIDIConfig(path string) (HIDIConfig, error) {
@@ -50,7 +87,31 @@ func LoadHIDIConfig(path string) (HIDIConfig, error) {
 		return HIDIConfig{}, err
 	}
 
+	midiIn, err := config.ParseMidiInRules(rawConfig.MidiIn)
+	if err != nil {
+		return HIDIConfig{}, err
+	}
+
+	thru, err := parseThru(rawConfig.Thru)
+	if err != nil {
+		return HIDIConfig{}, fmt.Errorf("[thru] %w", err)
+	}
+
+	clock, err := parseClock(rawConfig.Clock)
+	if err != nil {
+		return HIDIConfig{}, fmt.Errorf("[clock] %w", err)
+	}
+
+	looper, err := parseLooper(rawConfig.Looper, clock != nil)
+	if err != nil {
+		return HIDIConfig{}, fmt.Errorf("[looper] %w", err)
+	}
+
 	var config HIDIConfig
+	config.MidiIn = midiIn
+	config.Thru = thru
+	config.Clock = clock
+	config.Looper = looper
 
 	config.HIDI.EVThrottling = time.Second / time.Duration(rawConfig.HIDI.PoolRate)
 	config.HIDI.DiscoveryRate = time.Second / time.Duration(rawConfig.HIDI.DiscoveryRate)
@@ -59,6 +120,76 @@ func LoadHIDIConfig(path string) (HIDIConfig, error) {
 	return config, err
 }
 
+func parseThru(raw ThruRaw) (*midi.Thru, error) {
+	if !raw.Enabled {
+		return nil, nil
+	}
+
+	thru := midi.Thru{
+		Channels: make(map[byte]byte),
+		Drop:     make(map[midi.MessageKind]bool),
+	}
+	for src, dst := range raw.Channels {
+		channel, err := strconv.Atoi(src)
+		if err != nil || channel < 1 || channel > 16 {
+			return nil, fmt.Errorf("source channel outside of 1-16 range: %s", src)
+		}
+		if dst < 1 || dst > 16 {
+			return nil, fmt.Errorf("destination channel outside of 1-16 range: %d", dst)
+		}
+		thru.Channels[byte(channel-1)] = byte(dst - 1)
+	}
+	for _, kind := range raw.Drop {
+		if !midi.SupportedMessageKinds[midi.MessageKind(kind)] {
+			return nil, fmt.Errorf("unsupported message kind: %s", kind)
+		}
+		thru.Drop[midi.MessageKind(kind)] = true
+	}
+	return &thru, nil
+}
+
+func parseClock(raw ClockRaw) (*Clock, error) {
+	if !raw.Enabled {
+		return nil, nil
+	}
+
+	clock := Clock{Tempo: 120, TempoStep: 1}
+	if raw.BPM != 0 {
+		clock.Tempo = raw.BPM
+	}
+	if raw.TempoStep != 0 {
+		clock.TempoStep = raw.TempoStep
+	}
+	if clock.Tempo < midi.MinTempo || clock.Tempo > midi.MaxTempo {
+		return nil, fmt.Errorf("bpm outside of %.0f-%.0f range: %g", midi.MinTempo, midi.MaxTempo, clock.Tempo)
+	}
+	if clock.TempoStep < 0 {
+		return nil, fmt.Errorf("negative tempo_step: %g", clock.TempoStep)
+	}
+	return &clock, nil
+}
+
+func parseLooper(raw LooperRaw, clockEnabled bool) (*Looper, error) {
+	if !raw.Enabled {
+		return nil, nil
+	}
+	if !raw.Quantize {
+		return &Looper{}, nil
+	}
+
+	if !clockEnabled {
+		return nil, fmt.Errorf("quantize requires internal clock, enable it in [clock] section")
+	}
+	beats := 4
+	if raw.BeatsPerBar != 0 {
+		beats = raw.BeatsPerBar
+	}
+	if beats < 1 || beats > 16 {
+		return nil, fmt.Errorf("beats_per_bar outside of 1-16 range: %d", beats)
+	}
+	return &Looper{BarTicks: beats * midi.ClockPPQN}, nil
+}
+
 //go:embed hidi-config/hidi.toml
 //go:embed "hidi-config/device blacklist.txt"
 //go:embed hidi-config/*/*/*
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadHIDIConfigThru(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hidi.toml")
+	err := os.WriteFile(path, []byte(`
+[HIDI]
+  pool_rate = 120
+  discovery_rate = 1
+  stabilization_period = 500
+
+[thru]
+  enabled = true
+  channels = { 1 = 10, 16 = 2 }
+  drop = ["clock", "sysex"]
+`), 0644)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	cfg, err := LoadHIDIConfig(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, &midi.Thru{
+		Channels: map[byte]byte{0: 9, 15: 1},
+		Drop:     map[midi.MessageKind]bool{midi.KindClock: true, midi.KindSysEx: true},
+	}, cfg.Thru)
+}
+
+func TestParseThru(t *testing.T) {
+	thru, err := parseThru(ThruRaw{Channels: map[string]int{"1": 2}})
+	assert.NoError(t, err)
+	assert.Nil(t, thru, "disabled thru")
+
+	for _, raw := range []ThruRaw{
+		{Enabled: true, Channels: map[string]int{"0": 2}},
+		{Enabled: true, Channels: map[string]int{"a": 2}},
+		{Enabled: true, Channels: map[string]int{"1": 17}},
+		{Enabled: true, Drop: []string{"notes"}},
+	} {
+		_, err := parseThru(raw)
+		assert.Error(t, err, "%+v", raw)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	clock, err := parseClock(ClockRaw{BPM: 90})
+	assert.NoError(t, err)
+	assert.Nil(t, clock, "disabled clock")
+
+	clock, err = parseClock(ClockRaw{Enabled: true})
+	assert.NoError(t, err)
+	assert.Equal(t, &Clock{Tempo: 120, TempoStep: 1}, clock)
+
+	clock, err = parseClock(ClockRaw{Enabled: true, BPM: 90, TempoStep: 0.5})
+	assert.NoError(t, err)
+	assert.Equal(t, &Clock{Tempo: 90, TempoStep: 0.5}, clock)
+
+	for _, raw := range []ClockRaw{
+		{Enabled: true, BPM: 10},
+		{Enabled: true, BPM: 301},
+		{Enabled: true, TempoStep: -1},
+	} {
+		_, err := parseClock(raw)
+		assert.Error(t, err, "%+v", raw)
+	}
+}
+
+func TestParseLooper(t *testing.T) {
+	looper, err := parseLooper(LooperRaw{Quantize: true}, false)
+	assert.NoError(t, err)
+	assert.Nil(t, looper, "disabled looper")
+
+	looper, err = parseLooper(LooperRaw{Enabled: true}, false)
+	assert.NoError(t, err)
+	assert.Equal(t, &Looper{}, looper)
+
+	looper, err = parseLooper(LooperRaw{Enabled: true, Quantize: true}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &Looper{BarTicks: 96}, looper)
+
+	looper, err = parseLooper(LooperRaw{Enabled: true, Quantize: true, BeatsPerBar: 3}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &Looper{BarTicks: 72}, looper)
+
+	_, err = parseLooper(LooperRaw{Enabled: true, Quantize: true}, false)
+	assert.Error(t, err, "quantize without clock")
+	_, err = parseLooper(LooperRaw{Enabled: true, Quantize: true, BeatsPerBar: 17}, true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+const layoutCellWidth = 5
+
+// renderLayout draws key grid with notes played by keys, keys without a note are marked with "--"
+func renderLayout(notes map[evdev.EvCode]config.Key) string {
+	var b strings.Builder
+	for i, row := range config.KeyGrid {
+		line := strings.Repeat(" ", i*layoutCellWidth/2)
+		for _, code := range row {
+			label := "--"
+			if key, ok := notes[code]; ok {
+				label = config.NoteToString(key.Note)
+			}
+			line += fmt.Sprintf("%-*s", layoutCellWidth, label)
+		}
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+	return b.String()
+}
+
+// renderLayoutMap returns notes of key grid in mapping.keys.map format
+func renderLayoutMap(notes map[evdev.EvCode]config.Key) string {
+	var b strings.Builder
+	b.WriteString("[mapping.keys.map]\n")
+	for _, row := range config.KeyGrid {
+		for _, code := range row {
+			if key, ok := notes[code]; ok {
+				b.WriteString(fmt.Sprintf("  %s = \"%s\"\n", evdev.KEYToString[code], config.NoteToString(key.Note)))
+			}
+		}
+	}
+	return b.String()
+}
+
+func describeGenerator(g config.Generator) string {
+	return fmt.Sprintf(
+		"%s layout, %s at %s, column interval %d, row interval %d",
+		g.Layout, config.NoteToString(g.Start), evdev.KEYToString[g.Origin], g.ColumnInterval, g.RowInterval,
+	)
+}
+
+// printLayout prints notes of given layout preset, or notes of all generated mappings of given device config
+func printLayout(out io.Writer, layoutOrFile string) error {
+	if config.SupportedLayouts[config.Layout(layoutOrFile)] {
+		g, err := config.DefaultGenerator(config.Layout(layoutOrFile))
+		if err != nil {
+			return err
+		}
+		notes := g.Notes()
+		fmt.Fprintf(out, "%s\n\n%s\n%s", describeGenerator(g), renderLayout(notes), renderLayoutMap(notes))
+		return nil
+	}
+
+	data, err := config.ResolveFile(layoutOrFile)
+	if err != nil {
+		return fmt.Errorf("\"%s\" is neither a supported layout nor a readable config: %w", layoutOrFile, err)
+	}
+	cfg, err := config.ParseData(data)
+	if err != nil {
+		return fmt.Errorf("parsing \"%s\" failed: %w", layoutOrFile, err)
+	}
+
+	var found bool
+	for _, mapping := range cfg.KeyMappings {
+		if mapping.Generator == nil {
+			continue
+		}
+		found = true
+		notes := mapping.Midi[mapping.Generator.SubHandler]
+		fmt.Fprintf(out, "[%s] %s\n\n%s\n", mapping.Name, describeGenerator(*mapping.Generator), renderLayout(notes))
+	}
+	if !found {
+		return fmt.Errorf("\"%s\" doesn't contain generated mappings", layoutOrFile)
+	}
+	return nil
+}
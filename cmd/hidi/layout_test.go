@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRenderLayout(t *testing.T) {
+	notes := map[evdev.EvCode]config.Key{
+		evdev.KEY_GRAVE: {Note: 60},
+		evdev.KEY_1:     {Note: 61},
+		evdev.KEY_Z:     {Note: 24},
+	}
+
+	lines := strings.Split(renderLayout(notes), "\n")
+	assert.Len(t, lines, len(config.KeyGrid)+1)
+	assert.True(t, strings.HasPrefix(lines[0], "C3   C#3  --"))
+	assert.True(t, strings.HasPrefix(lines[3], "       --   C0   --"))
+
+	assert.Equal(t, "[mapping.keys.map]\n  KEY_GRAVE = \"C3\"\n  KEY_1 = \"C#3\"\n  KEY_Z = \"C0\"\n", renderLayoutMap(notes))
+}
+
+func TestPrintLayout(t *testing.T) {
+	out := &bytes.Buffer{}
+	assert.NoError(t, printLayout(out, "janko"))
+	assert.True(t, strings.HasPrefix(out.String(), "janko layout, C0 at KEY_Z, column interval 2, row interval -1\n"))
+	assert.Contains(t, out.String(), "  KEY_Z = \"C0\"\n")
+
+	file := filepath.Join(t.TempDir(), "generated.toml")
+	assert.NoError(t, os.WriteFile(file, []byte(`collision_mode = "off"
+[identifier]
+[defaults]
+  mapping = "Fourths"
+[action_mapping]
+  KEY_GRAVE = "panic"
+[[mapping]]
+  name = "Fourths"
+  [mapping.generator]
+    layout = "fourths"
+    start = "e1"
+[[mapping]]
+  name = "Plain"
+`), 0644))
+
+	out.Reset()
+	assert.NoError(t, printLayout(out, file))
+	lines := strings.Split(out.String(), "\n")
+	assert.Equal(t, "[Fourths] fourths layout, E1 at KEY_Z, column interval 1, row interval 5", lines[0])
+	assert.True(t, strings.HasPrefix(lines[2], "--   G2   G#2"), lines[2])
+	assert.NotContains(t, out.String(), "Plain")
+
+	assert.EqualError(t, printLayout(out, "dvorak"), "\"dvorak\" is neither a supported layout nor a readable config: reading file data failed: open dvorak: no such file or directory")
+}
@@ -48,18 +48,22 @@ var (
 	noPony          = flag.Bool("nopony", false, "oh my... You can disable me if you want to, I.. I don't really mind. I'm fine")
 	midiDevice      = flag.Int("mididevice", 0, "select N-th midi device, default: 0 (first)")
 	orgbPort        = flag.Int("orgbport", -1, "use external opengrb server")
+	orgbMock        = flag.Bool("openrgb-mock", false, "run built-in OpenRGB mock server and preview keyboard LEDs in terminal")
 	listMidiDevices = flag.Bool("listmididevices", false, "list available midi devices")
 	listDevices     = flag.Bool("listdevices", false, "list available keyboards/gamepads")
 	silent          = flag.Bool("silent", false, "no output logging, best performance")
 	virtual         = flag.Bool("virtual", false, "create virtual alsa midi port instead of connecting to existing one")
 	standalone      = flag.Bool("standalone", false, "start application and preserve selected by user keyboard as standard input device")
+	calibrate       = flag.Bool("calibrate", false, "record analog axes ranges of selected device and save them into user device config")
+	wizard          = flag.Bool("wizard", false, "learn notes and actions of selected device interactively and save them into user device config")
+	check           = flag.Bool("check", false, "validate given device configs (all factory and user configs by default) and exit")
+	layout          = flag.String("layout", "", "print notes generated by given layout (e.g. wicki_hayden) or by generated mappings of given device config and exit")
+	resolve         = flag.Bool("resolve", false, "with -check, print effective device configs with extends and include applied")
 )
 
 var log = logger.GetLogger()
 
 func init() {
-	flag.Parse()
-	*logLevel += 2
 	rand.Seed(time.Now().Unix())
 }
 
@@ -120,6 +124,9 @@ func collectDevices(s time.Duration) []input.Device {
 }
 
 func main() {
+	flag.Parse()
+	*logLevel += 2
+
 	defer gomidi.CloseDriver()
 
 	var ignoredIDs = make([]input.PhysicalID, 0)
@@ -137,6 +144,32 @@ func main() {
 			fmt.Printf("%s # [%s] (%s)\n", d.ID.String(), d.Name, d.DeviceType.String())
 		}
 		os.Exit(0)
+	case *layout != "":
+		err := printLayout(os.Stdout, *layout)
+		if err != nil {
+			fmt.Printf("%s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case *check:
+		if !runCheck(os.Stdout, flag.Args(), *resolve) {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case *calibrate:
+		err := runCalibration()
+		if err != nil {
+			fmt.Printf("calibration failed: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	case *wizard:
+		err := runWizard()
+		if err != nil {
+			fmt.Printf("wizard failed: %s\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
 	case *standalone:
 		ctx, cancel := context.WithCancel(context.Background())
 		go func() {
@@ -253,8 +286,20 @@ func main() {
 	wg := sync.WaitGroup{}
 
 	port := rand.Intn(65535-1024) + 1024
+	var matcher device.ControllerMatcher
 
-	if *orgb {
+	if *orgbMock {
+		if *orgbPort != -1 {
+			port = *orgbPort
+		}
+		log.Info(fmt.Sprintf("starting OpenRGB mock server on port %d", port), logger.Info)
+		err := runOpenRGBMock(ctx, &wg, port)
+		if err != nil {
+			log.Info(fmt.Sprintf("failed to start OpenRGB mock server: %s", err), logger.Error)
+		}
+		// mock keyboard does not report hidraw location, it's bound to every device
+		matcher = device.MatchAnyKeyboard
+	} else if *orgb {
 		if *orgbPort != -1 {
 			port = *orgbPort
 		} else {
@@ -275,7 +320,41 @@ func main() {
 
 	score := midi.Score{}
 
-	midi.ProcessMidiEvents(ctx, midiPort, midiEventsOut, midiEventsIn, &score)
+	midi.ProcessMidiEvents(&wg, ctx, midiPort, midiEventsOut, midiEventsIn, cfg.Thru, &score)
+
+	var clock *midi.Clock
+	clockWg := sync.WaitGroup{}
+	if cfg.Clock != nil {
+		clock = midi.NewClock(cfg.Clock.Tempo, cfg.Clock.TempoStep, midiEventsOut)
+		log.Info(fmt.Sprintf("internal clock enabled, tempo: %.1f BPM", clock.Tempo()), logger.Info)
+		clockWg.Add(1)
+		go func() {
+			defer clockWg.Done()
+			clock.Run(ctx)
+			log.Info(fmt.Sprintf("internal clock stopped, %s", clock.Status()), logger.Debug)
+		}()
+	}
+
+	// with looper enabled, devices send their events through it
+	var deviceEventsOut = midiEventsOut
+	var looper *midi.Looper
+	looperWg := sync.WaitGroup{}
+	if cfg.Looper != nil {
+		deviceEventsOut = make(chan midi.Event, 8)
+		looper = midi.NewLooper(cfg.Looper.BarTicks, midiEventsOut)
+		var clockEvents <-chan midi.Event
+		if cfg.Looper.BarTicks > 0 {
+			clockEvents = clock.Listen(ctx)
+			log.Info(fmt.Sprintf("looper enabled, loops quantized to %d beats", cfg.Looper.BarTicks/midi.ClockPPQN), logger.Info)
+		} else {
+			log.Info("looper enabled, loops are free-running", logger.Info)
+		}
+		looperWg.Add(1)
+		go func() {
+			defer looperWg.Done()
+			looper.Run(ctx, deviceEventsOut, clockEvents)
+		}()
+	}
 
 	var devices = make(map[*device.Device]*device.Device, 16)
 	var devicesMutex = sync.Mutex{}
@@ -287,13 +366,20 @@ func main() {
 		Grab:           *grab,
 		NoLogs:         *silent,
 		OpenRGBPort:    port,
+		OpenRGBMatcher: matcher,
 		IgnoredDevices: ignoredIDs,
 	}
 
-	manager := NewManager(managerConfig, midiEventsOut, midiEventsIn, &devicesMutex, devices, sigs)
+	manager := NewManager(managerConfig, deviceEventsOut, midiEventsIn, clock, looper, &devicesMutex, devices, sigs)
 	manager.Run(ctx)
+	if looper != nil {
+		// devices are done, looper releases its notes and exits
+		close(deviceEventsOut)
+	}
 
 	log.Info(fmt.Sprintf("waiting..."), logger.Debug)
+	clockWg.Wait()
+	looperWg.Wait()
 
 	close(midiEventsOut)
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gethiox/HIDI/internal/pkg/input"
@@ -22,6 +23,10 @@ type Manager struct {
 
 	midiOut chan<- midi.Event
 	midiIn  <-chan midi.Event
+	clock   *midi.Clock  // nil if disabled
+	looper  *midi.Looper // nil if disabled
+
+	heldNotes *device.HeldNotes // notes held on all devices, shared for poly pressure
 
 	devicesMutex *sync.Mutex
 	devices      map[*device.Device]*device.Device
@@ -33,6 +38,7 @@ type ManagerConfig struct {
 	HIDI           HIDIConfig
 	Grab, NoLogs   bool
 	OpenRGBPort    int
+	OpenRGBMatcher device.ControllerMatcher // nil for the default one
 	IgnoredDevices []input.PhysicalID
 }
 
@@ -40,6 +46,8 @@ func NewManager(
 	config ManagerConfig,
 	midiOut chan<- midi.Event,
 	midiIn <-chan midi.Event,
+	clock *midi.Clock,
+	looper *midi.Looper,
 	devicesMutex *sync.Mutex,
 	devices map[*device.Device]*device.Device,
 	sigs chan os.Signal,
@@ -48,6 +56,9 @@ func NewManager(
 		config:       config,
 		midiOut:      midiOut,
 		midiIn:       midiIn,
+		clock:        clock,
+		looper:       looper,
+		heldNotes:    device.NewHeldNotes(),
 		devicesMutex: devicesMutex,
 		devices:      devices,
 		sigs:         sigs,
@@ -58,6 +69,17 @@ func NewManager(
 // all goroutine execution has completed
 func (m Manager) Run(ctx context.Context) {
 	deviceConfigChange := config.DetectDeviceConfigChanges(ctx)
+	themeChange := config.DetectThemeChanges(ctx)
+
+	themes := &atomic.Value{}
+	themes.Store(map[string]config.Theme{})
+	m.reloadThemes(themes)
+
+	go func() {
+		for range themeChange {
+			m.reloadThemes(themes)
+		}
+	}()
 
 	wg := sync.WaitGroup{}
 	midiEventsInSpawner := utils.NewDynamicFanOut(m.midiIn)
@@ -82,19 +104,19 @@ root:
 
 		log.Info(fmt.Sprintf("Loaded factory Keyboard Configs: %d", len(configs.Factory.Keyboards)), logger.Debug)
 		for id, c := range configs.Factory.Keyboards {
-			log.Info(fmt.Sprintf("- [%s] (%s): %s", id.String(), c.ConfigFile), logger.Debug)
+			log.Info(fmt.Sprintf("- [%s]: %s", id.String(), c.ConfigFile), logger.Debug)
 		}
 		log.Info(fmt.Sprintf("Loaded factory Gamepad Configs: %d", len(configs.Factory.Gamepads)), logger.Debug)
 		for id, c := range configs.Factory.Gamepads {
-			log.Info(fmt.Sprintf("- [%s] (%s): %s", id.String(), c.ConfigFile), logger.Debug)
+			log.Info(fmt.Sprintf("- [%s]: %s", id.String(), c.ConfigFile), logger.Debug)
 		}
 		log.Info(fmt.Sprintf("Loaded user Keyboard Configs: %d", len(configs.User.Keyboards)), logger.Debug)
-		for id, c := range configs.Factory.Keyboards {
-			log.Info(fmt.Sprintf("- [%s] (%s): %s", id.String(), c.ConfigFile), logger.Debug)
+		for id, c := range configs.User.Keyboards {
+			log.Info(fmt.Sprintf("- [%s]: %s", id.String(), c.ConfigFile), logger.Debug)
 		}
 		log.Info(fmt.Sprintf("Loaded user Gamepad Configs: %d", len(configs.User.Gamepads)), logger.Debug)
-		for id, c := range configs.Factory.Gamepads {
-			log.Info(fmt.Sprintf("- [%s] (%s): %s", id.String(), c.ConfigFile), logger.Debug)
+		for id, c := range configs.User.Gamepads {
+			log.Info(fmt.Sprintf("- [%s]: %s", id.String(), c.ConfigFile), logger.Debug)
 		}
 
 		ctxDevice, cancel := context.WithCancel(context.Background())
@@ -134,6 +156,31 @@ root:
 			}
 			log.Info(fmt.Sprintf("config loaded: %s", conf.ConfigFile), logger.Debug)
 
+			if len(m.config.HIDI.MidiIn) > 0 {
+				// device rules are evaluated after global ones
+				conf.Config.MidiIn = append(append([]config.MidiInRule{}, m.config.HIDI.MidiIn...), conf.Config.MidiIn...)
+			}
+
+			if path, err := config.CCLearningPath(d); err == nil {
+				learned, err := config.LoadCCLearning(path)
+				if err != nil {
+					log.Info(fmt.Sprintf("cc_learning overlay %s load failed: %s", path, err), zap.String("device_name", d.Name), logger.Warning)
+				} else if learned != nil {
+					conf.Config.CCLearned = learned
+					log.Info(fmt.Sprintf("cc_learning overlay loaded: %s", path), zap.String("device_name", d.Name), logger.Debug)
+				}
+			}
+
+			if path, err := config.SequencerPath(d); err == nil {
+				patterns, err := config.LoadPatterns(path, conf.Config.Sequencer.Length)
+				if err != nil {
+					log.Info(fmt.Sprintf("sequencer patterns %s load failed: %s", path, err), zap.String("device_name", d.Name), logger.Warning)
+				} else if patterns != nil {
+					conf.Config.Patterns = patterns
+					log.Info(fmt.Sprintf("sequencer patterns loaded: %s", path), zap.String("device_name", d.Name), logger.Debug)
+				}
+			}
+
 			var inputEvents <-chan *input.InputEvent
 
 			appearedAt := time.Now()
@@ -162,6 +209,13 @@ root:
 
 				midiDev := device.NewDevice(dev, conf, m.midiOut, midiIn, m.config.NoLogs, m.config.OpenRGBPort, m.sigs)
 				m.devicesMutex.Lock()
+				midiDev.SetThemes(themes.Load().(map[string]config.Theme))
+				midiDev.SetClock(m.clock)
+				midiDev.SetLooper(m.looper)
+				midiDev.SetHeldNotes(m.heldNotes)
+				if m.config.OpenRGBMatcher != nil {
+					midiDev.SetControllerMatcher(m.config.OpenRGBMatcher)
+				}
 				m.devices[&midiDev] = &midiDev
 				m.devicesMutex.Unlock()
 				log.Info("Device connected", zap.String("device_name", dev.Name),
@@ -191,3 +245,20 @@ root:
 	wg.Wait()
 	log.Info("Exit manager", logger.Debug)
 }
+
+// reloadThemes loads OpenRGB themes and applies them on running devices, previous themes are kept on failure
+func (m Manager) reloadThemes(themes *atomic.Value) {
+	loaded, err := config.LoadThemes()
+	if err != nil {
+		log.Info(fmt.Sprintf("Themes load failed: %s", err), logger.Warning)
+		return
+	}
+	log.Info(fmt.Sprintf("Loaded themes: %d", len(loaded)), logger.Debug)
+
+	m.devicesMutex.Lock()
+	themes.Store(loaded)
+	for d := range m.devices {
+		d.SetThemes(loaded)
+	}
+	m.devicesMutex.Unlock()
+}
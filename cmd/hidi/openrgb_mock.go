@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device"
+	"github.com/gethiox/HIDI/internal/pkg/openrgbmock"
+	"github.com/holoplot/go-evdev"
+	"github.com/logrusorgru/aurora"
+	"github.com/realbucksavage/openrgb-go"
+)
+
+const mockPreviewInterval = time.Millisecond * 100
+
+type mockKey struct {
+	code  evdev.EvCode
+	label string
+}
+
+// mockKeyboard is a layout of keyboard advertised by -openrgb-mock server, LED names come from device.KeyToLedName
+var mockKeyboard = [][]mockKey{
+	{
+		{evdev.KEY_ESC, "Esc"}, {evdev.KEY_F1, "F1"}, {evdev.KEY_F2, "F2"}, {evdev.KEY_F3, "F3"}, {evdev.KEY_F4, "F4"},
+		{evdev.KEY_F5, "F5"}, {evdev.KEY_F6, "F6"}, {evdev.KEY_F7, "F7"}, {evdev.KEY_F8, "F8"},
+		{evdev.KEY_F9, "F9"}, {evdev.KEY_F10, "F10"}, {evdev.KEY_F11, "F11"}, {evdev.KEY_F12, "F12"},
+		{evdev.KEY_SYSRQ, "Prt"}, {evdev.KEY_SCROLLLOCK, "Scr"}, {evdev.KEY_PAUSE, "Brk"},
+		{evdev.KEY_MUTE, "Mut"}, {evdev.KEY_PREVIOUSSONG, "|<"}, {evdev.KEY_PLAYPAUSE, ">"}, {evdev.KEY_NEXTSONG, ">|"},
+	},
+	{
+		{evdev.KEY_GRAVE, "`"}, {evdev.KEY_1, "1"}, {evdev.KEY_2, "2"}, {evdev.KEY_3, "3"}, {evdev.KEY_4, "4"},
+		{evdev.KEY_5, "5"}, {evdev.KEY_6, "6"}, {evdev.KEY_7, "7"}, {evdev.KEY_8, "8"}, {evdev.KEY_9, "9"},
+		{evdev.KEY_0, "0"}, {evdev.KEY_MINUS, "-"}, {evdev.KEY_EQUAL, "="}, {evdev.KEY_BACKSPACE, "Bksp"},
+		{evdev.KEY_INSERT, "Ins"}, {evdev.KEY_HOME, "Hom"}, {evdev.KEY_PAGEUP, "PgU"},
+		{evdev.KEY_NUMLOCK, "Num"}, {evdev.KEY_KPSLASH, "/"}, {evdev.KEY_KPASTERISK, "*"}, {evdev.KEY_KPMINUS, "-"},
+	},
+	{
+		{evdev.KEY_TAB, "Tab"}, {evdev.KEY_Q, "Q"}, {evdev.KEY_W, "W"}, {evdev.KEY_E, "E"}, {evdev.KEY_R, "R"},
+		{evdev.KEY_T, "T"}, {evdev.KEY_Y, "Y"}, {evdev.KEY_U, "U"}, {evdev.KEY_I, "I"}, {evdev.KEY_O, "O"},
+		{evdev.KEY_P, "P"}, {evdev.KEY_LEFTBRACE, "["}, {evdev.KEY_RIGHTBRACE, "]"}, {evdev.KEY_BACKSLASH, "\\"},
+		{evdev.KEY_DELETE, "Del"}, {evdev.KEY_END, "End"}, {evdev.KEY_PAGEDOWN, "PgD"},
+		{evdev.KEY_KP7, "7"}, {evdev.KEY_KP8, "8"}, {evdev.KEY_KP9, "9"}, {evdev.KEY_KPPLUS, "+"},
+	},
+	{
+		{evdev.KEY_CAPSLOCK, "Caps"}, {evdev.KEY_A, "A"}, {evdev.KEY_S, "S"}, {evdev.KEY_D, "D"}, {evdev.KEY_F, "F"},
+		{evdev.KEY_G, "G"}, {evdev.KEY_H, "H"}, {evdev.KEY_J, "J"}, {evdev.KEY_K, "K"}, {evdev.KEY_L, "L"},
+		{evdev.KEY_SEMICOLON, ";"}, {evdev.KEY_APOSTROPHE, "'"}, {evdev.KEY_ENTER, "Enter"},
+		{evdev.KEY_KP4, "4"}, {evdev.KEY_KP5, "5"}, {evdev.KEY_KP6, "6"},
+	},
+	{
+		{evdev.KEY_LEFTSHIFT, "Shift"}, {evdev.KEY_Z, "Z"}, {evdev.KEY_X, "X"}, {evdev.KEY_C, "C"}, {evdev.KEY_V, "V"},
+		{evdev.KEY_B, "B"}, {evdev.KEY_N, "N"}, {evdev.KEY_M, "M"}, {evdev.KEY_COMMA, ","}, {evdev.KEY_DOT, "."},
+		{evdev.KEY_SLASH, "/"}, {evdev.KEY_RIGHTSHIFT, "Shift"}, {evdev.KEY_UP, "Up"},
+		{evdev.KEY_KP1, "1"}, {evdev.KEY_KP2, "2"}, {evdev.KEY_KP3, "3"}, {evdev.KEY_KPENTER, "Ent"},
+	},
+	{
+		{evdev.KEY_LEFTCTRL, "Ctrl"}, {evdev.KEY_LEFTMETA, "Win"}, {evdev.KEY_LEFTALT, "Alt"},
+		{evdev.KEY_SPACE, "Space"}, {evdev.KEY_RIGHTALT, "Alt"}, {evdev.KEY_RIGHTMETA, "Win"},
+		{evdev.KEY_COMPOSE, "Menu"}, {evdev.KEY_RIGHTCTRL, "Ctrl"},
+		{evdev.KEY_LEFT, "<"}, {evdev.KEY_DOWN, "Dn"}, {evdev.KEY_RIGHT, ">"},
+		{evdev.KEY_KP0, "0"}, {evdev.KEY_KPDOT, "."},
+	},
+}
+
+// mockController returns keyboard controller with LEDs in mockKeyboard order
+func mockController() openrgbmock.Controller {
+	c := openrgbmock.Controller{
+		Name:     "HIDI Mock Keyboard",
+		Type:     openrgbmock.TypeKeyboard,
+		Location: openrgbmock.Location,
+	}
+	for _, row := range mockKeyboard {
+		for _, key := range row {
+			c.LEDs = append(c.LEDs, device.KeyToLedName[key.code])
+		}
+	}
+	return c
+}
+
+// colorIndex returns the closest color of 6x6x6 cube of 256-color terminal palette
+func colorIndex(c openrgb.Color) uint8 {
+	level := func(v byte) uint8 {
+		return uint8((int(v)*5 + 127) / 255)
+	}
+	return 16 + 36*level(c.Red) + 6*level(c.Green) + level(c.Blue)
+}
+
+// shade returns a block character of given color brightness, used without color support
+func shade(c openrgb.Color) string {
+	shades := []string{" ", "░", "▒", "▓", "█"}
+
+	v := c.Red
+	if c.Green > v {
+		v = c.Green
+	}
+	if c.Blue > v {
+		v = c.Blue
+	}
+	return shades[int(v)*len(shades)/256]
+}
+
+// renderMockKeyboard renders keyboard with colors of mockController LEDs, key labels are drawn
+// on colored background, or replaced with brightness shades when colors are disabled
+func renderMockKeyboard(colors []openrgb.Color, color bool) string {
+	au := aurora.NewAurora(true)
+	var b strings.Builder
+
+	i := 0
+	for _, row := range mockKeyboard {
+		for j, key := range row {
+			if j > 0 {
+				b.WriteString(" ")
+			}
+
+			var c openrgb.Color
+			if i < len(colors) {
+				c = colors[i]
+			}
+			i++
+
+			if !color {
+				b.WriteString(strings.Repeat(shade(c), len([]rune(key.label))))
+				continue
+			}
+
+			var fg uint8 = 15 // white
+			if int(c.Red)*299+int(c.Green)*587+int(c.Blue)*114 > 128*1000 {
+				fg = 0 // black
+			}
+			b.WriteString(au.BgIndex(colorIndex(c), key.label).Index(fg).String())
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// runOpenRGBMock starts OpenRGB mock server on given port and prints its keyboard every time it changes
+func runOpenRGBMock(ctx context.Context, wg *sync.WaitGroup, port int) error {
+	s, err := openrgbmock.Listen(fmt.Sprintf("127.0.0.1:%d", port), mockController())
+	if err != nil {
+		return err
+	}
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		err := s.Serve(ctx)
+		if err != nil {
+			log.Info(fmt.Sprintf("OpenRGB mock server failed: %s", err), logger.Error)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+
+		var shown []openrgb.Color
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(mockPreviewInterval):
+			}
+
+			colors := s.Colors(0)
+			if shown != nil && !device.FrameChanged(shown, colors) {
+				continue
+			}
+			shown = colors
+			fmt.Printf("[OpenRGB mock]\n%s", renderMockKeyboard(colors, !*nocolor))
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMockController(t *testing.T) {
+	c := mockController()
+
+	var names = make(map[string]bool)
+	for _, led := range c.LEDs {
+		assert.NotEmpty(t, led, "every key of the layout needs OpenRGB LED name")
+		assert.False(t, names[led], "duplicated LED: %s", led)
+		names[led] = true
+	}
+}
+
+func TestRenderMockKeyboard(t *testing.T) {
+	colors := make([]openrgb.Color, len(mockController().LEDs))
+	colors[0] = openrgb.Color{Red: 0xff}   // Esc
+	colors[1] = openrgb.Color{Green: 0x80} // F1
+
+	lines := strings.Split(renderMockKeyboard(colors, false), "\n")
+	assert.Len(t, lines, len(mockKeyboard)+1)
+	assert.True(t, strings.HasPrefix(lines[0], "███ ▒▒    "), lines[0])
+
+	assert.Contains(t, renderMockKeyboard(colors, true), "Esc")
+}
+
+func TestColorIndex(t *testing.T) {
+	assert.Equal(t, uint8(16), colorIndex(openrgb.Color{}))
+	assert.Equal(t, uint8(196), colorIndex(openrgb.Color{Red: 0xff}))
+	assert.Equal(t, uint8(231), colorIndex(openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff}))
+}
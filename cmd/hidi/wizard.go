@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+const (
+	wizardDefaultStart = "c3"
+	wizardDefaultCount = 12
+)
+
+// wizardActions are actions wizard asks for after notes are learned
+var wizardActions = []config.Action{
+	config.OctaveDown, config.OctaveUp,
+	config.SemitoneDown, config.SemitoneUp,
+	config.ChannelDown, config.ChannelUp,
+	config.Panic,
+}
+
+// wizardStep asks for a key of given note, or of given action when action is not empty
+type wizardStep struct {
+	note   byte
+	action config.Action
+}
+
+// mappingWizard assigns notes and actions to pressed keys, notes are learned per subhandler. In guided mode user is asked for a key of every note,
+// in free-form mode every new key gets the next chromatic note until already assigned key is pressed.
+// Both modes ask for action keys at the end, pressing already assigned key skips given step.
+type mappingWizard struct {
+	freeForm bool // free-form learning in progress
+	next     byte // next free-form note
+	steps    []wizardStep
+	step     int
+
+	notes   map[string]map[evdev.EvCode]config.Key // main key: subhandler
+	actions map[evdev.EvCode]config.Action
+}
+
+func newWizard(guided bool, start byte, count int) *mappingWizard {
+	w := &mappingWizard{
+		freeForm: !guided,
+		next:     start,
+		notes:    make(map[string]map[evdev.EvCode]config.Key),
+		actions:  make(map[evdev.EvCode]config.Action),
+	}
+	if guided {
+		for i := 0; i < count && int(start)+i <= 127; i++ {
+			w.steps = append(w.steps, wizardStep{note: start + byte(i)})
+		}
+	}
+	for _, action := range wizardActions {
+		w.steps = append(w.steps, wizardStep{action: action})
+	}
+	return w
+}
+
+func (w *mappingWizard) done() bool {
+	return !w.freeForm && w.step >= len(w.steps)
+}
+
+// prompt returns instruction for the current step
+func (w *mappingWizard) prompt() string {
+	switch {
+	case w.freeForm:
+		return fmt.Sprintf("press keys in ascending order, next note: %s (press already assigned key to finish)", config.NoteToString(w.next))
+	case w.done():
+		return ""
+	case w.steps[w.step].action != "":
+		return fmt.Sprintf("press the key for %s (press already assigned key to skip)", w.steps[w.step].action)
+	default:
+		return fmt.Sprintf("press the key for %s (press already assigned key to skip)", config.NoteToString(w.steps[w.step].note))
+	}
+}
+
+// noteCount returns number of learned notes of all subhandlers
+func (w *mappingWizard) noteCount() int {
+	count := 0
+	for _, notes := range w.notes {
+		count += len(notes)
+	}
+	return count
+}
+
+// learn assigns note to the key of given subhandler
+func (w *mappingWizard) learn(subhandler string, code evdev.EvCode, note byte) {
+	if _, ok := w.notes[subhandler]; !ok {
+		w.notes[subhandler] = make(map[evdev.EvCode]config.Key)
+	}
+	w.notes[subhandler][code] = config.Key{Note: note}
+}
+
+// press assigns key pressed on given subhandler to the current step and returns feedback message
+func (w *mappingWizard) press(subhandler string, code evdev.EvCode) string {
+	name := evdev.CodeName(evdev.EV_KEY, code)
+	if subhandler != "" {
+		name = fmt.Sprintf("%s (%s)", name, subhandler)
+	}
+	_, isNote := w.notes[subhandler][code]
+	_, isAction := w.actions[code]
+	assigned := isNote || isAction
+
+	if w.freeForm {
+		if assigned {
+			w.freeForm = false
+			return fmt.Sprintf("%s: already assigned, %d notes learned", name, w.noteCount())
+		}
+		w.learn(subhandler, code, w.next)
+		message := fmt.Sprintf("%s: %s", name, config.NoteToString(w.next))
+		if w.next == 127 {
+			w.freeForm = false
+		} else {
+			w.next++
+		}
+		return message
+	}
+
+	if w.done() {
+		return ""
+	}
+
+	step := w.steps[w.step]
+	w.step++
+	switch {
+	case assigned:
+		return fmt.Sprintf("%s: already assigned, skipped", name)
+	case step.action != "":
+		w.actions[code] = step.action
+		return fmt.Sprintf("%s: %s", name, step.action)
+	default:
+		w.learn(subhandler, code, step.note)
+		return fmt.Sprintf("%s: %s", name, config.NoteToString(step.note))
+	}
+}
+
+// ask prints question and returns user's answer, default value is returned for empty answer
+func ask(stdin *bufio.Reader, question, def string) string {
+	fmt.Printf("%s [%s]: ", question, def)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def
+	}
+	return answer
+}
+
+// runWizard learns notes and actions of a picked device and writes them into its user config
+func runWizard() error {
+	err := updateHIDIConfiguration()
+	if err != nil {
+		fmt.Printf("configuration upkeep task failed: %s\n", err)
+	}
+
+	stdin := bufio.NewReader(os.Stdin)
+
+	guided := ask(stdin, "mode, 1: guided (asks for every note), 2: free-form (keys pressed in ascending order)", "1") != "2"
+	start, err := config.StringToNote(ask(stdin, "first note", wizardDefaultStart))
+	if err != nil {
+		return fmt.Errorf("invalid note: %w", err)
+	}
+	count := wizardDefaultCount
+	if guided {
+		count, err = strconv.Atoi(ask(stdin, "number of notes", strconv.Itoa(wizardDefaultCount)))
+		if err != nil || count < 1 {
+			return fmt.Errorf("invalid number of notes")
+		}
+	}
+
+	fmt.Printf("collecting devices...\n")
+	devices := make(SortabeDevices, 0)
+	for _, d := range collectDevices(time.Second) {
+		if d.DeviceType != input.JoystickDevice && d.DeviceType != input.KeyboardDevice {
+			continue
+		}
+		devices = append(devices, d)
+	}
+	sort.Sort(devices)
+
+	dev, err := pickDevice(devices, "configure")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("configuring: %s\n", dev.String())
+
+	// device is grabbed, so learned keys don't end up in the terminal
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := dev.ProcessEvents(ctx, true, 0)
+	if err != nil {
+		return fmt.Errorf("listening on device events failed: %w", err)
+	}
+
+	w := newWizard(guided, start, count)
+	for !w.done() {
+		fmt.Printf("%s\n", w.prompt())
+		for {
+			ie, ok := <-events
+			if !ok {
+				return errors.New("device disconnected")
+			}
+			if ie.Event.Type == evdev.EV_KEY && ie.Event.Value == 1 {
+				fmt.Printf("- %s\n", w.press(ie.Source.Name, ie.Event.Code))
+				break
+			}
+		}
+	}
+	cancel()
+
+	if w.noteCount() == 0 {
+		return errors.New("no notes have been learned, nothing to save")
+	}
+
+	path, backup, err := config.SaveUserConfig(*dev, config.LearnedConfig(*dev, w.notes, w.actions))
+	if err != nil {
+		return err
+	}
+	if backup != "" {
+		fmt.Printf("existing config moved to \"%s\"\n", backup)
+	}
+	fmt.Printf("config saved to \"%s\"\n", path)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWizardGuided(t *testing.T) {
+	w := newWizard(true, 60, 3)
+
+	assert.Equal(t, "press the key for C3 (press already assigned key to skip)", w.prompt())
+	assert.Equal(t, "KEY_Z: C3", w.press("", evdev.KEY_Z))
+	assert.Equal(t, "KEY_S: C#3", w.press("", evdev.KEY_S))
+	assert.Equal(t, "KEY_Z: already assigned, skipped", w.press("", evdev.KEY_Z))
+
+	assert.Equal(t, "press the key for octave_down (press already assigned key to skip)", w.prompt())
+	assert.Equal(t, "KEY_F1: octave_down", w.press("", evdev.KEY_F1))
+	for range wizardActions[1:] {
+		assert.False(t, w.done())
+		w.press("", evdev.KEY_F1)
+	}
+	assert.True(t, w.done())
+	assert.Equal(t, "", w.prompt())
+
+	assert.Equal(t, map[string]map[evdev.EvCode]config.Key{"": {evdev.KEY_Z: {Note: 60}, evdev.KEY_S: {Note: 61}}}, w.notes)
+	assert.Equal(t, map[evdev.EvCode]config.Action{evdev.KEY_F1: config.OctaveDown}, w.actions)
+}
+
+func TestWizardFreeForm(t *testing.T) {
+	w := newWizard(false, 126, 0)
+
+	assert.Equal(t, "press keys in ascending order, next note: F#8 (press already assigned key to finish)", w.prompt())
+	assert.Equal(t, "BTN_GAMEPAD/BTN_SOUTH/BTN_A: F#8", w.press("", evdev.BTN_SOUTH))
+	assert.Equal(t, "BTN_EAST/BTN_B: G8", w.press("", evdev.BTN_EAST))
+
+	// notes run out
+	assert.Equal(t, "press the key for octave_down (press already assigned key to skip)", w.prompt())
+	assert.Equal(t, "BTN_START: octave_down", w.press("", evdev.BTN_START))
+	assert.Equal(t, map[string]map[evdev.EvCode]config.Key{"": {evdev.BTN_SOUTH: {Note: 126}, evdev.BTN_EAST: {Note: 127}}}, w.notes)
+
+	w = newWizard(false, 60, 0)
+	w.press("", evdev.KEY_A)
+	assert.Equal(t, "KEY_A: already assigned, 1 notes learned", w.press("", evdev.KEY_A))
+	assert.Equal(t, "press the key for octave_down (press already assigned key to skip)", w.prompt())
+}
+
+func TestWizardSubhandlers(t *testing.T) {
+	w := newWizard(false, 60, 0)
+
+	assert.Equal(t, "KEY_A: C3", w.press("", evdev.KEY_A))
+	assert.Equal(t, "KEY_VOLUMEUP (Consumer Control): C#3", w.press("Consumer Control", evdev.KEY_VOLUMEUP))
+	// the same code of another subhandler is a different key
+	assert.Equal(t, "KEY_A (Consumer Control): D3", w.press("Consumer Control", evdev.KEY_A))
+	assert.Equal(t, "KEY_A (Consumer Control): already assigned, 3 notes learned", w.press("Consumer Control", evdev.KEY_A))
+
+	assert.Equal(t, map[string]map[evdev.EvCode]config.Key{
+		"":                 {evdev.KEY_A: {Note: 60}},
+		"Consumer Control": {evdev.KEY_VOLUMEUP: {Note: 61}, evdev.KEY_A: {Note: 62}},
+	}, w.notes)
+}
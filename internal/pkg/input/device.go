@@ -204,6 +204,18 @@ func (d *Device) SupportsNKRO() bool {
 	return false
 }
 
+// handlerCapableOf returns first handler supporting given event type
+func (d *Device) handlerCapableOf(t evdev.EvType) (Handler, bool) {
+	for _, h := range d.Handlers {
+		for _, ct := range h.DeviceInfo.CapableTypes {
+			if ct == t {
+				return h, true
+			}
+		}
+	}
+	return Handler{}, false
+}
+
 // DeviceID returns unique UUID for every device as much as possible, regardless of its connection source.
 // Vast amount of devices (especially keyboards) doesn't provide unique identifiers, so often it is
 // impossible to distinguish between two the vert same types of devices.
@@ -0,0 +1,96 @@
+package input
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// ffEffect mirrors kernel struct ff_effect with ff_rumble_effect in its union,
+// union is padded to the size of its largest member (ff_periodic_effect)
+type ffEffect struct {
+	Type      uint16
+	ID        int16
+	Direction uint16
+	Trigger   [2]uint16 // button, interval
+	Replay    [2]uint16 // length, delay (ms)
+	_         uint16    // union alignment
+	Strong    uint16
+	Weak      uint16
+	_         [5]uint32
+	_         uintptr
+}
+
+var (
+	eviocsff  = iow('E', 0x80, unsafe.Sizeof(ffEffect{}))
+	eviocrmff = iow('E', 0x81, unsafe.Sizeof(int32(0)))
+)
+
+func iow(t, nr, size uintptr) uintptr {
+	return 1<<30 | size<<16 | t<<8 | nr
+}
+
+func ioctl(fd, req, arg uintptr) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, req, arg)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// ForceFeedback is a rumble effect player of force-feedback capable event handler
+type ForceFeedback struct {
+	file *os.File
+	id   int16
+}
+
+// OpenForceFeedback opens given event device for uploading and playing rumble effect
+func OpenForceFeedback(path string) (*ForceFeedback, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	return &ForceFeedback{file: file, id: -1}, nil
+}
+
+// Rumble plays rumble effect, currently playing one is replaced
+func (f *ForceFeedback) Rumble(strong, weak uint16, duration time.Duration) error {
+	effect := ffEffect{
+		Type:   uint16(evdev.FF_RUMBLE),
+		ID:     f.id, // -1 allocates new effect slot, existing effect is updated otherwise
+		Replay: [2]uint16{uint16(duration.Milliseconds()), 0},
+		Strong: strong,
+		Weak:   weak,
+	}
+
+	err := ioctl(f.file.Fd(), eviocsff, uintptr(unsafe.Pointer(&effect)))
+	if err != nil {
+		return fmt.Errorf("uploading effect failed: %w", err)
+	}
+	f.id = effect.ID
+
+	play := evdev.InputEvent{Type: evdev.EV_FF, Code: evdev.EvCode(f.id), Value: 1}
+	err = binary.Write(f.file, binary.LittleEndian, &play)
+	if err != nil {
+		return fmt.Errorf("playing effect failed: %w", err)
+	}
+	return nil
+}
+
+// Close removes uploaded effect and closes the device
+func (f *ForceFeedback) Close() error {
+	if f.id != -1 {
+		_ = ioctl(f.file.Fd(), eviocrmff, uintptr(f.id))
+	}
+	return f.file.Close()
+}
+
+// ForceFeedbackHandler returns handler capable of force-feedback effects
+func (d *Device) ForceFeedbackHandler() (Handler, bool) {
+	return d.handlerCapableOf(evdev.EV_FF)
+}
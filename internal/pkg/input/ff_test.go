@@ -0,0 +1,170 @@
+package input
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	uiDevCreate  = 0x5501 // _IO('U', 1)
+	uiDevDestroy = 0x5502 // _IO('U', 2)
+
+	evUinput   = 0x0101
+	uiFFUpload = 1
+	uiFFErase  = 2
+)
+
+// uinputFFUpload mirrors kernel struct uinput_ff_upload
+type uinputFFUpload struct {
+	RequestID uint32
+	Retval    int32
+	Effect    ffEffect
+	Old       ffEffect
+}
+
+// uinputFFErase mirrors kernel struct uinput_ff_erase
+type uinputFFErase struct {
+	RequestID uint32
+	Retval    int32
+	EffectID  uint32
+}
+
+var (
+	uiSetEvBit      = iow('U', 100, unsafe.Sizeof(int32(0)))
+	uiSetLEDBit     = iow('U', 105, unsafe.Sizeof(int32(0)))
+	uiSetFFBit      = iow('U', 107, unsafe.Sizeof(int32(0)))
+	uiBeginFFUpload = 3<<30 | unsafe.Sizeof(uinputFFUpload{})<<16 | 'U'<<8 | 200
+	uiEndFFUpload   = iow('U', 201, unsafe.Sizeof(uinputFFUpload{}))
+	uiBeginFFErase  = 3<<30 | unsafe.Sizeof(uinputFFErase{})<<16 | 'U'<<8 | 202
+	uiEndFFErase    = iow('U', 203, unsafe.Sizeof(uinputFFErase{}))
+)
+
+type ffRecord struct {
+	uploaded []ffEffect
+	played   []evdev.InputEvent
+}
+
+// createVirtualFFDevice creates uinput device with rumble support, uploaded effects and
+// playback requests are reported through returned channel
+func createVirtualFFDevice(t *testing.T, name string) (string, <-chan ffRecord, func()) {
+	uinput, err := os.OpenFile("/dev/uinput", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("uinput not available: %s", err)
+	}
+
+	assert.Nil(t, ioctl(uinput.Fd(), uiSetEvBit, uintptr(evdev.EV_FF)))
+	assert.Nil(t, ioctl(uinput.Fd(), uiSetFFBit, uintptr(evdev.FF_RUMBLE)))
+
+	dev := evdev.UinputUserDevice{
+		ID:         evdev.InputID{BusType: 0x03, Vendor: 0x1234, Product: 0x5678},
+		EffectsMax: 1,
+	}
+	copy(dev.Name[:], name)
+	assert.Nil(t, binary.Write(uinput, binary.LittleEndian, &dev))
+	assert.Nil(t, ioctl(uinput.Fd(), uiDevCreate, 0))
+
+	records := make(chan ffRecord, 1)
+	go func() {
+		var record ffRecord
+		for {
+			var ev evdev.InputEvent
+			if err := binary.Read(uinput, binary.LittleEndian, &ev); err != nil {
+				records <- record
+				return
+			}
+
+			switch {
+			case ev.Type == evUinput && ev.Code == uiFFUpload:
+				upload := uinputFFUpload{RequestID: uint32(ev.Value)}
+				_ = ioctl(uinput.Fd(), uiBeginFFUpload, uintptr(unsafe.Pointer(&upload)))
+				record.uploaded = append(record.uploaded, upload.Effect)
+				upload.Retval = 0
+				_ = ioctl(uinput.Fd(), uiEndFFUpload, uintptr(unsafe.Pointer(&upload)))
+			case ev.Type == evUinput && ev.Code == uiFFErase:
+				erase := uinputFFErase{RequestID: uint32(ev.Value)}
+				_ = ioctl(uinput.Fd(), uiBeginFFErase, uintptr(unsafe.Pointer(&erase)))
+				erase.Retval = 0
+				_ = ioctl(uinput.Fd(), uiEndFFErase, uintptr(unsafe.Pointer(&erase)))
+			case ev.Type == evdev.EV_FF:
+				record.played = append(record.played, ev)
+			}
+		}
+	}()
+
+	cleanup := func() {
+		_ = ioctl(uinput.Fd(), uiDevDestroy, 0)
+		_ = uinput.Close()
+	}
+
+	path, ok := waitForEventNode(name)
+	if !ok {
+		cleanup()
+		t.Fatalf("virtual device event node not found")
+	}
+	return path, records, cleanup
+}
+
+// waitForEventNode looks up event device node of freshly created virtual device
+func waitForEventNode(name string) (string, bool) {
+	for i := 0; i < 50; i++ {
+		paths, _ := filepath.Glob("/dev/input/event*")
+		for _, path := range paths {
+			d, err := evdev.Open(path)
+			if err != nil {
+				continue
+			}
+			n, _ := d.Name()
+			_ = d.Close()
+			if n == name {
+				return path, true
+			}
+		}
+		time.Sleep(time.Millisecond * 20)
+	}
+	return "", false
+}
+
+func TestForceFeedbackRumble(t *testing.T) {
+	path, records, cleanup := createVirtualFFDevice(t, "HIDI virtual rumble")
+
+	ff, err := OpenForceFeedback(path)
+	if err != nil {
+		cleanup()
+		if err == syscall.EACCES {
+			t.Skipf("no access to virtual device: %s", err)
+		}
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, ff.Rumble(0xffff, 0x8000, time.Millisecond*50))
+	assert.Nil(t, ff.Rumble(0x1000, 0, time.Millisecond*20))
+	assert.Nil(t, ff.Close())
+
+	time.Sleep(time.Millisecond * 50)
+	cleanup()
+
+	record := <-records
+	if assert.Len(t, record.uploaded, 2) {
+		assert.Equal(t, uint16(evdev.FF_RUMBLE), record.uploaded[0].Type)
+		assert.Equal(t, uint16(0xffff), record.uploaded[0].Strong)
+		assert.Equal(t, uint16(0x8000), record.uploaded[0].Weak)
+		assert.Equal(t, uint16(50), record.uploaded[0].Replay[0])
+
+		// second effect updates the first one instead of allocating new slot
+		assert.Equal(t, record.uploaded[0].ID, record.uploaded[1].ID)
+		assert.Equal(t, uint16(0x1000), record.uploaded[1].Strong)
+		assert.Equal(t, uint16(20), record.uploaded[1].Replay[0])
+	}
+
+	if assert.Len(t, record.played, 2) {
+		assert.Equal(t, int32(1), record.played[0].Value)
+	}
+}
@@ -0,0 +1,58 @@
+package input
+
+import (
+	"fmt"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// LEDs controls indicator LEDs (Caps Lock, Num Lock etc.) of event handler
+type LEDs struct {
+	dev     *evdev.InputDevice
+	initial evdev.StateMap
+	touched map[evdev.EvCode]bool
+}
+
+// OpenLEDs opens given event device for writing LED events
+func OpenLEDs(path string) (*LEDs, error) {
+	dev, err := evdev.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	initial, err := dev.State(evdev.EV_LED)
+	if err != nil {
+		_ = dev.Close()
+		return nil, fmt.Errorf("reading led state failed: %w", err)
+	}
+
+	return &LEDs{dev: dev, initial: initial, touched: make(map[evdev.EvCode]bool)}, nil
+}
+
+// Set turns given LED on or off
+func (l *LEDs) Set(code evdev.EvCode, on bool) error {
+	var value int32
+	if on {
+		value = 1
+	}
+
+	err := l.dev.WriteOne(&evdev.InputEvent{Type: evdev.EV_LED, Code: code, Value: value})
+	if err != nil {
+		return err
+	}
+	l.touched[code] = true
+	return l.dev.WriteOne(&evdev.InputEvent{Type: evdev.EV_SYN, Code: evdev.SYN_REPORT})
+}
+
+// Close restores initial state of altered LEDs and closes the device
+func (l *LEDs) Close() error {
+	for code := range l.touched {
+		_ = l.Set(code, l.initial[code])
+	}
+	return l.dev.Close()
+}
+
+// LEDHandler returns handler capable of LED events
+func (d *Device) LEDHandler() (Handler, bool) {
+	return d.handlerCapableOf(evdev.EV_LED)
+}
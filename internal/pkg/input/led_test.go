@@ -0,0 +1,86 @@
+package input
+
+import (
+	"encoding/binary"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLEDs(t *testing.T) {
+	uinput, err := os.OpenFile("/dev/uinput", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("uinput not available: %s", err)
+	}
+
+	assert.Nil(t, ioctl(uinput.Fd(), uiSetEvBit, uintptr(evdev.EV_LED)))
+	assert.Nil(t, ioctl(uinput.Fd(), uiSetLEDBit, uintptr(evdev.LED_CAPSL)))
+	assert.Nil(t, ioctl(uinput.Fd(), uiSetLEDBit, uintptr(evdev.LED_SCROLLL)))
+
+	name := "HIDI virtual leds"
+	dev := evdev.UinputUserDevice{ID: evdev.InputID{BusType: 0x03, Vendor: 0x1234, Product: 0x5679}}
+	copy(dev.Name[:], name)
+	assert.Nil(t, binary.Write(uinput, binary.LittleEndian, &dev))
+	assert.Nil(t, ioctl(uinput.Fd(), uiDevCreate, 0))
+
+	written := make(chan []evdev.InputEvent, 1)
+	go func() {
+		var events []evdev.InputEvent
+		for {
+			var ev evdev.InputEvent
+			if err := binary.Read(uinput, binary.LittleEndian, &ev); err != nil {
+				written <- events
+				return
+			}
+			if ev.Type == evdev.EV_LED {
+				events = append(events, ev)
+			}
+		}
+	}()
+
+	cleanup := func() {
+		_ = ioctl(uinput.Fd(), uiDevDestroy, 0)
+		_ = uinput.Close()
+	}
+
+	path, ok := waitForEventNode(name)
+	if !ok {
+		cleanup()
+		t.Fatalf("virtual device event node not found")
+	}
+
+	leds, err := OpenLEDs(path)
+	if err != nil {
+		cleanup()
+		if err == syscall.EACCES {
+			t.Skipf("no access to virtual device: %s", err)
+		}
+		t.Fatal(err)
+	}
+
+	assert.Nil(t, leds.Set(evdev.LED_CAPSL, true))
+	assert.Nil(t, leds.Set(evdev.LED_SCROLLL, true))
+	assert.Nil(t, leds.Set(evdev.LED_CAPSL, false))
+	assert.Nil(t, leds.Close()) // restores scroll lock, caps lock is already in its initial state
+
+	time.Sleep(time.Millisecond * 50)
+	cleanup()
+
+	assert.Equal(t, []evdev.InputEvent{
+		{Type: evdev.EV_LED, Code: evdev.LED_CAPSL, Value: 1},
+		{Type: evdev.EV_LED, Code: evdev.LED_SCROLLL, Value: 1},
+		{Type: evdev.EV_LED, Code: evdev.LED_CAPSL, Value: 0},
+		{Type: evdev.EV_LED, Code: evdev.LED_SCROLLL, Value: 0},
+	}, stripTime(<-written))
+}
+
+func stripTime(events []evdev.InputEvent) []evdev.InputEvent {
+	for i := range events {
+		events[i].Time = syscall.Timeval{}
+	}
+	return events
+}
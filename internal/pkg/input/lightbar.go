@@ -0,0 +1,121 @@
+package input
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SysfsRoot is a mount point of sysfs
+const SysfsRoot = "/sys"
+
+var lightbarColors = [3]string{"red", "green", "blue"}
+
+// Lightbar controls RGB lightbar of gamepads (DualShock 4, DualSense) exposed by the kernel
+// as separate red, green and blue LEDs of sysfs LED class
+type Lightbar struct {
+	brightness [3]string // brightness file of red, green and blue LED
+	max        [3]int
+	initial    [3]int
+}
+
+// OpenLightbar finds lightbar LEDs of given event handler (e.g. "event5") in sysfs mounted at given root.
+// LEDs belong to the handler when their device is a parent of handler's input device in sysfs tree.
+func OpenLightbar(root, event string) (*Lightbar, error) {
+	inputPath, err := filepath.EvalSymlinks(filepath.Join(root, "class/input", event, "device"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve input device: %w", err)
+	}
+
+	ledsPath := filepath.Join(root, "class/leds")
+	entries, err := os.ReadDir(ledsPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list LEDs: %w", err)
+	}
+
+	var found = make(map[string][3]string) // LED name without color suffix: LED directories
+	for _, entry := range entries {
+		i := strings.LastIndex(entry.Name(), ":")
+		if i == -1 {
+			continue
+		}
+		prefix, color := entry.Name()[:i], entry.Name()[i+1:]
+
+		for c, name := range lightbarColors {
+			if color != name {
+				continue
+			}
+
+			devPath, err := filepath.EvalSymlinks(filepath.Join(ledsPath, entry.Name(), "device"))
+			if err != nil || !strings.HasPrefix(inputPath, devPath+string(filepath.Separator)) {
+				continue
+			}
+
+			leds := found[prefix]
+			leds[c] = filepath.Join(ledsPath, entry.Name())
+			found[prefix] = leds
+		}
+	}
+
+	for _, leds := range found {
+		if leds[0] == "" || leds[1] == "" || leds[2] == "" {
+			continue
+		}
+
+		l := &Lightbar{}
+		for c, led := range leds {
+			l.brightness[c] = filepath.Join(led, "brightness")
+			l.max[c], err = readSysfsInt(filepath.Join(led, "max_brightness"))
+			if err != nil {
+				return nil, err
+			}
+			l.initial[c], err = readSysfsInt(l.brightness[c])
+			if err != nil {
+				return nil, err
+			}
+		}
+		return l, nil
+	}
+
+	return nil, fmt.Errorf("lightbar not found")
+}
+
+func readSysfsInt(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	v, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("unexpected %s content: %w", path, err)
+	}
+	return v, nil
+}
+
+// Set changes lightbar color, channel values are scaled to LED brightness range
+func (l *Lightbar) Set(red, green, blue uint8) error {
+	for c, v := range [3]uint8{red, green, blue} {
+		err := l.write(c, (int(v)*l.max[c]+127)/255)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *Lightbar) write(c, value int) error {
+	return os.WriteFile(l.brightness[c], []byte(strconv.Itoa(value)), 0644)
+}
+
+// Close restores initial lightbar color
+func (l *Lightbar) Close() error {
+	var err error
+	for c, v := range l.initial {
+		if e := l.write(c, v); e != nil {
+			err = e
+		}
+	}
+	return err
+}
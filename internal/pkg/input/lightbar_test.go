@@ -0,0 +1,86 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSysfs creates sysfs tree of a gamepad with lightbar and a keyboard with lock LED
+func fakeSysfs(t *testing.T) string {
+	root := t.TempDir()
+
+	mkdir := func(path string) {
+		assert.NoError(t, os.MkdirAll(filepath.Join(root, path), 0755))
+	}
+	write := func(path, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(root, path), []byte(content), 0644))
+	}
+	link := func(target, path string) {
+		assert.NoError(t, os.Symlink(filepath.Join(root, target), filepath.Join(root, path)))
+	}
+
+	pad := "devices/usb1/0003:054C:09CC.0001"
+	keyboard := "devices/usb2/0003:046D:C31C.0002"
+
+	mkdir("class/input")
+	mkdir("class/leds")
+	for _, dev := range []struct{ input, event string }{
+		{pad + "/input/input10", "event5"},
+		{keyboard + "/input/input11", "event6"},
+	} {
+		mkdir(dev.input + "/" + dev.event)
+		link(dev.input, dev.input+"/"+dev.event+"/device")
+		link(dev.input+"/"+dev.event, "class/input/"+dev.event)
+	}
+
+	for _, color := range []string{"red", "green", "blue", "global"} {
+		led := pad + "/leds/0003:054C:09CC.0001:" + color
+		mkdir(led)
+		write(led+"/max_brightness", "255\n")
+		write(led+"/brightness", "10\n")
+		link(pad, led+"/device")
+		link(led, "class/leds/0003:054C:09CC.0001:"+color)
+	}
+
+	led := keyboard + "/input/input11/input11::capslock"
+	mkdir(led)
+	write(led+"/max_brightness", "1\n")
+	write(led+"/brightness", "0\n")
+	link(keyboard+"/input/input11", led+"/device")
+	link(led, "class/leds/input11::capslock")
+
+	return root
+}
+
+func TestOpenLightbar(t *testing.T) {
+	root := fakeSysfs(t)
+
+	l, err := OpenLightbar(root, "event5")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	brightness := func(color string) string {
+		data, err := os.ReadFile(filepath.Join(root, "class/leds/0003:054C:09CC.0001:"+color, "brightness"))
+		assert.NoError(t, err)
+		return string(data)
+	}
+
+	assert.NoError(t, l.Set(255, 128, 0))
+	assert.Equal(t, "255", brightness("red"))
+	assert.Equal(t, "128", brightness("green"))
+	assert.Equal(t, "0", brightness("blue"))
+
+	assert.NoError(t, l.Close())
+	assert.Equal(t, "10", brightness("red"))
+	assert.Equal(t, "10", brightness("blue"))
+
+	_, err = OpenLightbar(root, "event6")
+	assert.EqualError(t, err, "lightbar not found")
+
+	_, err = OpenLightbar(root, "event7")
+	assert.Error(t, err)
+}
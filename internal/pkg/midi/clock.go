@@ -0,0 +1,365 @@
+package midi
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+)
+
+const (
+	ClockPPQN = 24 // timing clock ticks per quarter note
+
+	MinTempo = 20.0  // BPM
+	MaxTempo = 300.0 // BPM
+
+	tapTimeout = time.Second * 2 // taps separated by longer time begin new tap tempo measurement
+	tapCount   = 5               // number of last taps tempo is averaged from
+)
+
+// ClockStats holds difference between scheduled and actual time of emitted timing clock ticks
+type ClockStats struct {
+	Ticks      uint
+	MeanJitter time.Duration
+	MaxJitter  time.Duration
+}
+
+func (s *ClockStats) record(jitter time.Duration) {
+	if jitter < 0 {
+		jitter = -jitter
+	}
+	s.Ticks++
+	s.MeanJitter += (jitter - s.MeanJitter) / time.Duration(s.Ticks)
+	if jitter > s.MaxJitter {
+		s.MaxJitter = jitter
+	}
+}
+
+// Clock is an internal transport emitting midi timing clock with start, stop, continue
+// and song position pointer messages. Ticks are scheduled on absolute deadlines, so the
+// tempo doesn't drift with scheduling latency. Methods are safe for concurrent use.
+type Clock struct {
+	out  chan<- Event
+	wake chan struct{}
+
+	mutex        sync.Mutex
+	defaultTempo float64
+	tempoStep    float64
+	tempo        float64
+	running      bool
+	position     uint        // ticks since song beginning
+	next         time.Time   // deadline of the next tick
+	pending      []Event     // transport messages waiting to be sent before the next tick
+	taps         []time.Time // last tap_tempo times
+	stats        ClockStats
+	listeners    map[chan Event]struct{}
+}
+
+func NewClock(tempo, tempoStep float64, out chan<- Event) *Clock {
+	return &Clock{
+		out:          out,
+		wake:         make(chan struct{}, 1),
+		defaultTempo: tempo,
+		tempoStep:    tempoStep,
+		tempo:        tempo,
+	}
+}
+
+// interval returns time between ticks, mutex has to be held by the caller
+func (c *Clock) interval() time.Duration {
+	return time.Duration(float64(time.Minute) / (c.tempo * ClockPPQN))
+}
+
+func (c *Clock) notify() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run emits clock messages until context is done
+func (c *Clock) Run(ctx context.Context) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		c.mutex.Lock()
+		pending := c.pending
+		c.pending = nil
+		running, deadline := c.running, c.next
+		c.mutex.Unlock()
+
+		for _, ev := range pending {
+			if !c.send(ctx, ev) {
+				return
+			}
+			c.broadcast(ev)
+		}
+		if len(pending) > 0 {
+			continue // state could change in meantime
+		}
+
+		if !running {
+			select {
+			case <-ctx.Done():
+				return
+			case <-c.wake:
+				continue
+			}
+		}
+
+		// timer wake-up latency delays single ticks only, the next deadline is not affected by it
+		if wait := time.Until(deadline); wait > 0 {
+			timer.Reset(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-c.wake:
+				if !timer.Stop() {
+					<-timer.C
+				}
+				continue
+			case <-timer.C:
+			}
+		}
+
+		c.mutex.Lock()
+		if !c.running || !c.next.Equal(deadline) || len(c.pending) > 0 {
+			c.mutex.Unlock()
+			continue
+		}
+		now := time.Now()
+		c.stats.record(now.Sub(deadline))
+		c.position++
+		c.next = deadline.Add(c.interval())
+		if now.After(c.next) {
+			// clock fell behind by whole tick (e.g. system suspend), missed ticks are not caught up
+			c.next = now.Add(c.interval())
+		}
+		c.mutex.Unlock()
+
+		if !c.send(ctx, Event{TimingClock}) {
+			return
+		}
+		c.broadcast(Event{TimingClock})
+	}
+}
+
+// Listen returns channel receiving copies of emitted messages until given context is done.
+// Messages are dropped when listener doesn't keep up.
+func (c *Clock) Listen(ctx context.Context) <-chan Event {
+	ch := make(chan Event, 32)
+
+	c.mutex.Lock()
+	if c.listeners == nil {
+		c.listeners = make(map[chan Event]struct{})
+	}
+	c.listeners[ch] = struct{}{}
+	c.mutex.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		c.mutex.Lock()
+		delete(c.listeners, ch)
+		c.mutex.Unlock()
+	}()
+	return ch
+}
+
+func (c *Clock) broadcast(ev Event) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for ch := range c.listeners {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+}
+
+func (c *Clock) send(ctx context.Context, ev Event) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	case c.out <- ev:
+		return true
+	}
+}
+
+// Start starts transport from the song beginning
+func (c *Clock) Start() {
+	c.mutex.Lock()
+	c.position = 0
+	c.running = true
+	c.next = time.Now()
+	c.pending = append(c.pending, Event{TimingStart})
+	c.mutex.Unlock()
+	c.notify()
+	log.Info("transport started", logger.Action)
+}
+
+// Stop stops transport, song position is kept
+func (c *Clock) Stop() {
+	c.mutex.Lock()
+	if !c.running {
+		c.mutex.Unlock()
+		return
+	}
+	c.running = false
+	c.pending = append(c.pending, Event{TimingStop})
+	c.mutex.Unlock()
+	c.notify()
+	log.Info("transport stopped", logger.Action)
+}
+
+// Continue resumes transport from the last sixteenth note before stop position
+func (c *Clock) Continue() {
+	c.mutex.Lock()
+	if c.running {
+		c.mutex.Unlock()
+		return
+	}
+	sixteenths := c.position / (ClockPPQN / 4)
+	if sixteenths > 0x3fff {
+		sixteenths = 0x3fff
+	}
+	c.position = sixteenths * (ClockPPQN / 4)
+	c.running = true
+	c.next = time.Now()
+	c.pending = append(c.pending,
+		Event{SongPosition, byte(sixteenths & 0x7f), byte(sixteenths >> 7)},
+		Event{TimingContinue},
+	)
+	c.mutex.Unlock()
+	c.notify()
+	log.Info(fmt.Sprintf("transport continued (%d/16)", sixteenths), logger.Action)
+}
+
+// Toggle stops running transport, stopped one is continued, or started when at the song beginning
+func (c *Clock) Toggle() {
+	c.mutex.Lock()
+	running, position := c.running, c.position
+	c.mutex.Unlock()
+
+	switch {
+	case running:
+		c.Stop()
+	case position == 0:
+		c.Start()
+	default:
+		c.Continue()
+	}
+}
+
+// Rewind moves transport to the song beginning, running transport is restarted
+func (c *Clock) Rewind() {
+	c.mutex.Lock()
+	running := c.running
+	if !running {
+		c.position = 0
+		c.pending = append(c.pending, Event{SongPosition, 0, 0})
+	}
+	c.mutex.Unlock()
+
+	if running {
+		c.Start()
+		return
+	}
+	c.notify()
+	log.Info("transport rewound", logger.Action)
+}
+
+// SetTempo changes tempo, values are clamped to MinTempo - MaxTempo range.
+// Running clock keeps the phase, the next tick is scheduled with new tempo after the last one.
+func (c *Clock) SetTempo(tempo float64) {
+	if tempo < MinTempo {
+		tempo = MinTempo
+	}
+	if tempo > MaxTempo {
+		tempo = MaxTempo
+	}
+
+	c.mutex.Lock()
+	if c.running {
+		last := c.next.Add(-c.interval())
+		c.tempo = tempo
+		c.next = last.Add(c.interval())
+		if now := time.Now(); c.next.Before(now) {
+			c.next = now
+		}
+	} else {
+		c.tempo = tempo
+	}
+	c.mutex.Unlock()
+	c.notify()
+	log.Info(fmt.Sprintf("tempo: %.1f BPM", tempo), logger.Action)
+}
+
+func (c *Clock) TempoUp() {
+	c.SetTempo(c.Tempo() + c.tempoStep)
+}
+
+func (c *Clock) TempoDown() {
+	c.SetTempo(c.Tempo() - c.tempoStep)
+}
+
+func (c *Clock) TempoReset() {
+	c.SetTempo(c.defaultTempo)
+}
+
+// Tap registers tap_tempo press at given time, tempo is set to the average of intervals between last taps
+func (c *Clock) Tap(now time.Time) {
+	c.mutex.Lock()
+	if len(c.taps) > 0 && now.Sub(c.taps[len(c.taps)-1]) > tapTimeout {
+		c.taps = c.taps[:0]
+	}
+	c.taps = append(c.taps, now)
+	if len(c.taps) > tapCount {
+		c.taps = c.taps[len(c.taps)-tapCount:]
+	}
+	taps := len(c.taps)
+	span := now.Sub(c.taps[0])
+	c.mutex.Unlock()
+
+	if taps < 2 || span <= 0 {
+		return
+	}
+	c.SetTempo(float64(time.Minute) * float64(taps-1) / float64(span))
+}
+
+func (c *Clock) Tempo() float64 {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.tempo
+}
+
+func (c *Clock) Running() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.running
+}
+
+// Stats returns jitter statistics of ticks emitted so far
+func (c *Clock) Stats() ClockStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.stats
+}
+
+func (c *Clock) Status() string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	transport := "stopped"
+	if c.running {
+		transport = "running"
+	}
+	beat := c.position / ClockPPQN
+	return fmt.Sprintf(
+		"tempo: %5.1f BPM, transport: %s, position: %d.%d, jitter: %s mean, %s max",
+		c.tempo, transport, beat/4+1, beat%4+1, c.stats.MeanJitter, c.stats.MaxJitter,
+	)
+}
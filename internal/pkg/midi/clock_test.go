@@ -0,0 +1,149 @@
+package midi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collect returns events received from clock output during given time
+func collect(events <-chan Event, d time.Duration) []Event {
+	var collected []Event
+	timeout := time.After(d)
+	for {
+		select {
+		case ev := <-events:
+			collected = append(collected, ev)
+		case <-timeout:
+			return collected
+		}
+	}
+}
+
+func count(events []Event, status byte) int {
+	var n int
+	for _, ev := range events {
+		if ev[0] == status {
+			n++
+		}
+	}
+	return n
+}
+
+// runClock runs clock at 300 BPM for given time, emitted events and the transport time are returned
+func runClock(d time.Duration) (*Clock, []Event, time.Duration) {
+	out := make(chan Event, 1024)
+	clock := NewClock(300, 1, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go clock.Run(ctx)
+
+	clock.Start()
+	start := time.Now()
+	events := collect(out, d)
+	clock.Stop()
+	elapsed := time.Since(start)
+	// tick in flight is sent before stop message
+	events = append(events, collect(out, time.Millisecond*20)...)
+	return clock, events, elapsed
+}
+
+func TestClockTicks(t *testing.T) {
+	clock, events, elapsed := runClock(time.Millisecond * 500)
+
+	// 300 BPM is 120 ticks per second, scheduling latency must not make the clock drift
+	assert.Equal(t, Event{TimingStart}, events[0])
+	assert.Equal(t, Event{TimingStop}, events[len(events)-1])
+	assert.InDelta(t, elapsed.Seconds()*120, float64(count(events, TimingClock)), 6)
+	assert.Equal(t, uint(count(events, TimingClock)), clock.Stats().Ticks)
+}
+
+// BenchmarkClockJitter reports difference between scheduled and actual tick time,
+// it depends on the machine load so it isn't asserted by tests
+func BenchmarkClockJitter(b *testing.B) {
+	var mean, max time.Duration
+	for i := 0; i < b.N; i++ {
+		clock, _, _ := runClock(time.Millisecond * 250)
+		stats := clock.Stats()
+		mean += stats.MeanJitter
+		if stats.MaxJitter > max {
+			max = stats.MaxJitter
+		}
+	}
+	b.ReportMetric(float64(mean.Microseconds())/float64(b.N), "µs-mean-jitter")
+	b.ReportMetric(float64(max.Microseconds()), "µs-max-jitter")
+}
+
+func TestClockTransport(t *testing.T) {
+	out := make(chan Event, 1024)
+	clock := NewClock(300, 1, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go clock.Run(ctx)
+
+	clock.Toggle()
+	events := collect(out, time.Millisecond*110)
+	assert.Equal(t, Event{TimingStart}, events[0])
+	assert.True(t, clock.Running())
+
+	clock.Toggle()
+	events = collect(out, time.Millisecond*20)
+	assert.Equal(t, Event{TimingStop}, events[len(events)-1])
+	assert.False(t, clock.Running())
+
+	clock.mutex.Lock()
+	position := clock.position
+	clock.mutex.Unlock()
+	assert.Greater(t, position, uint(6))
+
+	clock.Toggle()
+	events = collect(out, time.Millisecond*20)
+	sixteenths := int(position / 6)
+	assert.Equal(t, Event{SongPosition, byte(sixteenths & 0x7f), byte(sixteenths >> 7)}, events[0])
+	assert.Equal(t, Event{TimingContinue}, events[1])
+
+	clock.Stop()
+	clock.Rewind()
+	events = collect(out, time.Millisecond*20)
+	assert.Equal(t, Event{SongPosition, 0, 0}, events[len(events)-1])
+
+	clock.Toggle()
+	events = collect(out, time.Millisecond*20)
+	assert.Equal(t, Event{TimingStart}, events[0])
+}
+
+func TestClockTempo(t *testing.T) {
+	clock := NewClock(120, 2.5, make(chan Event, 1))
+
+	clock.TempoUp()
+	assert.Equal(t, 122.5, clock.Tempo())
+	clock.TempoDown()
+	clock.TempoDown()
+	assert.Equal(t, 117.5, clock.Tempo())
+	clock.TempoReset()
+	assert.Equal(t, 120.0, clock.Tempo())
+
+	clock.SetTempo(1000)
+	assert.Equal(t, MaxTempo, clock.Tempo())
+	clock.SetTempo(1)
+	assert.Equal(t, MinTempo, clock.Tempo())
+
+	now := time.Now()
+	clock.Tap(now)
+	assert.Equal(t, MinTempo, clock.Tempo(), "single tap")
+	clock.Tap(now.Add(time.Millisecond * 500))
+	assert.InDelta(t, 120, clock.Tempo(), 0.01)
+	clock.Tap(now.Add(time.Millisecond * 1000))
+	clock.Tap(now.Add(time.Millisecond * 1600))
+	assert.InDelta(t, 112.5, clock.Tempo(), 0.01) // 3 intervals in 1.6s
+
+	// long pause begins new measurement
+	clock.Tap(now.Add(time.Second * 5))
+	assert.InDelta(t, 112.5, clock.Tempo(), 0.01)
+	clock.Tap(now.Add(time.Second*5 + time.Millisecond*250))
+	assert.InDelta(t, 240, clock.Tempo(), 0.01)
+}
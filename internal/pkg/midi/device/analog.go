@@ -0,0 +1,226 @@
+package device
+
+import (
+	"math"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// axisRange describes raw value range of given axis after applying calibration overrides
+type axisRange struct {
+	min, max  int32
+	center    int32
+	hasCenter bool
+}
+
+// axisRange returns raw axis range, calibration defined for given analog mapping takes precedence
+// over device-wide calibration section, values reported by the device are used otherwise
+func (d *Device) axisRange(ie *input.InputEvent, analog config.Analog) axisRange {
+	absInfo := d.InputDevice.AbsInfos[ie.Source.DeviceInfo.Event()][ie.Event.Code]
+	r := axisRange{min: absInfo.Minimum, max: absInfo.Maximum}
+
+	for _, c := range []config.Calibration{d.config.Calibration[ie.Source.Name][ie.Event.Code], analog.Calibration} {
+		if c.Min != nil {
+			r.min = *c.Min
+		}
+		if c.Max != nil {
+			r.max = *c.Max
+		}
+		if c.Center != nil {
+			r.center = *c.Center
+			r.hasCenter = true
+		}
+	}
+	return r
+}
+
+// normalize converts raw integer value to float,
+// -1.0 - 1.0 range if negative values are included, 0.0 - 1.0 otherwise
+func (r axisRange) normalize(raw int32) (float64, bool) {
+	var value float64
+
+	switch {
+	case r.hasCenter:
+		// center may sit on the edge of range reported by the device, that side of axis is then empty
+		span := r.max - r.center
+		if raw < r.center {
+			span = r.center - r.min
+		}
+		if span > 0 {
+			value = float64(raw-r.center) / float64(span)
+		}
+		return math.Max(-1, math.Min(1, value)), true
+	case r.min < 0:
+		if raw < 0 {
+			value = float64(raw) / math.Abs(float64(r.min))
+		} else {
+			value = float64(raw) / math.Abs(float64(r.max))
+		}
+		return math.Max(-1, math.Min(1, value)), true
+	default:
+		if r.max == r.min {
+			return 0, false
+		}
+		value = float64(raw-r.min) / float64(r.max-r.min)
+		return math.Max(0, math.Min(1, value)), false
+	}
+}
+
+// applyCurve shapes the value with given response curve, curve is applied on absolute value
+// so centered axes keep their symmetry
+func applyCurve(curve config.Curve, value float64) float64 {
+	sign := 1.0
+	if value < 0 {
+		sign = -1.0
+		value = -value
+	}
+
+	k := curve.Factor
+
+	switch curve.Type {
+	case config.CurveExponential:
+		value = (math.Exp(k*value) - 1) / (math.Exp(k) - 1)
+	case config.CurveLogarithmic:
+		value = math.Log(1+(math.Exp(k)-1)*value) / k
+	case config.CurveS:
+		if value < 0.5 {
+			value = 0.5 * math.Pow(2*value, k)
+		} else {
+			value = 1 - 0.5*math.Pow(2-2*value, k)
+		}
+	case config.CurveCustom:
+		value = interpolatePoints(curve.Points, value)
+	}
+
+	return sign * math.Max(0, math.Min(1, value))
+}
+
+func interpolatePoints(points [][2]float64, value float64) float64 {
+	if len(points) == 0 {
+		return value
+	}
+	if value <= points[0][0] {
+		return points[0][1]
+	}
+	for i := 1; i < len(points); i++ {
+		a, b := points[i-1], points[i]
+		if value > b[0] {
+			continue
+		}
+		if b[0] == a[0] {
+			return b[1]
+		}
+		return a[1] + (b[1]-a[1])*(value-a[0])/(b[0]-a[0])
+	}
+	return points[len(points)-1][1]
+}
+
+type analogFilter interface {
+	Filter(value float64, t time.Time) float64
+	Reset(value float64, t time.Time)
+}
+
+func newAnalogFilter(s config.Smoothing) analogFilter {
+	switch s.Type {
+	case config.SmoothingEMA:
+		return &emaFilter{alpha: s.Factor}
+	case config.SmoothingOneEuro:
+		return &oneEuroFilter{minCutoff: s.MinCutoff, beta: s.Beta, dCutoff: 1.0}
+	}
+	return nil
+}
+
+type emaFilter struct {
+	alpha  float64
+	value  float64
+	primed bool
+}
+
+func (f *emaFilter) Reset(value float64, _ time.Time) {
+	f.value = value
+	f.primed = true
+}
+
+func (f *emaFilter) Filter(value float64, _ time.Time) float64 {
+	if !f.primed {
+		f.value = value
+		f.primed = true
+		return value
+	}
+	f.value += f.alpha * (value - f.value)
+	return f.value
+}
+
+// oneEuroFilter is an implementation of "1€ Filter" by Géry Casiez, Nicolas Roussel and Daniel Vogel
+// https://gery.casiez.net/1euro/
+type oneEuroFilter struct {
+	minCutoff, beta, dCutoff float64
+
+	value, derivative float64
+	last              time.Time
+	primed            bool
+}
+
+func smoothingFactor(cutoff, dt float64) float64 {
+	tau := 1.0 / (2 * math.Pi * cutoff)
+	return 1.0 / (1.0 + tau/dt)
+}
+
+func (f *oneEuroFilter) Reset(value float64, t time.Time) {
+	f.value = value
+	f.derivative = 0
+	f.last = t
+	f.primed = true
+}
+
+func (f *oneEuroFilter) Filter(value float64, t time.Time) float64 {
+	if !f.primed {
+		f.value = value
+		f.last = t
+		f.primed = true
+		return value
+	}
+
+	dt := t.Sub(f.last).Seconds()
+	if dt <= 0 {
+		return f.value
+	}
+	f.last = t
+
+	derivative := (value - f.value) / dt
+	f.derivative += smoothingFactor(f.dCutoff, dt) * (derivative - f.derivative)
+
+	cutoff := f.minCutoff + f.beta*math.Abs(f.derivative)
+	f.value += smoothingFactor(cutoff, dt) * (value - f.value)
+	return f.value
+}
+
+// smoothAnalog applies smoothing filter configured for given analog mapping.
+// Rest and end positions are passed through unfiltered, so axis released or pushed to its limit
+// always reaches its final value even if device doesn't report any further events.
+func (d *Device) smoothAnalog(ie *input.InputEvent, analog config.Analog, value float64) float64 {
+	if analog.Smoothing.Type == "" {
+		return value
+	}
+
+	filters, ok := d.analogFilters[ie.Source.Name]
+	if !ok {
+		filters = make(map[evdev.EvCode]analogFilter)
+		d.analogFilters[ie.Source.Name] = filters
+	}
+	filter, ok := filters[ie.Event.Code]
+	if !ok {
+		filter = newAnalogFilter(analog.Smoothing)
+		filters[ie.Event.Code] = filter
+	}
+
+	t := time.Unix(0, ie.Event.Time.Nano())
+	if value == 0 || value == 1 || value == -1 {
+		filter.Reset(value, t)
+		return value
+	}
+	return filter.Filter(value, t)
+}
@@ -0,0 +1,94 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAxisRangeNormalize(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		r        axisRange
+		raw      int32
+		expected float64
+		centered bool
+	}{
+		{"unipolar min", axisRange{min: 0, max: 255}, 0, 0, false},
+		{"unipolar max", axisRange{min: 0, max: 255}, 255, 1, false},
+		{"unipolar offset", axisRange{min: 10, max: 110}, 60, 0.5, false},
+		{"unipolar clamped", axisRange{min: 10, max: 110}, 200, 1, false},
+		{"bipolar negative", axisRange{min: -128, max: 127}, -128, -1, true},
+		{"bipolar positive", axisRange{min: -128, max: 127}, 127, 1, true},
+		{"calibrated center", axisRange{min: 0, max: 255, center: 100, hasCenter: true}, 100, 0, true},
+		{"calibrated below center", axisRange{min: 0, max: 255, center: 100, hasCenter: true}, 50, -0.5, true},
+		{"calibrated above center", axisRange{min: 0, max: 255, center: 100, hasCenter: true}, 255, 1, true},
+		{"center at device min", axisRange{min: 0, max: 255, center: 0, hasCenter: true}, 0, 0, true},
+		{"center at device max", axisRange{min: 0, max: 255, center: 255, hasCenter: true}, 255, 0, true},
+		{"center at device max, below", axisRange{min: 0, max: 255, center: 255, hasCenter: true}, 0, -1, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			value, centered := tc.r.normalize(tc.raw)
+			assert.InDelta(t, tc.expected, value, 1e-9)
+			assert.Equal(t, tc.centered, centered)
+		})
+	}
+}
+
+func TestApplyCurve(t *testing.T) {
+	curves := []config.Curve{
+		{Type: config.CurveLinear},
+		{Type: config.CurveExponential, Factor: 3},
+		{Type: config.CurveLogarithmic, Factor: 3},
+		{Type: config.CurveS, Factor: 2},
+		{Type: config.CurveCustom, Points: [][2]float64{{0, 0}, {0.5, 0.2}, {1, 1}}},
+	}
+
+	for _, curve := range curves {
+		t.Run(string(curve.Type), func(t *testing.T) {
+			// end points are preserved and curve is symmetrical for negative values
+			assert.InDelta(t, 0.0, applyCurve(curve, 0), 1e-9)
+			assert.InDelta(t, 1.0, applyCurve(curve, 1), 1e-9)
+			assert.InDelta(t, -1.0, applyCurve(curve, -1), 1e-9)
+			assert.InDelta(t, -applyCurve(curve, 0.3), applyCurve(curve, -0.3), 1e-9)
+		})
+	}
+
+	assert.Less(t, applyCurve(curves[1], 0.5), 0.5)
+	assert.Greater(t, applyCurve(curves[2], 0.5), 0.5)
+	assert.InDelta(t, 0.5, applyCurve(curves[3], 0.5), 1e-9)
+	assert.InDelta(t, 0.2, applyCurve(curves[4], 0.5), 1e-9)
+	assert.InDelta(t, 0.6, applyCurve(curves[4], 0.75), 1e-9)
+}
+
+func TestEMAFilter(t *testing.T) {
+	f := newAnalogFilter(config.Smoothing{Type: config.SmoothingEMA, Factor: 0.5})
+	now := time.Now()
+
+	assert.Equal(t, 0.2, f.Filter(0.2, now))
+	assert.InDelta(t, 0.4, f.Filter(0.6, now), 1e-9)
+	assert.InDelta(t, 0.5, f.Filter(0.6, now), 1e-9)
+
+	f.Reset(1, now)
+	assert.InDelta(t, 0.9, f.Filter(0.8, now), 1e-9)
+}
+
+func TestOneEuroFilter(t *testing.T) {
+	f := newAnalogFilter(config.Smoothing{Type: config.SmoothingOneEuro, MinCutoff: 1.0, Beta: 0.007})
+	now := time.Now()
+
+	assert.Equal(t, 0.5, f.Filter(0.5, now))
+
+	// small jitter is heavily attenuated
+	value := f.Filter(0.52, now.Add(time.Millisecond*4))
+	assert.Greater(t, value, 0.5)
+	assert.Less(t, value, 0.51)
+
+	// steady input converges
+	for i := 2; i < 500; i++ {
+		value = f.Filter(0.52, now.Add(time.Millisecond*4*time.Duration(i)))
+	}
+	assert.InDelta(t, 0.52, value, 1e-3)
+}
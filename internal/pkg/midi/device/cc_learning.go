@@ -0,0 +1,165 @@
+package device
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// CC learning workflow: while cc_learning action is held, the last touched key, axis or relative control
+// becomes the learning source. It is bound to the next CC number received on midi input,
+// or to the number typed with digit keys once cc_learning is released.
+// Learned controls take precedence over mappings and are saved into cc_learning overlay file.
+
+// ccLearningTouch is an absolute value an axis has to reach to become a learning source
+const ccLearningTouch = 0.9
+
+var ccLearningDigits = map[evdev.EvCode]byte{
+	evdev.KEY_0: '0', evdev.KEY_1: '1', evdev.KEY_2: '2', evdev.KEY_3: '3', evdev.KEY_4: '4',
+	evdev.KEY_5: '5', evdev.KEY_6: '6', evdev.KEY_7: '7', evdev.KEY_8: '8', evdev.KEY_9: '9',
+	evdev.KEY_KP0: '0', evdev.KEY_KP1: '1', evdev.KEY_KP2: '2', evdev.KEY_KP3: '3', evdev.KEY_KP4: '4',
+	evdev.KEY_KP5: '5', evdev.KEY_KP6: '6', evdev.KEY_KP7: '7', evdev.KEY_KP8: '8', evdev.KEY_KP9: '9',
+}
+
+// ccLearningTouched picks given control as a learning source
+func (d *Device) ccLearningTouched(control config.Control) {
+	if d.ccLearningSource != nil && *d.ccLearningSource == control {
+		return
+	}
+	d.ccLearningSource = &control
+	d.ccLearningNumber = ""
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("CC learning: %s selected, waiting for CC number", control), d.logFields(logger.Action)...)
+	}
+}
+
+// ccLearningKey handles key pressed in cc learning mode, digit keys type CC number once source is selected
+func (d *Device) ccLearningKey(control config.Control) {
+	digit, ok := ccLearningDigits[control.Code]
+	if ok && d.ccLearningSource != nil && *d.ccLearningSource != control {
+		d.ccLearningNumber += string(digit)
+		return
+	}
+	d.ccLearningTouched(control)
+}
+
+// ccLearningAxis picks axis moved close to the end of its range as a learning source
+func (d *Device) ccLearningAxis(ie *input.InputEvent, analog config.Analog) {
+	if analog.MappingType == config.AnalogMotion {
+		return
+	}
+	value, _ := d.axisRange(ie, analog).normalize(ie.Event.Value)
+	if value > -ccLearningTouch && value < ccLearningTouch {
+		return
+	}
+	d.ccLearningTouched(config.Control{SubHandler: ie.Source.Name, Type: evdev.EV_ABS, Code: ie.Event.Code})
+}
+
+// ccLearningMidiIn binds learning source to CC number received on midi input, returns true if CC was consumed
+func (d *Device) ccLearningMidiIn(cc byte) bool {
+	d.eventProcessMutex.Lock()
+	defer d.eventProcessMutex.Unlock()
+
+	if !d.ccLearning || d.ccLearningSource == nil {
+		return false
+	}
+	d.ccLearn(*d.ccLearningSource, cc)
+	return true
+}
+
+// ccLearningTyped binds learning source to typed CC number, called when cc learning mode ends
+func (d *Device) ccLearningTyped() {
+	if d.ccLearningSource == nil || d.ccLearningNumber == "" {
+		return
+	}
+	cc, err := strconv.Atoi(d.ccLearningNumber)
+	if err != nil || cc > 127 {
+		log.Info(fmt.Sprintf("CC learning: typed number %s outside of 0-127 range", d.ccLearningNumber), d.logFields(logger.Warning)...)
+		return
+	}
+	d.ccLearn(*d.ccLearningSource, byte(cc))
+}
+
+// ccLearn binds control to given CC number and saves learned controls
+func (d *Device) ccLearn(control config.Control, cc byte) {
+	d.config.CCLearned[control] = cc
+	d.ccLearningSource = nil
+	d.ccLearningNumber = ""
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("CC learning: %s bound to CC %d", control, cc), d.logFields(logger.Action)...)
+	}
+
+	if d.ccLearningPath == "" {
+		return
+	}
+	err := config.SaveCCLearning(d.ccLearningPath, d.config.CCLearned)
+	if err != nil {
+		log.Info(fmt.Sprintf("CC learning: saving \"%s\" failed: %s", d.ccLearningPath, err), d.logFields(logger.Warning)...)
+	}
+}
+
+// learnedAnalog returns analog mapping of axis bound to given CC number,
+// response options of the original mapping (if any) are kept
+func learnedAnalog(analog config.Analog, cc byte) config.Analog {
+	if analog.MappingType == config.AnalogMotion {
+		analog.Motion.Output = config.AnalogCC
+	} else {
+		analog.MappingType = config.AnalogCC
+	}
+	analog.CC = cc
+	analog.ChannelOffset = 0
+	analog.Bidirectional = false
+	return analog
+}
+
+// handleLearnedKey sends CC value 127 on key press and 0 on release
+func (d *Device) handleLearnedKey(control config.Control, cc byte, value int32) {
+	switch value {
+	case EV_KEY_PRESS:
+		d.learnedKeyTracker[control] = true
+		d.outputEvents <- midi.ControlChangeEvent(d.channel, cc, 127)
+	case EV_KEY_RELEASE:
+		if !d.learnedKeyTracker[control] {
+			return
+		}
+		delete(d.learnedKeyTracker, control)
+		d.outputEvents <- midi.ControlChangeEvent(d.channel, cc, 0)
+	}
+}
+
+// handleRELEvent accumulates relative control movement into 0-127 CC value, starting in the middle of the range.
+// Relative controls are handled only when learned.
+func (d *Device) handleRELEvent(ie *input.InputEvent) {
+	control := config.Control{SubHandler: ie.Source.Name, Type: evdev.EV_REL, Code: ie.Event.Code}
+	if d.ccLearning {
+		d.ccLearningTouched(control)
+		return
+	}
+
+	cc, ok := d.config.CCLearned[control]
+	if !ok {
+		return
+	}
+
+	previous, ok := d.relValues[control]
+	if !ok {
+		previous = 64
+	}
+	value := int32(previous) + ie.Event.Value
+	if value < 0 {
+		value = 0
+	}
+	if value > 127 {
+		value = 127
+	}
+	d.relValues[control] = byte(value)
+	if ok && byte(value) == previous {
+		return
+	}
+	d.outputEvents <- midi.ControlChangeEvent(d.channel, cc, byte(value))
+}
@@ -0,0 +1,121 @@
+package device
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func rel(code evdev.EvCode, value int32) *input.InputEvent {
+	ie := key(code, value)
+	ie.Event.Type = evdev.EV_REL
+	return ie
+}
+
+func TestCCLearningTyped(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+	cfg.Config.ActionMapping[evdev.KEY_F10] = config.Learning
+
+	midiEvents := make(chan midi.Event, 16)
+	d := NewDevice(input.Device{}, cfg, midiEvents, nil, true, 0, nil)
+	d.ccLearningPath = filepath.Join(t.TempDir(), "cc_learning", "Dummy.toml")
+
+	// touched key is selected, digit keys type CC number, nothing is played meanwhile
+	for _, ie := range []*input.InputEvent{
+		key(evdev.KEY_F10, EV_KEY_PRESS),
+		key(evdev.KEY_Z, EV_KEY_PRESS),
+		key(evdev.KEY_Z, EV_KEY_RELEASE),
+		key(evdev.KEY_2, EV_KEY_PRESS),
+		key(evdev.KEY_2, EV_KEY_RELEASE),
+		key(evdev.KEY_KP1, EV_KEY_PRESS),
+		key(evdev.KEY_KP1, EV_KEY_RELEASE),
+		key(evdev.KEY_F10, EV_KEY_RELEASE),
+	} {
+		d.processEvent(ie)
+	}
+	_, err = readN(midiEvents, 0)
+	assert.NoError(t, err)
+
+	learned := map[config.Control]byte{{Type: evdev.EV_KEY, Code: evdev.KEY_Z}: 21}
+	assert.Equal(t, learned, d.config.CCLearned)
+	assert.Empty(t, cfg.Config.CCLearned)
+
+	saved, err := config.LoadCCLearning(d.ccLearningPath)
+	assert.NoError(t, err)
+	assert.Equal(t, learned, saved)
+
+	// learned key takes precedence over its note
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_RELEASE))
+	events, err := readN(midiEvents, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 21, 127),
+		midi.ControlChangeEvent(0, 21, 0),
+	}, events)
+
+	// number outside of midi range is rejected
+	for _, ie := range []*input.InputEvent{
+		key(evdev.KEY_F10, EV_KEY_PRESS),
+		key(evdev.KEY_X, EV_KEY_PRESS),
+		key(evdev.KEY_2, EV_KEY_PRESS),
+		key(evdev.KEY_0, EV_KEY_PRESS),
+		key(evdev.KEY_0, EV_KEY_PRESS),
+		key(evdev.KEY_F10, EV_KEY_RELEASE),
+	} {
+		d.processEvent(ie)
+	}
+	assert.Equal(t, learned, d.config.CCLearned)
+}
+
+func TestCCLearningMidiIn(t *testing.T) {
+	d, midiEvents := motionDevice(map[evdev.EvCode]config.Analog{
+		evdev.ABS_X: {MappingType: config.AnalogCC, CC: 1, FlipAxis: true},
+	})
+	d.ccLearningPath = ""
+
+	d.CCLearningOn()
+	d.processEvent(abs(evdev.ABS_X, 100))    // not touched enough
+	d.processEvent(abs(evdev.ABS_RX, 32767)) // unmapped axis
+	assert.Equal(t, &config.Control{Type: evdev.EV_ABS, Code: evdev.ABS_RX}, d.ccLearningSource)
+
+	d.processEvent(abs(evdev.ABS_X, 32767))
+	d.handleMidiInEvent(midi.ControlChangeEvent(3, 30, 5))
+	d.processEvent(rel(evdev.REL_DIAL, 1))
+	d.handleMidiInEvent(midi.ControlChangeEvent(3, 40, 5))
+	d.CCLearningOff()
+
+	assert.Equal(t, map[config.Control]byte{
+		{Type: evdev.EV_ABS, Code: evdev.ABS_X}:    30,
+		{Type: evdev.EV_REL, Code: evdev.REL_DIAL}: 40,
+	}, d.config.CCLearned)
+	for len(midiEvents) > 0 {
+		<-midiEvents
+	}
+
+	// learned axis keeps response options of the mapping
+	d.processEvent(abs(evdev.ABS_X, -32768))
+	events, err := readN(midiEvents, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []midi.Event{midi.ControlChangeEvent(0, 30, 127)}, events)
+
+	// relative control moves from the middle of the range
+	d.processEvent(rel(evdev.REL_DIAL, 3))
+	d.processEvent(rel(evdev.REL_DIAL, -100))
+	d.processEvent(rel(evdev.REL_DIAL, -1))
+	d.processEvent(rel(evdev.REL_WHEEL, 1))
+	events, err = readN(midiEvents, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 40, 67),
+		midi.ControlChangeEvent(0, 40, 0),
+	}, events)
+}
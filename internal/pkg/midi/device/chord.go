@@ -0,0 +1,114 @@
+package device
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// chordKey tracks held chord modifier keys, the most recently pressed one selects played chord
+func (d *Device) chordKey(code evdev.EvCode, value int32) {
+	for i, c := range d.chordHeld {
+		if c == code {
+			d.chordHeld = append(d.chordHeld[:i], d.chordHeld[i+1:]...)
+			break
+		}
+	}
+	if value != EV_KEY_PRESS {
+		return
+	}
+	d.chordHeld = append(d.chordHeld, code)
+	if !d.noLogs {
+		chord := d.config.ChordKeys[code]
+		log.Info(fmt.Sprintf("chord: %s (%s)", chord.Type, chord.Voicing), d.logFields(logger.Action)...)
+	}
+}
+
+// activeChord returns chord played by note keys: chord of held modifier key, chord of current mapping
+// or multinote intervals, in that order
+func (d *Device) activeChord() (config.Chord, bool) {
+	if n := len(d.chordHeld); n > 0 {
+		return d.config.ChordKeys[d.chordHeld[n-1]], true
+	}
+	if chord := d.config.KeyMappings[d.mapping].Chord; chord != nil {
+		return *chord, true
+	}
+	if len(d.multiNote) > 0 {
+		return config.Chord{
+			Type:      "multinote",
+			Intervals: append([]int{0}, d.multiNote...),
+			Voicing:   config.VoicingClose,
+		}, true
+	}
+	return config.Chord{}, false
+}
+
+// voiceChord returns notes of the chord built on given root, from the lowest one
+func (d *Device) voiceChord(chord config.Chord, root int) []int {
+	notes := make([]int, len(chord.Intervals))
+	for i, interval := range chord.Intervals {
+		notes[i] = root + interval
+	}
+	sort.Ints(notes)
+
+	switch chord.Voicing {
+	case config.VoicingDrop2:
+		if len(notes) > 2 {
+			notes[len(notes)-2] -= 12
+			sort.Ints(notes)
+		}
+	case config.VoicingLeading:
+		if len(d.lastVoicing) > 0 {
+			notes = closestInversion(notes, d.lastVoicing)
+		}
+	}
+
+	d.lastVoicing = notes
+	return notes
+}
+
+// closestInversion returns inversion of close voiced notes with the smallest movement of voices from previous notes,
+// inversions are looked up within an octave below the root position
+func closestInversion(notes, previous []int) []int {
+	best, bestDistance := notes, -1
+	for _, shift := range []int{0, -12} {
+		for i := 0; i < len(notes); i++ {
+			inversion := make([]int, len(notes))
+			for j, note := range notes {
+				inversion[j] = note + shift
+				if j < i {
+					inversion[j] += 12
+				}
+			}
+			sort.Ints(inversion)
+
+			distance := voiceDistance(inversion, previous) + voiceDistance(previous, inversion)
+			if bestDistance < 0 || distance < bestDistance {
+				best, bestDistance = inversion, distance
+			}
+		}
+	}
+	return best
+}
+
+// voiceDistance sums distances of notes to the closest notes of other chord
+func voiceDistance(notes, other []int) int {
+	var sum int
+	for _, note := range notes {
+		closest := -1
+		for _, o := range other {
+			distance := note - o
+			if distance < 0 {
+				distance = -distance
+			}
+			if closest < 0 || distance < closest {
+				closest = distance
+			}
+		}
+		sum += closest
+	}
+	return sum
+}
@@ -0,0 +1,147 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func chordDevice(t *testing.T) (*Device, chan midi.Event) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	cfg.Config.ChordKeys = map[evdev.EvCode]config.Chord{
+		evdev.KEY_LEFTALT: {Type: "maj", Intervals: config.ChordTypes["maj"], Voicing: config.VoicingClose},
+		evdev.KEY_RIGHTALT: {
+			Type: "7", Intervals: config.ChordTypes["7"], Voicing: config.VoicingDrop2, Strum: time.Millisecond * 5,
+		},
+	}
+
+	return pipelineDevice(t, cfg)
+}
+
+func notesOn(notes ...byte) []midi.Event {
+	var events []midi.Event
+	for _, note := range notes {
+		events = append(events, midi.NoteEvent(midi.NoteOn, 0, note, 64))
+	}
+	return events
+}
+
+func notesOff(notes ...byte) []midi.Event {
+	var events []midi.Event
+	for _, note := range notes {
+		events = append(events, midi.NoteEvent(midi.NoteOff, 0, note, 0))
+	}
+	return events
+}
+
+func TestChordModifier(t *testing.T) {
+	d, midiEvents := chordDevice(t)
+	if d == nil {
+		return
+	}
+
+	d.processEvent(key(evdev.KEY_LEFTALT, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	events, err := readN(midiEvents, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(24, 28, 31), events)
+	assert.Len(t, d.deviceState().notes[evdev.KEY_Z], 3)
+
+	// voices are released with the key, even when modifier is released earlier
+	d.processEvent(key(evdev.KEY_LEFTALT, EV_KEY_RELEASE))
+	d.processEvent(key(evdev.KEY_X, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_RELEASE))
+	events, err = readN(midiEvents, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, append(notesOn(26), notesOff(24, 28, 31)...), events)
+
+	d.processEvent(key(evdev.KEY_X, EV_KEY_RELEASE))
+	_, err = readN(midiEvents, 1)
+	assert.NoError(t, err)
+	assert.Empty(t, d.noteTracker)
+}
+
+func TestChordStrum(t *testing.T) {
+	d, midiEvents := chordDevice(t)
+	if d == nil {
+		return
+	}
+
+	d.processEvent(key(evdev.KEY_RIGHTALT, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	events, err := readN(midiEvents, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(19, 24, 28, 34), events, "drop-2 voicing")
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_RELEASE))
+	events, err = readN(midiEvents, 4)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOff(19, 24, 28, 34), events)
+
+	// voices not played before key release are dropped
+	d.processEvent(key(evdev.KEY_X, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_X, EV_KEY_RELEASE))
+	time.Sleep(time.Millisecond * 30)
+	events, err = readN(midiEvents, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, append(notesOn(21), notesOff(21)...), events)
+}
+
+func TestChordMapping(t *testing.T) {
+	d, midiEvents := chordDevice(t)
+	if d == nil {
+		return
+	}
+	d.config.KeyMappings[d.mapping].Chord = &config.Chord{Type: "sus4", Intervals: config.ChordTypes["sus4"]}
+
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	events, err := readN(midiEvents, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(24, 29, 31), events)
+
+	// modifier takes precedence over mapping chord
+	d.processEvent(key(evdev.KEY_LEFTALT, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_X, EV_KEY_PRESS))
+	events, err = readN(midiEvents, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(26, 30, 33), events)
+}
+
+func TestChordMultinote(t *testing.T) {
+	d, midiEvents := chordDevice(t)
+	if d == nil {
+		return
+	}
+
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_B, EV_KEY_PRESS))
+	d.Multinote()
+	assert.Equal(t, []int{7}, d.multiNote)
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_RELEASE))
+	d.processEvent(key(evdev.KEY_B, EV_KEY_RELEASE))
+	_, err := readN(midiEvents, 4)
+	assert.NoError(t, err)
+
+	d.processEvent(key(evdev.KEY_X, EV_KEY_PRESS))
+	events, err := readN(midiEvents, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(26, 33), events)
+}
+
+func TestVoiceLeading(t *testing.T) {
+	d := &Device{}
+	chord := config.Chord{Intervals: config.ChordTypes["maj"], Voicing: config.VoicingLeading}
+
+	assert.Equal(t, []int{60, 64, 67}, d.voiceChord(chord, 60)) // no previous chord, close voicing
+	assert.Equal(t, []int{60, 65, 69}, d.voiceChord(chord, 65))
+	assert.Equal(t, []int{59, 62, 67}, d.voiceChord(chord, 67))
+
+	chord.Voicing = config.VoicingDrop2
+	assert.Equal(t, []int{52, 60, 67}, d.voiceChord(chord, 60))
+}
@@ -0,0 +1,60 @@
+package device
+
+import (
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+)
+
+// SetClock binds internal clock controlled by transport and tempo actions, it has to be called before ProcessEvents
+func (d *Device) SetClock(clock *midi.Clock) {
+	d.clock = clock
+}
+
+// clockEnabled reports if internal clock is available, a warning is logged otherwise
+func (d *Device) clockEnabled() bool {
+	if d.clock != nil {
+		return true
+	}
+	if !d.noLogs {
+		log.Info("internal clock is disabled, enable it in hidi.toml [clock] section", d.logFields(logger.Warning)...)
+	}
+	return false
+}
+
+func (d *Device) TapTempo() {
+	if d.clockEnabled() {
+		d.clock.Tap(time.Now())
+	}
+}
+
+func (d *Device) TempoUp() {
+	if d.clockEnabled() {
+		d.clock.TempoUp()
+	}
+}
+
+func (d *Device) TempoDown() {
+	if d.clockEnabled() {
+		d.clock.TempoDown()
+	}
+}
+
+func (d *Device) TempoReset() {
+	if d.clockEnabled() {
+		d.clock.TempoReset()
+	}
+}
+
+func (d *Device) TransportToggle() {
+	if d.clockEnabled() {
+		d.clock.Toggle()
+	}
+}
+
+func (d *Device) TransportRewind() {
+	if d.clockEnabled() {
+		d.clock.Rewind()
+	}
+}
@@ -0,0 +1,43 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClockActions(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	midiEvents := make(chan midi.Event, 256)
+	d := NewDevice(input.Device{}, cfg, midiEvents, nil, true, 0, nil)
+
+	// clock disabled
+	d.invokeActionPress(config.TempoUp)
+	d.invokeActionPress(config.TransportToggle)
+
+	clock := midi.NewClock(120, 2, midiEvents)
+	d.SetClock(clock)
+
+	d.invokeActionPress(config.TempoUp)
+	assert.Equal(t, 122.0, clock.Tempo())
+	d.invokeActionPress(config.TempoUp)
+	assert.Equal(t, 124.0, clock.Tempo())
+
+	// pressing both tempo actions resets tempo
+	d.actionTracker[config.TempoUp] = true
+	d.actionTracker[config.TempoDown] = true
+	assert.True(t, d.checkDoubleActions())
+	assert.Equal(t, 120.0, clock.Tempo())
+
+	d.invokeActionPress(config.TransportToggle)
+	assert.True(t, clock.Running())
+	d.invokeActionPress(config.TransportToggle)
+	assert.False(t, clock.Running())
+}
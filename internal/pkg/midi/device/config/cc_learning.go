@@ -0,0 +1,165 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/holoplot/go-evdev"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// userCCLearning is a directory of overlay files with CC numbers assigned in cc_learning mode
+const userCCLearning = "hidi-config/user/cc_learning"
+
+// Control identifies key, axis or relative control (knob, wheel) of the device
+type Control struct {
+	SubHandler string
+	Type       evdev.EvType // EV_KEY, EV_ABS or EV_REL
+	Code       evdev.EvCode
+}
+
+func (c Control) String() string {
+	if c.SubHandler == "" {
+		return controlName(c.Type, c.Code)
+	}
+	return fmt.Sprintf("%s (%s)", controlName(c.Type, c.Code), c.SubHandler)
+}
+
+// controlName returns name of given code, hexadecimal notation is used for unrecognized codes
+func controlName(evType evdev.EvType, code evdev.EvCode) string {
+	var names map[evdev.EvCode]string
+	switch evType {
+	case evdev.EV_KEY:
+		names = evdev.KEYToString
+	case evdev.EV_ABS:
+		names = evdev.ABSToString
+	case evdev.EV_REL:
+		names = evdev.RELToString
+	}
+	if name, ok := names[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("x%02x", code)
+}
+
+type TOMLCCLearning struct {
+	Learned []struct {
+		SubHandler string `toml:"subhandler"`
+		Key        string `toml:"key,omitempty"`
+		Abs        string `toml:"abs,omitempty"`
+		Rel        string `toml:"rel,omitempty"`
+		CC         int    `toml:"cc"`
+	} `toml:"cc_learning"`
+}
+
+// CCLearningPath returns path of cc_learning overlay file of given device, named after the device
+func CCLearningPath(dev input.Device) (string, error) {
+	path, err := UserConfigPath(dev)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userCCLearning, filepath.Base(path)), nil
+}
+
+// ParseCCLearning parses cc_learning overlay data, every entry binds exactly one control to CC number
+func ParseCCLearning(data []byte) (map[Control]byte, error) {
+	cfg := TOMLCCLearning{}
+
+	d := toml.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	err := d.Decode(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing failed: %w", err)
+	}
+
+	learned := make(map[Control]byte, len(cfg.Learned))
+	for i, l := range cfg.Learned {
+		var controls []Control
+		for _, c := range []struct {
+			raw         string
+			evType      evdev.EvType
+			lookupTable map[string]evdev.EvCode
+		}{
+			{l.Key, evdev.EV_KEY, evdev.KEYFromString},
+			{l.Abs, evdev.EV_ABS, evdev.ABSFromString},
+			{l.Rel, evdev.EV_REL, evdev.RELFromString},
+		} {
+			if c.raw == "" {
+				continue
+			}
+			evcode, err := TomlKeyToEvCode(c.raw, c.lookupTable)
+			if err != nil {
+				return nil, fmt.Errorf("[cc_learning %d] %w", i, err)
+			}
+			controls = append(controls, Control{SubHandler: l.SubHandler, Type: c.evType, Code: evcode})
+		}
+		if len(controls) != 1 {
+			return nil, fmt.Errorf("[cc_learning %d] exactly one of key, abs or rel expected", i)
+		}
+		if l.CC < 0 || l.CC > 127 {
+			return nil, fmt.Errorf("[cc_learning %d] cc outside of 0-127 range: %d", i, l.CC)
+		}
+		learned[controls[0]] = byte(l.CC)
+	}
+	return learned, nil
+}
+
+// LoadCCLearning reads cc_learning overlay file, missing file gives no learned controls
+func LoadCCLearning(path string) (map[Control]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading file data failed: %w", err)
+	}
+	return ParseCCLearning(data)
+}
+
+// CCLearningData returns cc_learning overlay data of given learned controls
+func CCLearningData(learned map[Control]byte) []byte {
+	controls := make([]Control, 0, len(learned))
+	for c := range learned {
+		controls = append(controls, c)
+	}
+	sort.Slice(controls, func(i, j int) bool {
+		a, b := controls[i], controls[j]
+		if a.SubHandler != b.SubHandler {
+			return a.SubHandler < b.SubHandler
+		}
+		if a.Type != b.Type {
+			return a.Type < b.Type
+		}
+		return a.Code < b.Code
+	})
+
+	buf := bytes.Buffer{}
+	buf.WriteString("# CC numbers assigned in cc_learning mode, entries override device config mappings\n")
+	for _, c := range controls {
+		field := strings.ToLower(strings.TrimPrefix(evdev.TypeName(c.Type), "EV_"))
+		buf.WriteString(fmt.Sprintf(
+			"\n[[cc_learning]]\n  subhandler = %q\n  %s = %q\n  cc = %d\n",
+			c.SubHandler, field, controlName(c.Type, c.Code), learned[c],
+		))
+	}
+	return buf.Bytes()
+}
+
+// SaveCCLearning writes learned controls into cc_learning overlay file, directory is created if necessary
+func SaveCCLearning(path string, learned map[Control]byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o777)
+	if err != nil {
+		return fmt.Errorf("creating directory failed: %w", err)
+	}
+	err = os.WriteFile(path, CCLearningData(learned), 0644)
+	if err != nil {
+		return fmt.Errorf("writing file failed: %w", err)
+	}
+	return nil
+}
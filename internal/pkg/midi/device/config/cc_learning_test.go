@@ -0,0 +1,83 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCCLearningData(t *testing.T) {
+	learned := map[Control]byte{
+		{SubHandler: "Motion Sensors", Type: evdev.EV_ABS, Code: evdev.ABS_X}: 7,
+		{Type: evdev.EV_REL, Code: evdev.REL_DIAL}:                            40,
+		{Type: evdev.EV_ABS, Code: evdev.ABS_Z}:                               21,
+		{Type: evdev.EV_KEY, Code: 0x2f0}:                                     22,
+	}
+
+	data := CCLearningData(learned)
+	assert.Equal(t, `# CC numbers assigned in cc_learning mode, entries override device config mappings
+
+[[cc_learning]]
+  subhandler = ""
+  key = "x2f0"
+  cc = 22
+
+[[cc_learning]]
+  subhandler = ""
+  rel = "REL_DIAL"
+  cc = 40
+
+[[cc_learning]]
+  subhandler = ""
+  abs = "ABS_Z"
+  cc = 21
+
+[[cc_learning]]
+  subhandler = "Motion Sensors"
+  abs = "ABS_X"
+  cc = 7
+`, string(data))
+
+	parsed, err := ParseCCLearning(data)
+	assert.NoError(t, err)
+	assert.Equal(t, learned, parsed)
+
+	path := filepath.Join(t.TempDir(), "cc_learning", "Dummy.toml")
+	assert.NoError(t, SaveCCLearning(path, learned))
+	loaded, err := LoadCCLearning(path)
+	assert.NoError(t, err)
+	assert.Equal(t, learned, loaded)
+}
+
+func TestLoadCCLearningMissing(t *testing.T) {
+	learned, err := LoadCCLearning(filepath.Join(t.TempDir(), "missing.toml"))
+	assert.NoError(t, err)
+	assert.Nil(t, learned)
+}
+
+func TestParseCCLearningErrors(t *testing.T) {
+	for _, tc := range []struct {
+		data     string
+		expected string
+	}{
+		{"[[cc_learning]]\n  cc = 1\n", "[cc_learning 0] exactly one of key, abs or rel expected"},
+		{"[[cc_learning]]\n  key = \"KEY_A\"\n  abs = \"ABS_X\"\n  cc = 1\n", "[cc_learning 0] exactly one of key, abs or rel expected"},
+		{"[[cc_learning]]\n  rel = \"ABS_X\"\n  cc = 1\n", "[cc_learning 0] EvCode name \"ABS_X\" not found / not supported"},
+		{"[[cc_learning]]\n  key = \"KEY_A\"\n  cc = 128\n", "[cc_learning 0] cc outside of 0-127 range: 128"},
+	} {
+		_, err := ParseCCLearning([]byte(tc.data))
+		assert.EqualError(t, err, tc.expected)
+	}
+}
+
+func TestCCLearningPath(t *testing.T) {
+	path, err := CCLearningPath(input.Device{Name: "Sony: Wireless/Controller", DeviceType: input.JoystickDevice})
+	assert.NoError(t, err)
+	assert.Equal(t, "hidi-config/user/cc_learning/Sony_ Wireless_Controller.toml", path)
+
+	_, err = CCLearningPath(input.Device{Name: "Mouse"})
+	assert.ErrorIs(t, err, UnsupportedDeviceType)
+}
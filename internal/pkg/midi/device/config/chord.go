@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/holoplot/go-evdev"
+)
+
+type Voicing string
+
+const (
+	VoicingClose   Voicing = "close"         // chord intervals stacked above the root
+	VoicingDrop2   Voicing = "drop2"         // second highest voice of close voicing dropped by an octave
+	VoicingLeading Voicing = "voice_leading" // inversion closest to the previously played chord
+)
+
+var SupportedVoicings = map[Voicing]bool{
+	VoicingClose:   true,
+	VoicingDrop2:   true,
+	VoicingLeading: true,
+}
+
+// ChordTypes are built-in chord types, semitone intervals from the root
+var ChordTypes = map[string][]int{
+	"maj":  {0, 4, 7},
+	"min":  {0, 3, 7},
+	"7":    {0, 4, 7, 10},
+	"maj7": {0, 4, 7, 11},
+	"sus2": {0, 2, 7},
+	"sus4": {0, 5, 7},
+	"dim":  {0, 3, 6},
+	"aug":  {0, 4, 8},
+}
+
+// Chord is played by a single note key, with the note being the chord root
+type Chord struct {
+	Type      string
+	Intervals []int // semitones from the root, ascending
+	Voicing   Voicing
+	Strum     time.Duration // delay between voices, from the lowest one
+}
+
+type TOMLChord struct {
+	Type    string `toml:"type"`
+	Key     string `toml:"key,omitempty"`     // chord is played while modifier key is held
+	Mapping string `toml:"mapping,omitempty"` // chord is played by every note key of the mapping
+	Voicing string `toml:"voicing,omitempty"`
+	Strum   int    `toml:"strum,omitempty"` // milliseconds
+}
+
+// parseChordTypes validates user-defined chord types, intervals are sorted
+func parseChordTypes(raw map[string][]int) (map[string][]int, error) {
+	types := make(map[string][]int, len(ChordTypes)+len(raw))
+	for name, intervals := range ChordTypes {
+		types[name] = intervals
+	}
+
+	for name, intervals := range raw {
+		if _, ok := ChordTypes[name]; ok {
+			return nil, fmt.Errorf("%s: built-in chord type can't be redefined", name)
+		}
+		if len(intervals) == 0 {
+			return nil, fmt.Errorf("%s: no intervals defined", name)
+		}
+		sorted := append([]int{}, intervals...)
+		sort.Ints(sorted)
+		for i, interval := range sorted {
+			if interval < -48 || interval > 48 {
+				return nil, fmt.Errorf("%s: interval outside of -48-48 range: %d", name, interval)
+			}
+			if i > 0 && interval == sorted[i-1] {
+				return nil, fmt.Errorf("%s: interval defined twice: %d", name, interval)
+			}
+		}
+		types[name] = sorted
+	}
+	return types, nil
+}
+
+// parseChords returns chords bound to modifier keys, chords of whole mappings are assigned to keyMapping
+func parseChords(
+	rawTypes map[string][]int, raw []TOMLChord,
+	actionMapping map[evdev.EvCode]Action, keyMapping []KeyMapping,
+) (map[evdev.EvCode]Chord, error) {
+	types, err := parseChordTypes(rawTypes)
+	if err != nil {
+		return nil, fmt.Errorf("[chord_types] %w", err)
+	}
+
+	var chordKeys map[evdev.EvCode]Chord
+	for i, c := range raw {
+		intervals, ok := types[c.Type]
+		if !ok {
+			return nil, fmt.Errorf("[chord %d] unknown chord type: %s", i, c.Type)
+		}
+		chord := Chord{
+			Type:      c.Type,
+			Intervals: intervals,
+			Voicing:   VoicingClose,
+			Strum:     time.Millisecond * time.Duration(c.Strum),
+		}
+		if c.Voicing != "" {
+			chord.Voicing = Voicing(c.Voicing)
+		}
+		if !SupportedVoicings[chord.Voicing] {
+			return nil, fmt.Errorf("[chord %d] unsupported voicing: %s", i, c.Voicing)
+		}
+		if c.Strum < 0 || c.Strum > 1000 {
+			return nil, fmt.Errorf("[chord %d] strum outside of 0-1000 range: %d", i, c.Strum)
+		}
+
+		switch {
+		case c.Key != "" && c.Mapping != "", c.Key == "" && c.Mapping == "":
+			return nil, fmt.Errorf("[chord %d] either key or mapping has to be defined", i)
+		case c.Key != "":
+			evcode, err := TomlKeyToEvCode(c.Key, evdev.KEYFromString)
+			if err != nil {
+				return nil, fmt.Errorf("[chord %d] %w", i, err)
+			}
+			if action, ok := actionMapping[evcode]; ok {
+				return nil, fmt.Errorf("[chord %d] %s is already mapped to %s action", i, c.Key, action)
+			}
+			if _, ok := chordKeys[evcode]; ok {
+				return nil, fmt.Errorf("[chord %d] %s is already mapped to another chord", i, c.Key)
+			}
+			if chordKeys == nil {
+				chordKeys = make(map[evdev.EvCode]Chord)
+			}
+			chordKeys[evcode] = chord
+		default:
+			found := false
+			for j := range keyMapping {
+				if keyMapping[j].Name != c.Mapping {
+					continue
+				}
+				if keyMapping[j].Chord != nil {
+					return nil, fmt.Errorf("[chord %d] mapping \"%s\" has another chord already", i, c.Mapping)
+				}
+				mappingChord := chord
+				keyMapping[j].Chord = &mappingChord
+				found = true
+			}
+			if !found {
+				return nil, fmt.Errorf("[chord %d] mapping \"%s\" not found", i, c.Mapping)
+			}
+		}
+	}
+	return chordKeys, nil
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseChordTypes(t *testing.T) {
+	types, err := parseChordTypes(map[string][]int{"add9": {14, 0, 7, 4}})
+	assert.NoError(t, err)
+	assert.Equal(t, []int{0, 4, 7, 14}, types["add9"])
+	assert.Equal(t, []int{0, 3, 7}, types["min"])
+
+	for _, raw := range []map[string][]int{
+		{"maj": {0, 4, 7}},
+		{"empty": {}},
+		{"wide": {0, 49}},
+		{"twice": {0, 4, 4}},
+	} {
+		_, err := parseChordTypes(raw)
+		assert.Error(t, err, "%v", raw)
+	}
+}
+
+func TestParseChords(t *testing.T) {
+	actionMapping := map[evdev.EvCode]Action{evdev.KEY_ESC: Panic}
+	keyMapping := []KeyMapping{{Name: "Default"}, {Name: "Chords"}}
+
+	chordKeys, err := parseChords(
+		map[string][]int{"add9": {0, 4, 7, 14}},
+		[]TOMLChord{
+			{Type: "min7", Key: "KEY_LEFTALT"},
+			{Type: "add9", Key: "KEY_RIGHTALT", Voicing: "drop2", Strum: 20},
+			{Type: "maj", Mapping: "Chords", Voicing: "voice_leading"},
+		},
+		actionMapping, keyMapping,
+	)
+	assert.Error(t, err, "min7 is not defined")
+
+	chordKeys, err = parseChords(
+		map[string][]int{"min7": {0, 3, 7, 10}, "add9": {0, 4, 7, 14}},
+		[]TOMLChord{
+			{Type: "min7", Key: "KEY_LEFTALT"},
+			{Type: "add9", Key: "KEY_RIGHTALT", Voicing: "drop2", Strum: 20},
+			{Type: "maj", Mapping: "Chords", Voicing: "voice_leading"},
+		},
+		actionMapping, keyMapping,
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, map[evdev.EvCode]Chord{
+		evdev.KEY_LEFTALT:  {Type: "min7", Intervals: []int{0, 3, 7, 10}, Voicing: VoicingClose},
+		evdev.KEY_RIGHTALT: {Type: "add9", Intervals: []int{0, 4, 7, 14}, Voicing: VoicingDrop2, Strum: time.Millisecond * 20},
+	}, chordKeys)
+	assert.Nil(t, keyMapping[0].Chord)
+	assert.Equal(t, &Chord{Type: "maj", Intervals: []int{0, 4, 7}, Voicing: VoicingLeading}, keyMapping[1].Chord)
+
+	for _, raw := range []TOMLChord{
+		{Type: "maj"},
+		{Type: "maj", Key: "KEY_A", Mapping: "Default"},
+		{Type: "maj", Key: "KEY_ESC"},
+		{Type: "maj", Key: "KEY_FOO"},
+		{Type: "maj", Mapping: "Missing"},
+		{Type: "maj", Key: "KEY_A", Voicing: "open"},
+		{Type: "maj", Key: "KEY_A", Strum: -1},
+	} {
+		_, err := parseChords(nil, []TOMLChord{raw}, actionMapping, []KeyMapping{{Name: "Default"}})
+		assert.Error(t, err, "%+v", raw)
+	}
+
+	_, err = parseChords(nil, []TOMLChord{{Type: "maj", Key: "KEY_A"}, {Type: "min", Key: "KEY_A"}}, nil, nil)
+	assert.Error(t, err, "key mapped twice")
+	_, err = parseChords(nil, []TOMLChord{{Type: "maj", Mapping: "Default"}, {Type: "min", Mapping: "Default"}}, nil, []KeyMapping{{Name: "Default"}})
+	assert.Error(t, err, "mapping with two chords")
+}
@@ -1,6 +1,8 @@
 package config
 
 import (
+	"time"
+
 	"github.com/gethiox/HIDI/internal/pkg/input"
 	"github.com/holoplot/go-evdev"
 	"github.com/realbucksavage/openrgb-go"
@@ -17,20 +19,108 @@ const (
 	ChannelUp    Action = "channel_up"
 	ChannelDown  Action = "channel_down"
 	Channel      Action = "channel"   // given with number parameter 1-16
+	Octave       Action = "octave"    // given with octave number
 	Multinote    Action = "multinote" // holding this button and pressing midi keys sets multinote mode
 	Panic        Action = "panic"
 	Learning     Action = "cc_learning"
 	Exit         Action = "exit"
+	MotionReset  Action = "motion_reset" // sets current orientation as motion mappings reference
+
+	TapTempo        Action = "tap_tempo" // internal clock tempo measured from intervals between presses
+	TempoUp         Action = "tempo_up"
+	TempoDown       Action = "tempo_down"
+	TransportToggle Action = "transport_toggle" // starts, stops and continues internal clock transport
+	TransportRewind Action = "transport_rewind" // moves internal clock transport to the song beginning
+	StepSequencer   Action = "sequencer"        // toggles step sequencer edit mode
+
+	LooperRecord   Action = "looper_record"  // records new loop in selected slot, pressed again starts playing it
+	LooperPlay     Action = "looper_play"    // starts or stops playback of selected slot
+	LooperOverdub  Action = "looper_overdub" // records another layer on top of selected slot
+	LooperUndo     Action = "looper_undo"    // removes the last layer of selected slot
+	LooperClear    Action = "looper_clear"
+	LooperSlotUp   Action = "looper_slot_up"
+	LooperSlotDown Action = "looper_slot_down"
+
+	StrumUp        Action = "strum_up"    // chord voices are strummed from the lowest one
+	StrumDown      Action = "strum_down"  // chord voices are strummed from the highest one
+	NoteRepeat     Action = "note_repeat" // held notes are retriggered at repeat rate while held
+	RepeatRateUp   Action = "repeat_rate_up"
+	RepeatRateDown Action = "repeat_rate_down"
+	Humanize       Action = "humanize" // toggles random timing and velocity deviation
+
+	AnalogPitchBend       MappingType = "pitch_bend"
+	AnalogCC              MappingType = "cc"
+	AnalogKeySim          MappingType = "key"
+	AnalogActionSim       MappingType = "action"
+	AnalogCC14            MappingType = "cc14" // 14-bit CC, MSB on CC n and LSB on CC n+32
+	AnalogNRPN            MappingType = "nrpn" // 14-bit Non-Registered Parameter Number
+	AnalogChannelPressure MappingType = "channel_pressure"
+	AnalogPolyPressure    MappingType = "poly_pressure"
+	AnalogMotion          MappingType = "motion" // motion sensors, accelerometer tilt or gyroscope rotation
+	AnalogEffect          MappingType = "effect" // note effect parameter
+
+	MidiInNote          MidiInMessage = "note"           // note on/off, note on triggers action press and note off its release
+	MidiInCC            MidiInMessage = "cc"             // control change, values from 64 up trigger action press, lower ones release
+	MidiInProgramChange MidiInMessage = "program_change" // program change
+	MidiInStart         MidiInMessage = "start"          // midi clock start
+	MidiInStop          MidiInMessage = "stop"           // midi clock stop
+	MidiInContinue      MidiInMessage = "continue"       // midi clock continue
+
+	RumbleNoteOn   RumbleTrigger = "note_on"   // notes played on the device itself
+	RumbleClock    RumbleTrigger = "clock"     // midi clock ticks received from midi input
+	RumbleMidiNote RumbleTrigger = "midi_note" // notes received from midi input
+	RumbleMidiCC   RumbleTrigger = "midi_cc"   // control changes received from midi input
+
+	IndicatorOctave     LEDIndicator = "octave"      // octave shifted from 0
+	IndicatorSemitone   LEDIndicator = "semitone"    // semitone shifted from 0
+	IndicatorChannel    LEDIndicator = "channel"     // channel other than first one
+	IndicatorMapping    LEDIndicator = "mapping"     // mapping other than first one
+	IndicatorMultinote  LEDIndicator = "multinote"   // multinote mode engaged
+	IndicatorCCLearning LEDIndicator = "cc_learning" // cc learning mode enabled
+	IndicatorNotes      LEDIndicator = "notes"       // any note is being played
+	IndicatorSustain    LEDIndicator = "sustain"     // sustain pedal (CC64) held, emitted by device or received from midi input
+	IndicatorClock      LEDIndicator = "clock"       // blinks on every beat of midi clock received from midi input
+
+	MeterAnalog   StripMeterType = "analog"   // position of analog value, pitch-bend or cc
+	MeterVelocity StripMeterType = "velocity" // level of played notes velocity, decaying over time
+	MeterClock    StripMeterType = "clock"    // chaser synced to midi clock received from midi input, one pass per bar
 
-	AnalogPitchBend MappingType = "pitch_bend"
-	AnalogCC        MappingType = "cc"
-	AnalogKeySim    MappingType = "key"
-	AnalogActionSim MappingType = "action"
+	ControllerChannel ControllerMode = "channel" // current channel color
+	ControllerNotes   ControllerMode = "notes"   // heatmap of active notes, whole midi range spread over controller LEDs
+	ControllerBeat    ControllerMode = "beat"    // flash on every beat of midi clock received from midi input
+
+	LightbarChannel LightbarMode = "channel" // current channel color, same as OpenRGB channel keys
+	LightbarMapping LightbarMode = "mapping" // color of current mapping number
+	LightbarStatic  LightbarMode = "static"  // color defined in config
+
+	LayoutIsomorphic    Layout = "isomorphic"     // column and row intervals given explicitly
+	LayoutWickiHayden   Layout = "wicki_hayden"   // whole tones along the row, fourths up-left, fifths up-right
+	LayoutJanko         Layout = "janko"          // whole tones along the row, neighbour rows a semitone apart
+	LayoutHarmonicTable Layout = "harmonic_table" // semitones along the row, minor thirds up-left, major thirds up-right
+	LayoutFourths       Layout = "fourths"        // semitones along the row, rows a fourth apart like guitar strings
+
+	MotionTilt     MotionSource = "tilt"     // accelerometer, angle relative to reference orientation
+	MotionRotation MotionSource = "rotation" // gyroscope, angular rate accumulated since reference reset
+
+	PressureAll  PressureTarget = "all"  // pressure applied to all held notes
+	PressureLast PressureTarget = "last" // pressure applied to the most recently pressed note
 
 	CollisionOff       CollisionMode = "off"       // always emit note_on/off events
 	CollisionNoRepeat  CollisionMode = "no_repeat" // emit note_on on first occurrence, note_off on last release
 	CollisionInterrupt CollisionMode = "interrupt" // interrupt previous occurrence with note_off event first, note_off on last release
 	CollisionRetrigger CollisionMode = "retrigger" // always emit note_on, note_off on last release
+
+	CurveLinear      CurveType = "linear"
+	CurveExponential CurveType = "exponential"
+	CurveLogarithmic CurveType = "logarithmic"
+	CurveS           CurveType = "s_curve"
+	CurveCustom      CurveType = "custom" // piecewise linear interpolation of user-defined points
+
+	SmoothingEMA     SmoothingType = "ema"      // exponential moving average
+	SmoothingOneEuro SmoothingType = "one_euro" // adaptive low-pass filter, smooth when slow, responsive when fast
+
+	SequencerInternal SequencerClock = "internal" // internal midi clock enabled in hidi.toml
+	SequencerExternal SequencerClock = "external" // midi clock received from midi input
 )
 
 var SupportedActions = map[Action]bool{
@@ -44,17 +134,137 @@ var SupportedActions = map[Action]bool{
 	ChannelUp:    true,
 	ChannelDown:  true,
 	Channel:      true,
+	Octave:       true,
 	Multinote:    true,
 	Panic:        true,
 	Learning:     true,
 	Exit:         true,
+	MotionReset:  true,
+
+	TapTempo:        true,
+	TempoUp:         true,
+	TempoDown:       true,
+	TransportToggle: true,
+	TransportRewind: true,
+	StepSequencer:   true,
+
+	LooperRecord:   true,
+	LooperPlay:     true,
+	LooperOverdub:  true,
+	LooperUndo:     true,
+	LooperClear:    true,
+	LooperSlotUp:   true,
+	LooperSlotDown: true,
+
+	StrumUp:        true,
+	StrumDown:      true,
+	NoteRepeat:     true,
+	RepeatRateUp:   true,
+	RepeatRateDown: true,
+	Humanize:       true,
 }
 
 var SupportedMappingTypes = map[MappingType]bool{
-	AnalogPitchBend: true,
-	AnalogCC:        true,
-	AnalogKeySim:    true,
-	AnalogActionSim: true,
+	AnalogPitchBend:       true,
+	AnalogCC:              true,
+	AnalogKeySim:          true,
+	AnalogActionSim:       true,
+	AnalogCC14:            true,
+	AnalogNRPN:            true,
+	AnalogChannelPressure: true,
+	AnalogPolyPressure:    true,
+	AnalogMotion:          true,
+	AnalogEffect:          true,
+}
+
+var SupportedMidiInMessages = map[MidiInMessage]bool{
+	MidiInNote:          true,
+	MidiInCC:            true,
+	MidiInProgramChange: true,
+	MidiInStart:         true,
+	MidiInStop:          true,
+	MidiInContinue:      true,
+}
+
+// MidiInValueActions take their number from the value of incoming message:
+// mapping (0 is the first one), channel (0-15) and octave (64 stands for octave 0)
+var MidiInValueActions = map[Action]bool{
+	Mapping: true,
+	Channel: true,
+	Octave:  true,
+}
+
+var SupportedRumbleTriggers = map[RumbleTrigger]bool{
+	RumbleNoteOn:   true,
+	RumbleClock:    true,
+	RumbleMidiNote: true,
+	RumbleMidiCC:   true,
+}
+
+var SupportedLEDIndicators = map[LEDIndicator]bool{
+	IndicatorOctave:     true,
+	IndicatorSemitone:   true,
+	IndicatorChannel:    true,
+	IndicatorMapping:    true,
+	IndicatorMultinote:  true,
+	IndicatorCCLearning: true,
+	IndicatorNotes:      true,
+	IndicatorSustain:    true,
+	IndicatorClock:      true,
+}
+
+var SupportedStripMeters = map[StripMeterType]bool{
+	MeterAnalog:   true,
+	MeterVelocity: true,
+	MeterClock:    true,
+}
+
+var SupportedControllerModes = map[ControllerMode]bool{
+	ControllerChannel: true,
+	ControllerNotes:   true,
+	ControllerBeat:    true,
+}
+
+var SupportedLightbarModes = map[LightbarMode]bool{
+	LightbarChannel: true,
+	LightbarMapping: true,
+	LightbarStatic:  true,
+}
+
+var SupportedLayouts = map[Layout]bool{
+	LayoutIsomorphic:    true,
+	LayoutWickiHayden:   true,
+	LayoutJanko:         true,
+	LayoutHarmonicTable: true,
+	LayoutFourths:       true,
+}
+
+var SupportedMotionSources = map[MotionSource]bool{
+	MotionTilt:     true,
+	MotionRotation: true,
+}
+
+var SupportedPressureTargets = map[PressureTarget]bool{
+	PressureAll:  true,
+	PressureLast: true,
+}
+
+var SupportedCurves = map[CurveType]bool{
+	CurveLinear:      true,
+	CurveExponential: true,
+	CurveLogarithmic: true,
+	CurveS:           true,
+	CurveCustom:      true,
+}
+
+var SupportedSmoothing = map[SmoothingType]bool{
+	SmoothingEMA:     true,
+	SmoothingOneEuro: true,
+}
+
+var SupportedSequencerClocks = map[SequencerClock]bool{
+	SequencerInternal: true,
+	SequencerExternal: true,
 }
 
 var SupportedCollisionModes = map[CollisionMode]bool{
@@ -67,6 +277,18 @@ var SupportedCollisionModes = map[CollisionMode]bool{
 type Action string
 type MappingType string
 type CollisionMode string
+type CurveType string
+type SmoothingType string
+type PressureTarget string
+type MotionSource string
+type MidiInMessage string
+type RumbleTrigger string
+type LEDIndicator string
+type StripMeterType string
+type ControllerMode string
+type LightbarMode string
+type Layout string
+type SequencerClock string
 
 type AnalogMappingCC struct {
 	CC, CCNeg     byte
@@ -85,16 +307,70 @@ type AnalogMappingAction struct {
 	Bidirectional     bool
 }
 
+// Curve defines analog response shape, zero value means linear response
+type Curve struct {
+	Type   CurveType
+	Factor float64      // curve steepness for exponential, logarithmic and s_curve types
+	Points [][2]float64 // input/output pairs for custom type, sorted by input value
+}
+
+// Smoothing defines filter applied on analog values, zero value disables smoothing
+type Smoothing struct {
+	Type      SmoothingType
+	Factor    float64 // ema: weight of the newest value (0.0 - 1.0)
+	MinCutoff float64 // one_euro: minimum cutoff frequency (Hz)
+	Beta      float64 // one_euro: speed coefficient
+}
+
+// Motion defines motion sensor processing, used by motion mapping type only
+type Motion struct {
+	Source      MotionSource
+	Output      MappingType // AnalogCC or AnalogPitchBend
+	Sensitivity float64     // full output range is reached at 90° tilt or 180° rotation with sensitivity 1.0
+}
+
+// MidiInRule triggers action when matching message is received on midi input
+type MidiInRule struct {
+	Message MidiInMessage
+	Channel int // incoming channel (0-15), -1 for any
+	Number  int // note or cc number, -1 for any
+	Action  Action
+}
+
+// Rumble defines force-feedback effect played when given trigger occurs
+type Rumble struct {
+	Trigger      RumbleTrigger
+	Strong, Weak float64 // motors magnitude, 0.0 - 1.0
+	Duration     time.Duration
+	Every        int  // clock: emit effect every n-th tick (24 ticks per quarter note)
+	Note, CC     int  // midi_note/midi_cc: incoming note or cc number, -1 for any
+	Channel      int  // midi_note/midi_cc: incoming channel (0-15), -1 for any
+	Scale        bool // scale magnitude by note velocity or cc value
+}
+
+// Calibration overrides raw axis range reported by the device, nil fields are not overridden
+type Calibration struct {
+	Min, Max, Center *int32
+}
+
 type Analog struct {
 	MappingType       MappingType
 	CC, CCNeg         byte
 	Note, NoteNeg     byte
+	NRPN              uint16
+	PressureTarget    PressureTarget
+	Motion            Motion
+	Effect            EffectParameter
 	ChannelOffset     byte
 	ChannelOffsetNeg  byte
 	Action, ActionNeg Action
 	FlipAxis          bool
 	Bidirectional     bool
 	DeadzoneAtCenter  bool
+	InvertRange       bool
+	Curve             Curve
+	Smoothing         Smoothing
+	Calibration       Calibration
 }
 
 type Key struct {
@@ -104,10 +380,13 @@ type Key struct {
 
 type KeyMapping struct {
 	Name            string
+	Theme           string                              // OpenRGB theme name, default theme is used when empty
+	Generator       *Generator                          // layout Midi notes were generated with, nil for hand written mappings
 	Midi            map[string]map[evdev.EvCode]Key     // main key: subhandler
 	Analog          map[string]map[evdev.EvCode]Analog  // main key: subhandler
 	Deadzones       map[string]map[evdev.EvCode]float64 // main key: subhandler
 	DefaultDeadzone map[string]float64                  // main key: subhandler
+	Chord           *Chord                              // chord played by every note key, nil if not defined
 }
 
 type Defaults struct {
@@ -122,8 +401,67 @@ type Colors struct {
 	ActiveExternal  openrgb.Color
 }
 
+// Effects defines animation of played keys, zero value disables all effects
+type Effects struct {
+	Afterglow   time.Duration // fade out time of released key
+	Ripple      time.Duration // lifetime of the ripple spreading from played key
+	RippleWidth int           // ripple reach in semitones
+	Velocity    bool          // scale active key brightness by note velocity
+}
+
+// Theme is a color palette with key animation, device's own [open_rgb] colors and effects make the default one
+type Theme struct {
+	Colors  Colors
+	Effects Effects
+}
+
+// OpenRGBLED points to OpenRGB LED by its name, or by its index when name is empty.
+// Index -1 with empty name stands for no LED.
+type OpenRGBLED struct {
+	Name  string
+	Index int
+}
+
+// StripMeter defines LED strip visualisation, zero value leaves the strip blank
+type StripMeter struct {
+	Type  StripMeterType
+	Axis  *evdev.EvCode // analog: axis driving the meter, any analog mapping if nil
+	Color openrgb.Color
+}
+
+// OpenRGBController binds OpenRGB controller other than device's own keyboard, e.g. LED strip or mousepad
+type OpenRGBController struct {
+	Name string // controller name as reported by OpenRGB
+	Zone string // controller zone, whole controller if empty
+	Mode ControllerMode
+}
+
 type OpenRGB struct {
-	Colors Colors
+	Colors      Colors
+	Effects     Effects
+	Theme       string // default theme name, device's own colors and effects are used when empty
+	FPS         int    // LED update rate
+	Meter       StripMeter
+	Controllers []OpenRGBController
+	Keys        map[evdev.EvCode]OpenRGBLED // hardware button to LED mapping, extends built-in layout
+	Strip       []OpenRGBLED                // LED strip ordering, replaces built-in strip of the controller
+}
+
+// Lightbar defines gamepad lightbar color, zero value leaves the lightbar untouched
+type Lightbar struct {
+	Mode       LightbarMode
+	Color      openrgb.Color // static mode color
+	Brightness float64       // 0.0 - 1.0
+	PanicFlash bool          // flash red on panic action
+	NotePulse  bool          // dim the lightbar and brighten it on every played note
+}
+
+// Sequencer defines step sequencer options, patterns themselves are kept in sequencer pattern file
+type Sequencer struct {
+	Clock  SequencerClock // internal clock is used when empty and enabled in hidi.toml, external one otherwise
+	Length int            // length of new patterns in steps (sixteenth notes)
+	Swing  float64        // position of every second step within the pair, 50 (straight) - 75 percent
+	Gate   float64        // gate of new steps, relative to the step length
 }
 
 type Config struct {
@@ -135,4 +473,14 @@ type Config struct {
 	CollisionMode CollisionMode
 	Defaults      Defaults
 	OpenRGB       OpenRGB
+	Calibration   map[string]map[evdev.EvCode]Calibration // main key: subhandler
+	Rumble        []Rumble
+	LEDIndicators map[evdev.EvCode]LEDIndicator // keyboard lock LEDs showing device state
+	Lightbar      Lightbar
+	MidiIn        []MidiInRule
+	CCLearned     map[Control]byte // CC numbers assigned in cc_learning mode, they take precedence over mappings
+	Sequencer     Sequencer
+	Patterns      *Patterns              // step sequencer patterns loaded from pattern file, nil if not saved yet
+	ChordKeys     map[evdev.EvCode]Chord // modifier keys, note keys play the chord of held modifier
+	NoteEffects   NoteEffects
 }
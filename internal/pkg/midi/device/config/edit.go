@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/holoplot/go-evdev"
+)
+
+// Config files are edited on text level instead of being re-encoded, so user comments
+// and formatting of untouched sections are preserved.
+
+// tableName returns name of the TOML table header, empty string if line is not a header
+func tableName(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") {
+		return ""
+	}
+	if i := strings.Index(line, "#"); i != -1 {
+		line = strings.TrimSpace(line[:i])
+	}
+	line = strings.TrimPrefix(strings.TrimSuffix(line, "]"), "[")
+	line = strings.TrimPrefix(strings.TrimSuffix(line, "]"), "[")
+	return strings.TrimSpace(line)
+}
+
+// replaceTable removes given table (including its sub-tables and all array of tables entries)
+// and puts content in place of its first occurrence, content is appended at the end when table is not present
+func replaceTable(data []byte, name, content string) []byte {
+	lines := strings.Split(string(data), "\n")
+	output := make([]string, 0, len(lines))
+
+	var inTable, inserted bool
+	for _, line := range lines {
+		if header := tableName(line); header != "" {
+			inTable = header == name || strings.HasPrefix(header, name+".")
+			if inTable && !inserted {
+				if content != "" {
+					output = append(output, strings.TrimRight(content, "\n"), "")
+				}
+				inserted = true
+			}
+		}
+		if inTable {
+			continue
+		}
+		output = append(output, line)
+	}
+
+	result := strings.TrimRight(strings.Join(output, "\n"), "\n") + "\n"
+	if !inserted && content != "" {
+		result += "\n" + strings.TrimRight(content, "\n") + "\n"
+	}
+	return []byte(result)
+}
+
+// identifierTable returns identifier section of given device
+func identifierTable(id input.InputID, uniq string) string {
+	content := fmt.Sprintf(
+		"[identifier]\n  bus = 0x%04x\n  vendor = 0x%04x\n  product = 0x%04x\n  version = 0x%04x\n",
+		id.Bus, id.Vendor, id.Product, id.Version,
+	)
+	if uniq != "" {
+		content += fmt.Sprintf("  uniq = %q\n", uniq)
+	}
+	return content
+}
+
+// SetIdentifier replaces identifier section of given config file data
+func SetIdentifier(data []byte, id input.InputID, uniq string) []byte {
+	return replaceTable(data, "identifier", identifierTable(id, uniq))
+}
+
+// SetCalibration replaces all calibration sections of given config file data
+func SetCalibration(data []byte, calibration map[string]map[evdev.EvCode]Calibration) []byte {
+	subHandlers := make([]string, 0, len(calibration))
+	for subHandler := range calibration {
+		subHandlers = append(subHandlers, subHandler)
+	}
+	sort.Strings(subHandlers)
+
+	buf := bytes.Buffer{}
+	for _, subHandler := range subHandlers {
+		axes := calibration[subHandler]
+		if len(axes) == 0 {
+			continue
+		}
+
+		codes := make([]evdev.EvCode, 0, len(axes))
+		for code := range axes {
+			codes = append(codes, code)
+		}
+		sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+
+		buf.WriteString(fmt.Sprintf("[[calibration]]\n  subhandler = %q\n  [calibration.axes]\n", subHandler))
+		for _, code := range codes {
+			c := axes[code]
+			values := make([]string, 0, 3)
+			if c.Min != nil {
+				values = append(values, fmt.Sprintf("min = %d", *c.Min))
+			}
+			if c.Max != nil {
+				values = append(values, fmt.Sprintf("max = %d", *c.Max))
+			}
+			if c.Center != nil {
+				values = append(values, fmt.Sprintf("center = %d", *c.Center))
+			}
+			buf.WriteString(fmt.Sprintf("    %s = { %s }\n", evdev.ABSToString[code], strings.Join(values, ", ")))
+		}
+		buf.WriteString("\n")
+	}
+
+	return replaceTable(data, "calibration", buf.String())
+}
+
+// UserConfigPath returns path of user config file dedicated to given device, named after the device
+func UserConfigPath(dev input.Device) (string, error) {
+	var dir string
+	switch dev.DeviceType {
+	case input.KeyboardDevice:
+		dir = userKeyboard
+	case input.JoystickDevice:
+		dir = userGamepad
+	default:
+		return "", fmt.Errorf("%w: %s", UnsupportedDeviceType, dev.DeviceType)
+	}
+
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, dev.Name)
+	return filepath.Join(dir, name+".toml"), nil
+}
+
+// SaveCalibration writes calibration into user config file of given device.
+// When device is handled by a factory config or a default user config, its copy with
+// device identifier filled in is created in user config directory first.
+// Path of the written file is returned.
+func SaveCalibration(dc DeviceConfig, dev input.Device, calibration map[string]map[evdev.EvCode]Calibration) (string, error) {
+	data, err := os.ReadFile(dc.ConfigPath)
+	if err != nil {
+		return "", fmt.Errorf("reading config file failed: %w", err)
+	}
+
+	path := dc.ConfigPath
+	if dc.ConfigType != "user" || dc.Config.ID != dev.ID {
+		path, err = UserConfigPath(dev)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); err == nil {
+			return "", fmt.Errorf("config file \"%s\" already exist but it's not used by the device", path)
+		}
+		data = SetIdentifier(data, dev.ID, "")
+	}
+
+	data = SetCalibration(data, calibration)
+	resolved, err := ResolveData(configRoot, path, data)
+	if err != nil {
+		return "", fmt.Errorf("calibrated config validation failed: %w", err)
+	}
+	if _, err = ParseData(resolved); err != nil {
+		return "", fmt.Errorf("calibrated config validation failed: %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return "", fmt.Errorf("writing config file failed: %w", err)
+	}
+	return path, nil
+}
+
+// LearnedMapping is a name of the mapping created by LearnedConfig
+const LearnedMapping = "Learned"
+
+// LearnedConfig returns user config data of given device with single mapping of learned notes and actions,
+// notes are grouped by subhandler
+func LearnedConfig(dev input.Device, notes map[string]map[evdev.EvCode]Key, actions map[evdev.EvCode]Action) []byte {
+	codeName := func(code evdev.EvCode) string {
+		if name, ok := evdev.KEYToString[code]; ok {
+			return name
+		}
+		return fmt.Sprintf("x%02x", code)
+	}
+
+	buf := bytes.Buffer{}
+	buf.WriteString("collision_mode = \"interrupt\"\nexit_sequence = []\n\n")
+	buf.WriteString(identifierTable(dev.ID, dev.Uniq))
+	buf.WriteString(fmt.Sprintf("\n[defaults]\n  octave = 0\n  semitone = 0\n  channel = 1\n  mapping = %q\n  velocity = 64\n", LearnedMapping))
+
+	buf.WriteString("\n[action_mapping]\n")
+	codes := make([]evdev.EvCode, 0, len(actions))
+	for code := range actions {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	for _, code := range codes {
+		buf.WriteString(fmt.Sprintf("  %s = %q\n", codeName(code), actions[code]))
+	}
+
+	buf.WriteString(fmt.Sprintf("\n[[mapping]]\n  name = %q\n", LearnedMapping))
+	subhandlers := make([]string, 0, len(notes))
+	for subhandler := range notes {
+		subhandlers = append(subhandlers, subhandler)
+	}
+	sort.Strings(subhandlers)
+	for _, subhandler := range subhandlers {
+		keys := notes[subhandler]
+		buf.WriteString(fmt.Sprintf("  [[mapping.keys]]\n    subhandler = %q\n    [mapping.keys.map]\n", subhandler))
+		codes = codes[:0]
+		for code := range keys {
+			codes = append(codes, code)
+		}
+		sort.Slice(codes, func(i, j int) bool {
+			if keys[codes[i]].Note != keys[codes[j]].Note {
+				return keys[codes[i]].Note < keys[codes[j]].Note
+			}
+			return codes[i] < codes[j]
+		})
+		for _, code := range codes {
+			buf.WriteString(fmt.Sprintf("      %s = %q\n", codeName(code), strings.ToLower(NoteToString(keys[code].Note))))
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// SaveUserConfig validates given config data and writes it into user config file of given device,
+// existing file is kept with ".bak" suffix. Paths of the written file and of the backup (if any) are returned.
+func SaveUserConfig(dev input.Device, data []byte) (path, backup string, err error) {
+	path, err = UserConfigPath(dev)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err = ParseData(data); err != nil {
+		return "", "", fmt.Errorf("config validation failed: %w", err)
+	}
+
+	if _, err = os.Stat(path); err == nil {
+		backup = path + ".bak"
+		err = os.Rename(path, backup)
+		if err != nil {
+			return "", "", fmt.Errorf("backup of existing config failed: %w", err)
+		}
+	}
+
+	err = os.WriteFile(path, data, 0644)
+	if err != nil {
+		return "", "", fmt.Errorf("writing config file failed: %w", err)
+	}
+	return path, backup, nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCalibration(t *testing.T) {
+	data, err := os.ReadFile("../../../../../cmd/hidi/hidi-config/factory/gamepad/PS4_Controller.toml")
+	assert.Nil(t, err)
+
+	original, err := ParseData(data)
+	assert.Nil(t, err)
+
+	min, max, center := int32(3), int32(250), int32(127)
+	calibration := map[string]map[evdev.EvCode]Calibration{
+		"": {
+			evdev.ABS_X: {Min: &min, Max: &max, Center: &center},
+			evdev.ABS_Z: {Min: &min, Max: &max},
+		},
+	}
+
+	data = SetIdentifier(data, input.InputID{Bus: 0x3, Vendor: 0x54c, Product: 0x9cc, Version: 0x8111}, "aa:bb")
+	data = SetCalibration(data, calibration)
+
+	c, err := ParseData(data)
+	assert.Nil(t, err)
+	assert.Equal(t, calibration, c.Calibration)
+	assert.Equal(t, "aa:bb", c.Uniq)
+	assert.Equal(t, original.KeyMappings, c.KeyMappings)
+	assert.Equal(t, original.ActionMapping, c.ActionMapping)
+
+	// calibrating again replaces previous values instead of duplicating the section
+	min = 10
+	data = SetCalibration(data, calibration)
+
+	c, err = ParseData(data)
+	assert.Nil(t, err)
+	assert.Equal(t, calibration, c.Calibration)
+	assert.Equal(t, original.KeyMappings, c.KeyMappings)
+}
+
+func TestLearnedConfig(t *testing.T) {
+	dev := input.Device{ID: input.InputID{Bus: 0x3, Vendor: 0x46d, Product: 0xc31c, Version: 0x111}, Uniq: "kb-1", DeviceType: input.KeyboardDevice}
+	notes := map[string]map[evdev.EvCode]Key{
+		"": {
+			evdev.KEY_Z: {Note: 60},
+			evdev.KEY_S: {Note: 61},
+			evdev.KEY_X: {Note: 62},
+			0x2f0:       {Note: 63},
+		},
+		"Consumer Control": {
+			evdev.KEY_VOLUMEUP:   {Note: 65},
+			evdev.KEY_VOLUMEDOWN: {Note: 64},
+		},
+	}
+	actions := map[evdev.EvCode]Action{evdev.KEY_F2: OctaveUp, evdev.KEY_F1: OctaveDown}
+
+	data := LearnedConfig(dev, notes, actions)
+	assert.Equal(t, `collision_mode = "interrupt"
+exit_sequence = []
+
+[identifier]
+  bus = 0x0003
+  vendor = 0x046d
+  product = 0xc31c
+  version = 0x0111
+  uniq = "kb-1"
+
+[defaults]
+  octave = 0
+  semitone = 0
+  channel = 1
+  mapping = "Learned"
+  velocity = 64
+
+[action_mapping]
+  KEY_F1 = "octave_down"
+  KEY_F2 = "octave_up"
+
+[[mapping]]
+  name = "Learned"
+  [[mapping.keys]]
+    subhandler = ""
+    [mapping.keys.map]
+      KEY_Z = "c3"
+      KEY_S = "c#3"
+      KEY_X = "d3"
+      x2f0 = "d#3"
+  [[mapping.keys]]
+    subhandler = "Consumer Control"
+    [mapping.keys.map]
+      KEY_VOLUMEDOWN = "e3"
+      KEY_VOLUMEUP = "f3"
+`, string(data))
+
+	c, err := ParseData(data)
+	assert.Nil(t, err)
+	assert.Equal(t, dev.ID, c.ID)
+	assert.Equal(t, "kb-1", c.Uniq)
+	assert.Equal(t, notes, c.KeyMappings[0].Midi)
+	assert.Equal(t, actions, c.ActionMapping)
+}
@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+type StrumDirection string
+type EffectParameter string
+
+const (
+	StrumDirectionUp   StrumDirection = "up"   // from the lowest voice
+	StrumDirectionDown StrumDirection = "down" // from the highest voice
+
+	EffectStrum      EffectParameter = "strum"       // delay between chord voices, 0 - MaxStrum
+	EffectRepeatRate EffectParameter = "repeat_rate" // note repeat rate, from the slowest one
+	EffectHumanize   EffectParameter = "humanize"    // humanize amount
+
+	MaxStrum = time.Millisecond * 1000
+)
+
+var SupportedStrumDirections = map[StrumDirection]bool{
+	StrumDirectionUp:   true,
+	StrumDirectionDown: true,
+}
+
+var SupportedEffectParameters = map[EffectParameter]bool{
+	EffectStrum:      true,
+	EffectRepeatRate: true,
+	EffectHumanize:   true,
+}
+
+// RepeatRate is a note repeat rate, clock divisions are given in midi clock ticks (24 per quarter note)
+type RepeatRate struct {
+	Name  string
+	Ticks int
+}
+
+// RepeatRates are note repeat rates ordered from the slowest one
+var RepeatRates = []RepeatRate{
+	{"1/4", 24},
+	{"1/8", 12},
+	{"1/8t", 8},
+	{"1/16", 6},
+	{"1/16t", 4},
+	{"1/32", 3},
+}
+
+// NoteEffects defines initial parameters of note effects, they can be changed with actions and effect analog mappings
+type NoteEffects struct {
+	Strum          time.Duration // delay between chord voices, chord strum takes precedence when defined
+	StrumDirection StrumDirection
+	Repeat         int            // note repeat rate, index of RepeatRates
+	Humanize       float64        // amount of random timing and velocity deviation, 0.0 - 1.0
+	Clock          SequencerClock // clock followed by note repeat, chosen the same way as the sequencer one when empty
+}
+
+type TOMLNoteEffects struct {
+	Strum          int     `toml:"strum,omitempty"` // milliseconds
+	StrumDirection string  `toml:"strum_direction,omitempty"`
+	Repeat         string  `toml:"repeat,omitempty"`
+	Humanize       float64 `toml:"humanize,omitempty"`
+	Clock          string  `toml:"clock,omitempty"`
+}
+
+func parseNoteEffects(e TOMLNoteEffects) (NoteEffects, error) {
+	effects := NoteEffects{
+		Strum:          time.Millisecond * time.Duration(e.Strum),
+		StrumDirection: StrumDirectionUp,
+		Repeat:         3, // 1/16
+		Humanize:       e.Humanize,
+		Clock:          SequencerClock(e.Clock),
+	}
+	if effects.Strum < 0 || effects.Strum > MaxStrum {
+		return NoteEffects{}, fmt.Errorf("strum outside of 0-%d range: %d", MaxStrum.Milliseconds(), e.Strum)
+	}
+	if e.StrumDirection != "" {
+		effects.StrumDirection = StrumDirection(e.StrumDirection)
+	}
+	if !SupportedStrumDirections[effects.StrumDirection] {
+		return NoteEffects{}, fmt.Errorf("strum direction not supported: %s", e.StrumDirection)
+	}
+	if e.Repeat != "" {
+		effects.Repeat = -1
+		for i, rate := range RepeatRates {
+			if rate.Name == e.Repeat {
+				effects.Repeat = i
+			}
+		}
+		if effects.Repeat < 0 {
+			return NoteEffects{}, fmt.Errorf("repeat rate not supported: %s", e.Repeat)
+		}
+	}
+	if effects.Humanize < 0 || effects.Humanize > 1 {
+		return NoteEffects{}, fmt.Errorf("humanize outside of 0.0-1.0 range: %g", e.Humanize)
+	}
+	if effects.Clock != "" && !SupportedSequencerClocks[effects.Clock] {
+		return NoteEffects{}, fmt.Errorf("clock not supported: %s", e.Clock)
+	}
+	return effects, nil
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseNoteEffects(t *testing.T) {
+	effects, err := parseNoteEffects(TOMLNoteEffects{})
+	assert.NoError(t, err)
+	assert.Equal(t, NoteEffects{StrumDirection: StrumDirectionUp, Repeat: 3}, effects)
+
+	effects, err = parseNoteEffects(TOMLNoteEffects{Strum: 30, StrumDirection: "down", Repeat: "1/8t", Humanize: 0.25, Clock: "external"})
+	assert.NoError(t, err)
+	assert.Equal(t, NoteEffects{Strum: time.Millisecond * 30, StrumDirection: StrumDirectionDown, Repeat: 2, Humanize: 0.25, Clock: SequencerExternal}, effects)
+
+	for _, raw := range []TOMLNoteEffects{
+		{Strum: -1},
+		{Strum: 1001},
+		{StrumDirection: "sideways"},
+		{Repeat: "1/64"},
+		{Humanize: 1.5},
+		{Clock: "wall"},
+	} {
+		_, err := parseNoteEffects(raw)
+		assert.Error(t, err, "%+v", raw)
+	}
+}
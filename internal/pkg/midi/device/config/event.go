@@ -55,3 +55,8 @@ func NoteToPitch(note byte) string {
 func NoteToOctave(note byte) int {
 	return int(note/12) - 2
 }
+
+// NoteToString returns note name in format accepted by StringToNote, e.g. "C#3"
+func NoteToString(note byte) string {
+	return NoteToPitch(note) + strconv.Itoa(NoteToOctave(note))
+}
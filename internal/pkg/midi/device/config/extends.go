@@ -0,0 +1,191 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// configRoot is a directory "extends" and "include" paths are relative to
+const configRoot = "hidi-config"
+
+// mergeKeys are arrays of tables merged by value of given key instead of being replaced,
+// e.g. mapping of the same name as in parent config is merged with it
+var mergeKeys = map[string]string{
+	"mapping":        "name",
+	"mapping.keys":   "subhandler",
+	"mapping.analog": "subhandler",
+}
+
+// ResolveFile reads device config and resolves its inheritance, see ResolveData
+func ResolveFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading file data failed: %w", err)
+	}
+	return ResolveData(configRoot, path, data)
+}
+
+// ResolveData returns effective device config of given config file data.
+// Config can inherit everything from a parent config with `extends = "path"` and merge additional
+// fragments with `include = ["path", ...]`, paths are relative to root directory.
+// Parent is applied first, includes next in given order and config itself at the end.
+// Tables are merged recursively, mappings are merged by name, keys and analog sections of mapping
+// by subhandler, remaining values (arrays included) replace inherited ones.
+// Data is returned unchanged when config doesn't inherit anything.
+func ResolveData(root, path string, data []byte) ([]byte, error) {
+	if !inherits(data) {
+		return data, nil
+	}
+
+	doc, err := resolve(root, path, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	return toml.Marshal(doc)
+}
+
+// inherits tells whether config data contains "extends" or "include" top level keys
+func inherits(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if bytes.HasPrefix(line, []byte("[")) {
+			return false
+		}
+		key, _, found := bytes.Cut(line, []byte("="))
+		if !found {
+			continue
+		}
+		switch string(bytes.Trim(bytes.TrimSpace(key), `"'`)) {
+		case "extends", "include":
+			return true
+		}
+	}
+	return false
+}
+
+func resolve(root, path string, data []byte, chain []string) (map[string]interface{}, error) {
+	path = filepath.Clean(path)
+	for _, p := range chain {
+		if p == path {
+			return nil, fmt.Errorf("inheritance loop: %s -> %s", strings.Join(chain, " -> "), path)
+		}
+	}
+	chain = append(chain, path)
+
+	var doc map[string]interface{}
+	err := toml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var parents []string
+	if extends, ok := doc["extends"]; ok {
+		parent, ok := extends.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s: extends: path expected, got %v", path, extends)
+		}
+		parents = append(parents, parent)
+	}
+	if include, ok := doc["include"]; ok {
+		fragments, ok := include.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s: include: list of paths expected, got %v", path, include)
+		}
+		for _, f := range fragments {
+			fragment, ok := f.(string)
+			if !ok {
+				return nil, fmt.Errorf("%s: include: path expected, got %v", path, f)
+			}
+			parents = append(parents, fragment)
+		}
+	}
+	delete(doc, "extends")
+	delete(doc, "include")
+
+	var result = make(map[string]interface{})
+	for _, parent := range parents {
+		parentPath := filepath.Join(root, parent)
+		parentData, err := os.ReadFile(parentPath)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil, fmt.Errorf("%s: missing parent config \"%s\"", path, parent)
+			}
+			return nil, fmt.Errorf("%s: reading parent config failed: %w", path, err)
+		}
+
+		parentDoc, err := resolve(root, parentPath, parentData, chain)
+		if err != nil {
+			return nil, err
+		}
+		result = mergeTables(result, parentDoc, "")
+	}
+
+	return mergeTables(result, doc, ""), nil
+}
+
+// mergeTables returns base table with override applied, given tables are not modified
+func mergeTables(base, override map[string]interface{}, table string) map[string]interface{} {
+	var result = make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+
+	for k, v := range override {
+		name := k
+		if table != "" {
+			name = table + "." + k
+		}
+
+		switch value := v.(type) {
+		case map[string]interface{}:
+			if inherited, ok := result[k].(map[string]interface{}); ok {
+				result[k] = mergeTables(inherited, value, name)
+				continue
+			}
+		case []interface{}:
+			key, ok := mergeKeys[name]
+			inherited, isArray := result[k].([]interface{})
+			if ok && isArray {
+				result[k] = mergeArrays(inherited, value, key, name)
+				continue
+			}
+		}
+		result[k] = v
+	}
+	return result
+}
+
+// mergeArrays merges arrays of tables, tables of the same key value are merged,
+// remaining tables of override are appended
+func mergeArrays(base, override []interface{}, key, table string) []interface{} {
+	var result = make([]interface{}, len(base))
+	copy(result, base)
+
+	for _, v := range override {
+		value, ok := v.(map[string]interface{})
+		if !ok {
+			return override
+		}
+
+		name, _ := value[key].(string)
+		merged := false
+		for i, r := range result {
+			inherited, ok := r.(map[string]interface{})
+			if inheritedName, _ := inherited[key].(string); ok && inheritedName == name {
+				result[i] = mergeTables(inherited, value, table)
+				merged = true
+				break
+			}
+		}
+		if !merged {
+			result = append(result, value)
+		}
+	}
+	return result
+}
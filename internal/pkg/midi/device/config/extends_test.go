@@ -0,0 +1,191 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+const extendingConfig = `extends = "factory/keyboard/0_default.toml"
+include = ["user/include/drums.toml"]
+
+[identifier]
+  vendor = 0x046d
+
+[defaults]
+  mapping = "Drums"
+
+[action_mapping]
+  KEY_F1 = "multinote"
+
+[open_rgb]
+  white = 0xffffff
+
+[[mapping]]
+  name = "Piano"
+  [[mapping.keys]]
+    subhandler = ""
+    [mapping.keys.map]
+      KEY_Z = "c1"
+`
+
+const drumsFragment = `[[mapping]]
+  name = "Drums"
+  [[mapping.keys]]
+    subhandler = ""
+    [mapping.keys.map]
+      KEY_SPACE = "36"
+`
+
+// configTree creates config root with factory keyboard config and given files
+func configTree(t *testing.T, files map[string]string) string {
+	root := t.TempDir()
+
+	factory, err := os.ReadFile("../../../../../cmd/hidi/hidi-config/factory/keyboard/0_default.toml")
+	assert.NoError(t, err)
+	writeConfig(t, filepath.Join(root, "factory/keyboard/0_default.toml"), string(factory))
+
+	for path, data := range files {
+		writeConfig(t, filepath.Join(root, path), data)
+	}
+	return root
+}
+
+func TestResolveData(t *testing.T) {
+	root := configTree(t, map[string]string{
+		"user/keyboard/custom.toml": extendingConfig,
+		"user/include/drums.toml":   drumsFragment,
+	})
+
+	data, err := ResolveData(root, filepath.Join(root, "user/keyboard/custom.toml"), []byte(extendingConfig))
+	if !assert.NoError(t, err) {
+		return
+	}
+	cfg, err := ParseData(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	factoryData, err := os.ReadFile(filepath.Join(root, "factory/keyboard/0_default.toml"))
+	assert.NoError(t, err)
+	factory, err := ParseData(factoryData)
+	assert.NoError(t, err)
+
+	assert.Equal(t, input.InputID{Vendor: 0x046d}, cfg.ID)
+	assert.Equal(t, factory.ExitSequence, cfg.ExitSequence)
+	assert.Equal(t, factory.CollisionMode, cfg.CollisionMode)
+
+	// action mapping is merged
+	assert.Equal(t, len(factory.ActionMapping), len(cfg.ActionMapping))
+	assert.Equal(t, Multinote, cfg.ActionMapping[evdev.KEY_F1])
+	assert.Equal(t, Panic, cfg.ActionMapping[evdev.KEY_ESC])
+
+	// open_rgb is merged
+	assert.Equal(t, openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff}, cfg.OpenRGB.Colors.White)
+	assert.Equal(t, factory.OpenRGB.Colors.Black, cfg.OpenRGB.Colors.Black)
+
+	// mappings are merged by name, included ones are appended
+	if !assert.Len(t, cfg.KeyMappings, len(factory.KeyMappings)+1) {
+		return
+	}
+	for i, mapping := range factory.KeyMappings {
+		assert.Equal(t, mapping.Name, cfg.KeyMappings[i].Name)
+	}
+	piano := cfg.KeyMappings[0].Midi[""]
+	assert.Equal(t, len(factory.KeyMappings[0].Midi[""]), len(piano))
+	assert.Equal(t, factory.KeyMappings[0].Midi[""][evdev.KEY_Z].Note+12, piano[evdev.KEY_Z].Note)
+	assert.Equal(t, factory.KeyMappings[0].Midi[""][evdev.KEY_X], piano[evdev.KEY_X])
+	assert.Equal(t, factory.KeyMappings[1], cfg.KeyMappings[1])
+
+	drums := cfg.KeyMappings[len(cfg.KeyMappings)-1]
+	assert.Equal(t, "Drums", drums.Name)
+	assert.Equal(t, map[evdev.EvCode]Key{evdev.KEY_SPACE: {Note: 36}}, drums.Midi[""])
+	assert.Equal(t, len(cfg.KeyMappings)-1, cfg.Defaults.Mapping)
+}
+
+func TestResolveDataUnchanged(t *testing.T) {
+	data := []byte("# extends = \"nothing\"\ncollision_mode = \"off\"\n[defaults]\n  extends = 1\n")
+	resolved, err := ResolveData(t.TempDir(), "config.toml", data)
+	assert.NoError(t, err)
+	assert.Equal(t, data, resolved)
+}
+
+func TestResolveDataErrors(t *testing.T) {
+	root := configTree(t, map[string]string{
+		"a.toml":      `extends = "b.toml"`,
+		"b.toml":      `include = ["a.toml"]`,
+		"self.toml":   `extends = "self.toml"`,
+		"orphan.toml": `extends = "user/keyboard/removed.toml"`,
+		"typo.toml":   `include = "a.toml"`,
+	})
+
+	for _, tc := range []struct {
+		file     string
+		expected string
+	}{
+		{"a.toml", "inheritance loop: {root}/a.toml -> {root}/b.toml -> {root}/a.toml"},
+		{"self.toml", "inheritance loop: {root}/self.toml -> {root}/self.toml"},
+		{"orphan.toml", "{root}/orphan.toml: missing parent config \"user/keyboard/removed.toml\""},
+		{"typo.toml", "{root}/typo.toml: include: list of paths expected, got a.toml"},
+	} {
+		path := filepath.Join(root, tc.file)
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+
+		_, err = ResolveData(root, path, data)
+		assert.EqualError(t, err, strings.ReplaceAll(tc.expected, "{root}", filepath.Clean(root)), tc.file)
+	}
+}
+
+func TestLintInherited(t *testing.T) {
+	root := configTree(t, map[string]string{
+		"user/keyboard/custom.toml": "\n" + extendingConfig,
+		"user/keyboard/orphan.toml": "collision_mode = \"off\"\nextends = \"user/keyboard/removed.toml\"\n",
+		"user/keyboard/broken.toml": "extends = \"factory/keyboard/0_default.toml\"\n\n[action_mapping]\n  KEY_F1 = \"dance\"\n",
+		"user/include/drums.toml":   drumsFragment,
+	})
+
+	custom := filepath.Join(root, "user/keyboard/custom.toml")
+	orphan := filepath.Join(root, "user/keyboard/orphan.toml")
+	broken := filepath.Join(root, "user/keyboard/broken.toml")
+
+	assert.Equal(t, []Problem{
+		{File: orphan, Line: 2, Error: true, Message: orphan + ": missing parent config \"user/keyboard/removed.toml\""},
+		{File: broken, Line: 3, Error: true, Message: "[actions] unsupported action: dance"},
+	}, lint(root, []string{custom, orphan, broken}))
+}
+
+func TestLintInheritedMappings(t *testing.T) {
+	// inherited mappings come first in resolved config, lines are found by mapping name
+	root := configTree(t, map[string]string{
+		"user/keyboard/custom.toml": `extends = "factory/keyboard/0_default.toml"
+
+[[mapping]]
+  name = "Chromatic"
+  [[mapping.keys]]
+    subhandler = ""
+    [mapping.keys.map]
+      KEY_F2 = "c2"
+
+[[mapping]]
+  name = "Pads"
+  [[mapping.keys]]
+    subhandler = ""
+    [mapping.keys.map]
+      KEY_F1 = "36"
+`,
+	})
+
+	custom := filepath.Join(root, "user/keyboard/custom.toml")
+
+	assert.Equal(t, []Problem{
+		{File: custom, Line: 8, Message: `[Chromatic] KEY_F2: key bound to a note and to "octave_up" action, action takes precedence`},
+		{File: custom, Line: 15, Message: `[Pads] KEY_F1: key bound to a note and to "octave_down" action, action takes precedence`},
+	}, lint(root, []string{custom}))
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// KeyGrid is a physical layout of keyboard keys used by note generators, rows from top to bottom.
+// Every row is shifted by half a key to the right relative to the row above, so the key at the same
+// index of the row above lays on the upper-left side, keys of hexagonal layouts are approximated that way.
+var KeyGrid = [][]evdev.EvCode{
+	{
+		evdev.KEY_GRAVE, evdev.KEY_1, evdev.KEY_2, evdev.KEY_3, evdev.KEY_4, evdev.KEY_5, evdev.KEY_6,
+		evdev.KEY_7, evdev.KEY_8, evdev.KEY_9, evdev.KEY_0, evdev.KEY_MINUS, evdev.KEY_EQUAL, evdev.KEY_BACKSPACE,
+	},
+	{
+		evdev.KEY_TAB, evdev.KEY_Q, evdev.KEY_W, evdev.KEY_E, evdev.KEY_R, evdev.KEY_T, evdev.KEY_Y,
+		evdev.KEY_U, evdev.KEY_I, evdev.KEY_O, evdev.KEY_P, evdev.KEY_LEFTBRACE, evdev.KEY_RIGHTBRACE, evdev.KEY_BACKSLASH,
+	},
+	{
+		evdev.KEY_CAPSLOCK, evdev.KEY_A, evdev.KEY_S, evdev.KEY_D, evdev.KEY_F, evdev.KEY_G, evdev.KEY_H,
+		evdev.KEY_J, evdev.KEY_K, evdev.KEY_L, evdev.KEY_SEMICOLON, evdev.KEY_APOSTROPHE, evdev.KEY_ENTER,
+	},
+	{
+		evdev.KEY_LEFTSHIFT, evdev.KEY_Z, evdev.KEY_X, evdev.KEY_C, evdev.KEY_V, evdev.KEY_B, evdev.KEY_N,
+		evdev.KEY_M, evdev.KEY_COMMA, evdev.KEY_DOT, evdev.KEY_SLASH, evdev.KEY_RIGHTSHIFT,
+	},
+}
+
+const (
+	defaultGeneratorOrigin = evdev.KEY_Z
+	defaultGeneratorStart  = "c0"
+)
+
+// layoutIntervals are column and row intervals of layout presets
+var layoutIntervals = map[Layout][2]int{
+	LayoutWickiHayden:   {2, 5},
+	LayoutJanko:         {2, -1},
+	LayoutHarmonicTable: {1, 3},
+	LayoutFourths:       {1, 5},
+}
+
+// Generator computes notes of isomorphic layouts from KeyGrid, every key plays origin note shifted
+// by column interval for every key to the right and by row interval for every row above.
+type Generator struct {
+	Layout         Layout
+	SubHandler     string
+	Origin         evdev.EvCode // key playing the start note
+	Start          byte
+	ColumnInterval int // semitones between neighbour keys of the row
+	RowInterval    int // semitones between a key and the key above it on the left side
+}
+
+// gridPosition returns row (counted from the bottom) and column of given key
+func gridPosition(code evdev.EvCode) (row, column int, ok bool) {
+	for i, keys := range KeyGrid {
+		for j, key := range keys {
+			if key == code {
+				return len(KeyGrid) - 1 - i, j, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Notes returns notes of all KeyGrid keys, keys outside of 0-127 range are skipped
+func (g Generator) Notes() map[evdev.EvCode]Key {
+	originRow, originColumn, _ := gridPosition(g.Origin)
+
+	var notes = make(map[evdev.EvCode]Key)
+	for i, keys := range KeyGrid {
+		row := len(KeyGrid) - 1 - i
+		for column, key := range keys {
+			note := int(g.Start) + (column-originColumn)*g.ColumnInterval + (row-originRow)*g.RowInterval
+			if note < 0 || note > 127 {
+				continue
+			}
+			notes[key] = Key{Note: byte(note)}
+		}
+	}
+	return notes
+}
+
+// DefaultGenerator returns generator of given layout preset with default origin and start note
+func DefaultGenerator(layout Layout) (Generator, error) {
+	return parseGenerator(TOMLGenerator{Layout: string(layout)})
+}
+
+func parseGenerator(g TOMLGenerator) (Generator, error) {
+	layout := Layout(g.Layout)
+	if !SupportedLayouts[layout] {
+		return Generator{}, fmt.Errorf("unsupported layout: %s", g.Layout)
+	}
+
+	generator := Generator{Layout: layout, SubHandler: g.SubHandler, Origin: defaultGeneratorOrigin}
+
+	if g.Origin != "" {
+		origin, err := TomlKeyToEvCode(g.Origin, evdev.KEYFromString)
+		if err != nil {
+			return Generator{}, fmt.Errorf("origin: %w", err)
+		}
+		if _, _, ok := gridPosition(origin); !ok {
+			return Generator{}, fmt.Errorf("origin: %s is not a part of the key grid", g.Origin)
+		}
+		generator.Origin = origin
+	}
+
+	start := g.Start
+	if start == "" {
+		start = defaultGeneratorStart
+	}
+	note, err := StringToNote(start)
+	if err != nil {
+		return Generator{}, fmt.Errorf("start: failed to parse note: %w", err)
+	}
+	generator.Start = note
+
+	intervals, preset := layoutIntervals[layout]
+	if !preset && (g.ColumnInterval == nil || g.RowInterval == nil) {
+		return Generator{}, fmt.Errorf("%s layout requires column_interval and row_interval", layout)
+	}
+	generator.ColumnInterval, generator.RowInterval = intervals[0], intervals[1]
+	if g.ColumnInterval != nil {
+		generator.ColumnInterval = *g.ColumnInterval
+	}
+	if g.RowInterval != nil {
+		generator.RowInterval = *g.RowInterval
+	}
+
+	for _, interval := range []int{generator.ColumnInterval, generator.RowInterval} {
+		if interval < -127 || interval > 127 {
+			return Generator{}, fmt.Errorf("interval outside of -127-127 range: %d", interval)
+		}
+	}
+
+	return generator, nil
+}
@@ -0,0 +1,125 @@
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratorNotes(t *testing.T) {
+	g, err := DefaultGenerator(LayoutWickiHayden)
+	assert.NoError(t, err)
+	assert.Equal(t, Generator{Layout: LayoutWickiHayden, Origin: evdev.KEY_Z, Start: 24, ColumnInterval: 2, RowInterval: 5}, g)
+
+	notes := g.Notes()
+	assert.Equal(t, Key{Note: 24}, notes[evdev.KEY_Z])
+	assert.Equal(t, Key{Note: 26}, notes[evdev.KEY_X]) // whole tone to the right
+	assert.Equal(t, Key{Note: 29}, notes[evdev.KEY_A]) // fourth up-left
+	assert.Equal(t, Key{Note: 31}, notes[evdev.KEY_S]) // fifth up-right
+	assert.Equal(t, Key{Note: 22}, notes[evdev.KEY_LEFTSHIFT])
+	assert.Equal(t, Key{Note: 39}, notes[evdev.KEY_1])
+	assert.Len(t, notes, 53)
+
+	g, err = DefaultGenerator(LayoutJanko)
+	assert.NoError(t, err)
+	notes = g.Notes()
+	assert.Equal(t, Key{Note: 23}, notes[evdev.KEY_A])
+	assert.Equal(t, Key{Note: 25}, notes[evdev.KEY_S])
+	assert.Equal(t, Key{Note: 24}, notes[evdev.KEY_W])
+
+	// notes outside of midi range are skipped
+	g = Generator{Layout: LayoutFourths, Origin: evdev.KEY_Z, Start: 120, ColumnInterval: 1, RowInterval: 5}
+	notes = g.Notes()
+	assert.Equal(t, Key{Note: 127}, notes[evdev.KEY_COMMA])
+	assert.NotContains(t, notes, evdev.KEY_DOT)
+	assert.NotContains(t, notes, evdev.KEY_Q)
+	assert.Equal(t, Key{Note: 119}, notes[evdev.KEY_LEFTSHIFT])
+}
+
+func TestGeneratorFactoryChromatic(t *testing.T) {
+	data, err := os.ReadFile("../../../../../cmd/hidi/hidi-config/factory/keyboard/0_default.toml")
+	assert.NoError(t, err)
+	cfg, err := ParseData(data)
+	assert.NoError(t, err)
+
+	chromatic := cfg.KeyMappings[1]
+	assert.Equal(t, "Chromatic", chromatic.Name)
+
+	// hand written chromatic mapping is an isomorphic layout
+	columns, rows := 3, -1
+	g, err := parseGenerator(TOMLGenerator{Layout: "isomorphic", Origin: "KEY_LEFTSHIFT", Start: "b-1", ColumnInterval: &columns, RowInterval: &rows})
+	assert.NoError(t, err)
+	notes := g.Notes()
+
+	var compared int
+	for code, key := range chromatic.Midi[""] {
+		if _, _, ok := gridPosition(code); !ok {
+			continue // numpad
+		}
+		assert.Equal(t, key, notes[code], evdev.KEYToString[code])
+		compared++
+	}
+	assert.Equal(t, 53, compared)
+}
+
+func TestParseGenerator(t *testing.T) {
+	data := []byte(`collision_mode = "off"
+
+[identifier]
+
+[defaults]
+  mapping = "Generated"
+
+[action_mapping]
+  KEY_Q = "panic"
+
+[[mapping]]
+  name = "Generated"
+  [mapping.generator]
+    layout = "harmonic_table"
+    origin = "KEY_A"
+    start = "c3"
+    row_interval = 4
+  [[mapping.keys]]
+    subhandler = ""
+    [mapping.keys.map]
+      KEY_S = "10"
+      KEY_SPACE = "11"
+`)
+
+	cfg, err := ParseData(data)
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	mapping := cfg.KeyMappings[0]
+	assert.Equal(t, &Generator{Layout: LayoutHarmonicTable, Origin: evdev.KEY_A, Start: 60, ColumnInterval: 1, RowInterval: 4}, mapping.Generator)
+
+	notes := mapping.Midi[""]
+	assert.Equal(t, Key{Note: 60}, notes[evdev.KEY_A])
+	assert.Equal(t, Key{Note: 62}, notes[evdev.KEY_D])
+	assert.Equal(t, Key{Note: 63}, notes[evdev.KEY_TAB])
+	assert.Equal(t, Key{Note: 10}, notes[evdev.KEY_S])
+	assert.Equal(t, Key{Note: 11}, notes[evdev.KEY_SPACE])
+	assert.NotContains(t, notes, evdev.KEY_Q)
+}
+
+func TestParseGeneratorErrors(t *testing.T) {
+	interval := 200
+	for _, tc := range []struct {
+		generator TOMLGenerator
+		expected  string
+	}{
+		{TOMLGenerator{Layout: "qwerty"}, "unsupported layout: qwerty"},
+		{TOMLGenerator{Layout: "isomorphic"}, "isomorphic layout requires column_interval and row_interval"},
+		{TOMLGenerator{Layout: "janko", Origin: "KEY_F1"}, "origin: KEY_F1 is not a part of the key grid"},
+		{TOMLGenerator{Layout: "janko", Origin: "KEY_NOPE"}, "origin: EvCode name \"KEY_NOPE\" not found / not supported"},
+		{TOMLGenerator{Layout: "janko", Start: "c#"}, "start: failed to parse note: unsupported format, bruh"},
+		{TOMLGenerator{Layout: "janko", RowInterval: &interval}, "interval outside of -127-127 range: 200"},
+	} {
+		_, err := parseGenerator(tc.generator)
+		assert.EqualError(t, err, tc.expected)
+	}
+}
@@ -0,0 +1,437 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/holoplot/go-evdev"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// Problem is a device config issue found by Lint
+type Problem struct {
+	File    string
+	Line    int  // 0 when line can't be determined
+	Error   bool // config is rejected or misbehaves, warning otherwise
+	Message string
+}
+
+func (p Problem) String() string {
+	severity := "warning"
+	if p.Error {
+		severity = "error"
+	}
+	if p.Line == 0 {
+		return fmt.Sprintf("%s: %s: %s", p.File, severity, p.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s: %s", p.File, p.Line, severity, p.Message)
+}
+
+// tomlLine is a key or table header of TOML document
+type tomlLine struct {
+	number  int
+	table   string // dotted table name, empty for top level keys
+	mapping int    // index of [[mapping]] table given line belongs to, -1 outside of mappings
+	key     string // empty for table headers
+	value   string // raw value with quotes trimmed
+}
+
+// locator finds line numbers of TOML keys, it understands the subset of TOML used by device configs
+type locator []tomlLine
+
+func newLocator(data []byte) locator {
+	var l locator
+	var table string
+	var mapping = -1
+
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			end := strings.LastIndex(line, "]")
+			if end == -1 {
+				continue
+			}
+			table = strings.TrimSpace(strings.Trim(line[:end+1], "[]"))
+			switch {
+			case table == "mapping":
+				mapping++
+			case !strings.HasPrefix(table, "mapping."):
+				mapping = -1
+			}
+			l = append(l, tomlLine{number: i + 1, table: table, mapping: mapping})
+			continue
+		}
+
+		eq := strings.Index(line, "=")
+		if eq == -1 {
+			continue
+		}
+		key := strings.Trim(strings.TrimSpace(line[:eq]), `"'`)
+		value := strings.TrimSpace(line[eq+1:])
+		if hash := strings.Index(value, " #"); hash != -1 {
+			value = strings.TrimSpace(value[:hash])
+		}
+		value = strings.Trim(value, `"'`)
+		l = append(l, tomlLine{number: i + 1, table: table, mapping: mapping, key: key, value: value})
+	}
+	return l
+}
+
+// find returns number of the first line matching given condition, 0 if not found
+func (l locator) find(match func(line tomlLine) bool) int {
+	for _, line := range l {
+		if match(line) {
+			return line.number
+		}
+	}
+	return 0
+}
+
+// key returns line of given key, mapping -1 matches tables outside of mappings
+func (l locator) key(table string, mapping int, key string) int {
+	return l.find(func(line tomlLine) bool {
+		return line.table == table && line.mapping == mapping && line.key == key
+	})
+}
+
+// evcode returns line of a key that stands for given evcode, e.g. "KEY_A" or "x1e"
+func (l locator) evcode(table string, mapping int, code evdev.EvCode, lookupTable map[string]evdev.EvCode) int {
+	return l.find(func(line tomlLine) bool {
+		if line.table != table || line.mapping != mapping || line.key == "" {
+			return false
+		}
+		c, err := TomlKeyToEvCode(line.key, lookupTable)
+		return err == nil && c == code
+	})
+}
+
+// noMapping is a mapping index matching no line, used for mappings not defined by the located file
+const noMapping = -2
+
+// mapping returns index of [[mapping]] table of given name, noMapping when the file doesn't define it.
+// Indices of resolved config differ from the file ones when mappings are inherited.
+func (l locator) mapping(name string) int {
+	for _, line := range l {
+		if line.table == "mapping" && line.key == "name" && line.value == name {
+			return line.mapping
+		}
+	}
+	return noMapping
+}
+
+// table returns line of given table header
+func (l locator) table(table string, mapping int) int {
+	return l.find(func(line tomlLine) bool {
+		return line.table == table && line.mapping == mapping && line.key == ""
+	})
+}
+
+var errorSection = regexp.MustCompile(`^\[([^]]+)]`)
+
+// errorSectionTables are tables of ParseData error sections named differently than the table
+var errorSectionTables = map[string]string{
+	"actions":   "action_mapping",
+	"deadzones": "mapping.analog.deadzones",
+}
+
+// containsWord reports if s mentions given word, not being a part of a longer key name
+func containsWord(s, word string) bool {
+	for i := 0; i <= len(s); {
+		n := strings.Index(s[i:], word)
+		if n == -1 {
+			return false
+		}
+		start, end := i+n, i+n+len(word)
+		if (start == 0 || !isKeyByte(s[start-1])) && (end == len(s) || !isKeyByte(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+	return false
+}
+
+// isKeyByte reports if c may be a part of TOML key name, including note names like "c#-1"
+func isKeyByte(c byte) bool {
+	return c == '_' || c == '#' || c == '-' || (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// guess returns line related to ParseData validation error. Errors point to the section with "[section]" prefix,
+// which is a table, top level key or mapping name, the first key of that section mentioned by the message is picked.
+func (l locator) guess(message string) int {
+	var section string
+	if m := errorSection.FindStringSubmatch(message); m != nil {
+		section = m[1]
+		message = strings.TrimPrefix(message, m[0])
+		if table, ok := errorSectionTables[section]; ok {
+			section = table
+		}
+	}
+
+	inSection := func(line tomlLine) bool {
+		switch {
+		case section == "":
+			return true
+		case line.table == section || strings.HasPrefix(line.table, section+"."):
+			return true
+		case line.table == "" && line.key == strings.Fields(section)[0]:
+			return true
+		}
+		return false
+	}
+
+	if section != "" {
+		// mapping name
+		for _, line := range l {
+			if line.table == "mapping" && line.key == "name" && line.value == section {
+				mapping := line.mapping
+				inSection = func(line tomlLine) bool { return line.mapping == mapping }
+				break
+			}
+		}
+	}
+
+	if n := l.find(func(line tomlLine) bool {
+		return inSection(line) && line.key != "" && containsWord(message, line.key)
+	}); n != 0 {
+		return n
+	}
+	return l.find(inSection)
+}
+
+// parseProblems converts ParseData error into problems with line numbers, error positions are meaningful
+// only when parsed data wasn't resolved from inherited configs
+func parseProblems(file string, data []byte, resolved bool, err error) []Problem {
+	loc := newLocator(data)
+
+	var strict *toml.StrictMissingError
+	if errors.As(err, &strict) {
+		var problems []Problem
+		for _, e := range strict.Errors {
+			row, _ := e.Position()
+			if resolved {
+				key := e.Key()
+				table := strings.Join(key[:len(key)-1], ".")
+				row = loc.find(func(line tomlLine) bool { return line.table == table && line.key == key[len(key)-1] })
+			}
+			problems = append(problems, Problem{
+				File: file, Line: row, Error: true,
+				Message: fmt.Sprintf("unknown field: %s", strings.Join(e.Key(), ".")),
+			})
+		}
+		return problems
+	}
+
+	var decode *toml.DecodeError
+	if errors.As(err, &decode) && !resolved {
+		row, _ := decode.Position()
+		return []Problem{{File: file, Line: row, Error: true, Message: err.Error()}}
+	}
+
+	return []Problem{{File: file, Line: loc.guess(err.Error()), Error: true, Message: err.Error()}}
+}
+
+// lintConfig looks for problems that are accepted by ParseData but are most likely mistakes
+func lintConfig(file string, data []byte, cfg Config) []Problem {
+	loc := newLocator(data)
+	var problems []Problem
+
+	warn := func(line int, format string, a ...interface{}) {
+		problems = append(problems, Problem{File: file, Line: line, Message: fmt.Sprintf(format, a...)})
+	}
+	fail := func(line int, format string, a ...interface{}) {
+		problems = append(problems, Problem{File: file, Line: line, Error: true, Message: fmt.Sprintf(format, a...)})
+	}
+
+	if cfg.Defaults.Channel < 1 || cfg.Defaults.Channel > 16 {
+		fail(loc.key("defaults", -1, "channel"), "default channel outside of 1-16 range: %d", cfg.Defaults.Channel)
+	}
+
+	offset := cfg.Defaults.Octave*12 + cfg.Defaults.Semitone
+	availableActions := make(map[Action]bool)
+	for _, action := range cfg.ActionMapping {
+		availableActions[action] = true
+	}
+
+	for _, mapping := range cfg.KeyMappings {
+		i := loc.mapping(mapping.Name)
+		for _, subhandler := range sortedKeys(mapping.Midi) {
+			for _, code := range sortedCodes(mapping.Midi[subhandler]) {
+				line := loc.evcode("mapping.keys.map", i, code, evdev.KEYFromString)
+				name := evdev.CodeName(evdev.EV_KEY, code)
+
+				if action, ok := cfg.ActionMapping[code]; ok {
+					warn(line, "[%s] %s: key bound to a note and to \"%s\" action, action takes precedence", mapping.Name, name, action)
+				}
+
+				note := int(mapping.Midi[subhandler][code].Note) + offset
+				if note < 0 || note > 127 {
+					warn(line, "[%s] %s: note outside of 0-127 range with default octave and semitone: %d", mapping.Name, name, note)
+				}
+			}
+		}
+
+		for _, subhandler := range sortedKeys(mapping.DefaultDeadzone) {
+			deadzone := mapping.DefaultDeadzone[subhandler]
+			if deadzone < 0 || deadzone > 1 {
+				line := loc.find(func(line tomlLine) bool {
+					return line.table == "mapping.analog" && line.mapping == i && line.key == "default_deadzone"
+				})
+				fail(line, "[%s] default deadzone outside of 0.0-1.0 range: %g", mapping.Name, deadzone)
+			}
+		}
+		for _, subhandler := range sortedKeys(mapping.Deadzones) {
+			for _, code := range sortedCodes(mapping.Deadzones[subhandler]) {
+				deadzone := mapping.Deadzones[subhandler][code]
+				if deadzone < 0 || deadzone > 1 {
+					line := loc.evcode("mapping.analog.deadzones", i, code, evdev.ABSFromString)
+					fail(line, "[%s] %s: deadzone outside of 0.0-1.0 range: %g", mapping.Name, evdev.CodeName(evdev.EV_ABS, code), deadzone)
+				}
+			}
+		}
+
+		for _, analogs := range mapping.Analog {
+			for _, analog := range analogs {
+				if analog.MappingType == AnalogActionSim {
+					availableActions[analog.Action] = true
+					availableActions[analog.ActionNeg] = true
+				}
+			}
+		}
+	}
+
+	// mapping switching doesn't wrap around
+	for i, mapping := range cfg.KeyMappings {
+		if (i > cfg.Defaults.Mapping && !availableActions[MappingUp]) || (i < cfg.Defaults.Mapping && !availableActions[MappingDown]) {
+			warn(loc.key("mapping", loc.mapping(mapping.Name), "name"), "[%s] mapping is unreachable, mapping_up/mapping_down action is not bound", mapping.Name)
+		}
+	}
+
+	return problems
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	var keys = make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedCodes[T any](m map[evdev.EvCode]T) []evdev.EvCode {
+	var codes = make([]evdev.EvCode, 0, len(m))
+	for code := range m {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// Lint parses given device configs and looks for common mistakes, configs loaded from the same config
+// directory are also checked for duplicated identifiers, only one of them would be used
+func Lint(files []string) []Problem {
+	return lint(configRoot, files)
+}
+
+// lint checks given configs, root is a directory inherited configs are resolved from
+func lint(root string, files []string) []Problem {
+	var problems []Problem
+
+	type identified struct {
+		file string
+		line int
+	}
+	var identifiers = make(map[string]map[string]identified) // config directory: identifier: first config
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			problems = append(problems, Problem{File: file, Error: true, Message: fmt.Sprintf("reading file failed: %s", err)})
+			continue
+		}
+
+		resolved, err := ResolveData(root, file, data)
+		if err != nil {
+			line := newLocator(data).find(func(line tomlLine) bool {
+				return line.table == "" && (line.key == "extends" || line.key == "include")
+			})
+			problems = append(problems, Problem{File: file, Line: line, Error: true, Message: err.Error()})
+			continue
+		}
+
+		cfg, err := ParseData(resolved)
+		if err != nil {
+			problems = append(problems, parseProblems(file, data, !bytes.Equal(data, resolved), err)...)
+			continue
+		}
+		problems = append(problems, lintConfig(file, data, cfg)...)
+
+		dir := configDir(root, file)
+		if identifiers[dir] == nil {
+			identifiers[dir] = make(map[string]identified)
+		}
+		id := cfg.ID.String()
+		line := newLocator(data).table("identifier", -1)
+		if first, ok := identifiers[dir][id]; ok {
+			problems = append(problems, Problem{
+				File: file, Line: line, Error: true,
+				Message: fmt.Sprintf("identifier already used by %s:%d, only one of them is loaded", first.file, first.line),
+			})
+			continue
+		}
+		identifiers[dir][id] = identified{file: file, line: line}
+	}
+
+	return problems
+}
+
+// configDir returns directory given config is loaded from, configs of its subdirectories are loaded into
+// the same config map. Directory of the file is returned for files outside of config directories.
+func configDir(root, file string) string {
+	path, err := filepath.Abs(file)
+	if err != nil {
+		return filepath.Dir(file)
+	}
+	for _, dir := range []string{factoryGamepad, factoryKeyboard, userGamepad, userKeyboard} {
+		dir, err = filepath.Abs(filepath.Join(root, strings.TrimPrefix(dir, configRoot+"/")))
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err == nil && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && rel != ".." {
+			return dir
+		}
+	}
+	return filepath.Dir(file)
+}
+
+// ConfigFiles returns all factory and user device configs, in the order they are loaded
+func ConfigFiles() ([]string, error) {
+	var files []string
+	for _, root := range []string{factoryGamepad, factoryKeyboard, userGamepad, userKeyboard} {
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".toml") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walk \"%s\" failed: %w", root, err)
+		}
+	}
+	return files, nil
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const lintConfigData = `collision_mode = "off"
+
+[identifier]
+  bus = 0x03
+  vendor = 0x01
+  product = 0x02
+
+[defaults]
+  octave = 5
+  channel = 0
+  mapping = "Default"
+
+[action_mapping]
+  KEY_ESC = "panic"
+  KEY_A = "octave_up"
+
+[[mapping]]
+  name = "Default"
+  [[mapping.keys]]
+    subhandler = ""
+    [mapping.keys.map]
+      KEY_A = "0"
+      KEY_B = "100"
+  [[mapping.analog]]
+    subhandler = ""
+    default_deadzone = 1.5
+    [mapping.analog.deadzones]
+      ABS_X = -0.1
+
+[[mapping]]
+  name = "Other"
+`
+
+func writeConfig(t *testing.T, path, data string) string {
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(data), 0644))
+	return path
+}
+
+func TestLint(t *testing.T) {
+	file := writeConfig(t, filepath.Join(t.TempDir(), "lint.toml"), lintConfigData)
+
+	assert.Equal(t, []Problem{
+		{File: file, Line: 10, Error: true, Message: "default channel outside of 1-16 range: 0"},
+		{File: file, Line: 22, Message: `[Default] KEY_A: key bound to a note and to "octave_up" action, action takes precedence`},
+		{File: file, Line: 23, Message: "[Default] KEY_B: note outside of 0-127 range with default octave and semitone: 160"},
+		{File: file, Line: 26, Error: true, Message: "[Default] default deadzone outside of 0.0-1.0 range: 1.5"},
+		{File: file, Line: 28, Error: true, Message: "[Default] ABS_X: deadzone outside of 0.0-1.0 range: -0.1"},
+		{File: file, Line: 31, Message: "[Other] mapping is unreachable, mapping_up/mapping_down action is not bound"},
+	}, Lint([]string{file}))
+}
+
+func TestLintParseErrors(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, tc := range []struct {
+		data     string
+		expected []Problem
+	}{
+		{
+			data: "collision_mode = \"off\"\n[identifier]\n[defaults]\n  chanel = 1\n  mapping = \"Default\"\n[[mapping]]\n  name = \"Default\"\n",
+			expected: []Problem{
+				{Line: 4, Error: true, Message: "unknown field: defaults.chanel"},
+			},
+		}, {
+			data: "collision_mode = \"off\"\n[identifier]\n[defaults]\n  mapping = \"Default\"\n" +
+				"[[mapping]]\n  name = \"Other\"\n[[mapping]]\n  name = \"Default\"\n  [[mapping.keys]]\n    subhandler = \"\"\n" +
+				"    [mapping.keys.map]\n      KEY_A = \"1\"\n      KEY_B = \"200\"\n",
+			expected: []Problem{
+				{Line: 13, Error: true, Message: "[Default] KEY_B: note value outside of 0-127 range: 200"},
+			},
+		},
+	} {
+		file := writeConfig(t, filepath.Join(dir, "broken.toml"), tc.data)
+		for i := range tc.expected {
+			tc.expected[i].File = file
+		}
+		assert.Equal(t, tc.expected, Lint([]string{file}))
+	}
+}
+
+func TestLintDuplicatedIdentifiers(t *testing.T) {
+	dir := t.TempDir()
+	data := "collision_mode = \"off\"\n\n[identifier]\n  vendor = 0x01\n[defaults]\n  channel = 1\n  mapping = \"Default\"\n[[mapping]]\n  name = \"Default\"\n"
+
+	first := writeConfig(t, filepath.Join(dir, "user", "first.toml"), data)
+	second := writeConfig(t, filepath.Join(dir, "user", "second.toml"), data)
+	factory := writeConfig(t, filepath.Join(dir, "factory", "first.toml"), data)
+
+	assert.Equal(t, []Problem{
+		{File: second, Line: 3, Error: true, Message: "identifier already used by " + first + ":3, only one of them is loaded"},
+	}, Lint([]string{first, factory, second}))
+}
+
+func TestLintDuplicatedIdentifiersSubdirectories(t *testing.T) {
+	root := t.TempDir()
+	data := "collision_mode = \"off\"\n\n[identifier]\n  vendor = 0x01\n[defaults]\n  channel = 1\n  mapping = \"Default\"\n[[mapping]]\n  name = \"Default\"\n"
+
+	// config directories are loaded recursively into a single config map
+	first := writeConfig(t, filepath.Join(root, "user", "keyboard", "first.toml"), data)
+	nested := writeConfig(t, filepath.Join(root, "user", "keyboard", "vendor", "nested.toml"), data)
+	gamepad := writeConfig(t, filepath.Join(root, "user", "gamepad", "first.toml"), data)
+
+	assert.Equal(t, []Problem{
+		{File: nested, Line: 3, Error: true, Message: "identifier already used by " + first + ":3, only one of them is loaded"},
+	}, lint(root, []string{first, gamepad, nested}))
+}
+
+func TestContainsWord(t *testing.T) {
+	assert.True(t, containsWord("KEY_A: unknown", "KEY_A"))
+	assert.True(t, containsWord("note \"c#3\" of KEY_B", "c#3"))
+	assert.True(t, containsWord("KEY_AB and KEY_A", "KEY_A"))
+	assert.False(t, containsWord("KEY_AB", "KEY_A"))
+	assert.False(t, containsWord("c#3 and c#-1", "c#"))
+	assert.False(t, containsWord("", "KEY_A"))
+}
+
+func TestLintFactoryConfigs(t *testing.T) {
+	files, err := filepath.Glob("../../../../../cmd/hidi/hidi-config/factory/*/*.toml")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, files)
+
+	for _, p := range Lint(files) {
+		assert.False(t, p.Error, p.String())
+	}
+}
+
+func TestProblemString(t *testing.T) {
+	assert.Equal(t, "a.toml:3: error: broken", Problem{File: "a.toml", Line: 3, Error: true, Message: "broken"}.String())
+	assert.Equal(t, "a.toml: warning: odd", Problem{File: "a.toml", Message: "odd"}.String())
+}
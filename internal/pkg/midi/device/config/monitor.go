@@ -3,6 +3,7 @@ package config
 import (
 	"context"
 	"fmt"
+	"path"
 	"strings"
 
 	"github.com/fsnotify/fsnotify"
@@ -51,3 +52,44 @@ func DetectDeviceConfigChanges(ctx context.Context) <-chan bool {
 
 	return change
 }
+
+// DetectThemeChanges notifies about themes file changes, unlike device configs
+// themes are applied on running devices without reloading them
+func DetectThemeChanges(ctx context.Context) <-chan bool {
+	var change = make(chan bool)
+
+	go func() {
+		defer close(change)
+		watcher, err := fsnotify.NewWatcher()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			<-ctx.Done()
+			err := watcher.Close()
+			if err != nil {
+				log.Info(fmt.Sprintf("closing watched failed: %v", err), logger.Debug)
+			}
+		}()
+
+		err = watcher.Add(path.Dir(themesFile))
+		if err != nil {
+			log.Info(fmt.Sprintf("watching themes failed: %v", err), logger.Warning)
+			return
+		}
+
+		for event := range watcher.Events {
+			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			if path.Base(event.Name) != path.Base(themesFile) {
+				continue
+			}
+			log.Info(fmt.Sprintf("themes change detected: %s", event.Name), logger.Info)
+			change <- true
+		}
+	}()
+
+	return change
+}
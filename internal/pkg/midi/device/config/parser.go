@@ -6,8 +6,10 @@ import (
 	"io"
 	"os"
 	path2 "path"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gethiox/HIDI/internal/pkg/input"
 	"github.com/holoplot/go-evdev"
@@ -38,17 +40,28 @@ type TOMLDeviceConfig struct {
 	ActionMapping map[string]string `toml:"action_mapping"`
 
 	OpenRGB struct {
-		White          int `toml:"white"`
-		Black          int `toml:"black"`
-		C              int `toml:"c"`
-		Unavailable    int `toml:"unavailable"`
-		Other          int `toml:"other"`
-		Active         int `toml:"active"`
-		ActiveExternal int `toml:"active_external"`
+		TOMLTheme
+		Theme string `toml:"theme,omitempty"`
+		FPS   int    `toml:"fps,omitempty"`
+
+		Meter      string `toml:"meter,omitempty"`
+		MeterAxis  string `toml:"meter_axis,omitempty"`
+		MeterColor *int   `toml:"meter_color,omitempty"`
+
+		Controllers []struct {
+			Name string `toml:"name"`
+			Zone string `toml:"zone,omitempty"`
+			Mode string `toml:"mode"`
+		} `toml:"controller,omitempty"`
+
+		Keys  map[string]interface{} `toml:"keys,omitempty"`  // LED name or index
+		Strip []interface{}          `toml:"strip,omitempty"` // LED names or indexes
 	} `toml:"open_rgb"`
 
 	KeyMappings []struct {
-		Name       string `toml:"name"`
+		Name       string         `toml:"name"`
+		Theme      string         `toml:"theme,omitempty"`
+		Generator  *TOMLGenerator `toml:"generator,omitempty"`
 		KeyMapping []struct {
 			SubHandler string            `toml:"subhandler"`
 			Map        map[string]string `toml:"map"`
@@ -57,25 +70,116 @@ type TOMLDeviceConfig struct {
 			SubHandler      string  `toml:"subhandler"`
 			DefaultDeadzone float64 `toml:"default_deadzone,omitempty"`
 			Map             map[string]struct {
-				Type                  string  `toml:"type"`
-				CC                    *int    `toml:"cc,omitempty"`
-				CCNegative            *int    `toml:"cc_negative,omitempty"`
-				Note                  *int    `toml:"note,omitempty"`
-				NoteNegative          *int    `toml:"note_negative,omitempty"`
-				ChannelOffset         int     `toml:"channel_offset"`
-				ChannelOffsetNegative int     `toml:"channel_offset_negative"`
-				Action                *string `toml:"action,omitempty"`
-				ActionNegative        *string `toml:"action_negative,omitempty"`
-				FlipAxis              bool    `toml:"flip_axis"`
-				DeadzoneAtCenter      bool    `toml:"deadzone_at_center,omitempty"`
+				Type                  string   `toml:"type"`
+				CC                    *int     `toml:"cc,omitempty"`
+				CCNegative            *int     `toml:"cc_negative,omitempty"`
+				Note                  *int     `toml:"note,omitempty"`
+				NoteNegative          *int     `toml:"note_negative,omitempty"`
+				NRPN                  *int     `toml:"nrpn,omitempty"`
+				Target                string   `toml:"target,omitempty"`
+				Motion                string   `toml:"motion,omitempty"`
+				Effect                string   `toml:"effect,omitempty"`
+				Output                string   `toml:"output,omitempty"`
+				Sensitivity           *float64 `toml:"sensitivity,omitempty"`
+				ChannelOffset         int      `toml:"channel_offset"`
+				ChannelOffsetNegative int      `toml:"channel_offset_negative"`
+				Action                *string  `toml:"action,omitempty"`
+				ActionNegative        *string  `toml:"action_negative,omitempty"`
+				FlipAxis              bool     `toml:"flip_axis"`
+				DeadzoneAtCenter      bool     `toml:"deadzone_at_center,omitempty"`
+				InvertRange           bool     `toml:"invert_range,omitempty"`
+				TOMLAnalogResponse
 			} `toml:"map"`
 			Deadzones map[string]float64 `toml:"deadzones,omitempty"`
 		} `toml:"analog,omitempty"`
 	} `toml:"mapping"`
+
+	Calibration []struct {
+		SubHandler string                     `toml:"subhandler"`
+		Axes       map[string]TOMLCalibration `toml:"axes"`
+	} `toml:"calibration,omitempty"`
+
+	Rumble []TOMLRumble `toml:"rumble,omitempty"`
+
+	MidiIn []TOMLMidiInRule `toml:"midi_in,omitempty"`
+
+	LEDIndicators map[string]string `toml:"led_indicators,omitempty"`
+
+	Lightbar TOMLLightbar `toml:"lightbar,omitempty"`
+
+	Sequencer TOMLSequencer `toml:"sequencer,omitempty"`
+
+	ChordTypes map[string][]int `toml:"chord_types,omitempty"` // user-defined chord types
+	Chords     []TOMLChord      `toml:"chord,omitempty"`
+
+	NoteEffects TOMLNoteEffects `toml:"note_effects,omitempty"`
+}
+
+type TOMLGenerator struct {
+	Layout         string `toml:"layout"`
+	SubHandler     string `toml:"subhandler,omitempty"`
+	Origin         string `toml:"origin,omitempty"`
+	Start          string `toml:"start,omitempty"`
+	ColumnInterval *int   `toml:"column_interval,omitempty"`
+	RowInterval    *int   `toml:"row_interval,omitempty"`
+}
+
+type TOMLLightbar struct {
+	Mode       string   `toml:"mode"`
+	Color      *int     `toml:"color,omitempty"`
+	Brightness *float64 `toml:"brightness,omitempty"`
+	PanicFlash bool     `toml:"panic_flash,omitempty"`
+	NotePulse  bool     `toml:"note_pulse,omitempty"`
+}
+
+type TOMLSequencer struct {
+	Clock  string   `toml:"clock,omitempty"`
+	Length int      `toml:"length,omitempty"`
+	Swing  *float64 `toml:"swing,omitempty"`
+	Gate   *float64 `toml:"gate,omitempty"`
+}
+
+type TOMLMidiInRule struct {
+	Message string `toml:"message"`
+	Channel int    `toml:"channel,omitempty"` // 1-16, any channel when not set
+	Note    string `toml:"note,omitempty"`
+	CC      *int   `toml:"cc,omitempty"`
+	Action  string `toml:"action"`
+}
+
+type TOMLRumble struct {
+	Trigger  string  `toml:"trigger"`
+	Strong   float64 `toml:"strong"`
+	Weak     float64 `toml:"weak"`
+	Duration int     `toml:"duration"` // milliseconds
+	Every    int     `toml:"every,omitempty"`
+	Note     string  `toml:"note,omitempty"`
+	CC       *int    `toml:"cc,omitempty"`
+	Channel  int     `toml:"channel,omitempty"`
+	Scale    bool    `toml:"scale,omitempty"`
+}
+
+type TOMLCalibration struct {
+	Min    *int32 `toml:"min,omitempty"`
+	Max    *int32 `toml:"max,omitempty"`
+	Center *int32 `toml:"center,omitempty"`
+}
+
+// TOMLAnalogResponse contains analog value shaping options common for all analog mapping types
+type TOMLAnalogResponse struct {
+	Curve           string      `toml:"curve,omitempty"`
+	CurveFactor     *float64    `toml:"curve_factor,omitempty"`
+	CurvePoints     [][]float64 `toml:"curve_points,omitempty"`
+	Smoothing       string      `toml:"smoothing,omitempty"`
+	SmoothingFactor *float64    `toml:"smoothing_factor,omitempty"`
+	MinCutoff       *float64    `toml:"min_cutoff,omitempty"`
+	Beta            *float64    `toml:"beta,omitempty"`
+	TOMLCalibration
 }
 
 type DeviceConfig struct {
 	ConfigFile string
+	ConfigPath string
 	ConfigType string // factory or user
 	Config     Config
 }
@@ -112,6 +216,18 @@ func ParseData(data []byte) (Config, error) {
 	var keyMapping []KeyMapping
 	var actionMapping = make(map[evdev.EvCode]Action)
 
+	for evcodeRaw, actionRaw := range cfg.ActionMapping {
+		evcode, err := TomlKeyToEvCode(evcodeRaw, evdev.KEYFromString)
+		if err != nil {
+			return Config{}, fmt.Errorf("[actions] %w", err)
+		}
+		action := Action(actionRaw)
+		if !SupportedActions[action] {
+			return Config{}, fmt.Errorf("[actions] unsupported action: %s", action)
+		}
+		actionMapping[evcode] = action
+	}
+
 	for _, mapping := range cfg.KeyMappings {
 		name := mapping.Name
 		var midiMapping = make(map[string]map[evdev.EvCode]Key)
@@ -168,6 +284,25 @@ func ParseData(data []byte) (Config, error) {
 			}
 		}
 
+		var generator *Generator
+		if mapping.Generator != nil {
+			g, err := parseGenerator(*mapping.Generator)
+			if err != nil {
+				return Config{}, fmt.Errorf("[%s] generator: %w", name, err)
+			}
+			generator = &g
+
+			// keys bound to actions are left for actions, hand written notes take precedence over generated ones
+			notes := g.Notes()
+			for evcode := range actionMapping {
+				delete(notes, evcode)
+			}
+			for evcode, key := range midiMapping[g.SubHandler] {
+				notes[evcode] = key
+			}
+			midiMapping[g.SubHandler] = notes
+		}
+
 		var analogMapping = make(map[string]map[evdev.EvCode]Analog)
 		var deadzones = make(map[string]map[evdev.EvCode]float64)
 		var defaultDeadzone = make(map[string]float64)
@@ -220,6 +355,101 @@ func ParseData(data []byte) (Config, error) {
 						Bidirectional:    bidirectional,
 						DeadzoneAtCenter: analog.DeadzoneAtCenter,
 					}
+				case AnalogCC14:
+					if analog.CC == nil {
+						return Config{}, fmt.Errorf("[%s] %s: cc value not set", name, evcodeRaw)
+					}
+
+					// CC n+32 carries LSB of 14-bit value
+					if *analog.CC < 0 || *analog.CC > 31 {
+						return Config{}, fmt.Errorf("[%s] %s: cc value outside of 0-31 range: %d", name, evcodeRaw, *analog.CC)
+					}
+
+					if analog.CCNegative != nil {
+						return Config{}, fmt.Errorf("[%s] %s: cc_negative is not supported by %s type", name, evcodeRaw, mappingType)
+					}
+
+					analogMappingTmp[evcode] = Analog{
+						MappingType:      mappingType,
+						CC:               byte(*analog.CC),
+						ChannelOffset:    byte(analog.ChannelOffset),
+						FlipAxis:         analog.FlipAxis,
+						DeadzoneAtCenter: analog.DeadzoneAtCenter,
+					}
+				case AnalogNRPN:
+					if analog.NRPN == nil {
+						return Config{}, fmt.Errorf("[%s] %s: nrpn value not set", name, evcodeRaw)
+					}
+
+					if *analog.NRPN < 0 || *analog.NRPN > 16383 {
+						return Config{}, fmt.Errorf("[%s] %s: nrpn value outside of 0-16383 range: %d", name, evcodeRaw, *analog.NRPN)
+					}
+
+					analogMappingTmp[evcode] = Analog{
+						MappingType:      mappingType,
+						NRPN:             uint16(*analog.NRPN),
+						ChannelOffset:    byte(analog.ChannelOffset),
+						FlipAxis:         analog.FlipAxis,
+						DeadzoneAtCenter: analog.DeadzoneAtCenter,
+					}
+				case AnalogChannelPressure:
+					analogMappingTmp[evcode] = Analog{
+						MappingType:      mappingType,
+						ChannelOffset:    byte(analog.ChannelOffset),
+						FlipAxis:         analog.FlipAxis,
+						DeadzoneAtCenter: analog.DeadzoneAtCenter,
+					}
+				case AnalogPolyPressure:
+					target := PressureAll
+					if analog.Target != "" {
+						target = PressureTarget(analog.Target)
+					}
+					if !SupportedPressureTargets[target] {
+						return Config{}, fmt.Errorf("[%s] %s: pressure target not supported: %s", name, evcodeRaw, analog.Target)
+					}
+
+					analogMappingTmp[evcode] = Analog{
+						MappingType:      mappingType,
+						PressureTarget:   target,
+						FlipAxis:         analog.FlipAxis,
+						DeadzoneAtCenter: analog.DeadzoneAtCenter,
+					}
+				case AnalogMotion:
+					motion, err := parseMotion(evcode, analog.Motion, analog.Output, analog.Sensitivity)
+					if err != nil {
+						return Config{}, fmt.Errorf("[%s] %s: %w", name, evcodeRaw, err)
+					}
+
+					var CC byte
+					if motion.Output == AnalogCC {
+						if analog.CC == nil {
+							return Config{}, fmt.Errorf("[%s] %s: cc value not set", name, evcodeRaw)
+						}
+						if *analog.CC < 0 || *analog.CC > 119 {
+							return Config{}, fmt.Errorf("[%s] %s: cc value outside of 0-119 range: %d", name, evcodeRaw, *analog.CC)
+						}
+						CC = byte(*analog.CC)
+					}
+
+					analogMappingTmp[evcode] = Analog{
+						MappingType:   mappingType,
+						CC:            CC,
+						ChannelOffset: byte(analog.ChannelOffset),
+						FlipAxis:      analog.FlipAxis,
+						Motion:        motion,
+					}
+				case AnalogEffect:
+					effect := EffectParameter(analog.Effect)
+					if !SupportedEffectParameters[effect] {
+						return Config{}, fmt.Errorf("[%s] %s: effect parameter not supported: %s", name, evcodeRaw, analog.Effect)
+					}
+
+					analogMappingTmp[evcode] = Analog{
+						MappingType:      mappingType,
+						Effect:           effect,
+						FlipAxis:         analog.FlipAxis,
+						DeadzoneAtCenter: analog.DeadzoneAtCenter,
+					}
 				case AnalogPitchBend:
 					analogMappingTmp[evcode] = Analog{
 						MappingType:      mappingType,
@@ -291,6 +521,17 @@ func ParseData(data []byte) (Config, error) {
 				default:
 					return Config{}, fmt.Errorf("[%s] %s: unexpected mapping type: %s", name, evcodeRaw, mappingType)
 				}
+
+				curve, smoothing, calibration, err := parseAnalogResponse(analog.TOMLAnalogResponse)
+				if err != nil {
+					return Config{}, fmt.Errorf("[%s] %s: %w", name, evcodeRaw, err)
+				}
+				a := analogMappingTmp[evcode]
+				a.InvertRange = analog.InvertRange
+				a.Curve = curve
+				a.Smoothing = smoothing
+				a.Calibration = calibration
+				analogMappingTmp[evcode] = a
 			}
 
 			for evcodeRaw, value := range subMapping.Deadzones {
@@ -308,6 +549,8 @@ func ParseData(data []byte) (Config, error) {
 
 		keyMapping = append(keyMapping, KeyMapping{
 			Name:            name,
+			Theme:           mapping.Theme,
+			Generator:       generator,
 			Midi:            midiMapping,
 			Analog:          analogMapping,
 			Deadzones:       deadzones,
@@ -316,16 +559,70 @@ func ParseData(data []byte) (Config, error) {
 
 	}
 
-	for evcodeRaw, actionRaw := range cfg.ActionMapping {
-		evcode, err := TomlKeyToEvCode(evcodeRaw, evdev.KEYFromString)
+	var calibration = make(map[string]map[evdev.EvCode]Calibration)
+	for _, subCalibration := range cfg.Calibration {
+		calibrationTmp, ok := calibration[subCalibration.SubHandler]
+		if !ok {
+			calibrationTmp = make(map[evdev.EvCode]Calibration)
+			calibration[subCalibration.SubHandler] = calibrationTmp
+		}
+		for evcodeRaw, axis := range subCalibration.Axes {
+			evcode, err := TomlKeyToEvCode(evcodeRaw, evdev.ABSFromString)
+			if err != nil {
+				return Config{}, fmt.Errorf("[calibration] %w", err)
+			}
+			c, err := parseCalibration(axis)
+			if err != nil {
+				return Config{}, fmt.Errorf("[calibration] %s: %w", evcodeRaw, err)
+			}
+			calibrationTmp[evcode] = c
+		}
+	}
+
+	var rumble []Rumble
+	for i, r := range cfg.Rumble {
+		parsed, err := parseRumble(r)
 		if err != nil {
-			return Config{}, fmt.Errorf("[actions] %w", err)
+			return Config{}, fmt.Errorf("[rumble %d] %w", i, err)
 		}
-		action := Action(actionRaw)
-		if !SupportedActions[action] {
-			return Config{}, fmt.Errorf("[actions] unsupported action: %s", action)
+		rumble = append(rumble, parsed)
+	}
+
+	midiIn, err := ParseMidiInRules(cfg.MidiIn)
+	if err != nil {
+		return Config{}, err
+	}
+
+	var ledIndicators = make(map[evdev.EvCode]LEDIndicator)
+	for ledString, indicator := range cfg.LEDIndicators {
+		evcode, ok := evdev.LEDFromString[ledString]
+		if !ok {
+			return Config{}, fmt.Errorf("[led_indicators] unknown led: %s", ledString)
 		}
-		actionMapping[evcode] = action
+		if !SupportedLEDIndicators[LEDIndicator(indicator)] {
+			return Config{}, fmt.Errorf("[led_indicators] %s: unsupported indicator: %s", ledString, indicator)
+		}
+		ledIndicators[evcode] = LEDIndicator(indicator)
+	}
+
+	lightbar, err := parseLightbar(cfg.Lightbar)
+	if err != nil {
+		return Config{}, fmt.Errorf("[lightbar] %w", err)
+	}
+
+	sequencer, err := parseSequencer(cfg.Sequencer)
+	if err != nil {
+		return Config{}, fmt.Errorf("[sequencer] %w", err)
+	}
+
+	noteEffects, err := parseNoteEffects(cfg.NoteEffects)
+	if err != nil {
+		return Config{}, fmt.Errorf("[note_effects] %w", err)
+	}
+
+	chordKeys, err := parseChords(cfg.ChordTypes, cfg.Chords, actionMapping, keyMapping)
+	if err != nil {
+		return Config{}, err
 	}
 
 	collisionMode := CollisionMode(cfg.CollisionMode)
@@ -360,12 +657,76 @@ func ParseData(data []byte) (Config, error) {
 		velocity = 64
 	}
 
-	convertToColor := func(v int) openrgb.Color {
-		return openrgb.Color{
-			Red:   byte(v >> 16),
-			Green: byte(v >> 8),
-			Blue:  byte(v),
+	var openrgbKeys map[evdev.EvCode]OpenRGBLED
+	for evcodeRaw, v := range cfg.OpenRGB.Keys {
+		evcode, err := TomlKeyToEvCode(evcodeRaw, evdev.KEYFromString)
+		if err != nil {
+			return Config{}, fmt.Errorf("[open_rgb.keys] %w", err)
+		}
+		led, err := parseOpenRGBLED(v, true)
+		if err != nil {
+			return Config{}, fmt.Errorf("[open_rgb.keys] %s: %w", evcodeRaw, err)
+		}
+		if openrgbKeys == nil {
+			openrgbKeys = make(map[evdev.EvCode]OpenRGBLED)
+		}
+		openrgbKeys[evcode] = led
+	}
+
+	var openrgbStrip []OpenRGBLED
+	for i, v := range cfg.OpenRGB.Strip {
+		led, err := parseOpenRGBLED(v, false)
+		if err != nil {
+			return Config{}, fmt.Errorf("[open_rgb.strip] LED %d: %w", i, err)
 		}
+		openrgbStrip = append(openrgbStrip, led)
+	}
+
+	var controllers []OpenRGBController
+	for i, c := range cfg.OpenRGB.Controllers {
+		if c.Name == "" {
+			return Config{}, fmt.Errorf("[open_rgb.controller %d] name not set", i)
+		}
+		mode := ControllerMode(c.Mode)
+		if !SupportedControllerModes[mode] {
+			return Config{}, fmt.Errorf("[open_rgb.controller %d] unsupported mode: %s", i, c.Mode)
+		}
+		controllers = append(controllers, OpenRGBController{Name: c.Name, Zone: c.Zone, Mode: mode})
+	}
+
+	theme, err := parseTheme(cfg.OpenRGB.TOMLTheme)
+	if err != nil {
+		return Config{}, fmt.Errorf("[open_rgb] %w", err)
+	}
+
+	fps := cfg.OpenRGB.FPS
+	if fps < 0 || fps > 1000 {
+		return Config{}, fmt.Errorf("[open_rgb] fps \"%d\" not in 1-1000 range", fps)
+	}
+	if fps == 0 {
+		fps = 100
+	}
+
+	var meter StripMeter
+	if cfg.OpenRGB.Meter != "" {
+		meter.Type = StripMeterType(cfg.OpenRGB.Meter)
+		if !SupportedStripMeters[meter.Type] {
+			return Config{}, fmt.Errorf("[open_rgb] unsupported meter: %s", cfg.OpenRGB.Meter)
+		}
+		meter.Color = openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff}
+		if cfg.OpenRGB.MeterColor != nil {
+			meter.Color = convertToColor(*cfg.OpenRGB.MeterColor)
+		}
+	}
+	if cfg.OpenRGB.MeterAxis != "" {
+		if meter.Type != MeterAnalog {
+			return Config{}, fmt.Errorf("[open_rgb] meter_axis is supported by analog meter only")
+		}
+		evcode, err := TomlKeyToEvCode(cfg.OpenRGB.MeterAxis, evdev.ABSFromString)
+		if err != nil {
+			return Config{}, fmt.Errorf("[open_rgb] meter_axis: %w", err)
+		}
+		meter.Axis = &evcode
 	}
 
 	devConfig := Config{
@@ -388,20 +749,393 @@ func ParseData(data []byte) (Config, error) {
 			Velocity: velocity,
 		},
 		OpenRGB: OpenRGB{
-			Colors: Colors{
-				White:          convertToColor(cfg.OpenRGB.White),
-				Black:          convertToColor(cfg.OpenRGB.Black),
-				C:              convertToColor(cfg.OpenRGB.C),
-				Unavailable:    convertToColor(cfg.OpenRGB.Unavailable),
-				Other:          convertToColor(cfg.OpenRGB.Other),
-				Active:         convertToColor(cfg.OpenRGB.Active),
-				ActiveExternal: convertToColor(cfg.OpenRGB.ActiveExternal),
-			},
+			Colors:      theme.Colors,
+			Effects:     theme.Effects,
+			Theme:       cfg.OpenRGB.Theme,
+			FPS:         fps,
+			Meter:       meter,
+			Controllers: controllers,
+			Keys:        openrgbKeys,
+			Strip:       openrgbStrip,
 		},
+		Calibration:   calibration,
+		Rumble:        rumble,
+		MidiIn:        midiIn,
+		LEDIndicators: ledIndicators,
+		Lightbar:      lightbar,
+		Sequencer:     sequencer,
+		ChordKeys:     chordKeys,
+		NoteEffects:   noteEffects,
 	}
 	return devConfig, nil
 }
 
+// parseMotion validates motion mapping options, motion source is guessed by the axis when not defined,
+// motion sensors report accelerometer on X/Y/Z axes and gyroscope on RX/RY/RZ
+func parseMotion(evcode evdev.EvCode, source, output string, sensitivity *float64) (Motion, error) {
+	motion := Motion{
+		Source:      MotionSource(source),
+		Output:      MappingType(output),
+		Sensitivity: 1.0,
+	}
+
+	if motion.Source == "" {
+		switch evcode {
+		case evdev.ABS_RX, evdev.ABS_RY, evdev.ABS_RZ:
+			motion.Source = MotionRotation
+		default:
+			motion.Source = MotionTilt
+		}
+	}
+	if !SupportedMotionSources[motion.Source] {
+		return Motion{}, fmt.Errorf("motion source not supported: %s", source)
+	}
+
+	if motion.Output == "" {
+		motion.Output = AnalogCC
+	}
+	if motion.Output != AnalogCC && motion.Output != AnalogPitchBend {
+		return Motion{}, fmt.Errorf("motion output not supported: %s", output)
+	}
+
+	if sensitivity != nil {
+		if *sensitivity <= 0 {
+			return Motion{}, fmt.Errorf("sensitivity has to be greater than 0.0: %f", *sensitivity)
+		}
+		motion.Sensitivity = *sensitivity
+	}
+
+	return motion, nil
+}
+
+// parseOpenRGBLED parses LED defined by its name or index,
+// empty name stands for no LED if allowUnset is true
+func parseOpenRGBLED(v interface{}, allowUnset bool) (OpenRGBLED, error) {
+	switch v := v.(type) {
+	case string:
+		if v == "" {
+			if !allowUnset {
+				return OpenRGBLED{}, fmt.Errorf("empty LED name")
+			}
+			return OpenRGBLED{Index: -1}, nil
+		}
+		return OpenRGBLED{Name: v}, nil
+	case int64:
+		if v < 0 {
+			return OpenRGBLED{}, fmt.Errorf("negative LED index: %d", v)
+		}
+		return OpenRGBLED{Index: int(v)}, nil
+	default:
+		return OpenRGBLED{}, fmt.Errorf("expected LED name or index, got: %v", v)
+	}
+}
+
+func parseLightbar(l TOMLLightbar) (Lightbar, error) {
+	if l.Mode == "" {
+		if l != (TOMLLightbar{}) {
+			return Lightbar{}, fmt.Errorf("mode has to be defined")
+		}
+		return Lightbar{}, nil
+	}
+
+	lightbar := Lightbar{
+		Mode:       LightbarMode(l.Mode),
+		Brightness: 1.0,
+		PanicFlash: l.PanicFlash,
+		NotePulse:  l.NotePulse,
+	}
+
+	if !SupportedLightbarModes[lightbar.Mode] {
+		return Lightbar{}, fmt.Errorf("unsupported mode: %s", l.Mode)
+	}
+
+	switch {
+	case lightbar.Mode == LightbarStatic && l.Color == nil:
+		return Lightbar{}, fmt.Errorf("color has to be defined for static mode")
+	case lightbar.Mode != LightbarStatic && l.Color != nil:
+		return Lightbar{}, fmt.Errorf("color is supported by static mode only")
+	case l.Color != nil:
+		lightbar.Color = convertToColor(*l.Color)
+	}
+
+	if l.Brightness != nil {
+		if *l.Brightness < 0 || *l.Brightness > 1 {
+			return Lightbar{}, fmt.Errorf("brightness outside of 0.0-1.0 range: %g", *l.Brightness)
+		}
+		lightbar.Brightness = *l.Brightness
+	}
+
+	return lightbar, nil
+}
+
+func parseRumble(r TOMLRumble) (Rumble, error) {
+	rumble := Rumble{
+		Trigger:  RumbleTrigger(r.Trigger),
+		Strong:   r.Strong,
+		Weak:     r.Weak,
+		Duration: time.Duration(r.Duration) * time.Millisecond,
+		Every:    r.Every,
+		Note:     -1,
+		CC:       -1,
+		Channel:  r.Channel - 1,
+		Scale:    r.Scale,
+	}
+
+	if !SupportedRumbleTriggers[rumble.Trigger] {
+		return Rumble{}, fmt.Errorf("trigger not supported: %s", r.Trigger)
+	}
+	if r.Strong < 0 || r.Strong > 1 || r.Weak < 0 || r.Weak > 1 {
+		return Rumble{}, fmt.Errorf("strong/weak magnitude outside of 0.0-1.0 range")
+	}
+	if r.Strong == 0 && r.Weak == 0 {
+		return Rumble{}, fmt.Errorf("strong or weak magnitude has to be defined")
+	}
+	if r.Duration <= 0 || r.Duration > 0xffff {
+		return Rumble{}, fmt.Errorf("duration outside of 1-65535 range: %d", r.Duration)
+	}
+	if r.Channel < 0 || r.Channel > 16 {
+		return Rumble{}, fmt.Errorf("channel outside of 1-16 range: %d", r.Channel)
+	}
+
+	switch rumble.Trigger {
+	case RumbleClock:
+		if rumble.Every == 0 {
+			rumble.Every = 24 // quarter note
+		}
+		if rumble.Every < 0 {
+			return Rumble{}, fmt.Errorf("every has to be greater than 0: %d", r.Every)
+		}
+	case RumbleMidiNote:
+		if r.Note != "" {
+			note, err := parseNote(r.Note)
+			if err != nil {
+				return Rumble{}, err
+			}
+			rumble.Note = note
+		}
+	case RumbleMidiCC:
+		if r.CC != nil {
+			if *r.CC < 0 || *r.CC > 127 {
+				return Rumble{}, fmt.Errorf("cc value outside of 0-127 range: %d", *r.CC)
+			}
+			rumble.CC = *r.CC
+		}
+	}
+
+	return rumble, nil
+}
+
+// parseNote parses note given as a number or in string representation
+func parseNote(raw string) (int, error) {
+	note, err := strconv.Atoi(raw)
+	if err != nil {
+		n, err := StringToNote(raw)
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse note: %w", err)
+		}
+		note = int(n)
+	}
+	if note < 0 || note > 127 {
+		return 0, fmt.Errorf("note value outside of 0-127 range: %d", note)
+	}
+	return note, nil
+}
+
+func parseSequencer(s TOMLSequencer) (Sequencer, error) {
+	sequencer := Sequencer{
+		Clock:  SequencerClock(s.Clock),
+		Length: 16,
+		Swing:  50,
+		Gate:   0.5,
+	}
+	if sequencer.Clock != "" && !SupportedSequencerClocks[sequencer.Clock] {
+		return Sequencer{}, fmt.Errorf("clock not supported: %s", s.Clock)
+	}
+	if s.Length != 0 {
+		sequencer.Length = s.Length
+	}
+	if sequencer.Length < 1 || sequencer.Length > SequencerMaxSteps {
+		return Sequencer{}, fmt.Errorf("length outside of 1-%d range: %d", SequencerMaxSteps, s.Length)
+	}
+	if s.Swing != nil {
+		sequencer.Swing = *s.Swing
+	}
+	if sequencer.Swing < 50 || sequencer.Swing > 75 {
+		return Sequencer{}, fmt.Errorf("swing outside of 50-75 range: %g", sequencer.Swing)
+	}
+	if s.Gate != nil {
+		sequencer.Gate = *s.Gate
+	}
+	if sequencer.Gate <= 0 || sequencer.Gate > 1 {
+		return Sequencer{}, fmt.Errorf("gate outside of 0.0-1.0 range: %g", sequencer.Gate)
+	}
+	return sequencer, nil
+}
+
+// ParseMidiInRules validates midi input rules, they are defined per device and in hidi.toml as well
+func ParseMidiInRules(rules []TOMLMidiInRule) ([]MidiInRule, error) {
+	var parsed []MidiInRule
+	for i, r := range rules {
+		rule, err := parseMidiInRule(r)
+		if err != nil {
+			return nil, fmt.Errorf("[midi_in %d] %w", i, err)
+		}
+		parsed = append(parsed, rule)
+	}
+	return parsed, nil
+}
+
+func parseMidiInRule(r TOMLMidiInRule) (MidiInRule, error) {
+	rule := MidiInRule{
+		Message: MidiInMessage(r.Message),
+		Channel: r.Channel - 1,
+		Number:  -1,
+		Action:  Action(r.Action),
+	}
+
+	if !SupportedMidiInMessages[rule.Message] {
+		return MidiInRule{}, fmt.Errorf("message not supported: %s", r.Message)
+	}
+	if !SupportedActions[rule.Action] {
+		return MidiInRule{}, fmt.Errorf("unsupported action: %s", r.Action)
+	}
+	if r.Channel < 0 || r.Channel > 16 {
+		return MidiInRule{}, fmt.Errorf("channel outside of 1-16 range: %d", r.Channel)
+	}
+
+	switch rule.Message {
+	case MidiInNote, MidiInCC, MidiInProgramChange:
+	default:
+		if r.Channel != 0 {
+			return MidiInRule{}, fmt.Errorf("%s message has no channel", rule.Message)
+		}
+	}
+	if r.Note != "" && rule.Message != MidiInNote {
+		return MidiInRule{}, fmt.Errorf("note is supported by note message only")
+	}
+	if r.CC != nil && rule.Message != MidiInCC {
+		return MidiInRule{}, fmt.Errorf("cc is supported by cc message only")
+	}
+	if MidiInValueActions[rule.Action] && rule.Message != MidiInCC && rule.Message != MidiInProgramChange {
+		return MidiInRule{}, fmt.Errorf("%s action requires cc or program_change message", rule.Action)
+	}
+
+	if r.Note != "" {
+		note, err := parseNote(r.Note)
+		if err != nil {
+			return MidiInRule{}, err
+		}
+		rule.Number = note
+	}
+	if r.CC != nil {
+		if *r.CC < 0 || *r.CC > 127 {
+			return MidiInRule{}, fmt.Errorf("cc value outside of 0-127 range: %d", *r.CC)
+		}
+		rule.Number = *r.CC
+	}
+
+	return rule, nil
+}
+
+func parseCalibration(c TOMLCalibration) (Calibration, error) {
+	if c.Min != nil && c.Max != nil && *c.Min >= *c.Max {
+		return Calibration{}, fmt.Errorf("min value (%d) has to be lower than max value (%d)", *c.Min, *c.Max)
+	}
+	if c.Center != nil {
+		if c.Min != nil && *c.Center <= *c.Min {
+			return Calibration{}, fmt.Errorf("center value (%d) has to be greater than min value (%d)", *c.Center, *c.Min)
+		}
+		if c.Max != nil && *c.Center >= *c.Max {
+			return Calibration{}, fmt.Errorf("center value (%d) has to be lower than max value (%d)", *c.Center, *c.Max)
+		}
+	}
+	return Calibration{Min: c.Min, Max: c.Max, Center: c.Center}, nil
+}
+
+func parseAnalogResponse(r TOMLAnalogResponse) (Curve, Smoothing, Calibration, error) {
+	var curve Curve
+	var smoothing Smoothing
+
+	if r.Curve != "" {
+		curveType := CurveType(r.Curve)
+		if !SupportedCurves[curveType] {
+			return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("curve not supported: %s", r.Curve)
+		}
+		curve.Type = curveType
+
+		switch curveType {
+		case CurveExponential, CurveLogarithmic, CurveS:
+			curve.Factor = 3.0
+			if curveType == CurveS {
+				curve.Factor = 2.0
+			}
+			if r.CurveFactor != nil {
+				if *r.CurveFactor <= 0 {
+					return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("curve_factor has to be greater than 0: %f", *r.CurveFactor)
+				}
+				curve.Factor = *r.CurveFactor
+			}
+		case CurveCustom:
+			if len(r.CurvePoints) < 2 {
+				return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("custom curve requires at least 2 curve_points")
+			}
+			for _, point := range r.CurvePoints {
+				if len(point) != 2 {
+					return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("curve point has to be [input, output] pair: %v", point)
+				}
+				if point[0] < 0 || point[0] > 1 || point[1] < 0 || point[1] > 1 {
+					return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("curve point outside of 0.0-1.0 range: %v", point)
+				}
+				curve.Points = append(curve.Points, [2]float64{point[0], point[1]})
+			}
+			sort.Slice(curve.Points, func(i, j int) bool {
+				return curve.Points[i][0] < curve.Points[j][0]
+			})
+		}
+	}
+
+	if r.Smoothing != "" {
+		smoothingType := SmoothingType(r.Smoothing)
+		if !SupportedSmoothing[smoothingType] {
+			return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("smoothing not supported: %s", r.Smoothing)
+		}
+		smoothing.Type = smoothingType
+
+		switch smoothingType {
+		case SmoothingEMA:
+			smoothing.Factor = 0.5
+			if r.SmoothingFactor != nil {
+				if *r.SmoothingFactor <= 0 || *r.SmoothingFactor > 1 {
+					return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("smoothing_factor outside of 0.0-1.0 range: %f", *r.SmoothingFactor)
+				}
+				smoothing.Factor = *r.SmoothingFactor
+			}
+		case SmoothingOneEuro:
+			smoothing.MinCutoff = 1.0
+			smoothing.Beta = 0.007
+			if r.MinCutoff != nil {
+				if *r.MinCutoff <= 0 {
+					return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("min_cutoff has to be greater than 0: %f", *r.MinCutoff)
+				}
+				smoothing.MinCutoff = *r.MinCutoff
+			}
+			if r.Beta != nil {
+				if *r.Beta < 0 {
+					return Curve{}, Smoothing{}, Calibration{}, fmt.Errorf("beta cannot be negative: %f", *r.Beta)
+				}
+				smoothing.Beta = *r.Beta
+			}
+		}
+	}
+
+	calibration, err := parseCalibration(r.TOMLCalibration)
+	if err != nil {
+		return Curve{}, Smoothing{}, Calibration{}, err
+	}
+
+	return curve, smoothing, calibration, nil
+}
+
 func readDeviceConfig(path, configType string) (DeviceConfig, error) {
 	fd, err := os.OpenFile(path, os.O_RDONLY, 0)
 	if err != nil {
@@ -414,6 +1148,11 @@ func readDeviceConfig(path, configType string) (DeviceConfig, error) {
 		return DeviceConfig{}, fmt.Errorf("reading file data failed: %w", err)
 	}
 
+	data, err = ResolveData(configRoot, path, data)
+	if err != nil {
+		return DeviceConfig{}, err
+	}
+
 	conf, err := ParseData(data)
 	if err != nil {
 		return DeviceConfig{}, err
@@ -421,6 +1160,7 @@ func readDeviceConfig(path, configType string) (DeviceConfig, error) {
 
 	return DeviceConfig{
 		ConfigFile: path2.Base(path),
+		ConfigPath: path,
 		ConfigType: configType,
 		Config:     conf,
 	}, nil
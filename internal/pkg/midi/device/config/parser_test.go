@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 
 	"github.com/gethiox/HIDI/internal/pkg/input"
 	"github.com/holoplot/go-evdev"
@@ -345,7 +346,12 @@ func TestParseDefaultKeyboard(t *testing.T) {
 				Active:         openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff},
 				ActiveExternal: openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff},
 			},
+			FPS: 100,
 		},
+		Calibration:   map[string]map[evdev.EvCode]Calibration{},
+		LEDIndicators: map[evdev.EvCode]LEDIndicator{},
+		Sequencer:     Sequencer{Length: 16, Swing: 50, Gate: 0.5},
+		NoteEffects:   NoteEffects{StrumDirection: StrumDirectionUp, Repeat: 3},
 	}
 
 	assert.Equal(t, expectedConfig, c)
@@ -434,7 +440,12 @@ func TestParseDefaultGamepad(t *testing.T) {
 				Active:         openrgb.Color{},
 				ActiveExternal: openrgb.Color{},
 			},
+			FPS: 100,
 		},
+		Calibration:   map[string]map[evdev.EvCode]Calibration{},
+		LEDIndicators: map[evdev.EvCode]LEDIndicator{},
+		Sequencer:     Sequencer{Length: 16, Swing: 50, Gate: 0.5},
+		NoteEffects:   NoteEffects{StrumDirection: StrumDirectionUp, Repeat: 3},
 	}
 
 	assert.Equal(t, expectedConfig, c)
@@ -452,7 +463,7 @@ func TestParseGamepadDeadzoneAtCenter(t *testing.T) {
 			Bus:     0x0003,
 			Vendor:  0x054c,
 			Product: 0x09cc,
-			Version: 0x0111,
+			Version: 0x8111,
 		},
 		KeyMappings: []KeyMapping{
 			{
@@ -484,14 +495,6 @@ func TestParseGamepadDeadzoneAtCenter(t *testing.T) {
 						evdev.ABS_HAT0X: {MappingType: AnalogActionSim, Action: OctaveUp, ActionNeg: OctaveDown, Bidirectional: true},
 						evdev.ABS_HAT0Y: {MappingType: AnalogActionSim, Action: MappingUp, ActionNeg: MappingDown, FlipAxis: true, Bidirectional: true},
 					},
-					"Motion Sensors": {
-						evdev.ABS_X:  {MappingType: AnalogCC, CC: 7},
-						evdev.ABS_Y:  {MappingType: AnalogCC, CC: 8},
-						evdev.ABS_Z:  {MappingType: AnalogCC, CC: 9},
-						evdev.ABS_RX: {MappingType: AnalogCC, CC: 10},
-						evdev.ABS_RY: {MappingType: AnalogCC, CC: 11},
-						evdev.ABS_RZ: {MappingType: AnalogCC, CC: 12},
-					},
 					"Touchpad": {
 						evdev.ABS_X: {MappingType: AnalogCC, CC: 13},
 						evdev.ABS_Y: {MappingType: AnalogCC, CC: 14},
@@ -508,23 +511,14 @@ func TestParseGamepadDeadzoneAtCenter(t *testing.T) {
 						evdev.ABS_HAT0X: 0.0,
 						evdev.ABS_HAT0Y: 0.0,
 					},
-					"Motion Sensors": {
-						evdev.ABS_X:  0.0,
-						evdev.ABS_Y:  0.0,
-						evdev.ABS_Z:  0.0,
-						evdev.ABS_RX: 0.0,
-						evdev.ABS_RY: 0.0,
-						evdev.ABS_RZ: 0.0,
-					},
 					"Touchpad": {
 						evdev.ABS_X: 0.0,
 						evdev.ABS_Y: 0.0,
 					},
 				},
 				DefaultDeadzone: map[string]float64{
-					"":               0.0,
-					"Motion Sensors": 0.0,
-					"Touchpad":       0.0,
+					"":         0.0,
+					"Touchpad": 0.0,
 				},
 			},
 		},
@@ -553,8 +547,535 @@ func TestParseGamepadDeadzoneAtCenter(t *testing.T) {
 				Active:         openrgb.Color{},
 				ActiveExternal: openrgb.Color{},
 			},
+			FPS: 100,
 		},
+		Calibration:   map[string]map[evdev.EvCode]Calibration{},
+		LEDIndicators: map[evdev.EvCode]LEDIndicator{},
+		Sequencer:     Sequencer{Length: 16, Swing: 50, Gate: 0.5},
+		NoteEffects:   NoteEffects{StrumDirection: StrumDirectionUp, Repeat: 3},
 	}
 
 	assert.Equal(t, expectedConfig, c)
 }
+
+const analogResponseConfig = `
+collision_mode = "off"
+
+[identifier]
+
+[defaults]
+  channel = 1
+  mapping = "Default"
+
+[[mapping]]
+  name = "Default"
+  [[mapping.analog]]
+    subhandler = ""
+    [mapping.analog.map]
+      ABS_X = { type = "cc", cc = 0, curve = "exponential", curve_factor = 2.0, smoothing = "ema", smoothing_factor = 0.25 }
+      ABS_Y = { type = "pitch_bend", invert_range = true, curve = "custom", curve_points = [[0.5, 0.2], [0.0, 0.0], [1.0, 1.0]], smoothing = "one_euro", min = -100, max = 100, center = 4 }
+      ABS_Z = { type = "cc", cc = 1, curve = "s_curve" }
+      ABS_RZ = { type = "cc14", cc = 31, channel_offset = 1 }
+      ABS_THROTTLE = { type = "nrpn", nrpn = 1000, flip_axis = true }
+      ABS_RUDDER = { type = "channel_pressure", channel_offset = 2 }
+      ABS_WHEEL = { type = "poly_pressure" }
+      ABS_GAS = { type = "poly_pressure", target = "last" }
+      ABS_RX = { type = "motion", output = "pitch_bend", sensitivity = 0.5 }
+      ABS_BRAKE = { type = "motion", cc = 11, motion = "rotation", flip_axis = true }
+
+[[calibration]]
+  subhandler = ""
+  [calibration.axes]
+    ABS_RX = { min = 10, max = 240, center = 130 }
+`
+
+func TestParseAnalogResponse(t *testing.T) {
+	c, err := ParseData([]byte(analogResponseConfig))
+	assert.Nil(t, err)
+
+	min, max, center := int32(-100), int32(100), int32(4)
+	calMin, calMax, calCenter := int32(10), int32(240), int32(130)
+
+	analog := c.KeyMappings[0].Analog[""]
+	assert.Equal(t, Analog{
+		MappingType: AnalogCC,
+		CC:          0,
+		Curve:       Curve{Type: CurveExponential, Factor: 2.0},
+		Smoothing:   Smoothing{Type: SmoothingEMA, Factor: 0.25},
+	}, analog[evdev.ABS_X])
+	assert.Equal(t, Analog{
+		MappingType: AnalogPitchBend,
+		InvertRange: true,
+		Curve:       Curve{Type: CurveCustom, Points: [][2]float64{{0, 0}, {0.5, 0.2}, {1, 1}}},
+		Smoothing:   Smoothing{Type: SmoothingOneEuro, MinCutoff: 1.0, Beta: 0.007},
+		Calibration: Calibration{Min: &min, Max: &max, Center: &center},
+	}, analog[evdev.ABS_Y])
+	assert.Equal(t, Curve{Type: CurveS, Factor: 2.0}, analog[evdev.ABS_Z].Curve)
+	assert.Equal(t, Analog{MappingType: AnalogCC14, CC: 31, ChannelOffset: 1}, analog[evdev.ABS_RZ])
+	assert.Equal(t, Analog{MappingType: AnalogNRPN, NRPN: 1000, FlipAxis: true}, analog[evdev.ABS_THROTTLE])
+	assert.Equal(t, Analog{MappingType: AnalogChannelPressure, ChannelOffset: 2}, analog[evdev.ABS_RUDDER])
+	assert.Equal(t, Analog{MappingType: AnalogPolyPressure, PressureTarget: PressureAll}, analog[evdev.ABS_WHEEL])
+	assert.Equal(t, Analog{MappingType: AnalogPolyPressure, PressureTarget: PressureLast}, analog[evdev.ABS_GAS])
+	assert.Equal(t, Analog{
+		MappingType: AnalogMotion,
+		Motion:      Motion{Source: MotionRotation, Output: AnalogPitchBend, Sensitivity: 0.5},
+	}, analog[evdev.ABS_RX])
+	assert.Equal(t, Analog{
+		MappingType: AnalogMotion,
+		CC:          11,
+		FlipAxis:    true,
+		Motion:      Motion{Source: MotionRotation, Output: AnalogCC, Sensitivity: 1.0},
+	}, analog[evdev.ABS_BRAKE])
+
+	assert.Equal(t, map[string]map[evdev.EvCode]Calibration{
+		"": {evdev.ABS_RX: {Min: &calMin, Max: &calMax, Center: &calCenter}},
+	}, c.Calibration)
+}
+
+func TestParseAnalogResponseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, line string
+	}{
+		{"unknown curve", `ABS_X = { type = "cc", cc = 0, curve = "cubic" }`},
+		{"custom curve without points", `ABS_X = { type = "cc", cc = 0, curve = "custom" }`},
+		{"custom point out of range", `ABS_X = { type = "cc", cc = 0, curve = "custom", curve_points = [[0.0, 0.0], [1.5, 1.0]] }`},
+		{"ema factor out of range", `ABS_X = { type = "cc", cc = 0, smoothing = "ema", smoothing_factor = 1.5 }`},
+		{"unknown smoothing", `ABS_X = { type = "cc", cc = 0, smoothing = "kalman" }`},
+		{"center outside of range", `ABS_X = { type = "cc", cc = 0, min = 0, max = 10, center = 20 }`},
+		{"cc14 outside of range", `ABS_X = { type = "cc14", cc = 32 }`},
+		{"cc14 with negative cc", `ABS_X = { type = "cc14", cc = 1, cc_negative = 2 }`},
+		{"nrpn not set", `ABS_X = { type = "nrpn" }`},
+		{"unknown pressure target", `ABS_X = { type = "poly_pressure", target = "first" }`},
+		{"motion cc not set", `ABS_X = { type = "motion" }`},
+		{"unknown motion source", `ABS_X = { type = "motion", cc = 1, motion = "compass" }`},
+		{"unknown motion output", `ABS_X = { type = "motion", cc = 1, output = "nrpn" }`},
+		{"motion sensitivity", `ABS_X = { type = "motion", cc = 1, sensitivity = 0.0 }`},
+		{"nrpn outside of range", `ABS_X = { type = "nrpn", nrpn = 16384 }`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := `
+[identifier]
+[defaults]
+  mapping = "Default"
+[[mapping]]
+  name = "Default"
+  [[mapping.analog]]
+    subhandler = ""
+    [mapping.analog.map]
+      ` + tc.line + "\n"
+			_, err := ParseData([]byte(data))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+const rumbleConfig = `
+collision_mode = "off"
+
+[identifier]
+
+[defaults]
+  mapping = "Default"
+
+[[mapping]]
+  name = "Default"
+
+[[rumble]]
+  trigger = "note_on"
+  weak = 0.5
+  duration = 40
+  scale = true
+
+[[rumble]]
+  trigger = "clock"
+  strong = 1.0
+  duration = 20
+
+[[rumble]]
+  trigger = "midi_note"
+  strong = 0.8
+  duration = 60
+  note = "C1"
+  channel = 10
+
+[[rumble]]
+  trigger = "midi_cc"
+  weak = 1.0
+  duration = 10
+  cc = 64
+`
+
+func TestParseRumble(t *testing.T) {
+	c, err := ParseData([]byte(rumbleConfig))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []Rumble{
+		{Trigger: RumbleNoteOn, Weak: 0.5, Duration: time.Millisecond * 40, Note: -1, CC: -1, Channel: -1, Scale: true},
+		{Trigger: RumbleClock, Strong: 1.0, Duration: time.Millisecond * 20, Every: 24, Note: -1, CC: -1, Channel: -1},
+		{Trigger: RumbleMidiNote, Strong: 0.8, Duration: time.Millisecond * 60, Note: 36, CC: -1, Channel: 9},
+		{Trigger: RumbleMidiCC, Weak: 1.0, Duration: time.Millisecond * 10, Note: -1, CC: 64, Channel: -1},
+	}, c.Rumble)
+}
+
+func TestParseRumbleErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, rule string
+	}{
+		{"unknown trigger", `trigger = "note_off", strong = 1.0, duration = 10`},
+		{"magnitude out of range", `trigger = "note_on", strong = 1.5, duration = 10`},
+		{"no magnitude", `trigger = "note_on", duration = 10`},
+		{"no duration", `trigger = "note_on", strong = 1.0`},
+		{"duration out of range", `trigger = "note_on", strong = 1.0, duration = 70000`},
+		{"channel out of range", `trigger = "midi_note", strong = 1.0, duration = 10, channel = 17`},
+		{"negative every", `trigger = "clock", strong = 1.0, duration = 10, every = -1`},
+		{"invalid note", `trigger = "midi_note", strong = 1.0, duration = 10, note = "H#"`},
+		{"cc out of range", `trigger = "midi_cc", strong = 1.0, duration = 10, cc = 128`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := `
+rumble = [{ ` + tc.rule + ` }]
+[identifier]
+[defaults]
+  mapping = "Default"
+[[mapping]]
+  name = "Default"
+`
+			_, err := ParseData([]byte(data))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+const midiInConfig = `
+collision_mode = "off"
+
+[identifier]
+
+[defaults]
+  mapping = "Default"
+
+[[mapping]]
+  name = "Default"
+
+[[midi_in]]
+  message = "program_change"
+  action = "mapping"
+
+[[midi_in]]
+  message = "cc"
+  cc = 20
+  channel = 16
+  action = "octave"
+
+[[midi_in]]
+  message = "note"
+  note = "c-1"
+  action = "panic"
+
+[[midi_in]]
+  message = "stop"
+  action = "panic"
+`
+
+func TestParseMidiIn(t *testing.T) {
+	c, err := ParseData([]byte(midiInConfig))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []MidiInRule{
+		{Message: MidiInProgramChange, Channel: -1, Number: -1, Action: Mapping},
+		{Message: MidiInCC, Channel: 15, Number: 20, Action: Octave},
+		{Message: MidiInNote, Channel: -1, Number: 12, Action: Panic},
+		{Message: MidiInStop, Channel: -1, Number: -1, Action: Panic},
+	}, c.MidiIn)
+}
+
+func TestParseMidiInErrors(t *testing.T) {
+	for _, tc := range []struct {
+		rule, expected string
+	}{
+		{`message = "sysex", action = "panic"`, "[midi_in 0] message not supported: sysex"},
+		{`message = "cc", action = "dance"`, "[midi_in 0] unsupported action: dance"},
+		{`message = "cc", action = "panic", channel = 17`, "[midi_in 0] channel outside of 1-16 range: 17"},
+		{`message = "start", action = "panic", channel = 1`, "[midi_in 0] start message has no channel"},
+		{`message = "cc", action = "panic", note = "c1"`, "[midi_in 0] note is supported by note message only"},
+		{`message = "note", action = "panic", cc = 1`, "[midi_in 0] cc is supported by cc message only"},
+		{`message = "note", action = "channel"`, "[midi_in 0] channel action requires cc or program_change message"},
+		{`message = "note", action = "panic", note = "200"`, "[midi_in 0] note value outside of 0-127 range: 200"},
+		{`message = "cc", action = "panic", cc = 128`, "[midi_in 0] cc value outside of 0-127 range: 128"},
+	} {
+		t.Run(tc.expected, func(t *testing.T) {
+			data := `
+midi_in = [{ ` + tc.rule + ` }]
+[identifier]
+[defaults]
+  mapping = "Default"
+[[mapping]]
+  name = "Default"
+`
+			_, err := ParseData([]byte(data))
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestParseLEDIndicatorsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, line string
+	}{
+		{"unknown led", `LED_FOO = "octave"`},
+		{"unknown indicator", `LED_CAPSL = "velocity"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := `
+[identifier]
+[defaults]
+  mapping = "Default"
+[led_indicators]
+  ` + tc.line + `
+[[mapping]]
+  name = "Default"
+`
+			_, err := ParseData([]byte(data))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestParseLightbar(t *testing.T) {
+	for _, tc := range []struct {
+		name, section string
+		expected      Lightbar
+	}{
+		{"disabled", ``, Lightbar{}},
+		{"channel", `mode = "channel"
+  panic_flash = true`, Lightbar{Mode: LightbarChannel, Brightness: 1.0, PanicFlash: true}},
+		{"static", `mode = "static"
+  color = 0x00ff80
+  brightness = 0.5
+  note_pulse = true`, Lightbar{Mode: LightbarStatic, Color: openrgb.Color{Green: 0xff, Blue: 0x80}, Brightness: 0.5, NotePulse: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := `
+collision_mode = "off"
+[identifier]
+[defaults]
+  mapping = "Default"
+[lightbar]
+  ` + tc.section + `
+[[mapping]]
+  name = "Default"
+`
+			c, err := ParseData([]byte(data))
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, c.Lightbar)
+		})
+	}
+}
+
+func TestParseLightbarErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name, section string
+	}{
+		{"no mode", `panic_flash = true`},
+		{"unknown mode", `mode = "rainbow"`},
+		{"static without color", `mode = "static"`},
+		{"color with channel mode", `mode = "channel"
+  color = 0xff0000`},
+		{"brightness out of range", `mode = "mapping"
+  brightness = 1.5`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := `
+collision_mode = "off"
+[identifier]
+[defaults]
+  mapping = "Default"
+[lightbar]
+  ` + tc.section + `
+[[mapping]]
+  name = "Default"
+`
+			_, err := ParseData([]byte(data))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestParseOpenRGBLayout(t *testing.T) {
+	data := `
+collision_mode = "off"
+[identifier]
+[defaults]
+  mapping = "Default"
+[open_rgb]
+  strip = ["Top 1", "Top 2", 40]
+  [open_rgb.keys]
+    KEY_102ND = "Key: \\ (ISO)"
+    KEY_FN = 104
+    KEY_F1 = ""
+[[mapping]]
+  name = "Default"
+`
+	c, err := ParseData([]byte(data))
+	assert.Nil(t, err)
+	assert.Equal(t, map[evdev.EvCode]OpenRGBLED{
+		evdev.KEY_102ND: {Name: "Key: \\ (ISO)"},
+		evdev.KEY_FN:    {Index: 104},
+		evdev.KEY_F1:    {Index: -1},
+	}, c.OpenRGB.Keys)
+	assert.Equal(t, []OpenRGBLED{{Name: "Top 1"}, {Name: "Top 2"}, {Index: 40}}, c.OpenRGB.Strip)
+
+	for _, section := range []string{
+		`strip = [""]`,
+		`strip = [-1]`,
+		`strip = [1.5]`,
+		`keys = { KEY_FOO = 1 }`,
+		`keys = { KEY_A = true }`,
+	} {
+		_, err := ParseData([]byte(`
+[identifier]
+[defaults]
+  mapping = "Default"
+[open_rgb]
+  ` + section + `
+[[mapping]]
+  name = "Default"
+`))
+		assert.NotNil(t, err, section)
+	}
+}
+
+func TestParseOpenRGBMeter(t *testing.T) {
+	parse := func(section string) (Config, error) {
+		return ParseData([]byte(`
+collision_mode = "off"
+[identifier]
+[defaults]
+  mapping = "Default"
+[open_rgb]
+  ` + section + `
+[[mapping]]
+  name = "Default"
+`))
+	}
+
+	c, err := parse(`meter = "analog"
+  meter_axis = "ABS_RX"
+  meter_color = 0x00ff80`)
+	assert.Nil(t, err)
+	axis := evdev.EvCode(evdev.ABS_RX)
+	assert.Equal(t, StripMeter{
+		Type:  MeterAnalog,
+		Axis:  &axis,
+		Color: openrgb.Color{Red: 0x00, Green: 0xff, Blue: 0x80},
+	}, c.OpenRGB.Meter)
+
+	c, err = parse(`meter = "clock"`)
+	assert.Nil(t, err)
+	assert.Equal(t, StripMeter{Type: MeterClock, Color: openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff}}, c.OpenRGB.Meter)
+
+	for _, section := range []string{
+		`meter = "spectrum"`,
+		`meter = "velocity"
+  meter_axis = "ABS_X"`,
+		`meter = "analog"
+  meter_axis = "KEY_A"`,
+	} {
+		_, err := parse(section)
+		assert.NotNil(t, err, section)
+	}
+}
+
+func TestParseOpenRGBControllers(t *testing.T) {
+	parse := func(section string) (Config, error) {
+		return ParseData([]byte(`
+collision_mode = "off"
+[identifier]
+[defaults]
+  mapping = "Default"
+` + section + `
+[[mapping]]
+  name = "Default"
+`))
+	}
+
+	c, err := parse(`
+[[open_rgb.controller]]
+  name = "Corsair MM800"
+  mode = "notes"
+[[open_rgb.controller]]
+  name = "ASUS Aura Motherboard"
+  zone = "Aura Addressable 1"
+  mode = "beat"
+`)
+	assert.Nil(t, err)
+	assert.Equal(t, []OpenRGBController{
+		{Name: "Corsair MM800", Mode: ControllerNotes},
+		{Name: "ASUS Aura Motherboard", Zone: "Aura Addressable 1", Mode: ControllerBeat},
+	}, c.OpenRGB.Controllers)
+
+	_, err = parse(`
+[[open_rgb.controller]]
+  mode = "notes"
+`)
+	assert.NotNil(t, err)
+
+	_, err = parse(`
+[[open_rgb.controller]]
+  name = "Corsair MM800"
+  mode = "rainbow"
+`)
+	assert.NotNil(t, err)
+}
+
+func TestParseOpenRGBTheme(t *testing.T) {
+	parse := func(section string) (Config, error) {
+		return ParseData([]byte(`
+collision_mode = "off"
+[identifier]
+[defaults]
+  mapping = "Default"
+[open_rgb]
+  active = 0xff0000
+  ` + section + `
+[[mapping]]
+  name = "Default"
+  theme = "neon"
+`))
+	}
+
+	c, err := parse(`fps = 60
+  theme = "calm"
+  afterglow = 400
+  ripple = 300
+  velocity = true`)
+	assert.Nil(t, err)
+	assert.Equal(t, 60, c.OpenRGB.FPS)
+	assert.Equal(t, "calm", c.OpenRGB.Theme)
+	assert.Equal(t, "neon", c.KeyMappings[0].Theme)
+	assert.Equal(t, openrgb.Color{Red: 0xff}, c.OpenRGB.Colors.Active)
+	assert.Equal(t, Effects{
+		Afterglow:   time.Millisecond * 400,
+		Ripple:      time.Millisecond * 300,
+		RippleWidth: 12,
+		Velocity:    true,
+	}, c.OpenRGB.Effects)
+
+	c, err = parse(`ripple = 300
+  ripple_width = 5`)
+	assert.Nil(t, err)
+	assert.Equal(t, 100, c.OpenRGB.FPS)
+	assert.Equal(t, 5, c.OpenRGB.Effects.RippleWidth)
+
+	for _, section := range []string{
+		`fps = -1`,
+		`fps = 2000`,
+		`afterglow = -100`,
+		`ripple_width = 5`,
+		`ripple = 300
+  ripple_width = 0`,
+	} {
+		_, err := parse(section)
+		assert.NotNil(t, err, section)
+	}
+}
@@ -0,0 +1,183 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// userSequencer is a directory of step sequencer pattern files
+const userSequencer = "hidi-config/user/sequencer"
+
+const (
+	SequencerPatterns = 8  // number of patterns of the step sequencer
+	SequencerMaxSteps = 64 // maximum pattern length in steps (sixteenth notes)
+)
+
+// Step is a single sixteenth note of sequencer pattern, step without notes is a rest
+type Step struct {
+	Notes    []byte
+	Velocity byte
+	Gate     float64 // note length relative to the step length, 0.0 - 1.0
+}
+
+type Pattern struct {
+	Steps []Step // pattern length is the number of steps
+}
+
+// Patterns holds all patterns of the step sequencer
+type Patterns struct {
+	Patterns [SequencerPatterns]Pattern
+	Chain    []int // patterns played one after another, currently edited pattern is looped when empty
+}
+
+// NewPatterns returns empty patterns of given length
+func NewPatterns(length int) *Patterns {
+	p := &Patterns{}
+	for i := range p.Patterns {
+		p.Patterns[i].Steps = make([]Step, length)
+	}
+	return p
+}
+
+type TOMLPatterns struct {
+	Chain    []int `toml:"chain"`
+	Patterns []struct {
+		Length int `toml:"length"`
+		Steps  []struct {
+			Step     int      `toml:"step"` // 1 is the first step
+			Notes    []string `toml:"notes"`
+			Velocity int      `toml:"velocity"`
+			Gate     float64  `toml:"gate"`
+		} `toml:"step"`
+	} `toml:"pattern"`
+}
+
+// SequencerPath returns path of step sequencer pattern file of given device, named after the device
+func SequencerPath(dev input.Device) (string, error) {
+	path, err := UserConfigPath(dev)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userSequencer, filepath.Base(path)), nil
+}
+
+// ParsePatterns parses step sequencer pattern file, missing patterns are empty with given length
+func ParsePatterns(data []byte, length int) (*Patterns, error) {
+	cfg := TOMLPatterns{}
+
+	d := toml.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	err := d.Decode(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing failed: %w", err)
+	}
+
+	if len(cfg.Patterns) > SequencerPatterns {
+		return nil, fmt.Errorf("more than %d patterns defined: %d", SequencerPatterns, len(cfg.Patterns))
+	}
+
+	patterns := NewPatterns(length)
+	for i, p := range cfg.Patterns {
+		if p.Length < 1 || p.Length > SequencerMaxSteps {
+			return nil, fmt.Errorf("[pattern %d] length outside of 1-%d range: %d", i, SequencerMaxSteps, p.Length)
+		}
+		steps := make([]Step, p.Length)
+		for _, s := range p.Steps {
+			if s.Step < 1 || s.Step > p.Length {
+				return nil, fmt.Errorf("[pattern %d] step outside of 1-%d range: %d", i, p.Length, s.Step)
+			}
+			if s.Velocity < 1 || s.Velocity > 127 {
+				return nil, fmt.Errorf("[pattern %d] step %d: velocity outside of 1-127 range: %d", i, s.Step, s.Velocity)
+			}
+			if s.Gate <= 0 || s.Gate > 1 {
+				return nil, fmt.Errorf("[pattern %d] step %d: gate outside of 0.0-1.0 range: %g", i, s.Step, s.Gate)
+			}
+			step := Step{Velocity: byte(s.Velocity), Gate: s.Gate}
+			for _, raw := range s.Notes {
+				note, err := parseNote(raw)
+				if err != nil {
+					return nil, fmt.Errorf("[pattern %d] step %d: %w", i, s.Step, err)
+				}
+				step.Notes = append(step.Notes, byte(note))
+			}
+			steps[s.Step-1] = step
+		}
+		patterns.Patterns[i].Steps = steps
+	}
+
+	for _, p := range cfg.Chain {
+		if p < 1 || p > SequencerPatterns {
+			return nil, fmt.Errorf("[chain] pattern outside of 1-%d range: %d", SequencerPatterns, p)
+		}
+		patterns.Chain = append(patterns.Chain, p-1)
+	}
+	return patterns, nil
+}
+
+// LoadPatterns reads step sequencer pattern file, missing file gives no patterns
+func LoadPatterns(path string, length int) (*Patterns, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading file data failed: %w", err)
+	}
+	return ParsePatterns(data, length)
+}
+
+// PatternsData returns step sequencer pattern file data, only steps with notes are written
+func PatternsData(patterns *Patterns) []byte {
+	buf := bytes.Buffer{}
+	buf.WriteString("# Step sequencer patterns, written when sequencer edit mode is left\n")
+
+	chain := make([]string, len(patterns.Chain))
+	for i, p := range patterns.Chain {
+		chain[i] = fmt.Sprint(p + 1)
+	}
+	buf.WriteString(fmt.Sprintf("chain = [%s]\n", strings.Join(chain, ", ")))
+
+	for i, p := range patterns.Patterns {
+		buf.WriteString(fmt.Sprintf("\n[[pattern]] # %d\n  length = %d\n", i+1, len(p.Steps)))
+		for j, s := range p.Steps {
+			if len(s.Notes) == 0 {
+				continue
+			}
+			// gate is always written as float, integer value can't be decoded into float field
+			gate := strconv.FormatFloat(s.Gate, 'f', -1, 64)
+			if !strings.Contains(gate, ".") {
+				gate += ".0"
+			}
+			notes := make([]string, len(s.Notes))
+			for k, note := range s.Notes {
+				notes[k] = fmt.Sprintf("%q", strings.ToLower(NoteToString(note)))
+			}
+			buf.WriteString(fmt.Sprintf(
+				"  [[pattern.step]]\n    step = %d\n    notes = [%s]\n    velocity = %d\n    gate = %s\n",
+				j+1, strings.Join(notes, ", "), s.Velocity, gate,
+			))
+		}
+	}
+	return buf.Bytes()
+}
+
+// SavePatterns writes patterns into step sequencer pattern file, directory is created if necessary
+func SavePatterns(path string, patterns *Patterns) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o777)
+	if err != nil {
+		return fmt.Errorf("creating directory failed: %w", err)
+	}
+	err = os.WriteFile(path, PatternsData(patterns), 0644)
+	if err != nil {
+		return fmt.Errorf("writing file failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatternsRoundTrip(t *testing.T) {
+	patterns := NewPatterns(16)
+	patterns.Patterns[0].Steps[0] = Step{Notes: []byte{60, 64}, Velocity: 100, Gate: 0.5}
+	patterns.Patterns[0].Steps[15] = Step{Notes: []byte{61}, Velocity: 1, Gate: 1}
+	patterns.Patterns[2].Steps = make([]Step, 3)
+	patterns.Patterns[2].Steps[1] = Step{Notes: []byte{24}, Velocity: 127, Gate: 0.125}
+	patterns.Chain = []int{0, 2, 0}
+
+	path := filepath.Join(t.TempDir(), "sequencer", "Dummy.toml")
+	assert.NoError(t, SavePatterns(path, patterns))
+
+	loaded, err := LoadPatterns(path, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, patterns, loaded)
+
+	loaded, err = LoadPatterns(filepath.Join(t.TempDir(), "missing.toml"), 16)
+	assert.NoError(t, err)
+	assert.Nil(t, loaded)
+}
+
+func TestParsePatterns(t *testing.T) {
+	patterns, err := ParsePatterns([]byte(`
+chain = [2]
+
+[[pattern]]
+  length = 4
+  [[pattern.step]]
+    step = 4
+    notes = ["c3", "e3"]
+    velocity = 80
+    gate = 0.25
+`), 8)
+	assert.NoError(t, err)
+	assert.Equal(t, []Step{{}, {}, {}, {Notes: []byte{60, 64}, Velocity: 80, Gate: 0.25}}, patterns.Patterns[0].Steps)
+	assert.Len(t, patterns.Patterns[1].Steps, 8)
+	assert.Equal(t, []int{1}, patterns.Chain)
+
+	for _, data := range []string{
+		"chain = [9]",
+		"[[pattern]]\n  length = 65",
+		"[[pattern]]\n  length = 4\n  [[pattern.step]]\n    step = 5\n    notes = [\"c3\"]\n    velocity = 80\n    gate = 0.5",
+		"[[pattern]]\n  length = 4\n  [[pattern.step]]\n    step = 1\n    notes = [\"c3\"]\n    velocity = 0\n    gate = 0.5",
+		"[[pattern]]\n  length = 4\n  [[pattern.step]]\n    step = 1\n    notes = [\"c3\"]\n    velocity = 80\n    gate = 0",
+		"[[pattern]]\n  length = 4\n  [[pattern.step]]\n    step = 1\n    notes = [\"foo\"]\n    velocity = 80\n    gate = 0.5",
+	} {
+		_, err := ParsePatterns([]byte(data), 16)
+		assert.Error(t, err, data)
+	}
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/realbucksavage/openrgb-go"
+)
+
+const themesFile = "hidi-config/user/themes.toml"
+
+// TOMLTheme contains OpenRGB colors and key animation, shared by [open_rgb] section and themes file
+type TOMLTheme struct {
+	White          int `toml:"white"`
+	Black          int `toml:"black"`
+	C              int `toml:"c"`
+	Unavailable    int `toml:"unavailable"`
+	Other          int `toml:"other"`
+	Active         int `toml:"active"`
+	ActiveExternal int `toml:"active_external"`
+
+	Afterglow   int  `toml:"afterglow,omitempty"` // milliseconds
+	Ripple      int  `toml:"ripple,omitempty"`    // milliseconds
+	RippleWidth *int `toml:"ripple_width,omitempty"`
+	Velocity    bool `toml:"velocity,omitempty"`
+}
+
+type TOMLThemes struct {
+	Themes []struct {
+		Name string `toml:"name"`
+		TOMLTheme
+	} `toml:"theme"`
+}
+
+func convertToColor(v int) openrgb.Color {
+	return openrgb.Color{
+		Red:   byte(v >> 16),
+		Green: byte(v >> 8),
+		Blue:  byte(v),
+	}
+}
+
+func parseTheme(t TOMLTheme) (Theme, error) {
+	if t.Afterglow < 0 {
+		return Theme{}, fmt.Errorf("afterglow has to be positive: %d", t.Afterglow)
+	}
+	if t.Ripple < 0 {
+		return Theme{}, fmt.Errorf("ripple has to be positive: %d", t.Ripple)
+	}
+
+	var rippleWidth int
+	if t.Ripple > 0 {
+		rippleWidth = 12
+	}
+	if t.RippleWidth != nil {
+		if t.Ripple == 0 {
+			return Theme{}, fmt.Errorf("ripple_width requires ripple to be set")
+		}
+		if *t.RippleWidth < 1 || *t.RippleWidth > 127 {
+			return Theme{}, fmt.Errorf("ripple_width \"%d\" not in 1-127 range", *t.RippleWidth)
+		}
+		rippleWidth = *t.RippleWidth
+	}
+
+	return Theme{
+		Colors: Colors{
+			White:          convertToColor(t.White),
+			Black:          convertToColor(t.Black),
+			C:              convertToColor(t.C),
+			Unavailable:    convertToColor(t.Unavailable),
+			Other:          convertToColor(t.Other),
+			Active:         convertToColor(t.Active),
+			ActiveExternal: convertToColor(t.ActiveExternal),
+		},
+		Effects: Effects{
+			Afterglow:   time.Duration(t.Afterglow) * time.Millisecond,
+			Ripple:      time.Duration(t.Ripple) * time.Millisecond,
+			RippleWidth: rippleWidth,
+			Velocity:    t.Velocity,
+		},
+	}, nil
+}
+
+// ParseThemes parses themes file, theme names have to be unique
+func ParseThemes(data []byte) (map[string]Theme, error) {
+	cfg := TOMLThemes{}
+
+	d := toml.NewDecoder(bytes.NewReader(data))
+	d.DisallowUnknownFields()
+
+	err := d.Decode(&cfg)
+	if err != nil {
+		return nil, fmt.Errorf("parsing failed: %w", err)
+	}
+
+	var themes = make(map[string]Theme, len(cfg.Themes))
+	for i, t := range cfg.Themes {
+		if t.Name == "" {
+			return nil, fmt.Errorf("[theme %d] name not set", i)
+		}
+		if _, ok := themes[t.Name]; ok {
+			return nil, fmt.Errorf("[theme %d] duplicated name: %s", i, t.Name)
+		}
+		theme, err := parseTheme(t.TOMLTheme)
+		if err != nil {
+			return nil, fmt.Errorf("[theme %d] %s: %w", i, t.Name, err)
+		}
+		themes[t.Name] = theme
+	}
+	return themes, nil
+}
+
+// LoadThemes loads user themes file, missing file is not an error
+func LoadThemes() (map[string]Theme, error) {
+	data, err := os.ReadFile(themesFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string]Theme{}, nil
+		}
+		return nil, err
+	}
+	return ParseThemes(data)
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseThemes(t *testing.T) {
+	themes, err := ParseThemes([]byte(`
+[[theme]]
+  name = "neon"
+  white = 0x00ffff
+  black = 0xff00ff
+  active = 0xffffff
+  afterglow = 250
+  ripple = 500
+  ripple_width = 7
+
+[[theme]]
+  name = "plain"
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]Theme{
+		"neon": {
+			Colors: Colors{
+				White:  openrgb.Color{Green: 0xff, Blue: 0xff},
+				Black:  openrgb.Color{Red: 0xff, Blue: 0xff},
+				Active: openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff},
+			},
+			Effects: Effects{
+				Afterglow:   time.Millisecond * 250,
+				Ripple:      time.Millisecond * 500,
+				RippleWidth: 7,
+			},
+		},
+		"plain": {},
+	}, themes)
+
+	for _, data := range []string{
+		`[[theme]]
+  white = 0xffffff`,
+		`[[theme]]
+  name = "a"
+[[theme]]
+  name = "a"`,
+		`[[theme]]
+  name = "a"
+  ripple = -1`,
+		`[[theme]]
+  name = "a"
+  sparkle = true`,
+	} {
+		_, err := ParseThemes([]byte(data))
+		assert.NotNil(t, err, data)
+	}
+}
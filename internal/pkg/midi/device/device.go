@@ -5,6 +5,8 @@ import (
 	"os"
 	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gethiox/HIDI/internal/pkg/input"
 	"github.com/gethiox/HIDI/internal/pkg/logger"
@@ -22,48 +24,92 @@ const (
 	EV_KEY_REPEAT  = 2
 )
 
+// maxOctave is the octave shift limit of OctaveSet, no note fits 0-127 range beyond it
+const maxOctave = 10
+
 type Device struct {
 	noLogs      bool // skips producing most of the log entries for maximum performance
 	config      config.Config
 	InputDevice input.Device
 	openrgbPort int
 
-	effectEvents chan<- midi.Event
+	matchController ControllerMatcher // finds OpenRGB keyboard controller of the device
+
+	effectEvents chan effectEvent // note messages delayed by note effects, sent to outputEvents by handleEffects
+	effectNotes  *effectNotes
 	outputEvents chan<- midi.Event
 	target       *chan<- midi.Event
 	midiIn       <-chan midi.Event
 
 	// keeps track of currently active notes on midi input
 	externalNoteTracker  map[byte]map[byte]bool // channel: note
-	externalTrackerMutex *sync.Mutex
+	externalTrackerMutex *sync.Mutex            // guards external state: notes, sustain and clock
+	externalSustain      bool                   // sustain pedal held on midi input
+	clockTicks           int                    // midi clock ticks received since last start message
+	lastClock            time.Time              // time of last midi clock tick
 
 	// instead of generating NoteOff events based on the current Device state (lazy approach), every emitted note
 	// is being tracked and released precisely on related hardware button release.
 	// This approach gives much nicer user experience as the User may conveniently hold some keys
 	// and modify state on the fly (changing octave, channel etc.), NoteOff events will be emitted correctly anyway.
-	noteTracker       map[evdev.EvCode][2]byte // 1: note, 2: channel
-	analogNoteTracker map[string][2]byte       // 1: note, 2: channel
+	noteTracker       map[evdev.EvCode][][2]byte // voices played by the key, 1: note, 2: channel
+	noteOrder         []evdev.EvCode             // noteTracker keys in order of pressing
+	heldNotes         *HeldNotes                 // notes held on all devices, nil if not shared
+	analogNoteTracker map[string][2]byte         // 1: note, 2: channel
 	// used to track active occurrence number for given channel/note for purpose of handling clashed notes.
 	// more info in hidi.toml at "collision_mode" option.
 	activeNotesCounter map[byte]map[byte]int // map[channel]map[note]occurrence_number
 	lastAnalogValue    map[string]map[evdev.EvCode]float64
+	analogFilters      map[string]map[evdev.EvCode]analogFilter
+	hiResTracker       hiResTracker
+	hiResThrottles     map[string]map[evdev.EvCode]*hiResThrottle
+	motionStates       map[string]map[evdev.EvCode]*motionState
+	rumbleRequests     chan rumbleRequest
+	sustain            bool      // sustain pedal held by analog cc mapping
+	meterValue         float64   // last analog value driving LED strip meter, -1.0 - 1.0
+	meterLevel         float64   // velocity of last played note, 0.0 - 1.0
+	meterLevelTime     time.Time // time of last played note
+	lastNoteOn         time.Time // time of last note played on the device itself
+	lastPanic          time.Time // time of last panic action
+	sysfsRoot          string    // sysfs mount point, lightbar LEDs are looked up there
+	keyEffects         *keyEffects
+	noteChanges        int           // incremented on every change of played notes
+	publishedKey       stateKey      // summary of the last published state
+	themes             *atomic.Value // map[string]config.Theme, replaced by SetThemes
+	state              *atomic.Value // *deviceSnapshot, replaced by publishState
+	external           *atomic.Value // *externalSnapshot, replaced by publishExternal
 
 	actionTracker map[config.Action]bool
 	ccZeroed      map[byte]bool // 1: positive, 2: negative
 	keyTracker    map[evdev.EvCode]struct{}
 	sigs          chan os.Signal
+	clock         *midi.Clock  // internal clock controlled by transport actions, nil if disabled
+	looper        *midi.Looper // phrase looper controlled by looper actions, nil if disabled
 
 	eventProcessMutex *sync.Mutex
 
-	octave   int8
-	semitone int8
-	channel  uint8
-	velocity uint8
-	// warning: currently lazy implementation
-	multiNote  []int // list of additional note intervals (offsets)
+	octave     int8
+	semitone   int8
+	channel    uint8
+	velocity   uint8
+	multiNote  []int // list of additional note intervals (offsets), played as a chord
 	mapping    int
 	ccLearning bool
 
+	ccLearningSource  *config.Control         // control touched in cc learning mode, waiting for CC number
+	ccLearningNumber  string                  // CC number typed in cc learning mode
+	ccLearningPath    string                  // cc_learning overlay file, learned controls are not saved when empty
+	learnedKeyTracker map[config.Control]bool // learned keys with CC value 127 sent
+	relValues         map[config.Control]byte // last CC values of learned relative controls
+
+	sequencerMode bool      // sequencer edit mode, keys edit patterns instead of playing notes
+	sequencerPath string    // sequencer pattern file, patterns are not saved when empty
+	seq           sequencer // step sequencer, guarded by eventProcessMutex
+
+	chordHeld   []evdev.EvCode // held chord modifier keys in order of pressing
+	lastVoicing []int          // notes of the last played chord, followed by voice_leading voicing
+	effects     noteEffects    // strum, note repeat and humanize parameters
+
 	actionsPress   map[config.Action]func(*Device)
 	actionsRelease map[config.Action]func(*Device)
 }
@@ -112,17 +158,53 @@ func NewDevice(
 		config.ChannelDown:  (*Device).ChannelDown,
 		config.Multinote:    func(*Device) {}, // on key release only
 		config.Learning:     (*Device).CCLearningOn,
+		config.MotionReset:  (*Device).MotionReset,
+
+		config.TapTempo:        (*Device).TapTempo,
+		config.TempoUp:         (*Device).TempoUp,
+		config.TempoDown:       (*Device).TempoDown,
+		config.TransportToggle: (*Device).TransportToggle,
+		config.TransportRewind: (*Device).TransportRewind,
+		config.StepSequencer:   (*Device).SequencerToggle,
+
+		config.LooperRecord:   (*Device).LooperRecord,
+		config.LooperPlay:     (*Device).LooperPlay,
+		config.LooperOverdub:  (*Device).LooperOverdub,
+		config.LooperUndo:     (*Device).LooperUndo,
+		config.LooperClear:    (*Device).LooperClear,
+		config.LooperSlotUp:   (*Device).LooperSlotUp,
+		config.LooperSlotDown: (*Device).LooperSlotDown,
+
+		config.StrumUp:        (*Device).StrumUp,
+		config.StrumDown:      (*Device).StrumDown,
+		config.NoteRepeat:     (*Device).NoteRepeatOn,
+		config.RepeatRateUp:   (*Device).RepeatRateUp,
+		config.RepeatRateDown: (*Device).RepeatRateDown,
+		config.Humanize:       (*Device).HumanizeToggle,
 	}
 	actionsRelease := map[config.Action]func(*Device){
-		config.Learning: (*Device).CCLearningOff,
+		config.Learning:   (*Device).CCLearningOff,
+		config.NoteRepeat: (*Device).NoteRepeatOff,
+	}
+
+	// learned controls are modified at runtime, config map is not shared with other devices
+	ccLearned := make(map[config.Control]byte, len(cfg.Config.CCLearned))
+	for control, cc := range cfg.Config.CCLearned {
+		ccLearned[control] = cc
 	}
+	ccLearningPath, _ := config.CCLearningPath(inputDevice)
+	sequencerPath, _ := config.SequencerPath(inputDevice)
+
+	devConfig := cfg.Config
+	devConfig.CCLearned = ccLearned
 
 	device := Device{
 		noLogs:               noLogs,
-		config:               cfg.Config,
+		config:               devConfig,
 		InputDevice:          inputDevice,
 		outputEvents:         midiEvents,
-		effectEvents:         make(chan midi.Event, 8),
+		effectEvents:         make(chan effectEvent, 8),
+		effectNotes:          &effectNotes{mutex: &sync.Mutex{}, waiting: make(map[[2]byte]int)},
 		target:               &midiEvents,
 		midiIn:               midiIn,
 		sigs:                 sigs,
@@ -130,14 +212,31 @@ func NewDevice(
 		externalTrackerMutex: &sync.Mutex{},
 		externalNoteTracker:  inmap,
 		openrgbPort:          openrgbPort,
+		matchController:      MatchHidraw,
 
-		noteTracker:        make(map[evdev.EvCode][2]byte, 32),
+		noteTracker:        make(map[evdev.EvCode][][2]byte, 32),
 		keyTracker:         make(map[evdev.EvCode]struct{}, 32),
 		analogNoteTracker:  make(map[string][2]byte, 32),
 		activeNotesCounter: activeNoteCounter,
 		actionTracker:      make(map[config.Action]bool, 16),
 		ccZeroed:           make(map[byte]bool, 32),
 		lastAnalogValue:    lastAnalogValue,
+		analogFilters:      make(map[string]map[evdev.EvCode]analogFilter),
+		hiResTracker:       newHiResTracker(),
+		hiResThrottles:     make(map[string]map[evdev.EvCode]*hiResThrottle),
+		motionStates:       make(map[string]map[evdev.EvCode]*motionState),
+		rumbleRequests:     make(chan rumbleRequest, 8),
+		keyEffects:         newKeyEffects(),
+		themes:             &atomic.Value{},
+		state:              &atomic.Value{},
+		external:           &atomic.Value{},
+		sysfsRoot:          input.SysfsRoot,
+		ccLearningPath:     ccLearningPath,
+		learnedKeyTracker:  make(map[config.Control]bool),
+		relValues:          make(map[config.Control]byte),
+		sequencerPath:      sequencerPath,
+		seq:                newSequencer(cfg.Config.Patterns, cfg.Config.Sequencer.Length),
+		effects:            newNoteEffects(cfg.Config.NoteEffects),
 
 		actionsPress:   actionsPress,
 		actionsRelease: actionsRelease,
@@ -150,6 +249,8 @@ func NewDevice(
 		ccLearning: false,
 		velocity:   uint8(cfg.Config.Defaults.Velocity),
 	}
+	device.publishState()
+	device.publishExternal()
 
 	return device
 }
@@ -182,6 +283,10 @@ func (d *Device) checkDoubleActions() bool {
 			d.SemitoneReset()
 		case d.actionTracker[config.ChannelUp] && d.actionTracker[config.ChannelDown]:
 			d.ChannelReset()
+		case d.actionTracker[config.TempoUp] && d.actionTracker[config.TempoDown]:
+			d.TempoReset()
+		case d.actionTracker[config.RepeatRateUp] && d.actionTracker[config.RepeatRateDown]:
+			d.RepeatRateReset()
 		default:
 			return false
 		}
@@ -195,19 +300,59 @@ func (d *Device) NoteOn(ev *input.InputEvent) {
 	if !ok {
 		return
 	}
-	note := key.Note
-	noteCalculatored := int(note) + int(d.octave*12) + int(d.semitone)
-	if noteCalculatored < 0 || noteCalculatored > 127 {
+	root := int(key.Note) + int(d.octave)*12 + int(d.semitone)
+	channel := (d.channel + key.ChannelOffset) % 16
+
+	notes := []int{root}
+	strum := d.effects.strum
+	if chord, ok := d.activeChord(); ok {
+		notes = d.voiceChord(chord, root)
+		if chord.Strum > 0 {
+			strum = chord.Strum
+		}
+	}
+
+	var voices []byte
+	for _, note := range notes {
+		if note >= 0 && note <= 127 {
+			voices = append(voices, byte(note))
+		}
+	}
+	if len(voices) == 0 {
 		return
 	}
-	note = uint8(noteCalculatored)
-	channel := (d.channel + key.ChannelOffset) % 16
+	if strum > 0 && d.effects.direction == config.StrumDirectionDown {
+		for i, j := 0, len(voices)-1; i < j; i, j = i+1, j-1 {
+			voices[i], voices[j] = voices[j], voices[i]
+		}
+	}
+
+	d.trackNoteOrder(ev.Event.Code, true)
+
+	for i, note := range voices {
+		delay := strum*time.Duration(i) + d.humanizeDelay()
+		if d.voiceOn(ev, note, channel, delay, i > 0 && strum > 0) && i == 0 {
+			d.rumbleOnNoteOn(d.velocity)
+			d.lastNoteOn = time.Now()
+			d.trackMeterVelocity(d.velocity, time.Now())
+			d.keyEffects.press(ev.Event.Code, note, d.velocity, d.theme().Effects, time.Now())
+		}
+	}
+	if d.heldNotes != nil {
+		d.heldNotes.press(d, ev.Event.Code, d.noteTracker[ev.Event.Code])
+	}
+}
 
+// voiceOn sends note on of a single voice played by the key after given delay, the voice is added to noteTracker.
+// Strummed voice isn't played when the key is released in the meantime.
+// It returns false if note on wasn't sent due to collision mode.
+func (d *Device) voiceOn(ev *input.InputEvent, note, channel byte, delay time.Duration, strum bool) bool {
 	var event midi.Event
+	velocity := d.humanizeVelocity(d.velocity)
 	switch d.config.CollisionMode {
 	case config.CollisionOff, config.CollisionRetrigger:
-		event = midi.NoteEvent(midi.NoteOn, channel, note, d.velocity)
-		d.outputEvents <- event
+		event = midi.NoteEvent(midi.NoteOn, channel, note, velocity)
+		d.sendNote(event, delay, strum)
 		if !d.noLogs { // TODO: maybe move logging outside of device, but it will need InputEvent and Device reference tho
 			log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
 		}
@@ -215,22 +360,22 @@ func (d *Device) NoteOn(ev *input.InputEvent) {
 		if d.activeNotesCounter[channel][note] > 0 {
 			break
 		}
-		event = midi.NoteEvent(midi.NoteOn, channel, note, d.velocity)
-		d.outputEvents <- event
+		event = midi.NoteEvent(midi.NoteOn, channel, note, velocity)
+		d.sendNote(event, delay, strum)
 		if !d.noLogs {
 			log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
 		}
 	case config.CollisionInterrupt:
 		if d.activeNotesCounter[channel][note] > 0 {
 			event = midi.NoteEvent(midi.NoteOff, channel, note, 0)
-			d.outputEvents <- event
+			d.sendNote(event, delay, false)
 			if !d.noLogs {
 				log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
 			}
 		}
 
-		event = midi.NoteEvent(midi.NoteOn, channel, note, d.velocity)
-		d.outputEvents <- event
+		event = midi.NoteEvent(midi.NoteOn, channel, note, velocity)
+		d.sendNote(event, delay, strum)
 		if !d.noLogs {
 			log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
 		}
@@ -238,44 +383,52 @@ func (d *Device) NoteOn(ev *input.InputEvent) {
 		panic("unsupported collision mode")
 	}
 
-	d.noteTracker[ev.Event.Code] = [2]byte{note, channel}
+	d.noteTracker[ev.Event.Code] = append(d.noteTracker[ev.Event.Code], [2]byte{note, channel})
+	d.noteChanges++
 	d.activeNotesCounter[channel][note]++
+	return event != nil
 }
 
+// NoteOff releases all voices played by the key, voices of the chord not strummed yet are not played anymore
 func (d *Device) NoteOff(ev *input.InputEvent) {
-	noteAndChannel, ok := d.noteTracker[ev.Event.Code]
+	voices, ok := d.noteTracker[ev.Event.Code]
 	if !ok {
 		return
 	}
-	note, channel := noteAndChannel[0], noteAndChannel[1]
+	d.trackNoteOrder(ev.Event.Code, false)
+	if d.heldNotes != nil {
+		d.heldNotes.release(d, ev.Event.Code)
+	}
+	d.keyEffects.release(ev.Event.Code, time.Now())
+	delete(d.noteTracker, ev.Event.Code)
+	d.noteChanges++
 
-	var event midi.Event
-	switch d.config.CollisionMode {
-	case config.CollisionOff:
-		event = midi.NoteEvent(midi.NoteOff, channel, note, 0)
-		d.outputEvents <- event
-		delete(d.noteTracker, ev.Event.Code)
-		if !d.noLogs {
-			log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
-		}
-	case config.CollisionNoRepeat, config.CollisionRetrigger, config.CollisionInterrupt:
-		if d.activeNotesCounter[channel][note] != 1 {
-			delete(d.noteTracker, ev.Event.Code)
-			break
-		}
-		event = midi.NoteEvent(midi.NoteOff, channel, note, 0)
-		d.outputEvents <- event
-		delete(d.noteTracker, ev.Event.Code)
-		if !d.noLogs {
-			log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
+	for _, noteAndChannel := range voices {
+		note, channel := noteAndChannel[0], noteAndChannel[1]
+
+		switch d.config.CollisionMode {
+		case config.CollisionOff:
+			d.voiceOff(ev, note, channel)
+		case config.CollisionNoRepeat, config.CollisionRetrigger, config.CollisionInterrupt:
+			if d.activeNotesCounter[channel][note] == 1 {
+				d.voiceOff(ev, note, channel)
+			}
 		}
+
+		d.activeNotesCounter[channel][note]--
 	}
+}
 
-	d.activeNotesCounter[channel][note]--
+func (d *Device) voiceOff(ev *input.InputEvent, note, channel byte) {
+	event := midi.NoteEvent(midi.NoteOff, channel, note, 0)
+	d.sendNote(event, d.humanizeDelay(), false)
+	if !d.noLogs {
+		log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
+	}
 }
 
 func (d *Device) AnalogNoteOn(identifier string, note byte, channelOffset byte, ev *input.InputEvent) { // TODO: multinote, collision handler
-	noteCalculatored := int(note) + int(d.octave*12) + int(d.semitone)
+	noteCalculatored := int(note) + int(d.octave)*12 + int(d.semitone)
 	if noteCalculatored < 0 || noteCalculatored > 127 {
 		return
 	}
@@ -284,6 +437,7 @@ func (d *Device) AnalogNoteOn(identifier string, note byte, channelOffset byte,
 	channel := (d.channel + channelOffset) % 16
 
 	d.analogNoteTracker[identifier] = [2]byte{note, channel}
+	d.noteChanges++
 	event := midi.NoteEvent(midi.NoteOn, channel, note, 64)
 	d.outputEvents <- event
 	if !d.noLogs {
@@ -301,6 +455,7 @@ func (d *Device) AnalogNoteOff(identifier string, ev *input.InputEvent) {
 	event := midi.NoteEvent(midi.NoteOff, channel, note, 0)
 	d.outputEvents <- event
 	delete(d.analogNoteTracker, identifier)
+	d.noteChanges++
 	if !d.noLogs {
 		log.Info(event.String(), d.logFields(logger.Keys, zap.String("handler_event", ev.Source.DeviceInfo.Event()))...)
 	}
@@ -327,6 +482,16 @@ func (d *Device) OctaveReset() {
 	}
 }
 
+func (d *Device) OctaveSet(octave int) {
+	if octave < -maxOctave || octave > maxOctave {
+		return
+	}
+	d.octave = int8(octave)
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("octave set (%d)", d.octave), d.logFields(logger.Action)...)
+	}
+}
+
 func (d *Device) SemitoneDown() {
 	d.semitone--
 	if !d.noLogs {
@@ -373,6 +538,17 @@ func (d *Device) MappingReset() {
 	}
 }
 
+// MappingSet selects mapping of given index, index outside of mappings range is ignored
+func (d *Device) MappingSet(mapping int) {
+	if mapping < 0 || mapping >= len(d.config.KeyMappings) {
+		return
+	}
+	d.mapping = mapping
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("mapping set (%s)", d.config.KeyMappings[d.mapping].Name), d.logFields(logger.Action)...)
+	}
+}
+
 func (d *Device) ChannelDown() {
 	if d.channel != 0 {
 		d.channel--
@@ -398,10 +574,23 @@ func (d *Device) ChannelReset() {
 	}
 }
 
+// ChannelSet selects given channel (0-15), channel outside of that range is ignored
+func (d *Device) ChannelSet(channel uint8) {
+	if channel > 15 {
+		return
+	}
+	d.channel = channel
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("channel set (%2d)", d.channel+1), d.logFields(logger.Action)...)
+	}
+}
+
 func (d *Device) Multinote() {
 	var pressedNotes []int
-	for _, noteAndChannel := range d.noteTracker {
-		pressedNotes = append(pressedNotes, int(noteAndChannel[0]))
+	for _, voices := range d.noteTracker {
+		for _, noteAndChannel := range voices {
+			pressedNotes = append(pressedNotes, int(noteAndChannel[0]))
+		}
 	}
 
 	if len(pressedNotes) == 0 {
@@ -438,6 +627,8 @@ func (d *Device) Multinote() {
 }
 
 func (d *Device) Panic() {
+	d.lastPanic = time.Now()
+	d.flushEffects()
 	d.outputEvents <- midi.ControlChangeEvent(d.channel, midi.AllNotesOff, 0)
 
 	// Some plugins may not respect AllNotesOff control change message, there is a simple workaround
@@ -455,17 +646,24 @@ func (d *Device) Panic() {
 		inmap[i] = make(map[byte]bool)
 	}
 	d.externalNoteTracker = inmap
+	d.externalSustain = false
+	d.publishExternal()
 	d.externalTrackerMutex.Unlock()
 }
 
 func (d *Device) CCLearningOn() {
 	d.ccLearning = true
+	d.ccLearningSource = nil
+	d.ccLearningNumber = ""
 	if !d.noLogs {
 		log.Info("CC learning mode enabled", d.logFields(logger.Action)...)
 	}
 }
 
 func (d *Device) CCLearningOff() {
+	d.ccLearningTyped()
+	d.ccLearningSource = nil
+	d.ccLearningNumber = ""
 	d.ccLearning = false
 	if !d.noLogs {
 		log.Info("CC learning mode disabled", d.logFields(logger.Action)...)
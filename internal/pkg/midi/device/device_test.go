@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/logger"
 	"github.com/gethiox/HIDI/internal/pkg/midi"
 	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
 	"github.com/holoplot/go-evdev"
@@ -15,6 +16,15 @@ import (
 	"time"
 )
 
+func TestMain(m *testing.M) {
+	// nothing displays log messages in tests, device would block on full log channel otherwise
+	go func() {
+		for range logger.Messages {
+		}
+	}()
+	os.Exit(m.Run())
+}
+
 func getFactoryKeyboardConfiguration() (config.DeviceConfig, error) {
 	data, err := os.ReadFile("../../../../cmd/hidi/hidi-config/factory/keyboard/0_default.toml")
 	if err != nil {
@@ -33,6 +43,48 @@ func getFactoryKeyboardConfiguration() (config.DeviceConfig, error) {
 	}, nil
 }
 
+// testConfig returns configuration with a single "Default" key mapping of given keys and analogs,
+// options adjust the rest of the configuration
+func testConfig(keys map[evdev.EvCode]config.Key, analogs map[evdev.EvCode]config.Analog, options ...func(*config.Config)) config.DeviceConfig {
+	if keys == nil {
+		keys = map[evdev.EvCode]config.Key{}
+	}
+	if analogs == nil {
+		analogs = map[evdev.EvCode]config.Analog{}
+	}
+
+	c := config.Config{
+		KeyMappings: []config.KeyMapping{
+			{
+				Name:            "Default",
+				Midi:            map[string]map[evdev.EvCode]config.Key{"": keys},
+				Analog:          map[string]map[evdev.EvCode]config.Analog{"": analogs},
+				Deadzones:       map[string]map[evdev.EvCode]float64{"": {}},
+				DefaultDeadzone: map[string]float64{"": 0},
+			},
+		},
+		ActionMapping: map[evdev.EvCode]config.Action{},
+		CollisionMode: config.CollisionOff,
+		Defaults:      config.Defaults{Channel: 1, Velocity: 64},
+	}
+	for _, option := range options {
+		option(&c)
+	}
+	return config.DeviceConfig{Config: c}
+}
+
+// testDevice returns device of given configuration, axes define ranges of absolute axes of the device
+func testDevice(cfg config.DeviceConfig, axes map[evdev.EvCode]evdev.AbsInfo) (*Device, chan midi.Event) {
+	inputDevice := input.Device{
+		Name:     "Dummy",
+		AbsInfos: map[string]map[evdev.EvCode]evdev.AbsInfo{"": axes},
+	}
+
+	midiEvents := make(chan midi.Event, 1024)
+	d := NewDevice(inputDevice, cfg, midiEvents, nil, true, 0, nil)
+	return &d, midiEvents
+}
+
 func readN(ch chan midi.Event, n int) ([]midi.Event, error) {
 	events := make([]midi.Event, 0, n)
 
@@ -0,0 +1,357 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+)
+
+// Note effects shape notes played by note keys: strum staggers voices of chords, note repeat retriggers
+// held notes at a clock division and humanize adds random timing and velocity deviation.
+// Delayed note messages go through the effect pipeline, handleEffects sends them to outputEvents when they are due,
+// messages of the same note keep their order.
+
+const (
+	maxAnalogStrum      = time.Millisecond * 200 // strum set by effect analog mapping at the full range
+	maxHumanizeDelay    = time.Millisecond * 20  // note delay at humanize amount 1.0
+	maxHumanizeVelocity = 16                     // velocity deviation at humanize amount 1.0
+)
+
+// effectEvent is a note message passed through the effect pipeline
+type effectEvent struct {
+	event midi.Event
+	delay time.Duration
+	strum bool // strummed note on, it is dropped with note off of the voice arriving before it's sent
+	flush bool // drops all waiting messages
+}
+
+type scheduledEvent struct {
+	at    time.Time
+	event midi.Event
+	strum bool
+}
+
+// effectNotes counts note messages waiting in the effect pipeline
+type effectNotes struct {
+	mutex   *sync.Mutex
+	waiting map[[2]byte]int // 1: note, 2: channel
+	closed  bool            // pipeline is exiting, messages are sent directly
+	sending sync.WaitGroup  // messages passed to the pipeline but not received yet
+}
+
+// noteEffects holds current effect parameters, guarded by eventProcessMutex
+type noteEffects struct {
+	strum       time.Duration
+	direction   config.StrumDirection
+	rate        int  // note repeat rate, index of config.RepeatRates
+	repeat      bool // note repeat action is held
+	tick        int  // clock ticks since song beginning, repeated notes are aligned to them
+	humanize    float64
+	humanizeOff bool // humanize disabled by the action
+	random      *rand.Rand
+}
+
+func newNoteEffects(effects config.NoteEffects) noteEffects {
+	return noteEffects{
+		strum:     effects.Strum,
+		direction: effects.StrumDirection,
+		rate:      effects.Repeat,
+		humanize:  effects.Humanize,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func noteKey(event midi.Event) [2]byte {
+	return [2]byte{event.Note(), event.Channel()}
+}
+
+// sendNote sends note message of note key, delayed messages and messages of notes
+// still waiting in the effect pipeline go through it
+func (d *Device) sendNote(event midi.Event, delay time.Duration, strum bool) {
+	key := noteKey(event)
+	d.effectNotes.mutex.Lock()
+	if d.effectNotes.closed || (delay <= 0 && d.effectNotes.waiting[key] == 0) {
+		d.effectNotes.mutex.Unlock()
+		d.outputEvents <- event
+		return
+	}
+	d.effectNotes.waiting[key]++
+	d.effectNotes.sending.Add(1)
+	d.effectNotes.mutex.Unlock()
+
+	d.effectEvents <- effectEvent{event: event, delay: delay, strum: strum}
+	d.effectNotes.sending.Done()
+}
+
+// flushEffects drops note messages waiting in the effect pipeline
+func (d *Device) flushEffects() {
+	d.effectNotes.mutex.Lock()
+	if len(d.effectNotes.waiting) == 0 || d.effectNotes.closed {
+		d.effectNotes.mutex.Unlock()
+		return
+	}
+	d.effectNotes.sending.Add(1)
+	d.effectNotes.mutex.Unlock()
+
+	d.effectEvents <- effectEvent{flush: true}
+	d.effectNotes.sending.Done()
+}
+
+// effectSent removes message from waiting ones, after it has been sent or dropped
+func (d *Device) effectSent(event midi.Event) {
+	key := noteKey(event)
+	d.effectNotes.mutex.Lock()
+	d.effectNotes.waiting[key]--
+	if d.effectNotes.waiting[key] <= 0 {
+		delete(d.effectNotes.waiting, key)
+	}
+	d.effectNotes.mutex.Unlock()
+}
+
+// scheduleEffect adds message to the queue ordered by time, the message is not sent before
+// waiting messages of the same note
+func (d *Device) scheduleEffect(queue []scheduledEvent, ev effectEvent, now time.Time) []scheduledEvent {
+	if ev.flush {
+		for _, s := range queue {
+			d.effectSent(s.event)
+		}
+		return queue[:0]
+	}
+
+	key := noteKey(ev.event)
+	last := -1
+	for i, s := range queue {
+		if noteKey(s.event) == key {
+			last = i
+		}
+	}
+
+	at := now.Add(ev.delay)
+	if last >= 0 {
+		if ev.event.Type() == midi.NoteOff && queue[last].strum {
+			// voice released before it was strummed
+			d.effectSent(queue[last].event)
+			d.effectSent(ev.event)
+			return append(queue[:last], queue[last+1:]...)
+		}
+		if at.Before(queue[last].at) {
+			at = queue[last].at
+		}
+	}
+
+	i := sort.Search(len(queue), func(i int) bool { return queue[i].at.After(at) })
+	queue = append(queue, scheduledEvent{})
+	copy(queue[i+1:], queue[i:])
+	queue[i] = scheduledEvent{at: at, event: ev.event, strum: ev.strum}
+	return queue
+}
+
+// sendDueEffects sends messages due at given time, remaining ones are returned
+func (d *Device) sendDueEffects(queue []scheduledEvent, now time.Time) []scheduledEvent {
+	var sent int
+	for _, s := range queue {
+		if s.at.After(now) {
+			break
+		}
+		d.outputEvents <- s.event
+		d.effectSent(s.event)
+		sent++
+	}
+	return append(queue[:0], queue[sent:]...)
+}
+
+// handleEffects runs the effect pipeline, waiting note offs are sent at exit and following messages
+// are not delayed anymore
+func (d *Device) handleEffects(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	var queue []scheduledEvent
+	for {
+		var due <-chan time.Time
+		if len(queue) > 0 {
+			due = time.After(time.Until(queue[0].at))
+		}
+
+		select {
+		case <-ctx.Done():
+			d.effectNotes.mutex.Lock()
+			d.effectNotes.closed = true
+			d.effectNotes.mutex.Unlock()
+
+			// messages of senders that passed the closed check before are still on their way
+			sent := make(chan bool)
+			go func() {
+				d.effectNotes.sending.Wait()
+				close(sent)
+			}()
+			for receiving := true; receiving; {
+				select {
+				case ev := <-d.effectEvents:
+					queue = d.scheduleEffect(queue, ev, time.Time{})
+				case <-sent:
+					receiving = len(d.effectEvents) > 0
+				}
+			}
+
+			for _, s := range queue {
+				if s.event.Type() == midi.NoteOff {
+					d.outputEvents <- s.event
+				}
+			}
+			return
+		case ev := <-d.effectEvents:
+			queue = d.scheduleEffect(queue, ev, time.Now())
+		case <-due:
+		}
+		queue = d.sendDueEffects(queue, time.Now())
+	}
+}
+
+// humanizeDelay returns random delay of a note message
+func (d *Device) humanizeDelay() time.Duration {
+	if d.effects.humanizeOff || d.effects.humanize == 0 {
+		return 0
+	}
+	return time.Duration(d.effects.random.Float64() * d.effects.humanize * float64(maxHumanizeDelay))
+}
+
+// humanizeVelocity returns velocity with random deviation, within 1-127 range
+func (d *Device) humanizeVelocity(velocity byte) byte {
+	if d.effects.humanizeOff || d.effects.humanize == 0 {
+		return velocity
+	}
+	deviation := (d.effects.random.Float64()*2 - 1) * d.effects.humanize * maxHumanizeVelocity
+	return byte(math.Max(1, math.Min(127, math.Round(float64(velocity)+deviation))))
+}
+
+// effectsClock retriggers held notes at note repeat rate, following midi clock messages
+func (d *Device) effectsClock(ev midi.Event) {
+	if len(ev) == 0 {
+		return
+	}
+
+	d.eventProcessMutex.Lock()
+	defer d.eventProcessMutex.Unlock()
+
+	switch ev[0] {
+	case midi.TimingStart:
+		d.effects.tick = 0
+	case midi.SongPosition:
+		if len(ev) == 3 {
+			d.effects.tick = (int(ev[2])<<7 | int(ev[1])) * stepTicks
+		}
+	case midi.TimingClock:
+		tick := d.effects.tick
+		d.effects.tick++
+		if d.effects.repeat && tick%config.RepeatRates[d.effects.rate].Ticks == 0 {
+			d.repeatNotes()
+		}
+	}
+}
+
+// repeatNotes retriggers all held notes
+func (d *Device) repeatNotes() {
+	// the same note may be held by multiple keys
+	sent := make(map[[2]byte]bool)
+	for _, code := range d.noteOrder {
+		for _, noteAndChannel := range d.noteTracker[code] {
+			if sent[noteAndChannel] {
+				continue
+			}
+			sent[noteAndChannel] = true
+
+			note, channel := noteAndChannel[0], noteAndChannel[1]
+			delay := d.humanizeDelay()
+			d.sendNote(midi.NoteEvent(midi.NoteOff, channel, note, 0), delay, false)
+			d.sendNote(midi.NoteEvent(midi.NoteOn, channel, note, d.humanizeVelocity(d.velocity)), delay, false)
+		}
+	}
+}
+
+// handleEffectAnalog sets effect parameter, centered axes are mapped with rest position in the middle of the range
+func (d *Device) handleEffectAnalog(analog config.Analog, value float64, canBeNegative bool) {
+	if canBeNegative {
+		value = (value + 1) / 2
+	}
+	value = math.Max(0, math.Min(1, value))
+
+	switch analog.Effect {
+	case config.EffectStrum:
+		d.effects.strum = time.Duration(value * float64(maxAnalogStrum)).Round(time.Millisecond)
+	case config.EffectRepeatRate:
+		d.effects.rate = int(math.Round(value * float64(len(config.RepeatRates)-1)))
+	case config.EffectHumanize:
+		d.effects.humanize = value
+	}
+}
+
+func (d *Device) StrumUp() {
+	d.setStrumDirection(config.StrumDirectionUp)
+}
+
+func (d *Device) StrumDown() {
+	d.setStrumDirection(config.StrumDirectionDown)
+}
+
+func (d *Device) setStrumDirection(direction config.StrumDirection) {
+	d.effects.direction = direction
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("strum %s (%s)", direction, d.effects.strum), d.logFields(logger.Action)...)
+	}
+}
+
+func (d *Device) NoteRepeatOn() {
+	d.effects.repeat = true
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("note repeat on (%s)", config.RepeatRates[d.effects.rate].Name), d.logFields(logger.Action)...)
+	}
+}
+
+func (d *Device) NoteRepeatOff() {
+	d.effects.repeat = false
+	if !d.noLogs {
+		log.Info("note repeat off", d.logFields(logger.Action)...)
+	}
+}
+
+func (d *Device) RepeatRateUp() {
+	d.setRepeatRate(d.effects.rate + 1)
+}
+
+func (d *Device) RepeatRateDown() {
+	d.setRepeatRate(d.effects.rate - 1)
+}
+
+func (d *Device) RepeatRateReset() {
+	d.setRepeatRate(d.config.NoteEffects.Repeat)
+}
+
+func (d *Device) setRepeatRate(rate int) {
+	if rate < 0 || rate >= len(config.RepeatRates) {
+		return
+	}
+	d.effects.rate = rate
+	if !d.noLogs {
+		log.Info(fmt.Sprintf("note repeat rate %s", config.RepeatRates[rate].Name), d.logFields(logger.Action)...)
+	}
+}
+
+func (d *Device) HumanizeToggle() {
+	d.effects.humanizeOff = !d.effects.humanizeOff
+	if d.noLogs {
+		return
+	}
+	if d.effects.humanizeOff {
+		log.Info("humanize disabled", d.logFields(logger.Action)...)
+	} else {
+		log.Info(fmt.Sprintf("humanize enabled (%.2f)", d.effects.humanize), d.logFields(logger.Action)...)
+	}
+}
@@ -0,0 +1,281 @@
+package device
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+// pipelineDevice returns device with running effect pipeline
+func pipelineDevice(t *testing.T, cfg config.DeviceConfig) (*Device, chan midi.Event) {
+	d, midiEvents := testDevice(cfg, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go d.handleEffects(ctx, wg)
+	t.Cleanup(func() {
+		cancel()
+		wg.Wait()
+	})
+	return d, midiEvents
+}
+
+func TestEffectPipeline(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+	d, midiEvents := pipelineDevice(t, cfg)
+
+	// messages of the same note keep their order
+	d.sendNote(midi.NoteEvent(midi.NoteOn, 0, 60, 64), time.Millisecond*5, false)
+	d.sendNote(midi.NoteEvent(midi.NoteOff, 0, 60, 0), 0, false)
+	d.sendNote(midi.NoteEvent(midi.NoteOn, 0, 62, 64), 0, false)
+	events, err := readN(midiEvents, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.NoteEvent(midi.NoteOn, 0, 62, 64),
+		midi.NoteEvent(midi.NoteOn, 0, 60, 64),
+		midi.NoteEvent(midi.NoteOff, 0, 60, 0),
+	}, events)
+
+	// strummed note on is dropped with its note off
+	d.sendNote(midi.NoteEvent(midi.NoteOn, 0, 60, 64), time.Millisecond*5, true)
+	d.sendNote(midi.NoteEvent(midi.NoteOff, 0, 60, 0), 0, false)
+	_, err = readN(midiEvents, 0)
+	assert.NoError(t, err)
+
+	d.sendNote(midi.NoteEvent(midi.NoteOn, 0, 60, 64), time.Millisecond*5, false)
+	d.flushEffects()
+	_, err = readN(midiEvents, 0)
+	assert.NoError(t, err)
+
+	time.Sleep(time.Millisecond)
+	d.effectNotes.mutex.Lock()
+	assert.Empty(t, d.effectNotes.waiting)
+	d.effectNotes.mutex.Unlock()
+}
+
+func TestStrumDirection(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+	cfg.Config.NoteEffects.Strum = time.Millisecond * 5
+	cfg.Config.ChordKeys = map[evdev.EvCode]config.Chord{
+		evdev.KEY_LEFTALT: {Type: "maj", Intervals: config.ChordTypes["maj"]},
+	}
+	d, midiEvents := pipelineDevice(t, cfg)
+
+	d.processEvent(key(evdev.KEY_LEFTALT, EV_KEY_PRESS))
+	start := time.Now()
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	events, err := readN(midiEvents, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(24, 28, 31), events)
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*10)
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_RELEASE))
+	_, err = readN(midiEvents, 3)
+	assert.NoError(t, err)
+
+	d.StrumDown()
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	events, err = readN(midiEvents, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(31, 28, 24), events)
+}
+
+func TestNoteRepeat(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+	d, midiEvents := pipelineDevice(t, cfg)
+	d.RepeatRateDown()
+	d.RepeatRateDown()
+	assert.Equal(t, "1/8", config.RepeatRates[d.effects.rate].Name)
+
+	d.effectsClock(midi.Event{midi.TimingStart})
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	d.invokeActionPress(config.NoteRepeat)
+	for i := 0; i < 13; i++ {
+		d.effectsClock(midi.Event{midi.TimingClock})
+	}
+	events, err := readN(midiEvents, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, append(notesOn(24), append(notesOff(24), append(notesOn(24), append(notesOff(24), notesOn(24)...)...)...)...), events)
+
+	d.invokeActionRelease(config.NoteRepeat)
+	for i := 0; i < 12; i++ {
+		d.effectsClock(midi.Event{midi.TimingClock})
+	}
+	_, err = readN(midiEvents, 0)
+	assert.NoError(t, err)
+}
+
+func TestNoteRepeatClockSource(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+	cfg.Config.Sequencer.Clock = config.SequencerInternal
+	cfg.Config.NoteEffects.Clock = config.SequencerExternal
+	d, midiEvents := pipelineDevice(t, cfg)
+
+	// note repeat follows midi input clock while the sequencer follows internal one
+	d.handleMidiInEvent(midi.Event{midi.TimingStart})
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	d.invokeActionPress(config.NoteRepeat)
+	for i := 0; i < 7; i++ {
+		d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	}
+	events, err := readN(midiEvents, 5)
+	assert.NoError(t, err)
+	assert.Equal(t, append(notesOn(24), append(notesOff(24), append(notesOn(24), append(notesOff(24), notesOn(24)...)...)...)...), events)
+
+	cfg.Config.NoteEffects.Clock = config.SequencerInternal
+	d, midiEvents = pipelineDevice(t, cfg)
+	d.handleMidiInEvent(midi.Event{midi.TimingStart})
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	d.invokeActionPress(config.NoteRepeat)
+	for i := 0; i < 7; i++ {
+		d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	}
+	events, err = readN(midiEvents, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(24), events)
+}
+
+func TestHumanize(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+	cfg.Config.NoteEffects.Humanize = 1.0
+	d, midiEvents := pipelineDevice(t, cfg)
+
+	velocities := make(map[byte]bool)
+	for i := 0; i < 20; i++ {
+		d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+		d.processEvent(key(evdev.KEY_Z, EV_KEY_RELEASE))
+	}
+	time.Sleep(maxHumanizeDelay * 2)
+	events, err := readN(midiEvents, 40)
+	assert.NoError(t, err)
+	for i, event := range events {
+		if i%2 == 0 {
+			assert.Equal(t, midi.NoteOn, event.Type(), "note off never precedes its note on")
+			assert.InDelta(t, 64, event[2], maxHumanizeVelocity)
+			velocities[event[2]] = true
+		} else {
+			assert.Equal(t, midi.NoteOff, event.Type())
+		}
+	}
+	assert.Greater(t, len(velocities), 1)
+
+	d.HumanizeToggle()
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	events, err = readN(midiEvents, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, notesOn(24), events)
+}
+
+func TestEffectsShutdown(t *testing.T) {
+	for name, effects := range map[string]config.NoteEffects{
+		"strum":    {Strum: time.Millisecond * 50},
+		"humanize": {Humanize: 1.0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := getFactoryKeyboardConfiguration()
+			if !assert.NoError(t, err) {
+				return
+			}
+			cfg.Config.NoteEffects = effects
+			cfg.Config.ChordKeys = map[evdev.EvCode]config.Chord{
+				evdev.KEY_LEFTALT: {Type: "maj", Intervals: config.ChordTypes["maj"]},
+			}
+			d, midiEvents := testDevice(cfg, nil)
+
+			inputEvents := make(chan *input.InputEvent)
+			done := make(chan bool)
+			go func() {
+				d.ProcessEvents(inputEvents)
+				close(done)
+			}()
+
+			// device unplugged while played notes are still waiting in the pipeline,
+			// there are more of them than the pipeline buffer holds
+			inputEvents <- key(evdev.KEY_LEFTALT, EV_KEY_PRESS)
+			for _, code := range []evdev.EvCode{evdev.KEY_Z, evdev.KEY_X, evdev.KEY_C, evdev.KEY_V, evdev.KEY_B, evdev.KEY_N, evdev.KEY_M} {
+				inputEvents <- key(code, EV_KEY_PRESS)
+			}
+			close(inputEvents)
+
+			select {
+			case <-done:
+			case <-time.After(time.Second):
+				t.Fatal("device didn't exit")
+			}
+
+			events, _ := readN(midiEvents, 64) // all remaining events, their number depends on the effect
+			held := make(map[byte]bool)
+			for _, event := range events {
+				switch event.Type() {
+				case midi.NoteOn:
+					held[event.Note()] = true
+				case midi.NoteOff:
+					delete(held, event.Note())
+				}
+			}
+			assert.Empty(t, held, "every played note is released")
+		})
+	}
+}
+
+func TestEffectsExitWaitsForSenders(t *testing.T) {
+	d, midiEvents := testDevice(testConfig(nil, nil), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go d.handleEffects(ctx, wg)
+
+	// sender passed the closed check, pipeline exits before its message arrives
+	noteOff := midi.NoteEvent(midi.NoteOff, 0, 60, 0)
+	d.effectNotes.mutex.Lock()
+	d.effectNotes.waiting[noteKey(noteOff)]++
+	d.effectNotes.sending.Add(1)
+	d.effectNotes.mutex.Unlock()
+
+	cancel()
+	time.Sleep(time.Millisecond * 10)
+	d.effectEvents <- effectEvent{event: noteOff, delay: time.Hour}
+	d.effectNotes.sending.Done()
+
+	wg.Wait()
+	events, err := readN(midiEvents, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, []midi.Event{noteOff}, events)
+	assert.Empty(t, d.effectEvents)
+}
+
+func TestEffectAnalog(t *testing.T) {
+	d := &Device{effects: newNoteEffects(config.NoteEffects{})}
+
+	d.handleEffectAnalog(config.Analog{Effect: config.EffectStrum}, 0.5, false)
+	assert.Equal(t, maxAnalogStrum/2, d.effects.strum)
+	d.handleEffectAnalog(config.Analog{Effect: config.EffectRepeatRate}, 1.0, true)
+	assert.Equal(t, len(config.RepeatRates)-1, d.effects.rate)
+	d.handleEffectAnalog(config.Analog{Effect: config.EffectRepeatRate}, -1.0, true)
+	assert.Equal(t, 0, d.effects.rate)
+	d.handleEffectAnalog(config.Analog{Effect: config.EffectHumanize}, 0.0, true)
+	assert.Equal(t, 0.5, d.effects.humanize)
+}
@@ -12,6 +12,7 @@ import (
 	"math"
 	"sync"
 	"syscall"
+	"time"
 )
 
 func (d *Device) checkExitSequence() bool {
@@ -44,6 +45,23 @@ func (d *Device) handleKEYEvent(ie *input.InputEvent) {
 		delete(d.keyTracker, ie.Event.Code)
 	}
 
+	control := config.Control{SubHandler: ie.Source.Name, Type: evdev.EV_KEY, Code: ie.Event.Code}
+	if d.ccLearning && ie.Event.Value == EV_KEY_PRESS && !(actionOk && action == config.Learning) {
+		d.ccLearningKey(control)
+		return
+	}
+	if cc, ok := d.config.CCLearned[control]; ok {
+		d.handleLearnedKey(control, cc, ie.Event.Value)
+		return
+	}
+	if d.sequencerMode && !(actionOk && action == config.StepSequencer) && d.sequencerKey(ie) {
+		return
+	}
+	if _, ok := d.config.ChordKeys[ie.Event.Code]; ok {
+		d.chordKey(ie.Event.Code, ie.Event.Value)
+		return
+	}
+
 	switch {
 	case actionOk:
 		switch ie.Event.Value {
@@ -95,6 +113,19 @@ func (d *Device) handleKEYEvent(ie *input.InputEvent) {
 func (d *Device) handleABSEvent(ie *input.InputEvent) {
 	analog, analogOk := d.config.KeyMappings[d.mapping].Analog[ie.Source.Name][ie.Event.Code]
 
+	if d.ccLearning {
+		d.ccLearningAxis(ie, analog)
+	}
+
+	control := config.Control{SubHandler: ie.Source.Name, Type: evdev.EV_ABS, Code: ie.Event.Code}
+	cc, learned := d.config.CCLearned[control]
+	if learned {
+		analog, analogOk = learnedAnalog(analog, cc), true
+		if d.lastAnalogValue[ie.Source.Name] == nil {
+			d.lastAnalogValue[ie.Source.Name] = make(map[evdev.EvCode]float64)
+		}
+	}
+
 	if !analogOk {
 		if !d.noLogs {
 			log.Info(fmt.Sprintf("Undefined ABS event: %s", ie.Event.String()), d.logFields(
@@ -106,27 +137,20 @@ func (d *Device) handleABSEvent(ie *input.InputEvent) {
 		return
 	}
 
-	// converting integer value to float and applying deadzone
-	// -1.0 - 1.0 range if negative values are included, 0.0 - 1.0 otherwise
-	var value float64
-	var canBeNegative bool
-	absInfo := d.InputDevice.AbsInfos[ie.Source.DeviceInfo.Event()][ie.Event.Code]
-	min := absInfo.Minimum
-	max := absInfo.Maximum
-
-	if min < 0 {
-		canBeNegative = true
+	// motion sensors are not regular axes, they are processed separately
+	if analog.MappingType == config.AnalogMotion {
+		d.handleMotion(ie, analog)
+		return
 	}
 
-	// Normalize Value
-	if ie.Event.Value < 0 {
-		value = float64(ie.Event.Value) / math.Abs(float64(min))
-	} else {
-		value = float64(ie.Event.Value) / math.Abs(float64(max))
-	}
+	// converting integer value to float and applying deadzone
+	// -1.0 - 1.0 range if negative values are included, 0.0 - 1.0 otherwise
+	axis := d.axisRange(ie, analog)
+	value, canBeNegative := axis.normalize(ie.Event.Value)
 
 	// Put it always between -1.0 and 1.0 so we can deadzone the center
-	if analog.DeadzoneAtCenter {
+	// (calibrated center already gives centered values)
+	if analog.DeadzoneAtCenter && !axis.hasCenter {
 		value = value*2 - 1.0
 		canBeNegative = true
 	}
@@ -136,7 +160,7 @@ func (d *Device) handleABSEvent(ie *input.InputEvent) {
 		deadzone, ok = d.config.KeyMappings[d.mapping].DefaultDeadzone[ie.Source.Name]
 		if !ok {
 			deadzone, ok = d.config.KeyMappings[d.mapping].DefaultDeadzone[""]
-			if !ok {
+			if !ok && !learned { // learned axes may not be mapped at all
 				panic("tee hee")
 			}
 		}
@@ -156,6 +180,8 @@ func (d *Device) handleABSEvent(ie *input.InputEvent) {
 		}
 	}
 
+	value = d.smoothAnalog(ie, analog, value)
+
 	// prevent from repeating value that was already sent before
 	lastValue := d.lastAnalogValue[ie.Source.Name][ie.Event.Code]
 	if lastValue == value {
@@ -171,10 +197,23 @@ func (d *Device) handleABSEvent(ie *input.InputEvent) {
 		}
 	}
 
+	value = applyCurve(analog.Curve, value)
+
+	// unlike flip_axis, output range is inverted after applying the curve
+	if analog.InvertRange {
+		if canBeNegative {
+			value = -value
+		} else {
+			value = 1.0 - value
+		}
+	}
+
 	if d.ccLearning && !(value < -0.5 || value > 0.5) {
 		return
 	}
 
+	d.trackMeterAnalog(ie.Event.Code, analog.MappingType, value, canBeNegative)
+
 	if !d.noLogs {
 		log.Info(fmt.Sprintf("Analog event: %s", ie.Event.String()), d.logFields(
 			logger.Analog,
@@ -233,6 +272,16 @@ func (d *Device) handleABSEvent(ie *input.InputEvent) {
 			adjustedValue = value
 			d.outputEvents <- midi.ControlChangeEvent(channel, analog.CC, byte(int(float64(127)*adjustedValue)))
 		}
+
+		if analog.CC == midi.Sustain && !analog.Bidirectional {
+			d.sustain = adjustedValue >= 0.5
+		}
+	case config.AnalogCC14, config.AnalogNRPN:
+		d.handleHiResAnalog(ie, analog, value, canBeNegative)
+	case config.AnalogChannelPressure, config.AnalogPolyPressure:
+		d.handlePressureAnalog(analog, value, canBeNegative)
+	case config.AnalogEffect:
+		d.handleEffectAnalog(analog, value, canBeNegative)
 	case config.AnalogPitchBend:
 		channel := (d.channel + analog.ChannelOffset) % 16
 		if canBeNegative {
@@ -315,10 +364,16 @@ func (d *Device) processEvent(event *input.InputEvent) {
 	case evdev.EV_KEY:
 		d.eventProcessMutex.Lock()
 		d.handleKEYEvent(event)
+		d.publishState()
 		d.eventProcessMutex.Unlock()
 	case evdev.EV_ABS:
 		d.eventProcessMutex.Lock()
 		d.handleABSEvent(event)
+		d.publishChangedState()
+		d.eventProcessMutex.Unlock()
+	case evdev.EV_REL:
+		d.eventProcessMutex.Lock()
+		d.handleRELEvent(event)
 		d.eventProcessMutex.Unlock()
 	}
 }
@@ -330,9 +385,18 @@ func (d *Device) ProcessEvents(inputEvents <-chan *input.InputEvent) {
 
 	ctx, cancel := context.WithCancel(context.Background())
 
-	wg.Add(2)
+	wg.Add(7)
 	go d.handleOpenrgb(ctx, &wg)
+	go d.handleOpenrgbControllers(ctx, &wg)
 	go d.handleInputEvents(ctx, &wg)
+	go d.handleRumble(ctx, &wg)
+	go d.handleLEDs(ctx, &wg)
+	go d.handleLightbar(ctx, &wg)
+	go d.handleSequencer(ctx, &wg)
+
+	effectsWg := sync.WaitGroup{}
+	effectsWg.Add(1)
+	go d.handleEffects(ctx, &effectsWg)
 
 	for ie := range inputEvents {
 		d.processEvent(ie)
@@ -340,6 +404,14 @@ func (d *Device) ProcessEvents(inputEvents <-chan *input.InputEvent) {
 	cancel()
 	log.Info("input events closed", d.logFields(logger.Debug)...)
 
+	// effect pipeline exits first, cleanup note offs are sent directly then
+	effectsWg.Wait()
+
+	d.eventProcessMutex.Lock()
+	d.stopHiResThrottles()
+	d.seq.releaseNotes(d)
+	d.eventProcessMutex.Unlock()
+
 	if len(d.noteTracker) > 0 || len(d.analogNoteTracker) > 0 {
 		log.Info("active midi notes cleanup", d.logFields(logger.Debug)...)
 	}
@@ -376,17 +448,45 @@ root:
 		case <-ctx.Done():
 			break root
 		case ev := <-d.midiIn:
-			switch ev.Type() {
-			case midi.NoteOn:
-				d.externalTrackerMutex.Lock()
-				d.externalNoteTracker[ev.Channel()][ev.Note()] = true
-				d.externalTrackerMutex.Unlock()
-			case midi.NoteOff:
-				d.externalTrackerMutex.Lock()
-				delete(d.externalNoteTracker[ev.Channel()], ev.Note())
-				d.externalTrackerMutex.Unlock()
-			}
+			d.handleMidiInEvent(ev)
 		}
 	}
 	log.Info(fmt.Sprintf("processing midi events done"), d.logFields(logger.Debug)...)
 }
+
+func (d *Device) handleMidiInEvent(ev midi.Event) {
+	d.rumbleOnMidiIn(ev)
+	if ev.Type() == midi.ControlChange && len(ev) == 3 && d.ccLearningMidiIn(ev[1]) {
+		return
+	}
+	d.handleMidiInRules(ev)
+	if d.sequencerClockSource() == config.SequencerExternal {
+		d.sequencerClock(ev)
+	}
+	if d.effectsClockSource() == config.SequencerExternal {
+		d.effectsClock(ev)
+	}
+
+	d.externalTrackerMutex.Lock()
+	defer d.externalTrackerMutex.Unlock()
+
+	switch ev.Type() {
+	case midi.NoteOn:
+		d.externalNoteTracker[ev.Channel()][ev.Note()] = true
+	case midi.NoteOff:
+		delete(d.externalNoteTracker[ev.Channel()], ev.Note())
+	case midi.ControlChange:
+		if len(ev) != 3 || ev[1] != midi.Sustain {
+			return
+		}
+		d.externalSustain = ev[2] >= 64
+	case midi.TimingStart:
+		d.clockTicks = 0
+	case midi.TimingClock:
+		d.clockTicks++
+		d.lastClock = time.Now()
+	default:
+		return
+	}
+	d.publishExternal()
+}
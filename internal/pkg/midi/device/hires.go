@@ -0,0 +1,157 @@
+package device
+
+import (
+	"math"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// hiResTracker keeps track of already transmitted 14-bit controller state,
+// so redundant parts of messages (unchanged MSB, repeated NRPN parameter selection) are not sent again
+type hiResTracker struct {
+	cc14      map[[2]byte]uint16   // key: channel, cc
+	nrpn      map[[2]uint16]uint16 // key: channel, parameter number
+	nrpnParam map[byte]uint16      // currently selected parameter number per channel
+}
+
+func newHiResTracker() hiResTracker {
+	return hiResTracker{
+		cc14:      make(map[[2]byte]uint16),
+		nrpn:      make(map[[2]uint16]uint16),
+		nrpnParam: make(map[byte]uint16),
+	}
+}
+
+// cc14Events returns MSB/LSB pair for CC n and n+32, MSB is skipped when receiver already have it
+func (t *hiResTracker) cc14Events(channel, cc byte, value uint16) []midi.Event {
+	key := [2]byte{channel, cc}
+	last, ok := t.cc14[key]
+	if ok && last == value {
+		return nil
+	}
+	t.cc14[key] = value
+
+	events := make([]midi.Event, 0, 2)
+	if !ok || last>>7 != value>>7 {
+		events = append(events, midi.ControlChangeEvent(channel, cc, byte(value>>7)))
+	}
+	return append(events, midi.ControlChangeEvent(channel, cc+32, byte(value&0x7f)))
+}
+
+// nrpnEvents returns parameter selection followed by data entry pair,
+// parameter is selected only when it differs from the previously selected one on that channel
+func (t *hiResTracker) nrpnEvents(channel byte, param, value uint16) []midi.Event {
+	key := [2]uint16{uint16(channel), param}
+	last, ok := t.nrpn[key]
+	if ok && last == value {
+		return nil
+	}
+	t.nrpn[key] = value
+
+	events := make([]midi.Event, 0, 4)
+	selected, paramOk := t.nrpnParam[channel]
+	if !paramOk || selected != param {
+		events = append(events,
+			midi.ControlChangeEvent(channel, midi.NRPNMSB, byte(param>>7)),
+			midi.ControlChangeEvent(channel, midi.NRPNLSB, byte(param&0x7f)),
+		)
+		t.nrpnParam[channel] = param
+		ok = false
+	}
+	if !ok || last>>7 != value>>7 {
+		events = append(events, midi.ControlChangeEvent(channel, midi.DataEntryMSB, byte(value>>7)))
+	}
+	return append(events, midi.ControlChangeEvent(channel, midi.DataEntryLSB, byte(value&0x7f)))
+}
+
+// hiResThrottle limits rate of 14-bit messages of a single axis to the throughput of serial MIDI link.
+// Values arriving too fast are coalesced, the latest one is sent when the link becomes available again.
+type hiResThrottle struct {
+	next    time.Time
+	pending func() []midi.Event
+	timer   *time.Timer
+}
+
+// sendHiRes sends events produced by build function, build is called at the time of actual transmission,
+// so its output reflects the most recent receiver state
+func (d *Device) sendHiRes(ie *input.InputEvent, build func() []midi.Event) {
+	throttles, ok := d.hiResThrottles[ie.Source.Name]
+	if !ok {
+		throttles = make(map[evdev.EvCode]*hiResThrottle)
+		d.hiResThrottles[ie.Source.Name] = throttles
+	}
+	t, ok := throttles[ie.Event.Code]
+	if !ok {
+		t = &hiResThrottle{}
+		throttles[ie.Event.Code] = t
+	}
+
+	now := time.Now()
+	if now.Before(t.next) {
+		t.pending = build
+		if t.timer == nil {
+			t.timer = time.AfterFunc(t.next.Sub(now), func() {
+				d.eventProcessMutex.Lock()
+				defer d.eventProcessMutex.Unlock()
+				t.timer = nil
+				if t.pending != nil {
+					d.emitHiRes(t, t.pending)
+				}
+			})
+		}
+		return
+	}
+
+	d.emitHiRes(t, build)
+}
+
+// stopHiResThrottles drops values still waiting for the link, so no stale message is sent after device shutdown.
+// Must be called with eventProcessMutex held, timer callbacks already waiting for the mutex find nothing pending.
+func (d *Device) stopHiResThrottles() {
+	for _, throttles := range d.hiResThrottles {
+		for _, t := range throttles {
+			if t.timer != nil {
+				t.timer.Stop()
+				t.timer = nil
+			}
+			t.pending = nil
+		}
+	}
+}
+
+func (d *Device) emitHiRes(t *hiResThrottle, build func() []midi.Event) {
+	t.pending = nil
+	events := build()
+	for _, e := range events {
+		d.outputEvents <- e
+	}
+	t.next = time.Now().Add(midi.ByteTime * time.Duration(midi.WireSize(events...)))
+}
+
+// hiResValue converts analog value into 14-bit range, centered axes are mapped with rest position at 8192
+func hiResValue(value float64, canBeNegative bool) uint16 {
+	if canBeNegative {
+		value = (value + 1) / 2
+	}
+	return uint16(math.Round(math.Max(0, math.Min(1, value)) * 16383))
+}
+
+func (d *Device) handleHiResAnalog(ie *input.InputEvent, analog config.Analog, value float64, canBeNegative bool) {
+	channel := (d.channel + analog.ChannelOffset) % 16
+	target := hiResValue(value, canBeNegative)
+
+	switch analog.MappingType {
+	case config.AnalogCC14:
+		d.sendHiRes(ie, func() []midi.Event {
+			return d.hiResTracker.cc14Events(channel, analog.CC, target)
+		})
+	case config.AnalogNRPN:
+		d.sendHiRes(ie, func() []midi.Event {
+			return d.hiResTracker.nrpnEvents(channel, analog.NRPN, target)
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHiResTrackerCC14(t *testing.T) {
+	tracker := newHiResTracker()
+
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 1, 0x40),
+		midi.ControlChangeEvent(0, 33, 0x00),
+	}, tracker.cc14Events(0, 1, 8192))
+
+	// only LSB changed
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 33, 0x05),
+	}, tracker.cc14Events(0, 1, 8197))
+
+	// nothing changed
+	assert.Nil(t, tracker.cc14Events(0, 1, 8197))
+
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 1, 0x7f),
+		midi.ControlChangeEvent(0, 33, 0x7f),
+	}, tracker.cc14Events(0, 1, 16383))
+
+	// different channel is tracked separately
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(1, 1, 0x7f),
+		midi.ControlChangeEvent(1, 33, 0x7f),
+	}, tracker.cc14Events(1, 1, 16383))
+}
+
+func TestHiResTrackerNRPN(t *testing.T) {
+	tracker := newHiResTracker()
+
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, midi.NRPNMSB, 0x01),
+		midi.ControlChangeEvent(0, midi.NRPNLSB, 0x02),
+		midi.ControlChangeEvent(0, midi.DataEntryMSB, 0x40),
+		midi.ControlChangeEvent(0, midi.DataEntryLSB, 0x00),
+	}, tracker.nrpnEvents(0, 130, 8192))
+
+	// parameter already selected, only LSB changed
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, midi.DataEntryLSB, 0x01),
+	}, tracker.nrpnEvents(0, 130, 8193))
+
+	// another parameter selected in between
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, midi.NRPNMSB, 0x00),
+		midi.ControlChangeEvent(0, midi.NRPNLSB, 0x05),
+		midi.ControlChangeEvent(0, midi.DataEntryMSB, 0x00),
+		midi.ControlChangeEvent(0, midi.DataEntryLSB, 0x00),
+	}, tracker.nrpnEvents(0, 5, 0))
+
+	// re-selecting parameter requires full data entry
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, midi.NRPNMSB, 0x01),
+		midi.ControlChangeEvent(0, midi.NRPNLSB, 0x02),
+		midi.ControlChangeEvent(0, midi.DataEntryMSB, 0x40),
+		midi.ControlChangeEvent(0, midi.DataEntryLSB, 0x02),
+	}, tracker.nrpnEvents(0, 130, 8194))
+}
+
+func abs(code evdev.EvCode, value int32) *input.InputEvent {
+	ie := key(code, value)
+	ie.Event.Type = evdev.EV_ABS
+	return ie
+}
+
+func hiResDevice() (*Device, chan midi.Event) {
+	analogs := map[evdev.EvCode]config.Analog{evdev.ABS_Z: {MappingType: config.AnalogCC14, CC: 1}}
+	return testDevice(testConfig(nil, analogs), map[evdev.EvCode]evdev.AbsInfo{
+		evdev.ABS_Z: {Minimum: 0, Maximum: 16383},
+	})
+}
+
+func TestHiResThrottle(t *testing.T) {
+	d, midiEvents := hiResDevice()
+
+	// burst of values faster than serial link is able to transmit
+	for _, v := range []int32{1000, 1001, 1002, 1003, 1004} {
+		d.processEvent(abs(evdev.ABS_Z, v))
+	}
+
+	time.Sleep(time.Millisecond * 10)
+
+	events, err := readN(midiEvents, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 1, 1000>>7),
+		midi.ControlChangeEvent(0, 33, 1000&0x7f),
+		// intermediate values coalesced, last one sent as soon as link is available
+		midi.ControlChangeEvent(0, 33, 1004&0x7f),
+	}, events)
+}
+
+func TestHiResThrottleShutdown(t *testing.T) {
+	d, midiEvents := hiResDevice()
+
+	inputEvents := make(chan *input.InputEvent)
+	done := make(chan bool)
+	go func() {
+		d.ProcessEvents(inputEvents)
+		done <- true
+	}()
+
+	inputEvents <- abs(evdev.ABS_Z, 1000)
+	inputEvents <- abs(evdev.ABS_Z, 1004)
+	close(inputEvents)
+	<-done
+
+	time.Sleep(time.Millisecond * 10)
+
+	// value throttled at the time of unplug is dropped instead of being sent after shutdown
+	events, err := readN(midiEvents, 3)
+	assert.NotNil(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 1, 1000>>7),
+		midi.ControlChangeEvent(0, 33, 1000&0x7f),
+	}, events)
+}
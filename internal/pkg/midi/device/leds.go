@@ -0,0 +1,117 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+const (
+	clockBlinkTicks = 6                      // clock indicator lights up for a sixteenth note on every beat
+	clockTimeout    = time.Millisecond * 500 // clock indicator goes off when midi clock is not received
+)
+
+// ledController switches indicator LEDs, implemented by input.LEDs
+type ledController interface {
+	Set(code evdev.EvCode, on bool) error
+	Close() error
+}
+
+// indicatorState tells if given indicator should be lit in published device and midi input state
+func indicatorState(indicator config.LEDIndicator, state *deviceSnapshot, external *externalSnapshot, now time.Time) bool {
+	switch indicator {
+	case config.IndicatorOctave:
+		return state.octave != 0
+	case config.IndicatorSemitone:
+		return state.semitone != 0
+	case config.IndicatorChannel:
+		return state.channel != 0
+	case config.IndicatorMapping:
+		return state.mapping != 0
+	case config.IndicatorMultinote:
+		return state.multinote
+	case config.IndicatorCCLearning:
+		return state.ccLearning
+	case config.IndicatorNotes:
+		return len(state.notes)+len(state.analogNotes) > 0
+	case config.IndicatorSustain:
+		return state.sustain || external.sustain
+	case config.IndicatorClock:
+		if now.Sub(external.lastClock) > clockTimeout {
+			return false
+		}
+		// clockTicks is incremented on every tick, first tick of the beat is counted as 1
+		return (external.clockTicks-1)%24 < clockBlinkTicks
+	}
+	return false
+}
+
+// ledStates returns expected state of all configured LEDs
+func (d *Device) ledStates(now time.Time) map[evdev.EvCode]bool {
+	state, external := d.deviceState(), d.externalState()
+
+	states := make(map[evdev.EvCode]bool, len(d.config.LEDIndicators))
+	for code, indicator := range d.config.LEDIndicators {
+		states[code] = indicatorState(indicator, state, external, now)
+	}
+	return states
+}
+
+// updateLEDs writes LED states that differ from the current ones
+func (d *Device) updateLEDs(leds ledController, current map[evdev.EvCode]bool, now time.Time) error {
+	for code, on := range d.ledStates(now) {
+		if state, ok := current[code]; ok && state == on {
+			continue
+		}
+		err := leds.Set(code, on)
+		if err != nil {
+			return err
+		}
+		current[code] = on
+	}
+	return nil
+}
+
+func (d *Device) openLEDs() (ledController, error) {
+	handler, ok := d.InputDevice.LEDHandler()
+	if !ok {
+		return nil, fmt.Errorf("device doesn't have LEDs")
+	}
+	return input.OpenLEDs(handler.DeviceInfo.EventPath())
+}
+
+func (d *Device) handleLEDs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if len(d.config.LEDIndicators) == 0 {
+		return
+	}
+
+	leds, err := d.openLEDs()
+	if err != nil {
+		log.Info(fmt.Sprintf("led indicators disabled: %s", err), d.logFields(logger.Warning)...)
+		return
+	}
+	defer leds.Close()
+
+	current := make(map[evdev.EvCode]bool, len(d.config.LEDIndicators))
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Millisecond * 10):
+		}
+
+		err := d.updateLEDs(leds, current, time.Now())
+		if err != nil {
+			log.Info(fmt.Sprintf("led indicators update failed: %s", err), d.logFields(logger.Warning)...)
+			return
+		}
+	}
+}
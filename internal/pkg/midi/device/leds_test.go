@@ -0,0 +1,88 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLEDs map[evdev.EvCode]bool
+
+func (f fakeLEDs) Set(code evdev.EvCode, on bool) error {
+	f[code] = on
+	return nil
+}
+
+func (f fakeLEDs) Close() error {
+	return nil
+}
+
+func ledsDevice() *Device {
+	analogs := map[evdev.EvCode]config.Analog{evdev.ABS_Z: {MappingType: config.AnalogCC, CC: midi.Sustain}}
+	d, _ := testDevice(testConfig(nil, analogs, func(c *config.Config) {
+		c.ActionMapping[evdev.KEY_F1] = config.OctaveDown
+		c.ActionMapping[evdev.KEY_F2] = config.OctaveUp
+		c.LEDIndicators = map[evdev.EvCode]config.LEDIndicator{
+			evdev.LED_CAPSL:   config.IndicatorOctave,
+			evdev.LED_NUML:    config.IndicatorClock,
+			evdev.LED_SCROLLL: config.IndicatorSustain,
+		}
+	}), map[evdev.EvCode]evdev.AbsInfo{evdev.ABS_Z: {Minimum: 0, Maximum: 255}})
+	return d
+}
+
+func TestLEDIndicators(t *testing.T) {
+	d := ledsDevice()
+	leds := fakeLEDs{}
+	current := map[evdev.EvCode]bool{}
+	now := time.Now()
+
+	assert.Nil(t, d.updateLEDs(leds, current, now))
+	assert.Equal(t, fakeLEDs{evdev.LED_CAPSL: false, evdev.LED_NUML: false, evdev.LED_SCROLLL: false}, leds)
+
+	// octave shifted, sustain held by analog mapping
+	d.processEvent(key(evdev.KEY_F2, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_F2, EV_KEY_RELEASE))
+	d.processEvent(abs(evdev.ABS_Z, 255))
+	assert.Nil(t, d.updateLEDs(leds, current, now))
+	assert.Equal(t, fakeLEDs{evdev.LED_CAPSL: true, evdev.LED_NUML: false, evdev.LED_SCROLLL: true}, leds)
+
+	// pedal released on device, but still held on midi input
+	d.processEvent(abs(evdev.ABS_Z, 0))
+	d.handleMidiInEvent(midi.ControlChangeEvent(0, midi.Sustain, 127))
+	assert.Nil(t, d.updateLEDs(leds, current, now))
+	assert.True(t, leds[evdev.LED_SCROLLL])
+
+	d.handleMidiInEvent(midi.ControlChangeEvent(0, midi.Sustain, 0))
+	d.processEvent(key(evdev.KEY_F1, EV_KEY_PRESS))
+	d.processEvent(key(evdev.KEY_F1, EV_KEY_RELEASE))
+	assert.Nil(t, d.updateLEDs(leds, current, now))
+	assert.Equal(t, fakeLEDs{evdev.LED_CAPSL: false, evdev.LED_NUML: false, evdev.LED_SCROLLL: false}, leds)
+}
+
+func TestLEDIndicatorClock(t *testing.T) {
+	d := ledsDevice()
+	leds := fakeLEDs{}
+	current := map[evdev.EvCode]bool{}
+
+	d.handleMidiInEvent(midi.Event{midi.TimingStart})
+
+	var blink []bool
+	for i := 0; i < 48; i++ {
+		d.handleMidiInEvent(midi.Event{midi.TimingClock})
+		assert.Nil(t, d.updateLEDs(leds, current, time.Now()))
+		blink = append(blink, leds[evdev.LED_NUML])
+	}
+
+	for i, on := range blink {
+		assert.Equal(t, i%24 < clockBlinkTicks, on, "tick %d", i)
+	}
+
+	// clock stopped
+	assert.Nil(t, d.updateLEDs(leds, current, time.Now().Add(time.Second)))
+	assert.False(t, leds[evdev.LED_NUML])
+}
@@ -0,0 +1,113 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/realbucksavage/openrgb-go"
+)
+
+const (
+	lightbarInterval   = time.Millisecond * 20
+	lightbarFlashTime  = time.Millisecond * 600 // panic flash duration, red blinks 3 times
+	lightbarFlashBlink = time.Millisecond * 100
+	lightbarPulseTime  = time.Millisecond * 300 // note pulse fade out time
+	lightbarPulseIdle  = 0.2                    // brightness between note pulses
+)
+
+// lightbarController sets gamepad lightbar color, implemented by input.Lightbar
+type lightbarController interface {
+	Set(red, green, blue uint8) error
+	Close() error
+}
+
+// lightbarColor returns lightbar color for given device state
+func lightbarColor(cfg config.Lightbar, state *deviceSnapshot, now time.Time) openrgb.Color {
+	if cfg.PanicFlash {
+		if since := now.Sub(state.lastPanic); since < lightbarFlashTime {
+			if (since/lightbarFlashBlink)%2 == 0 {
+				return dimColor(openrgb.Color{Red: 0xff}, cfg.Brightness)
+			}
+			return openrgb.Color{}
+		}
+	}
+
+	var color openrgb.Color
+	switch cfg.Mode {
+	case config.LightbarChannel:
+		color = channelColors[state.channel]
+	case config.LightbarMapping:
+		color = channelColors[byte(state.mapping%16)]
+	case config.LightbarStatic:
+		color = cfg.Color
+	}
+
+	brightness := cfg.Brightness
+	if cfg.NotePulse {
+		pulse := 0.0
+		if since := now.Sub(state.lastNoteOn); since < lightbarPulseTime {
+			pulse = 1 - float64(since)/float64(lightbarPulseTime)
+		}
+		brightness *= lightbarPulseIdle + (1-lightbarPulseIdle)*pulse
+	}
+
+	return dimColor(color, brightness)
+}
+
+func (d *Device) openLightbar() (lightbarController, error) {
+	for _, handler := range d.InputDevice.Handlers {
+		l, err := input.OpenLightbar(d.sysfsRoot, handler.DeviceInfo.Event())
+		if err == nil {
+			return l, nil
+		}
+	}
+	return nil, fmt.Errorf("device doesn't have a lightbar")
+}
+
+// updateLightbar sets lightbar color when it differs from the current one, nil current color stands for unknown one
+func (d *Device) updateLightbar(lightbar lightbarController, current *openrgb.Color, now time.Time) (*openrgb.Color, error) {
+	color := lightbarColor(d.config.Lightbar, d.deviceState(), now)
+	if current != nil && *current == color {
+		return current, nil
+	}
+	err := lightbar.Set(color.Red, color.Green, color.Blue)
+	if err != nil {
+		return current, err
+	}
+	return &color, nil
+}
+
+func (d *Device) handleLightbar(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if d.config.Lightbar.Mode == "" {
+		return
+	}
+
+	lightbar, err := d.openLightbar()
+	if err != nil {
+		log.Info(fmt.Sprintf("lightbar disabled: %s", err), d.logFields(logger.Warning)...)
+		return
+	}
+	defer lightbar.Close()
+
+	var current *openrgb.Color
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(lightbarInterval):
+		}
+
+		current, err = d.updateLightbar(lightbar, current, time.Now())
+		if err != nil {
+			log.Info(fmt.Sprintf("lightbar update failed: %s", err), d.logFields(logger.Warning)...)
+			return
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLightbar struct {
+	colors []openrgb.Color
+}
+
+func (f *fakeLightbar) Set(red, green, blue uint8) error {
+	f.colors = append(f.colors, openrgb.Color{Red: red, Green: green, Blue: blue})
+	return nil
+}
+
+func (f *fakeLightbar) Close() error {
+	return nil
+}
+
+func lightbarDevice(lightbar config.Lightbar) *Device {
+	d, _ := testDevice(testConfig(map[evdev.EvCode]config.Key{evdev.BTN_SOUTH: {Note: 60}}, nil, func(c *config.Config) {
+		c.KeyMappings = append(c.KeyMappings, config.KeyMapping{
+			Name: "Other", Midi: map[string]map[evdev.EvCode]config.Key{"": {}},
+		})
+		c.ActionMapping[evdev.BTN_START] = config.Panic
+		c.ActionMapping[evdev.BTN_TR] = config.ChannelUp
+		c.ActionMapping[evdev.BTN_SELECT] = config.MappingUp
+		c.Lightbar = lightbar
+	}), nil)
+	return d
+}
+
+func TestLightbarColor(t *testing.T) {
+	d := lightbarDevice(config.Lightbar{Mode: config.LightbarChannel, Brightness: 1.0, PanicFlash: true})
+	now := time.Now()
+
+	assert.Equal(t, channelColors[0], lightbarColor(d.config.Lightbar, d.deviceState(), now))
+
+	d.processEvent(key(evdev.BTN_TR, EV_KEY_PRESS))
+	assert.Equal(t, channelColors[1], lightbarColor(d.config.Lightbar, d.deviceState(), now))
+
+	// blinking red after panic
+	d.processEvent(key(evdev.BTN_START, EV_KEY_PRESS))
+	panicked := d.deviceState().lastPanic
+	assert.Equal(t, openrgb.Color{Red: 0xff}, lightbarColor(d.config.Lightbar, d.deviceState(), panicked))
+	assert.Equal(t, openrgb.Color{}, lightbarColor(d.config.Lightbar, d.deviceState(), panicked.Add(lightbarFlashBlink)))
+	assert.Equal(t, channelColors[1], lightbarColor(d.config.Lightbar, d.deviceState(), panicked.Add(lightbarFlashTime)))
+
+	mapping := config.Lightbar{Mode: config.LightbarMapping, Brightness: 0.5}
+	d.processEvent(key(evdev.BTN_SELECT, EV_KEY_PRESS))
+	assert.Equal(t, dimColor(channelColors[1], 0.5), lightbarColor(mapping, d.deviceState(), now))
+}
+
+func TestLightbarNotePulse(t *testing.T) {
+	static := openrgb.Color{Red: 100, Green: 200}
+	d := lightbarDevice(config.Lightbar{Mode: config.LightbarStatic, Color: static, Brightness: 1.0, NotePulse: true})
+
+	assert.Equal(t, dimColor(static, lightbarPulseIdle), lightbarColor(d.config.Lightbar, d.deviceState(), time.Now()))
+
+	d.processEvent(key(evdev.BTN_SOUTH, EV_KEY_PRESS))
+	played := d.deviceState().lastNoteOn
+	assert.Equal(t, static, lightbarColor(d.config.Lightbar, d.deviceState(), played))
+	assert.Equal(t, dimColor(static, 0.6), lightbarColor(d.config.Lightbar, d.deviceState(), played.Add(lightbarPulseTime/2)))
+	assert.Equal(t, dimColor(static, lightbarPulseIdle), lightbarColor(d.config.Lightbar, d.deviceState(), played.Add(lightbarPulseTime)))
+}
+
+func TestUpdateLightbar(t *testing.T) {
+	d := lightbarDevice(config.Lightbar{Mode: config.LightbarChannel, Brightness: 1.0})
+	lightbar := &fakeLightbar{}
+	now := time.Now()
+
+	current, err := d.updateLightbar(lightbar, nil, now)
+	assert.NoError(t, err)
+	current, err = d.updateLightbar(lightbar, current, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []openrgb.Color{channelColors[0]}, lightbar.colors)
+
+	d.processEvent(key(evdev.BTN_TR, EV_KEY_PRESS))
+	_, err = d.updateLightbar(lightbar, current, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []openrgb.Color{channelColors[0], channelColors[1]}, lightbar.colors)
+}
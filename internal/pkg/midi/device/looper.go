@@ -0,0 +1,64 @@
+package device
+
+import (
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+)
+
+// SetLooper binds phrase looper controlled by looper actions, it has to be called before ProcessEvents
+func (d *Device) SetLooper(looper *midi.Looper) {
+	d.looper = looper
+}
+
+// looperEnabled reports if phrase looper is available, a warning is logged otherwise
+func (d *Device) looperEnabled() bool {
+	if d.looper != nil {
+		return true
+	}
+	if !d.noLogs {
+		log.Info("looper is disabled, enable it in hidi.toml [looper] section", d.logFields(logger.Warning)...)
+	}
+	return false
+}
+
+func (d *Device) LooperRecord() {
+	if d.looperEnabled() {
+		d.looper.Record()
+	}
+}
+
+func (d *Device) LooperPlay() {
+	if d.looperEnabled() {
+		d.looper.Play()
+	}
+}
+
+func (d *Device) LooperOverdub() {
+	if d.looperEnabled() {
+		d.looper.Overdub()
+	}
+}
+
+func (d *Device) LooperUndo() {
+	if d.looperEnabled() {
+		d.looper.Undo()
+	}
+}
+
+func (d *Device) LooperClear() {
+	if d.looperEnabled() {
+		d.looper.Clear()
+	}
+}
+
+func (d *Device) LooperSlotUp() {
+	if d.looperEnabled() {
+		d.looper.SlotUp()
+	}
+}
+
+func (d *Device) LooperSlotDown() {
+	if d.looperEnabled() {
+		d.looper.SlotDown()
+	}
+}
@@ -0,0 +1,45 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLooperActions(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	midiEvents := make(chan midi.Event, 256)
+	d := NewDevice(input.Device{}, cfg, midiEvents, nil, true, 0, nil)
+
+	// looper disabled
+	d.invokeActionPress(config.LooperRecord)
+	d.invokeActionPress(config.LooperSlotUp)
+
+	looper := midi.NewLooper(0, midiEvents)
+	d.SetLooper(looper)
+
+	d.invokeActionPress(config.LooperSlotUp)
+	d.invokeActionPress(config.LooperRecord)
+	assert.Equal(t, "slot 2/8 recording", looper.Status())
+	d.invokeActionPress(config.LooperRecord)
+	assert.Contains(t, looper.Status(), "slot 2/8 playing, 1 layers")
+	d.invokeActionPress(config.LooperOverdub)
+	assert.Contains(t, looper.Status(), "slot 2/8 overdubbing, 1 layers")
+	d.invokeActionPress(config.LooperOverdub)
+	assert.Contains(t, looper.Status(), "slot 2/8 playing, 2 layers")
+	d.invokeActionPress(config.LooperUndo)
+	assert.Contains(t, looper.Status(), "slot 2/8 playing, 1 layers")
+	d.invokeActionPress(config.LooperPlay)
+	assert.Contains(t, looper.Status(), "slot 2/8 stopped")
+	d.invokeActionPress(config.LooperClear)
+	assert.Equal(t, "slot 2/8 empty", looper.Status())
+	d.invokeActionPress(config.LooperSlotDown)
+	assert.Equal(t, "slot 1/8 empty", looper.Status())
+}
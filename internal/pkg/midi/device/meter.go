@@ -0,0 +1,66 @@
+package device
+
+import (
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+const (
+	meterDecay      = 1.5 // velocity meter level drop per second
+	meterChaserSize = 1.0 // clock chaser width in LEDs
+	ticksPerBar     = 24 * 4
+)
+
+// trackMeterAnalog stores analog value for the strip meter, value range -1.0 - 1.0 or 0.0 - 1.0 if it can't be negative
+func (d *Device) trackMeterAnalog(code evdev.EvCode, mappingType config.MappingType, value float64, canBeNegative bool) {
+	meter := d.config.OpenRGB.Meter
+	if meter.Type != config.MeterAnalog {
+		return
+	}
+	if meter.Axis != nil && *meter.Axis != code {
+		return
+	}
+
+	switch mappingType {
+	case config.AnalogKeySim, config.AnalogActionSim:
+		return
+	}
+
+	if !canBeNegative {
+		value = value*2 - 1
+	}
+	d.meterValue = value
+}
+
+// trackMeterVelocity stores velocity of played note for the strip meter
+func (d *Device) trackMeterVelocity(velocity byte, now time.Time) {
+	if d.config.OpenRGB.Meter.Type != config.MeterVelocity {
+		return
+	}
+	d.meterLevel = float64(velocity) / 127
+	d.meterLevelTime = now
+}
+
+// meterLEDs returns LED strip intensities of configured meter
+func meterLEDs(meter config.StripMeter, strip *DeviceLedStrip, state *deviceSnapshot, external *externalSnapshot, now time.Time) map[string]float64 {
+	switch meter.Type {
+	case config.MeterAnalog:
+		return strip.Value(state.meterValue, 1.0)
+	case config.MeterVelocity:
+		level := state.meterLevel - now.Sub(state.meterLevelTime).Seconds()*meterDecay
+		if level < 0 {
+			level = 0
+		}
+		return strip.Level(level)
+	case config.MeterClock:
+		if now.Sub(external.lastClock) > clockTimeout {
+			return map[string]float64{}
+		}
+		// clockTicks is incremented on every tick, first tick of the bar is counted as 1
+		position := float64((external.clockTicks-1+ticksPerBar)%ticksPerBar) / (ticksPerBar - 1)
+		return strip.Value(position*2-1, meterChaserSize)
+	}
+	return map[string]float64{}
+}
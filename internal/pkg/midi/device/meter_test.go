@@ -0,0 +1,92 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+var testStrip = ConfigLedStrip{sequence: []string{"1", "2", "3", "4"}}
+
+func TestLedStripValue(t *testing.T) {
+	strip := NewDeviceLedStrip(testStrip)
+
+	assert.Equal(t, map[string]float64{"1": 1, "2": 0, "3": 0, "4": 0}, strip.Value(-1, 1))
+	assert.Equal(t, map[string]float64{"1": 0, "2": 0.5, "3": 0.5, "4": 0}, strip.Value(0, 1))
+	assert.Equal(t, map[string]float64{"1": 0, "2": 0, "3": 1, "4": 1}, strip.Value(1, 2))
+	assert.Equal(t, map[string]float64{}, (&DeviceLedStrip{}).Value(0, 1))
+}
+
+func TestLedStripLevel(t *testing.T) {
+	strip := NewDeviceLedStrip(testStrip)
+
+	assert.Equal(t, map[string]float64{"1": 0, "2": 0, "3": 0, "4": 0}, strip.Level(0))
+	assert.Equal(t, map[string]float64{"1": 1, "2": 1, "3": 0.5, "4": 0}, strip.Level(0.625))
+	assert.Equal(t, map[string]float64{"1": 1, "2": 1, "3": 1, "4": 1}, strip.Level(1))
+}
+
+func meterDevice(meter config.StripMeter) *Device {
+	analogs := map[evdev.EvCode]config.Analog{
+		evdev.ABS_X: {MappingType: config.AnalogPitchBend},
+		evdev.ABS_Z: {MappingType: config.AnalogCC, CC: 1},
+	}
+	d, _ := testDevice(testConfig(map[evdev.EvCode]config.Key{evdev.BTN_A: {Note: 0}}, analogs, func(c *config.Config) {
+		c.Defaults.Velocity = 127
+		c.OpenRGB = config.OpenRGB{Meter: meter}
+	}), map[evdev.EvCode]evdev.AbsInfo{
+		evdev.ABS_X: {Minimum: -128, Maximum: 128},
+		evdev.ABS_Z: {Minimum: 0, Maximum: 256},
+	})
+	return d
+}
+
+func TestMeterAnalog(t *testing.T) {
+	axis := evdev.EvCode(evdev.ABS_X)
+	d := meterDevice(config.StripMeter{Type: config.MeterAnalog, Axis: &axis})
+	strip := NewDeviceLedStrip(testStrip)
+
+	d.processEvent(abs(evdev.ABS_X, 128))
+	d.processEvent(abs(evdev.ABS_Z, 0)) // not a meter axis
+	assert.Equal(t, map[string]float64{"1": 0, "2": 0, "3": 0, "4": 1}, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), time.Now()))
+
+	d.processEvent(abs(evdev.ABS_X, -128))
+	assert.Equal(t, map[string]float64{"1": 1, "2": 0, "3": 0, "4": 0}, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), time.Now()))
+}
+
+func TestMeterVelocity(t *testing.T) {
+	d := meterDevice(config.StripMeter{Type: config.MeterVelocity})
+	strip := NewDeviceLedStrip(testStrip)
+
+	d.processEvent(key(evdev.BTN_A, EV_KEY_PRESS))
+	now := d.meterLevelTime
+	assert.Equal(t, map[string]float64{"1": 1, "2": 1, "3": 1, "4": 1}, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), now))
+
+	// level decays after the note was played
+	leds := meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), now.Add(time.Millisecond*500))
+	assert.Equal(t, 1.0, leds["1"])
+	assert.Equal(t, 0.0, leds["4"])
+	assert.Equal(t, map[string]float64{"1": 0, "2": 0, "3": 0, "4": 0}, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), now.Add(time.Second)))
+}
+
+func TestMeterClock(t *testing.T) {
+	d := meterDevice(config.StripMeter{Type: config.MeterClock})
+	strip := NewDeviceLedStrip(testStrip)
+
+	d.handleMidiInEvent(midi.Event{midi.TimingStart})
+	d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	assert.Equal(t, 1.0, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), time.Now())["1"])
+
+	for i := 1; i < ticksPerBar; i++ {
+		d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	}
+	assert.Equal(t, 1.0, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), time.Now())["4"]) // last tick of the bar
+
+	d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	assert.Equal(t, 1.0, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), time.Now())["1"]) // next bar
+
+	assert.Equal(t, map[string]float64{}, meterLEDs(d.config.OpenRGB.Meter, &strip, d.deviceState(), d.externalState(), time.Now().Add(time.Second)))
+}
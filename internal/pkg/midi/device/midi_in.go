@@ -0,0 +1,101 @@
+package device
+
+import (
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+)
+
+// midiInMessage describes message received on midi input for the purpose of rule matching.
+// press tells whether action should be pressed or released, one-shot messages press and release it at once.
+type midiInMessage struct {
+	message config.MidiInMessage
+	number  int // note or cc number, -1 for other messages
+	value   int // cc value or program number
+	press   bool
+	oneShot bool
+}
+
+func parseMidiInMessage(ev midi.Event) (midiInMessage, bool) {
+	switch ev.Type() {
+	case midi.NoteOn:
+		if len(ev) < 3 {
+			return midiInMessage{}, false
+		}
+		// note on with zero velocity is a note off
+		return midiInMessage{message: config.MidiInNote, number: int(ev[1]), press: ev[2] != 0}, true
+	case midi.NoteOff:
+		if len(ev) < 3 {
+			return midiInMessage{}, false
+		}
+		return midiInMessage{message: config.MidiInNote, number: int(ev[1])}, true
+	case midi.ControlChange:
+		if len(ev) < 3 {
+			return midiInMessage{}, false
+		}
+		return midiInMessage{message: config.MidiInCC, number: int(ev[1]), value: int(ev[2]), press: ev[2] >= 64}, true
+	case midi.ProgramChange:
+		if len(ev) < 2 {
+			return midiInMessage{}, false
+		}
+		return midiInMessage{message: config.MidiInProgramChange, number: -1, value: int(ev[1]), oneShot: true}, true
+	case midi.TimingStart:
+		return midiInMessage{message: config.MidiInStart, number: -1, oneShot: true}, true
+	case midi.TimingStop:
+		return midiInMessage{message: config.MidiInStop, number: -1, oneShot: true}, true
+	case midi.TimingContinue:
+		return midiInMessage{message: config.MidiInContinue, number: -1, oneShot: true}, true
+	}
+	return midiInMessage{}, false
+}
+
+// matches tells whether the rule applies to given message received on given channel
+func matches(r config.MidiInRule, m midiInMessage, channel uint8) bool {
+	if r.Message != m.message {
+		return false
+	}
+	if r.Channel != -1 && r.Channel != int(channel) {
+		return false
+	}
+	return r.Number == -1 || r.Number == m.number
+}
+
+// handleMidiInRules triggers actions of rules matching message received on midi input
+func (d *Device) handleMidiInRules(ev midi.Event) {
+	if len(d.config.MidiIn) == 0 {
+		return
+	}
+	m, ok := parseMidiInMessage(ev)
+	if !ok {
+		return
+	}
+
+	d.eventProcessMutex.Lock()
+	defer d.eventProcessMutex.Unlock()
+
+	var triggered bool
+	for _, r := range d.config.MidiIn {
+		if !matches(r, m, ev.Channel()) {
+			continue
+		}
+		triggered = true
+
+		switch {
+		case r.Action == config.Mapping:
+			d.MappingSet(m.value)
+		case r.Action == config.Channel:
+			d.ChannelSet(uint8(m.value))
+		case r.Action == config.Octave:
+			d.OctaveSet(m.value - 64)
+		case m.oneShot:
+			d.invokeActionPress(r.Action)
+			d.invokeActionRelease(r.Action)
+		case m.press:
+			d.invokeActionPress(r.Action)
+		default:
+			d.invokeActionRelease(r.Action)
+		}
+	}
+	if triggered {
+		d.publishState()
+	}
+}
@@ -0,0 +1,68 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMidiInRules(t *testing.T) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return
+	}
+	cfg.Config.MidiIn = []config.MidiInRule{
+		{Message: config.MidiInProgramChange, Channel: -1, Number: -1, Action: config.Mapping},
+		{Message: config.MidiInCC, Channel: 15, Number: 20, Action: config.Octave},
+		{Message: config.MidiInCC, Channel: -1, Number: 21, Action: config.Channel},
+		{Message: config.MidiInCC, Channel: -1, Number: 64, Action: config.Learning},
+		{Message: config.MidiInNote, Channel: -1, Number: 12, Action: config.Panic},
+		{Message: config.MidiInStop, Channel: -1, Number: -1, Action: config.OctaveUp},
+	}
+
+	midiEvents := make(chan midi.Event, 256)
+	d := NewDevice(input.Device{}, cfg, midiEvents, nil, true, 0, nil)
+
+	d.handleMidiInEvent(midi.Event{midi.ProgramChange | 3, 1})
+	assert.Equal(t, 1, d.mapping)
+	d.handleMidiInEvent(midi.Event{midi.ProgramChange | 3, 100}) // no such mapping
+	assert.Equal(t, 1, d.mapping)
+	assert.Equal(t, 1, d.deviceState().mapping)
+
+	d.handleMidiInEvent(midi.ControlChangeEvent(0, 20, 66)) // other channel
+	assert.Equal(t, int8(0), d.octave)
+	d.handleMidiInEvent(midi.ControlChangeEvent(15, 20, 62))
+	assert.Equal(t, int8(-2), d.octave)
+	d.handleMidiInEvent(midi.ControlChangeEvent(15, 20, 0)) // octave -64
+	assert.Equal(t, int8(-2), d.octave)
+	d.handleMidiInEvent(midi.ControlChangeEvent(15, 20, 127)) // octave 63
+	assert.Equal(t, int8(-2), d.octave)
+	d.handleMidiInEvent(midi.ControlChangeEvent(15, 20, 64+maxOctave))
+	assert.Equal(t, int8(maxOctave), d.octave)
+	d.handleMidiInEvent(midi.ControlChangeEvent(15, 20, 62))
+	assert.Equal(t, int8(-2), d.octave)
+
+	d.handleMidiInEvent(midi.ControlChangeEvent(0, 21, 9))
+	assert.Equal(t, uint8(9), d.channel)
+	d.handleMidiInEvent(midi.ControlChangeEvent(0, 21, 16))
+	assert.Equal(t, uint8(9), d.channel)
+
+	// footswitch holds the action
+	d.handleMidiInEvent(midi.ControlChangeEvent(0, 64, 127))
+	assert.True(t, d.ccLearning)
+	d.handleMidiInEvent(midi.ControlChangeEvent(0, 64, 0))
+	assert.False(t, d.ccLearning)
+
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 5, 13, 100))
+	assert.True(t, d.lastPanic.IsZero())
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 5, 12, 100))
+	assert.False(t, d.lastPanic.IsZero())
+
+	d.handleMidiInEvent(midi.Event{midi.TimingStart})
+	assert.Equal(t, int8(-2), d.octave)
+	d.handleMidiInEvent(midi.Event{midi.TimingStop})
+	assert.Equal(t, int8(-1), d.octave)
+}
@@ -0,0 +1,150 @@
+package device
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"go.uber.org/zap"
+)
+
+const (
+	tiltFullScale     = 90.0  // degrees of tilt covering full output range with sensitivity 1.0
+	rotationFullScale = 180.0 // degrees of rotation covering full output range with sensitivity 1.0
+	gyroNoiseFloor    = 1.0   // deg/s, slower rotation is ignored so accumulated value doesn't drift
+
+	// fallback resolutions for drivers that doesn't report it, values used by DualShock 4
+	accelDefaultResolution = 8192 // units per g
+	gyroDefaultResolution  = 1024 // units per deg/s
+)
+
+// motionState keeps orientation of a single motion sensor axis
+type motionState struct {
+	analog     config.Analog
+	angle      float64 // tilt: current angle, rotation: angle accumulated since reference reset
+	reference  float64 // tilt only
+	referenced bool
+	last       time.Time
+	output     int // last emitted value, -1 if nothing was emitted yet
+}
+
+func (d *Device) motionState(ie *input.InputEvent) *motionState {
+	states, ok := d.motionStates[ie.Source.Name]
+	if !ok {
+		states = make(map[evdev.EvCode]*motionState)
+		d.motionStates[ie.Source.Name] = states
+	}
+	state, ok := states[ie.Event.Code]
+	if !ok {
+		state = &motionState{output: -1}
+		states[ie.Event.Code] = state
+	}
+	return state
+}
+
+// handleMotion converts motion sensor data into controller value, accelerometer tilt is measured
+// relatively to the reference orientation, gyroscope angular rate is accumulated into rotation angle
+func (d *Device) handleMotion(ie *input.InputEvent, analog config.Analog) {
+	absInfo := d.InputDevice.AbsInfos[ie.Source.DeviceInfo.Event()][ie.Event.Code]
+	state := d.motionState(ie)
+	state.analog = analog
+	sensitivity := analog.Motion.Sensitivity
+
+	var value float64
+
+	switch analog.Motion.Source {
+	case config.MotionTilt:
+		resolution := float64(absInfo.Resolution)
+		if resolution == 0 {
+			resolution = accelDefaultResolution
+		}
+		state.angle = math.Asin(math.Max(-1, math.Min(1, float64(ie.Event.Value)/resolution))) * 180 / math.Pi
+		if !state.referenced {
+			state.reference = state.angle
+			state.referenced = true
+		}
+		value = (state.angle - state.reference) * sensitivity / tiltFullScale
+	case config.MotionRotation:
+		resolution := float64(absInfo.Resolution)
+		if resolution == 0 {
+			resolution = gyroDefaultResolution
+		}
+		t := time.Unix(0, ie.Event.Time.Nano())
+		if !state.last.IsZero() {
+			rate := float64(ie.Event.Value) / resolution
+			if math.Abs(rate) > gyroNoiseFloor {
+				limit := rotationFullScale / sensitivity
+				state.angle = math.Max(-limit, math.Min(limit, state.angle+rate*t.Sub(state.last).Seconds()))
+			}
+		}
+		state.last = t
+		value = state.angle * sensitivity / rotationFullScale
+	}
+
+	value = math.Max(-1, math.Min(1, value))
+	if analog.FlipAxis {
+		value = -value
+	}
+
+	if d.ccLearning && !(value < -0.5 || value > 0.5) {
+		return
+	}
+
+	d.emitMotion(ie, state, value)
+}
+
+func (d *Device) emitMotion(ie *input.InputEvent, state *motionState, value float64) {
+	channel := (d.channel + state.analog.ChannelOffset) % 16
+
+	var event midi.Event
+	var output int
+
+	switch state.analog.Motion.Output {
+	case config.AnalogCC:
+		output = int(127 * (value + 1) / 2)
+		event = midi.ControlChangeEvent(channel, state.analog.CC, byte(output))
+	case config.AnalogPitchBend:
+		event = midi.PitchBendEvent(channel, value)
+		output = int(event[2])<<7 | int(event[1])
+	}
+
+	if output == state.output {
+		return
+	}
+	state.output = output
+
+	if !d.noLogs && ie != nil {
+		log.Info(fmt.Sprintf("Motion event: %s", ie.Event.String()), d.logFields(
+			logger.Analog,
+			zap.String("handler_event", ie.Source.DeviceInfo.Event()),
+			zap.String("handler_subhandler", ie.Source.Name),
+		)...)
+	}
+
+	d.outputEvents <- event
+}
+
+// MotionReset sets current orientation as a reference for all motion mappings
+func (d *Device) MotionReset() {
+	for _, states := range d.motionStates {
+		for _, state := range states {
+			state.reference = state.angle
+			state.referenced = true
+			if state.analog.Motion.Source == config.MotionRotation {
+				state.angle = 0
+			}
+			if state.output != -1 {
+				d.emitMotion(nil, state, 0)
+			}
+		}
+	}
+
+	if !d.noLogs {
+		log.Info("motion reference reset", d.logFields(logger.Action)...)
+	}
+}
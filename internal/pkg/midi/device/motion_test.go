@@ -0,0 +1,81 @@
+package device
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func motionDevice(analogs map[evdev.EvCode]config.Analog) (*Device, chan midi.Event) {
+	return testDevice(testConfig(nil, analogs), map[evdev.EvCode]evdev.AbsInfo{
+		evdev.ABS_X:  {Minimum: -32768, Maximum: 32767, Resolution: 8192},
+		evdev.ABS_RX: {Minimum: -32768, Maximum: 32767, Resolution: 1024},
+	})
+}
+
+func motionEvent(code evdev.EvCode, value int32, ms int64) *input.InputEvent {
+	ie := abs(code, value)
+	ie.Event.Time = syscall.NsecToTimeval(ms * 1000000)
+	return ie
+}
+
+func TestMotionTilt(t *testing.T) {
+	d, midiEvents := motionDevice(map[evdev.EvCode]config.Analog{
+		evdev.ABS_X: {
+			MappingType: config.AnalogMotion,
+			CC:          1,
+			Motion:      config.Motion{Source: config.MotionTilt, Output: config.AnalogCC, Sensitivity: 2.0},
+		},
+	})
+
+	// first reading is a reference orientation
+	d.processEvent(motionEvent(evdev.ABS_X, 1000, 0))
+	// tilt over 45° relatively to the reference one reaches full range with sensitivity 2.0
+	d.processEvent(motionEvent(evdev.ABS_X, 8192, 1))
+	// reference re-zeroed at current position
+	d.MotionReset()
+	d.processEvent(motionEvent(evdev.ABS_X, 8192, 2))
+
+	events, err := readN(midiEvents, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.ControlChangeEvent(0, 1, 63),
+		midi.ControlChangeEvent(0, 1, 127),
+		midi.ControlChangeEvent(0, 1, 63),
+	}, events)
+}
+
+func TestMotionRotation(t *testing.T) {
+	d, midiEvents := motionDevice(map[evdev.EvCode]config.Analog{
+		evdev.ABS_RX: {
+			MappingType: config.AnalogMotion,
+			Motion:      config.Motion{Source: config.MotionRotation, Output: config.AnalogPitchBend, Sensitivity: 1.0},
+		},
+	})
+
+	// rotating with 90 deg/s for one second
+	d.processEvent(motionEvent(evdev.ABS_RX, 90*1024, 0))
+	d.processEvent(motionEvent(evdev.ABS_RX, 90*1024, 1000))
+	// slow drift below noise floor is ignored
+	d.processEvent(motionEvent(evdev.ABS_RX, 512, 2000))
+	// rotation is clamped at full range
+	d.processEvent(motionEvent(evdev.ABS_RX, -360*1024, 3000))
+
+	events, err := readN(midiEvents, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.PitchBendEvent(0, 0),
+		midi.PitchBendEvent(0, 0.5),
+		midi.PitchBendEvent(0, -1),
+	}, events)
+
+	d.MotionReset()
+	events, err = readN(midiEvents, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, midi.PitchBendEvent(0, 0), events[0])
+}
@@ -20,8 +20,23 @@ func init() {
 	for k, v := range KeyToLedName {
 		LedNameToKey[v] = k
 	}
+
+	for ch := 0; ch < 16; ch++ {
+		var h = 720/16*float64(ch) + 30
+		if h >= 360 {
+			h -= 360
+		}
+		c := colorful.Hsv(h, 1, 1)
+		channelColors[byte(ch)] = openrgb.Color{
+			Red:   byte(c.R * 255),
+			Green: byte(c.G * 255),
+			Blue:  byte(c.B * 255),
+		}
+	}
 }
 
+var channelColors = map[byte]openrgb.Color{} // filled up with init()
+
 var LedNameToKey = map[string]evdev.EvCode{} // filled up with init()
 
 var KeyToLedName = map[evdev.EvCode]string{ // hardware button to OpenRGB LED name mapping
@@ -151,33 +166,39 @@ func NewDeviceLedStrip(ledStrip LedStrip) DeviceLedStrip {
 	}
 }
 
+// Value returns LED intensities of a window with given width (in LEDs) at position of given value,
+// value range -1.0 - 1.0
 func (s *DeviceLedStrip) Value(value float64, width float64) map[string]float64 {
+	var leds = make(map[string]float64, s.ledNumber)
 	if s.ledNumber == 0 {
-		return map[string]float64{}
+		return leds
 	}
 
-	value = (value + 1.0) / 2
-	center := value * (float64(s.ledNumber) - 1) // eg. led index 3.21
-
-	var leds = make(map[string]float64)
+	if width > float64(s.ledNumber) {
+		width = float64(s.ledNumber)
+	}
 
-	low := center - 0.5*width
-	high := center + 0.5*width
+	// every LED covers [i, i+1) range of the strip, window always fits on the strip
+	value = (value + 1.0) / 2
+	low := value * (float64(s.ledNumber) - width)
+	high := low + width
 
-	if low < 0 {
-		low = 0
-	}
-	if high > float64(s.ledNumber)-1 {
-		high = float64(s.ledNumber) - 1
+	for i, led := range s.ledSeq {
+		overlap := math.Min(high, float64(i+1)) - math.Max(low, float64(i))
+		leds[led] = math.Max(0, overlap)
 	}
 
-	for i := int(low); i < int(high); i++ {
-		leds[s.ledSeq[i]] = 1.0
-	}
+	return leds
+}
 
-	leds[s.ledSeq[int(low)]] = 1 - (low - float64(int(low)))
-	leds[s.ledSeq[int(high)]] = high - float64(int(high))
+// Level returns LED intensities of a bar filled from the beginning of the strip, value range 0.0 - 1.0
+func (s *DeviceLedStrip) Level(value float64) map[string]float64 {
+	var leds = make(map[string]float64, s.ledNumber)
 
+	filled := value * float64(s.ledNumber)
+	for i, led := range s.ledSeq {
+		leds[led] = math.Max(0, math.Min(1, filled-float64(i)))
+	}
 	return leds
 }
 
@@ -201,7 +222,7 @@ func (h HyperXAlloyElite2) Device() string {
 	return "HyperX Alloy Elite 2 (HP)"
 }
 
-var ledStrips = map[string]LedStrip{
+var ledStrips = map[string]LedStrip{ // built-in LED strips, controller name: strip
 	"HyperX Alloy Elite 2 (HP)": HyperXAlloyElite2{},
 }
 
@@ -213,6 +234,79 @@ func (h HyperXAlloyElite2) LEDSequence() []string {
 	return leds
 }
 
+// ConfigLedStrip is a LED strip defined in device config
+type ConfigLedStrip struct {
+	device   string
+	sequence []string
+}
+
+func (s ConfigLedStrip) Device() string {
+	return s.device
+}
+
+func (s ConfigLedStrip) LEDSequence() []string {
+	return s.sequence
+}
+
+// resolveLED returns index of given LED in controller LED list
+func resolveLED(led config.OpenRGBLED, leds []openrgb.LED) (int, bool) {
+	if led.Name == "" {
+		return led.Index, led.Index >= 0 && led.Index < len(leds)
+	}
+	for i, l := range leds {
+		if l.Name == led.Name {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// keyLayout returns hardware button to LED index mapping, built-in KeyToLedName layout
+// is extended or overridden by layout defined in device config
+func keyLayout(leds []openrgb.LED, keys map[evdev.EvCode]config.OpenRGBLED) map[evdev.EvCode]int {
+	var indexMap = make(map[evdev.EvCode]int)
+
+	for i, led := range leds {
+		key, ok := LedNameToKey[led.Name]
+		if !ok {
+			continue
+		}
+		indexMap[key] = i
+	}
+
+	for code, led := range keys {
+		i, ok := resolveLED(led, leds)
+		if !ok {
+			delete(indexMap, code)
+			continue
+		}
+		indexMap[code] = i
+	}
+
+	return indexMap
+}
+
+// ledStrip returns LED strip of given controller, strip defined in device config takes precedence over built-in one
+func ledStrip(dev openrgb.Device, strip []config.OpenRGBLED) LedStrip {
+	if len(strip) == 0 {
+		s, ok := ledStrips[dev.Name]
+		if !ok {
+			return NoLeds{}
+		}
+		return s
+	}
+
+	var sequence = make([]string, 0, len(strip))
+	for _, led := range strip {
+		i, ok := resolveLED(led, dev.LEDs)
+		if !ok {
+			continue
+		}
+		sequence = append(sequence, dev.LEDs[i].Name)
+	}
+	return ConfigLedStrip{device: dev.Name, sequence: sequence}
+}
+
 // resolveHidraw returns event name that relates to given hidraw device
 // "/dev/hidraw0" > "event0"
 func resolveHidraw(dev string) (string, error) {
@@ -254,7 +348,40 @@ func resolveHidraw(dev string) (string, error) {
 	return "", fmt.Errorf("event not found")
 }
 
-func findController(c *openrgb.Client, events map[string]bool) (openrgb.Device, int, error) {
+// openrgbKeyboard is OpenRGB device type of keyboards
+const openrgbKeyboard = 5
+
+var hidrawLocation = regexp.MustCompile(`.*(/dev/hidraw\d+)`)
+
+// ControllerMatcher tells if OpenRGB keyboard controller belongs to the device with given event handlers
+type ControllerMatcher func(dev openrgb.Device, events map[string]bool) (bool, error)
+
+// MatchHidraw matches controller by hidraw path reported as its location, it is the default matcher
+func MatchHidraw(dev openrgb.Device, events map[string]bool) (bool, error) {
+	out := hidrawLocation.FindStringSubmatch(dev.Location)
+	if len(out) != 2 {
+		return false, nil
+	}
+
+	event, err := resolveHidraw(out[1])
+	if err != nil {
+		return false, fmt.Errorf("resolve hidraw failed: %s", err)
+	}
+	return events[event], nil
+}
+
+// MatchAnyKeyboard matches every keyboard controller, used with OpenRGB mock server
+func MatchAnyKeyboard(openrgb.Device, map[string]bool) (bool, error) {
+	return true, nil
+}
+
+// SetControllerMatcher replaces the way OpenRGB keyboard controller of the device is found,
+// it has to be called before ProcessEvents
+func (d *Device) SetControllerMatcher(match ControllerMatcher) {
+	d.matchController = match
+}
+
+func findController(c *openrgb.Client, events map[string]bool, match ControllerMatcher) (openrgb.Device, int, error) {
 	count, err := c.GetControllerCount()
 	if err != nil {
 		return openrgb.Device{}, 0, fmt.Errorf("failed to get controller count: %s", err)
@@ -264,29 +391,21 @@ func findController(c *openrgb.Client, events map[string]bool) (openrgb.Device,
 		return openrgb.Device{}, 0, fmt.Errorf("no supported controllers available")
 	}
 
-	regex := regexp.MustCompile(`.*(/dev/hidraw\d+)`)
-
 	for i := 0; i < count; i++ {
 		dev, err := c.GetDeviceController(i)
 		if err != nil {
 			return openrgb.Device{}, 0, fmt.Errorf("getting controller information failed (%d/%d): %s", i, count, err)
 		}
 
-		if dev.Type != 5 { // keyboard
+		if dev.Type != openrgbKeyboard {
 			continue
 		}
 
-		out := regex.FindStringSubmatch(dev.Location)
-		if len(out) != 2 {
-			continue
-		}
-
-		event, err := resolveHidraw(out[1])
+		matched, err := match(dev, events)
 		if err != nil {
-			return openrgb.Device{}, 0, fmt.Errorf("resolve hidraw failed (%d/%d): %s", i, count, err)
+			return openrgb.Device{}, 0, fmt.Errorf("matching controller failed (%d/%d): %s", i, count, err)
 		}
-
-		if events[event] {
+		if matched {
 			return dev, i, nil
 		}
 	}
@@ -313,6 +432,15 @@ func shiftColor(color openrgb.Color, value float64) openrgb.Color {
 	}
 }
 
+// dimColor scales color brightness, value range 0.0 - 1.0
+func dimColor(color openrgb.Color, value float64) openrgb.Color {
+	return openrgb.Color{
+		Red:   byte(float64(color.Red) * value),
+		Green: byte(float64(color.Green) * value),
+		Blue:  byte(float64(color.Blue) * value),
+	}
+}
+
 // value range -1.0 - 1.0
 func valueToColor(value, s, v float64) openrgb.Color {
 	value = 120 - value*120
@@ -325,9 +453,8 @@ func valueToColor(value, s, v float64) openrgb.Color {
 	}
 }
 
-func (d *Device) handleOpenrgb(ctx context.Context, wg *sync.WaitGroup) {
-	defer wg.Done()
-
+// connectOpenrgb connects to OpenRGB server, retrying for a few seconds
+func (d *Device) connectOpenrgb(ctx context.Context) (*openrgb.Client, error) {
 	host, port := "localhost", d.openrgbPort
 
 	log.Info(fmt.Sprintf("[OpenRGB] Connecting: %s:%d...", host, port), d.logFields(logger.Debug)...)
@@ -340,7 +467,7 @@ func (d *Device) handleOpenrgb(ctx context.Context, wg *sync.WaitGroup) {
 	for {
 		select {
 		case <-ctx.Done():
-			return
+			return nil, ctx.Err()
 		case <-time.After(time.Millisecond * 250):
 			break
 		}
@@ -357,6 +484,16 @@ func (d *Device) handleOpenrgb(ctx context.Context, wg *sync.WaitGroup) {
 		break
 	}
 
+	return c, err
+}
+
+func (d *Device) handleOpenrgb(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	c, err := d.connectOpenrgb(ctx)
+	if ctx.Err() != nil {
+		return
+	}
 	if err != nil {
 		log.Info(fmt.Sprintf("[OpenRGB] Cannot connect to server: %s", err), d.logFields(logger.Debug)...)
 		return
@@ -372,7 +509,7 @@ func (d *Device) handleOpenrgb(ctx context.Context, wg *sync.WaitGroup) {
 		events[handler.DeviceInfo.Event()] = true
 	}
 
-	timeout = time.Now().Add(time.Second * 2)
+	timeout := time.Now().Add(time.Second * 2)
 
 	for {
 		select {
@@ -387,7 +524,7 @@ func (d *Device) handleOpenrgb(ctx context.Context, wg *sync.WaitGroup) {
 			break
 		}
 
-		dev, index, err = findController(c, events)
+		dev, index, err = findController(c, events, d.matchController)
 		if err != nil {
 			continue
 		}
@@ -401,268 +538,20 @@ func (d *Device) handleOpenrgb(ctx context.Context, wg *sync.WaitGroup) {
 
 	log.Info(fmt.Sprintf("[OpenRGB] Controller found: %s, index: %d", dev.Name, index), d.logFields(logger.Debug)...)
 
-	var ledArray = make([]openrgb.Color, 0)
-
-	for range dev.Colors {
-		ledArray = append(ledArray, openrgb.Color{})
-	}
-
-	ledSequence := dev.LEDs
-
 	leds := make([]string, 0)
-	for _, l := range ledSequence {
+	for _, l := range dev.LEDs {
 		leds = append(leds, l.Name)
 	}
 	log.Info(fmt.Sprintf("[OpenRGB] LED sequence: %#v", leds), d.logFields(logger.Debug)...)
 
-	var indexMap = make(map[evdev.EvCode]int)
-
-	for i, led := range ledSequence {
-		key, ok := LedNameToKey[led.Name]
-		if !ok {
-			continue
-		}
-		indexMap[key] = i
-	}
-
-	var nameToIndex = make(map[string]int)
-
-	for i, led := range ledSequence {
-		nameToIndex[led.Name] = i
-	}
-
-	var MidiKeyMappings = make([]map[byte][]evdev.EvCode, 0)
-
-	for _, m := range d.config.KeyMappings {
-		var midiKeyMapping = make(map[byte][]evdev.EvCode)
-		for code, key := range m.Midi[""] {
-			_, ok := midiKeyMapping[key.Note]
-			if !ok {
-				midiKeyMapping[key.Note] = []evdev.EvCode{code}
-			} else {
-				midiKeyMapping[key.Note] = append(midiKeyMapping[key.Note], code)
-			}
-		}
-		MidiKeyMappings = append(MidiKeyMappings, midiKeyMapping)
-	}
-
-	var actionToEvcode = make(map[config.Action]evdev.EvCode)
-
-	for code, action := range d.config.ActionMapping {
-		actionToEvcode[action] = code
-	}
-
-	white1 := openrgb.Color{Red: 27, Green: 27, Blue: 27}
-	white2 := openrgb.Color{Red: 100, Green: 100, Blue: 100}
-	white3 := openrgb.Color{Red: 255, Green: 255, Blue: 255}
-
-	var channelColors = make(map[byte]openrgb.Color)
-
-	for ch := 0; ch < 16; ch++ {
-		var h = 720/16*float64(ch) + 30
-		if h >= 360 {
-			h -= 360
-		}
-		c := colorful.Hsv(h, 1, 1)
-		channelColors[byte(ch)] = openrgb.Color{
-			Red:   byte(c.R * 255),
-			Green: byte(c.G * 255),
-			Blue:  byte(c.B * 255),
-		}
-	}
-
-	var ledstrip LedStrip
-	var ok bool
-	ledstrip, ok = ledStrips[dev.Name]
-	if !ok {
-		ledstrip = NoLeds{}
-	}
-
-	strip := NewDeviceLedStrip(ledstrip)
+	r := d.newKeyboardRenderer(dev)
 
 	log.Info(fmt.Sprintf("[OpenRGB] LED update loop started"), d.logFields(logger.Debug)...)
+	d.renderLoop(ctx, c, index, r.render)
 
-	nextFailedLedUpdateReport := time.Now()
-	updateFails := 0
-root:
-	for {
-		select {
-		case <-ctx.Done():
-			break root
-		default:
-			break
-		}
-		time.Sleep(time.Millisecond * 10)
-
-		d.eventProcessMutex.Lock()
-		offset := int(d.semitone) + int(d.octave)*12
-
-		for i := 0; i < len(ledArray); i++ {
-			ledArray[i] = d.config.OpenRGB.Colors.Unavailable
-		}
-
-		for _, key := range strip.ledSeq {
-			ledArray[nameToIndex[key]] = openrgb.Color{}
-		}
-
-		ledArray[indexMap[actionToEvcode[config.Panic]]] = openrgb.Color{Red: 0xff}
-
-		ledArray[indexMap[actionToEvcode[config.OctaveUp]]] = white1
-		ledArray[indexMap[actionToEvcode[config.OctaveDown]]] = white1
-
-		if d.octave > 0 {
-			if d.octave == 1 {
-				ledArray[indexMap[actionToEvcode[config.OctaveUp]]] = white2
-			} else {
-				ledArray[indexMap[actionToEvcode[config.OctaveUp]]] = white3
-			}
-		}
-		if d.octave < 0 {
-			if d.octave == -1 {
-				ledArray[indexMap[actionToEvcode[config.OctaveDown]]] = white2
-			} else {
-				ledArray[indexMap[actionToEvcode[config.OctaveDown]]] = white3
-			}
-		}
-
-		ledArray[indexMap[actionToEvcode[config.SemitoneUp]]] = white1
-		ledArray[indexMap[actionToEvcode[config.SemitoneDown]]] = white1
-		if d.semitone > 0 {
-			if d.semitone == 1 {
-				ledArray[indexMap[actionToEvcode[config.SemitoneUp]]] = white2
-			} else {
-				ledArray[indexMap[actionToEvcode[config.SemitoneUp]]] = white3
-			}
-		}
-		if d.semitone < 0 {
-			if d.semitone == -1 {
-				ledArray[indexMap[actionToEvcode[config.SemitoneDown]]] = white2
-			} else {
-				ledArray[indexMap[actionToEvcode[config.SemitoneDown]]] = white3
-			}
-		}
-
-		ledArray[indexMap[actionToEvcode[config.MappingUp]]] = white3
-		ledArray[indexMap[actionToEvcode[config.MappingDown]]] = white3
-		if d.mapping == 0 {
-			ledArray[indexMap[actionToEvcode[config.MappingDown]]] = white1
-		}
-		if d.mapping == len(d.config.KeyMappings)-1 {
-			ledArray[indexMap[actionToEvcode[config.MappingUp]]] = white1
-		}
-
-		chanColor := channelColors[d.channel]
-		ledArray[indexMap[actionToEvcode[config.ChannelUp]]] = chanColor
-		ledArray[indexMap[actionToEvcode[config.ChannelDown]]] = chanColor
-		if d.channel == 0 {
-			ledArray[indexMap[actionToEvcode[config.ChannelDown]]] = openrgb.Color{
-				Red:   chanColor.Red / 3,
-				Green: chanColor.Green / 3,
-				Blue:  chanColor.Blue / 3,
-			}
-		}
-		if d.channel == 15 {
-			ledArray[indexMap[actionToEvcode[config.ChannelUp]]] = openrgb.Color{
-				Red:   chanColor.Red / 3,
-				Green: chanColor.Green / 3,
-				Blue:  chanColor.Blue / 3,
-			}
-		}
-
-		ledArray[indexMap[actionToEvcode[config.Multinote]]] = white1
-
-		var hsvOfsset float64
-
-		// keyboard mapping
-		for code, key := range d.config.KeyMappings[d.mapping].Midi[""] {
-			id, ok := indexMap[code]
-			if !ok {
-				continue
-			}
-
-			note := key.Note
-			x := int(note) + offset
-			if x < 0 || x > 127 {
-				continue
-			}
-
-			var color openrgb.Color
-
-			if d.config.KeyMappings[d.mapping].Name == "Control" {
-				color = d.config.OpenRGB.Colors.White
-			} else {
-				switch x % 12 {
-				case 0: // c
-					color = d.config.OpenRGB.Colors.C
-				case 1, 3, 6, 8, 10: // black keys
-					color = d.config.OpenRGB.Colors.Black
-				default: // white keys
-					color = d.config.OpenRGB.Colors.White
-				}
-			}
-
-			color = shiftColor(color, hsvOfsset*0.5)
-
-			ledArray[id] = color
-		}
-
-		// active external
-		d.externalTrackerMutex.Lock()
-		for ch := 15; ch >= 0; ch-- {
-			for note := range d.externalNoteTracker[byte(ch)] {
-				note = note - byte(offset)
-				for _, code := range MidiKeyMappings[d.mapping][note] {
-					id, ok := indexMap[code]
-					if !ok {
-						continue
-					}
-					ledArray[id] = channelColors[byte(ch)]
-				}
-			}
-		}
-
-		// current channel
-		for note := range d.externalNoteTracker[d.channel] {
-			note = note - byte(offset)
-			for _, code := range MidiKeyMappings[d.mapping][note] {
-				id, ok := indexMap[code]
-				if !ok {
-					continue
-				}
-				ledArray[id] = d.config.OpenRGB.Colors.ActiveExternal
-			}
-		}
-		d.externalTrackerMutex.Unlock()
-
-		// other channels
-		for _, noteAndChannel := range d.noteTracker {
-			note := noteAndChannel[0] - byte(offset)
-
-			for _, code := range MidiKeyMappings[d.mapping][note] {
-				id, ok := indexMap[code]
-				if !ok {
-					continue
-				}
-				ledArray[id] = d.config.OpenRGB.Colors.Active
-			}
-		}
-
-		err = c.UpdateLEDs(index, ledArray)
-		if err != nil {
-			updateFails++
-			now := time.Now()
-			if now.After(nextFailedLedUpdateReport) {
-				log.Info(fmt.Sprintf("[OpenRGB] Led update fails %d times, last err: %s", updateFails, err), d.logFields(logger.Debug)...)
-				updateFails = 0
-				nextFailedLedUpdateReport = now.Add(time.Second * 2)
-			}
-		}
-		d.eventProcessMutex.Unlock()
-	}
-
-	for i := range ledArray {
-		ledArray[i] = openrgb.Color{Red: 0xff}
+	for i := range r.colors {
+		r.colors[i] = openrgb.Color{Red: 0xff}
 	}
-	c.UpdateLEDs(index, ledArray)
+	c.UpdateLEDs(index, r.colors)
 	log.Info(fmt.Sprintf("[OpenRGB] device thread exited"), d.logFields(logger.Debug)...)
 }
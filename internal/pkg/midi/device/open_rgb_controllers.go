@@ -0,0 +1,250 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/realbucksavage/openrgb-go"
+)
+
+const heatDecay = 2.0 // note heatmap cooling per second
+
+// controllerFrame is a snapshot of device state shown on bound OpenRGB controllers
+type controllerFrame struct {
+	channel      byte
+	notes        [128]bool // notes active on any channel, played on the device or received from midi input
+	clockTicks   int
+	clockRunning bool
+}
+
+// boundController is an OpenRGB controller bound by name in device config
+type boundController struct {
+	index        int
+	config       config.OpenRGBController
+	colors       []openrgb.Color // all LEDs of the controller
+	initial      []openrgb.Color // colors restored on exit
+	first, count int             // LEDs range of configured zone
+	heat         [128]float64
+}
+
+func newBoundController(index int, dev openrgb.Device, cfg config.OpenRGBController) (*boundController, error) {
+	b := &boundController{
+		index:   index,
+		config:  cfg,
+		colors:  make([]openrgb.Color, len(dev.Colors)),
+		initial: make([]openrgb.Color, len(dev.Colors)),
+		count:   len(dev.Colors),
+	}
+	copy(b.colors, dev.Colors)
+	copy(b.initial, dev.Colors)
+
+	if cfg.Zone == "" {
+		return b, nil
+	}
+
+	first := 0
+	for _, zone := range dev.Zones {
+		if zone.Name == cfg.Zone {
+			b.first, b.count = first, int(zone.TotalLEDs)
+			if b.first+b.count > len(b.colors) {
+				return nil, fmt.Errorf("zone \"%s\" exceeds controller LEDs", cfg.Zone)
+			}
+			return b, nil
+		}
+		first += int(zone.TotalLEDs)
+	}
+	return nil, fmt.Errorf("zone \"%s\" not found", cfg.Zone)
+}
+
+// render updates colors of configured zone, dt is a time since previous frame in seconds
+func (b *boundController) render(frame controllerFrame, dt float64) {
+	leds := b.colors[b.first : b.first+b.count]
+
+	switch b.config.Mode {
+	case config.ControllerChannel:
+		for i := range leds {
+			leds[i] = channelColors[frame.channel]
+		}
+	case config.ControllerNotes:
+		for note, active := range frame.notes {
+			if active {
+				b.heat[note] = 1.0
+				continue
+			}
+			b.heat[note] -= dt * heatDecay
+			if b.heat[note] < 0 {
+				b.heat[note] = 0
+			}
+		}
+
+		for i := range leds {
+			low, high := i*128/len(leds), (i+1)*128/len(leds)
+			if high == low {
+				high = low + 1
+			}
+
+			var heat float64
+			for _, h := range b.heat[low:high] {
+				if h > heat {
+					heat = h
+				}
+			}
+			leds[i] = valueToColor(heat*2-1, 1, heat)
+		}
+	case config.ControllerBeat:
+		var color openrgb.Color
+		if frame.clockRunning {
+			// clockTicks is incremented on every tick, first tick of the beat is counted as 1
+			tick := (frame.clockTicks - 1 + ticksPerBar) % ticksPerBar
+			color = channelColors[frame.channel]
+			if tick < 24 { // first beat of the bar
+				color = openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff}
+			}
+			color = dimColor(color, 1-float64(tick%24)/24)
+		}
+		for i := range leds {
+			leds[i] = color
+		}
+	}
+}
+
+// controllerFrame returns controller frame of published device and midi input state
+func (d *Device) controllerFrame(now time.Time) controllerFrame {
+	state, external := d.deviceState(), d.externalState()
+
+	frame := controllerFrame{
+		channel:      state.channel,
+		clockTicks:   external.clockTicks,
+		clockRunning: now.Sub(external.lastClock) <= clockTimeout,
+	}
+	for _, voices := range state.notes {
+		for _, noteAndChannel := range voices {
+			frame.notes[noteAndChannel[0]&0x7f] = true
+		}
+	}
+	for _, noteAndChannel := range state.analogNotes {
+		frame.notes[noteAndChannel[0]&0x7f] = true
+	}
+	for _, notes := range external.notes {
+		for _, note := range notes {
+			frame.notes[note&0x7f] = true
+		}
+	}
+	return frame
+}
+
+// renderer returns render function of the controller, it renders frames of published device state
+func (b *boundController) renderer(d *Device) func(now time.Time) []openrgb.Color {
+	var last time.Time
+	return func(now time.Time) []openrgb.Color {
+		var dt float64
+		if !last.IsZero() {
+			dt = now.Sub(last).Seconds()
+		}
+		last = now
+
+		b.render(d.controllerFrame(now), dt)
+		return b.colors
+	}
+}
+
+// lockedUpdater serializes LED updates of controllers sharing one OpenRGB connection
+type lockedUpdater struct {
+	mutex sync.Mutex
+	c     ledUpdater
+}
+
+func (u *lockedUpdater) UpdateLEDs(index int, colors []openrgb.Color) error {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return u.c.UpdateLEDs(index, colors)
+}
+
+// bindControllers finds OpenRGB controllers defined in device config, every controller with matching name is bound
+func (d *Device) bindControllers(c *openrgb.Client) ([]*boundController, error) {
+	count, err := c.GetControllerCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get controller count: %s", err)
+	}
+
+	var bound []*boundController
+	var found = make(map[string]bool)
+
+	for i := 0; i < count; i++ {
+		dev, err := c.GetDeviceController(i)
+		if err != nil {
+			return nil, fmt.Errorf("getting controller information failed (%d/%d): %s", i, count, err)
+		}
+
+		for _, cfg := range d.config.OpenRGB.Controllers {
+			if cfg.Name != dev.Name {
+				continue
+			}
+			found[cfg.Name] = true
+
+			b, err := newBoundController(i, dev, cfg)
+			if err != nil {
+				log.Info(fmt.Sprintf("[OpenRGB] Cannot bind controller \"%s\": %s", dev.Name, err), d.logFields(logger.Warning)...)
+				continue
+			}
+			bound = append(bound, b)
+		}
+	}
+
+	for _, cfg := range d.config.OpenRGB.Controllers {
+		if !found[cfg.Name] {
+			log.Info(fmt.Sprintf("[OpenRGB] Controller \"%s\" not found", cfg.Name), d.logFields(logger.Warning)...)
+		}
+	}
+
+	return bound, nil
+}
+
+func (d *Device) handleOpenrgbControllers(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if len(d.config.OpenRGB.Controllers) == 0 {
+		return
+	}
+
+	c, err := d.connectOpenrgb(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Info(fmt.Sprintf("[OpenRGB] Cannot connect to server: %s", err), d.logFields(logger.Debug)...)
+		return
+	}
+	defer c.Close()
+
+	controllers, err := d.bindControllers(c)
+	if err != nil {
+		log.Info(fmt.Sprintf("[OpenRGB] Cannot bind controllers: %s", err), d.logFields(logger.Warning)...)
+		return
+	}
+	if len(controllers) == 0 {
+		return
+	}
+
+	log.Info(fmt.Sprintf("[OpenRGB] %d controllers bound", len(controllers)), d.logFields(logger.Debug)...)
+
+	updater := &lockedUpdater{c: c}
+	renderers := sync.WaitGroup{}
+	for _, b := range controllers {
+		renderers.Add(1)
+		go func(b *boundController) {
+			defer renderers.Done()
+			d.renderLoop(ctx, updater, b.index, b.renderer(d))
+		}(b)
+	}
+	renderers.Wait()
+
+	for _, b := range controllers {
+		_ = c.UpdateLEDs(b.index, b.initial)
+	}
+	log.Info(fmt.Sprintf("[OpenRGB] controllers thread exited"), d.logFields(logger.Debug)...)
+}
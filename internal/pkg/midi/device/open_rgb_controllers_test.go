@@ -0,0 +1,81 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var testController = openrgb.Device{
+	Name:   "Mousepad",
+	Colors: make([]openrgb.Color, 6),
+	Zones: []openrgb.Zone{
+		{Name: "Logo", TotalLEDs: 2},
+		{Name: "Edge", TotalLEDs: 4},
+	},
+}
+
+func TestBoundControllerZone(t *testing.T) {
+	b, err := newBoundController(3, testController, config.OpenRGBController{Name: "Mousepad", Zone: "Edge"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, b.first)
+	assert.Equal(t, 4, b.count)
+
+	b, err = newBoundController(3, testController, config.OpenRGBController{Name: "Mousepad"})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, b.first)
+	assert.Equal(t, 6, b.count)
+
+	_, err = newBoundController(3, testController, config.OpenRGBController{Name: "Mousepad", Zone: "Top"})
+	assert.NotNil(t, err)
+}
+
+func TestBoundControllerRender(t *testing.T) {
+	b, err := newBoundController(0, testController, config.OpenRGBController{Zone: "Edge", Mode: config.ControllerChannel})
+	assert.Nil(t, err)
+
+	b.render(controllerFrame{channel: 2}, 0.02)
+	black, chanColor := openrgb.Color{}, channelColors[2]
+	assert.Equal(t, []openrgb.Color{black, black, chanColor, chanColor, chanColor, chanColor}, b.colors)
+
+	// notes spread over 4 LEDs, 32 notes each
+	b.config.Mode = config.ControllerNotes
+	var frame controllerFrame
+	frame.notes[40] = true
+	b.render(frame, 0.02)
+	assert.Equal(t, []openrgb.Color{black, valueToColor(1, 1, 1), black, black}, b.colors[2:])
+
+	// released note cools down
+	b.render(controllerFrame{}, 0.25)
+	assert.Equal(t, valueToColor(0, 1, 0.5), b.colors[3])
+	b.render(controllerFrame{}, 0.25)
+	assert.Equal(t, black, b.colors[3])
+
+	b.config.Mode = config.ControllerBeat
+	b.render(controllerFrame{channel: 2, clockTicks: 25, clockRunning: true}, 0.02)
+	assert.Equal(t, chanColor, b.colors[2]) // second beat of the bar
+	b.render(controllerFrame{channel: 2, clockTicks: 1, clockRunning: true}, 0.02)
+	assert.Equal(t, openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff}, b.colors[2]) // downbeat
+	b.render(controllerFrame{channel: 2, clockTicks: 1}, 0.02)
+	assert.Equal(t, black, b.colors[2])
+}
+
+func TestControllerFrame(t *testing.T) {
+	d := rumbleDevice(nil)
+
+	d.processEvent(key(evdev.BTN_A, EV_KEY_PRESS))
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 5, 60, 100))
+	d.handleMidiInEvent(midi.Event{midi.TimingClock})
+
+	frame := d.controllerFrame(time.Now())
+	assert.True(t, frame.notes[0])
+	assert.True(t, frame.notes[60])
+	assert.Equal(t, 1, frame.clockTicks)
+	assert.True(t, frame.clockRunning)
+	assert.False(t, d.controllerFrame(time.Now().Add(time.Second)).clockRunning)
+}
@@ -0,0 +1,164 @@
+package device
+
+import (
+	"math"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+)
+
+// keyGlow is an animation state of a played key
+type keyGlow struct {
+	velocity float64 // 0.0 - 1.0
+	held     bool
+	released time.Time
+}
+
+// ripple spreads in note space from played note
+type ripple struct {
+	note     int
+	velocity float64 // 0.0 - 1.0
+	start    time.Time
+}
+
+// keyEffects keeps animation state of played keys, guarded by eventProcessMutex
+type keyEffects struct {
+	keys    map[evdev.EvCode]keyGlow
+	ripples []ripple
+}
+
+func newKeyEffects() *keyEffects {
+	return &keyEffects{keys: make(map[evdev.EvCode]keyGlow, 32)}
+}
+
+// snapshot returns a copy that is not affected by further changes
+func (e *keyEffects) snapshot() *keyEffects {
+	s := &keyEffects{
+		keys:    make(map[evdev.EvCode]keyGlow, len(e.keys)),
+		ripples: make([]ripple, len(e.ripples)),
+	}
+	for code, glow := range e.keys {
+		s.keys[code] = glow
+	}
+	copy(s.ripples, e.ripples)
+	return s
+}
+
+func (e *keyEffects) press(code evdev.EvCode, note, velocity byte, fx config.Effects, now time.Time) {
+	e.prune(fx, now)
+
+	v := float64(velocity) / 127
+	e.keys[code] = keyGlow{velocity: v, held: true}
+	if fx.Ripple > 0 {
+		e.ripples = append(e.ripples, ripple{note: int(note), velocity: v, start: now})
+	}
+}
+
+func (e *keyEffects) release(code evdev.EvCode, now time.Time) {
+	glow, ok := e.keys[code]
+	if !ok {
+		return
+	}
+	glow.held = false
+	glow.released = now
+	e.keys[code] = glow
+}
+
+// prune drops animations that are finished for given effects
+func (e *keyEffects) prune(fx config.Effects, now time.Time) {
+	for code, glow := range e.keys {
+		if !glow.held && now.Sub(glow.released) >= fx.Afterglow {
+			delete(e.keys, code)
+		}
+	}
+
+	ripples := e.ripples[:0]
+	for _, r := range e.ripples {
+		if now.Sub(r.start) < fx.Ripple {
+			ripples = append(ripples, r)
+		}
+	}
+	e.ripples = ripples
+}
+
+// color returns color of a key playing given note, base is a color of idle key
+func (e *keyEffects) color(code evdev.EvCode, note int, base, active openrgb.Color, fx config.Effects, now time.Time) openrgb.Color {
+	var intensity float64
+
+	if glow, ok := e.keys[code]; ok {
+		v := 1.0
+		if fx.Velocity {
+			v = glow.velocity
+		}
+		if glow.held {
+			return blendColor(base, active, v)
+		}
+		if fx.Afterglow > 0 {
+			intensity = v * (1 - float64(now.Sub(glow.released))/float64(fx.Afterglow))
+		}
+	}
+
+	if fx.Ripple > 0 {
+		for _, r := range e.ripples {
+			progress := float64(now.Sub(r.start)) / float64(fx.Ripple)
+			if progress < 0 || progress >= 1 {
+				continue
+			}
+			distance := math.Abs(float64(note - r.note))
+			if distance == 0 || distance > float64(fx.RippleWidth) {
+				continue
+			}
+
+			// ring of one semitone width, reaching RippleWidth at the end of its lifetime
+			radius := progress * float64(fx.RippleWidth)
+			ring := (1 - math.Abs(distance-radius)) * (1 - progress)
+			if fx.Velocity {
+				ring *= r.velocity
+			}
+			intensity = math.Max(intensity, ring)
+		}
+	}
+
+	return blendColor(base, active, math.Max(0, math.Min(1, intensity)))
+}
+
+// blendColor mixes two colors, value 0.0 returns a, value 1.0 returns b
+func blendColor(a, b openrgb.Color, value float64) openrgb.Color {
+	mix := func(x, y byte) byte {
+		return byte(math.Round(float64(x) + (float64(y)-float64(x))*value))
+	}
+	return openrgb.Color{
+		Red:   mix(a.Red, b.Red),
+		Green: mix(a.Green, b.Green),
+		Blue:  mix(a.Blue, b.Blue),
+	}
+}
+
+// SetThemes replaces OpenRGB themes, it is safe to call on running device
+func (d *Device) SetThemes(themes map[string]config.Theme) {
+	d.themes.Store(themes)
+}
+
+// theme returns theme of current mapping, eventProcessMutex has to be held by the caller
+func (d *Device) theme() config.Theme {
+	return d.themeOf(d.mapping)
+}
+
+// themeOf returns theme of given mapping
+func (d *Device) themeOf(mapping int) config.Theme {
+	name := d.config.KeyMappings[mapping].Theme
+	if name == "" {
+		name = d.config.OpenRGB.Theme
+	}
+
+	if name != "" {
+		themes, _ := d.themes.Load().(map[string]config.Theme)
+		if theme, ok := themes[name]; ok {
+			return theme
+		}
+	}
+
+	return config.Theme{Colors: d.config.OpenRGB.Colors, Effects: d.config.OpenRGB.Effects}
+}
@@ -0,0 +1,122 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testBase   = openrgb.Color{Blue: 100}
+	testActive = openrgb.Color{Red: 200, Blue: 200}
+)
+
+func TestBlendColor(t *testing.T) {
+	assert.Equal(t, testBase, blendColor(testBase, testActive, 0))
+	assert.Equal(t, testActive, blendColor(testBase, testActive, 1))
+	assert.Equal(t, openrgb.Color{Red: 100, Blue: 150}, blendColor(testBase, testActive, 0.5))
+}
+
+func TestKeyEffectsAfterglow(t *testing.T) {
+	fx := config.Effects{Afterglow: time.Millisecond * 100}
+	e := newKeyEffects()
+	now := time.Now()
+
+	e.press(evdev.KEY_A, 60, 127, fx, now)
+	assert.Equal(t, testActive, e.color(evdev.KEY_A, 60, testBase, testActive, fx, now))
+	assert.Equal(t, testBase, e.color(evdev.KEY_S, 62, testBase, testActive, fx, now))
+
+	e.release(evdev.KEY_A, now)
+	assert.Equal(t, testActive, e.color(evdev.KEY_A, 60, testBase, testActive, fx, now))
+	assert.Equal(t, openrgb.Color{Red: 100, Blue: 150}, e.color(evdev.KEY_A, 60, testBase, testActive, fx, now.Add(time.Millisecond*50)))
+
+	e.prune(fx, now.Add(time.Millisecond*100))
+	assert.Empty(t, e.keys)
+	assert.Equal(t, testBase, e.color(evdev.KEY_A, 60, testBase, testActive, fx, now.Add(time.Millisecond*100)))
+
+	// without afterglow key goes dark right after release
+	e.press(evdev.KEY_A, 60, 127, config.Effects{}, now)
+	e.release(evdev.KEY_A, now)
+	assert.Equal(t, testBase, e.color(evdev.KEY_A, 60, testBase, testActive, config.Effects{}, now))
+}
+
+func TestKeyEffectsVelocity(t *testing.T) {
+	fx := config.Effects{Velocity: true}
+	e := newKeyEffects()
+	now := time.Now()
+
+	e.press(evdev.KEY_A, 60, 127, fx, now)
+	e.press(evdev.KEY_S, 62, 0, fx, now)
+	assert.Equal(t, testActive, e.color(evdev.KEY_A, 60, testBase, testActive, fx, now))
+	assert.Equal(t, testBase, e.color(evdev.KEY_S, 62, testBase, testActive, fx, now))
+
+	// velocity scaling disabled by theme
+	assert.Equal(t, testActive, e.color(evdev.KEY_S, 62, testBase, testActive, config.Effects{}, now))
+}
+
+func TestKeyEffectsRipple(t *testing.T) {
+	fx := config.Effects{Ripple: time.Millisecond * 100, RippleWidth: 4}
+	e := newKeyEffects()
+	now := time.Now()
+
+	e.press(evdev.KEY_A, 60, 127, fx, now)
+
+	// halfway through its lifetime ripple reaches 2 semitones with half of intensity
+	half := now.Add(time.Millisecond * 50)
+	assert.Equal(t, openrgb.Color{Red: 100, Blue: 150}, e.color(evdev.KEY_S, 62, testBase, testActive, fx, half))
+	assert.Equal(t, openrgb.Color{Red: 100, Blue: 150}, e.color(evdev.KEY_Q, 58, testBase, testActive, fx, half))
+	assert.Equal(t, testBase, e.color(evdev.KEY_D, 64, testBase, testActive, fx, half))
+	assert.Equal(t, testBase, e.color(evdev.KEY_F, 60, testBase, testActive, fx, half)) // same note, other key
+
+	e.prune(fx, now.Add(time.Millisecond*100))
+	assert.Empty(t, e.ripples)
+
+	// ripples are not recorded when disabled
+	e.press(evdev.KEY_A, 60, 127, config.Effects{}, now)
+	assert.Empty(t, e.ripples)
+}
+
+func TestDeviceTheme(t *testing.T) {
+	own := config.Colors{Active: openrgb.Color{Red: 0xff}}
+	neon := config.Theme{
+		Colors:  config.Colors{Active: openrgb.Color{Green: 0xff}},
+		Effects: config.Effects{Afterglow: time.Second},
+	}
+
+	cfg := config.DeviceConfig{
+		Config: config.Config{
+			KeyMappings: []config.KeyMapping{
+				{Name: "Default", Midi: map[string]map[evdev.EvCode]config.Key{"": {evdev.KEY_A: {Note: 60}}}},
+				{Name: "Neon", Theme: "neon", Midi: map[string]map[evdev.EvCode]config.Key{"": {}}},
+			},
+			ActionMapping: map[evdev.EvCode]config.Action{},
+			CollisionMode: config.CollisionOff,
+			Defaults:      config.Defaults{Channel: 1, Velocity: 64},
+			OpenRGB:       config.OpenRGB{Colors: own, FPS: 100},
+		},
+	}
+	dev := NewDevice(input.Device{Name: "Dummy"}, cfg, make(chan midi.Event, 256), nil, true, 0, nil)
+	d := &dev
+
+	assert.Equal(t, config.Theme{Colors: own}, d.theme())
+
+	// theme not loaded yet
+	d.mapping = 1
+	assert.Equal(t, config.Theme{Colors: own}, d.theme())
+
+	d.SetThemes(map[string]config.Theme{"neon": neon})
+	assert.Equal(t, neon, d.theme())
+
+	d.mapping = 0
+	d.processEvent(key(evdev.KEY_A, EV_KEY_PRESS))
+	assert.True(t, d.keyEffects.keys[evdev.KEY_A].held)
+	assert.InDelta(t, 64.0/127, d.keyEffects.keys[evdev.KEY_A].velocity, 0.001)
+	d.processEvent(key(evdev.KEY_A, EV_KEY_RELEASE))
+	assert.False(t, d.keyEffects.keys[evdev.KEY_A].held)
+}
@@ -0,0 +1,330 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+)
+
+// unchanged frame is resent once in a while to recover from changes made by other OpenRGB clients
+const frameResendInterval = time.Second
+
+// ledUpdater sends colors to OpenRGB controller, implemented by openrgb.Client
+type ledUpdater interface {
+	UpdateLEDs(index int, colors []openrgb.Color) error
+}
+
+// keyboardRenderer renders frames of device's own keyboard from published state snapshots
+type keyboardRenderer struct {
+	d *Device
+
+	colors     []openrgb.Color
+	baseColors map[int]openrgb.Color // idle colors of mapped keys, by LED index
+
+	indexMap        map[evdev.EvCode]int
+	nameToIndex     map[string]int
+	actionToEvcode  map[config.Action]evdev.EvCode
+	midiKeyMappings []map[byte][]evdev.EvCode // note to hardware buttons, by mapping
+	strip           DeviceLedStrip
+}
+
+func (d *Device) newKeyboardRenderer(dev openrgb.Device) *keyboardRenderer {
+	r := &keyboardRenderer{
+		d:              d,
+		colors:         make([]openrgb.Color, len(dev.Colors)),
+		baseColors:     make(map[int]openrgb.Color),
+		indexMap:       keyLayout(dev.LEDs, d.config.OpenRGB.Keys),
+		nameToIndex:    make(map[string]int),
+		actionToEvcode: make(map[config.Action]evdev.EvCode),
+		strip:          NewDeviceLedStrip(ledStrip(dev, d.config.OpenRGB.Strip)),
+	}
+
+	for i, led := range dev.LEDs {
+		r.nameToIndex[led.Name] = i
+	}
+
+	for _, m := range d.config.KeyMappings {
+		var midiKeyMapping = make(map[byte][]evdev.EvCode)
+		for code, key := range m.Midi[""] {
+			midiKeyMapping[key.Note] = append(midiKeyMapping[key.Note], code)
+		}
+		r.midiKeyMappings = append(r.midiKeyMappings, midiKeyMapping)
+	}
+
+	for code, action := range d.config.ActionMapping {
+		r.actionToEvcode[action] = code
+	}
+
+	return r
+}
+
+// render returns a frame for given time, returned slice is reused by the next call
+func (r *keyboardRenderer) render(now time.Time) []openrgb.Color {
+	d := r.d
+	state, external := d.deviceState(), d.externalState()
+
+	ledArray, indexMap, actionToEvcode := r.colors, r.indexMap, r.actionToEvcode
+
+	offset := int(state.semitone) + int(state.octave)*12
+	theme := d.themeOf(state.mapping)
+	colors, fx := theme.Colors, theme.Effects
+
+	white1 := openrgb.Color{Red: 27, Green: 27, Blue: 27}
+	white2 := openrgb.Color{Red: 100, Green: 100, Blue: 100}
+	white3 := openrgb.Color{Red: 255, Green: 255, Blue: 255}
+
+	for i := 0; i < len(ledArray); i++ {
+		ledArray[i] = colors.Unavailable
+	}
+
+	for _, key := range r.strip.ledSeq {
+		ledArray[r.nameToIndex[key]] = openrgb.Color{}
+	}
+
+	for key, intensity := range meterLEDs(d.config.OpenRGB.Meter, &r.strip, state, external, now) {
+		ledArray[r.nameToIndex[key]] = dimColor(d.config.OpenRGB.Meter.Color, intensity)
+	}
+
+	ledArray[indexMap[actionToEvcode[config.Panic]]] = openrgb.Color{Red: 0xff}
+
+	ledArray[indexMap[actionToEvcode[config.OctaveUp]]] = white1
+	ledArray[indexMap[actionToEvcode[config.OctaveDown]]] = white1
+
+	if state.octave > 0 {
+		if state.octave == 1 {
+			ledArray[indexMap[actionToEvcode[config.OctaveUp]]] = white2
+		} else {
+			ledArray[indexMap[actionToEvcode[config.OctaveUp]]] = white3
+		}
+	}
+	if state.octave < 0 {
+		if state.octave == -1 {
+			ledArray[indexMap[actionToEvcode[config.OctaveDown]]] = white2
+		} else {
+			ledArray[indexMap[actionToEvcode[config.OctaveDown]]] = white3
+		}
+	}
+
+	ledArray[indexMap[actionToEvcode[config.SemitoneUp]]] = white1
+	ledArray[indexMap[actionToEvcode[config.SemitoneDown]]] = white1
+	if state.semitone > 0 {
+		if state.semitone == 1 {
+			ledArray[indexMap[actionToEvcode[config.SemitoneUp]]] = white2
+		} else {
+			ledArray[indexMap[actionToEvcode[config.SemitoneUp]]] = white3
+		}
+	}
+	if state.semitone < 0 {
+		if state.semitone == -1 {
+			ledArray[indexMap[actionToEvcode[config.SemitoneDown]]] = white2
+		} else {
+			ledArray[indexMap[actionToEvcode[config.SemitoneDown]]] = white3
+		}
+	}
+
+	ledArray[indexMap[actionToEvcode[config.MappingUp]]] = white3
+	ledArray[indexMap[actionToEvcode[config.MappingDown]]] = white3
+	if state.mapping == 0 {
+		ledArray[indexMap[actionToEvcode[config.MappingDown]]] = white1
+	}
+	if state.mapping == len(d.config.KeyMappings)-1 {
+		ledArray[indexMap[actionToEvcode[config.MappingUp]]] = white1
+	}
+
+	chanColor := channelColors[state.channel]
+	ledArray[indexMap[actionToEvcode[config.ChannelUp]]] = chanColor
+	ledArray[indexMap[actionToEvcode[config.ChannelDown]]] = chanColor
+	if state.channel == 0 {
+		ledArray[indexMap[actionToEvcode[config.ChannelDown]]] = openrgb.Color{
+			Red:   chanColor.Red / 3,
+			Green: chanColor.Green / 3,
+			Blue:  chanColor.Blue / 3,
+		}
+	}
+	if state.channel == 15 {
+		ledArray[indexMap[actionToEvcode[config.ChannelUp]]] = openrgb.Color{
+			Red:   chanColor.Red / 3,
+			Green: chanColor.Green / 3,
+			Blue:  chanColor.Blue / 3,
+		}
+	}
+
+	ledArray[indexMap[actionToEvcode[config.Multinote]]] = white1
+
+	var hsvOfsset float64
+
+	// keyboard mapping
+	for code, key := range d.config.KeyMappings[state.mapping].Midi[""] {
+		id, ok := indexMap[code]
+		if !ok {
+			continue
+		}
+
+		note := key.Note
+		x := int(note) + offset
+		if x < 0 || x > 127 {
+			continue
+		}
+
+		var color openrgb.Color
+
+		if d.config.KeyMappings[state.mapping].Name == "Control" {
+			color = colors.White
+		} else {
+			switch x % 12 {
+			case 0: // c
+				color = colors.C
+			case 1, 3, 6, 8, 10: // black keys
+				color = colors.Black
+			default: // white keys
+				color = colors.White
+			}
+		}
+
+		color = shiftColor(color, hsvOfsset*0.5)
+
+		r.baseColors[id] = color
+		ledArray[id] = state.keyEffects.color(code, x, color, colors.Active, fx, now)
+	}
+
+	midiKeyMapping := r.midiKeyMappings[state.mapping]
+
+	// active external
+	for ch := 15; ch >= 0; ch-- {
+		for _, note := range external.notes[ch] {
+			note = note - byte(offset)
+			for _, code := range midiKeyMapping[note] {
+				id, ok := indexMap[code]
+				if !ok {
+					continue
+				}
+				ledArray[id] = channelColors[byte(ch)]
+			}
+		}
+	}
+
+	// current channel
+	for _, note := range external.notes[state.channel] {
+		note = note - byte(offset)
+		for _, code := range midiKeyMapping[note] {
+			id, ok := indexMap[code]
+			if !ok {
+				continue
+			}
+			ledArray[id] = colors.ActiveExternal
+		}
+	}
+
+	// other channels
+	for trackedCode, voices := range state.notes {
+		for _, noteAndChannel := range voices {
+			note := noteAndChannel[0] - byte(offset)
+
+			for _, code := range midiKeyMapping[note] {
+				id, ok := indexMap[code]
+				if !ok {
+					continue
+				}
+				ledArray[id] = state.keyEffects.color(trackedCode, int(noteAndChannel[0]), r.baseColors[id], colors.Active, fx, now)
+			}
+		}
+	}
+
+	if state.sequencer != nil {
+		r.renderSequencer(state.sequencer, midiKeyMapping, offset, colors, white1, white3)
+	}
+
+	return ledArray
+}
+
+// renderSequencer shows steps of the current page on the number row and notes of the selected step
+func (r *keyboardRenderer) renderSequencer(
+	s *sequencerSnapshot, midiKeyMapping map[byte][]evdev.EvCode,
+	offset int, colors config.Colors, empty, selected openrgb.Color,
+) {
+	page := s.selected / sequencerPage * sequencerPage
+	for code, i := range sequencerStepKeys {
+		id, ok := r.indexMap[code]
+		if !ok {
+			continue
+		}
+		step := page + i
+		switch {
+		case step >= len(s.steps):
+			r.colors[id] = colors.Unavailable
+		case step == s.playhead:
+			r.colors[id] = colors.Active
+		case step == s.selected:
+			r.colors[id] = selected
+		case s.steps[step]:
+			r.colors[id] = colors.C
+		default:
+			r.colors[id] = empty
+		}
+	}
+
+	for _, note := range s.notes {
+		for _, code := range midiKeyMapping[note-byte(offset)] {
+			if id, ok := r.indexMap[code]; ok {
+				r.colors[id] = colors.Active
+			}
+		}
+	}
+}
+
+// FrameChanged tells if frame differs from the previously sent one
+func FrameChanged(sent, frame []openrgb.Color) bool {
+	if len(sent) != len(frame) {
+		return true
+	}
+	for i := range frame {
+		if sent[i] != frame[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// renderLoop renders frames with configured rate and sends the changed ones to OpenRGB server.
+// Frames are rendered from published state snapshots without taking any device lock,
+// slow server doesn't delay event processing this way.
+func (d *Device) renderLoop(ctx context.Context, c ledUpdater, index int, render func(now time.Time) []openrgb.Color) {
+	frameInterval := time.Second / time.Duration(d.config.OpenRGB.FPS)
+
+	var sent []openrgb.Color
+	var lastSent time.Time
+
+	nextFailedLedUpdateReport := time.Now()
+	updateFails := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(frameInterval):
+		}
+
+		now := time.Now()
+		frame := render(now)
+		if !FrameChanged(sent, frame) && now.Sub(lastSent) < frameResendInterval {
+			continue
+		}
+
+		err := c.UpdateLEDs(index, frame)
+		if err != nil {
+			updateFails++
+			if now.After(nextFailedLedUpdateReport) {
+				log.Info(fmt.Sprintf("[OpenRGB] Led update fails %d times, last err: %s", updateFails, err), d.logFields(logger.Debug)...)
+				updateFails = 0
+				nextFailedLedUpdateReport = now.Add(time.Second * 2)
+			}
+			continue
+		}
+		sent = append(sent[:0], frame...)
+		lastSent = now
+	}
+}
@@ -0,0 +1,193 @@
+package device
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	renderWhite  = openrgb.Color{Green: 0x55}
+	renderActive = openrgb.Color{Red: 0xff, Green: 0xff, Blue: 0xff}
+)
+
+var testKeyboard = openrgb.Device{
+	Name:   "Keyboard",
+	LEDs:   []openrgb.LED{{Name: "Key: A"}, {Name: "Key: S"}, {Name: "Key: Escape"}},
+	Colors: make([]openrgb.Color, 3),
+}
+
+// fakeUpdater counts frames sent to OpenRGB, every update takes given delay like a slow server,
+// with block set updates hang until it is closed like an unresponsive one
+type fakeUpdater struct {
+	mutex  sync.Mutex
+	delay  time.Duration
+	block  chan struct{}
+	frames [][]openrgb.Color
+}
+
+func (f *fakeUpdater) UpdateLEDs(index int, colors []openrgb.Color) error {
+	time.Sleep(f.delay)
+	if f.block != nil {
+		<-f.block
+	}
+	f.mutex.Lock()
+	f.frames = append(f.frames, append([]openrgb.Color{}, colors...))
+	f.mutex.Unlock()
+	return nil
+}
+
+func (f *fakeUpdater) count() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return len(f.frames)
+}
+
+func renderDevice() (*Device, chan midi.Event) {
+	keys := map[evdev.EvCode]config.Key{evdev.KEY_A: {Note: 62}, evdev.KEY_S: {Note: 64}}
+	return testDevice(testConfig(keys, nil, func(c *config.Config) {
+		c.ActionMapping[evdev.KEY_ESC] = config.Panic
+		c.OpenRGB = config.OpenRGB{
+			Colors: config.Colors{White: renderWhite, Active: renderActive, ActiveExternal: renderActive},
+			FPS:    1000,
+		}
+	}), nil)
+}
+
+func TestFrameChanged(t *testing.T) {
+	frame := []openrgb.Color{{Red: 1}, {Green: 2}}
+
+	assert.True(t, FrameChanged(nil, frame))
+	assert.False(t, FrameChanged([]openrgb.Color{{Red: 1}, {Green: 2}}, frame))
+	assert.True(t, FrameChanged([]openrgb.Color{{Red: 1}, {Green: 3}}, frame))
+}
+
+func TestKeyboardRenderer(t *testing.T) {
+	d, _ := renderDevice()
+	r := d.newKeyboardRenderer(testKeyboard)
+
+	assert.Equal(t, []openrgb.Color{renderWhite, renderWhite, {Red: 0xff}}, r.render(time.Now()))
+
+	// renderer sees published state only
+	d.processEvent(key(evdev.KEY_A, EV_KEY_PRESS))
+	assert.Equal(t, []openrgb.Color{renderActive, renderWhite, {Red: 0xff}}, r.render(time.Now()))
+
+	d.processEvent(key(evdev.KEY_A, EV_KEY_RELEASE))
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 3, 64, 100))
+	assert.Equal(t, []openrgb.Color{renderWhite, channelColors[3], {Red: 0xff}}, r.render(time.Now()))
+}
+
+func TestPublishChangedState(t *testing.T) {
+	d := ledsDevice()
+	published := d.deviceState()
+
+	// analog events not changing rendered state are not published
+	d.processEvent(abs(evdev.ABS_Z, 100))
+	assert.Same(t, published, d.deviceState())
+
+	d.processEvent(abs(evdev.ABS_Z, 255))
+	assert.NotSame(t, published, d.deviceState())
+	assert.True(t, d.deviceState().sustain)
+}
+
+func TestRenderLoop(t *testing.T) {
+	d, _ := renderDevice()
+	r := d.newKeyboardRenderer(testKeyboard)
+	updater := &fakeUpdater{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		d.renderLoop(ctx, updater, 0, r.render)
+		close(done)
+	}()
+
+	// unchanged frames are not resent
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, 1, updater.count())
+
+	d.processEvent(key(evdev.KEY_S, EV_KEY_PRESS))
+	time.Sleep(time.Millisecond * 50)
+	assert.Equal(t, 2, updater.count())
+	assert.Equal(t, []openrgb.Color{renderWhite, renderActive, {Red: 0xff}}, updater.frames[1])
+
+	cancel()
+	<-done
+}
+
+func TestRenderLoopDoesNotBlockInput(t *testing.T) {
+	d, midiEvents := renderDevice()
+	r := d.newKeyboardRenderer(testKeyboard)
+	updater := &fakeUpdater{block: make(chan struct{})}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		d.renderLoop(ctx, updater, 0, r.render)
+		close(done)
+	}()
+
+	// render loop hangs on the first frame
+	time.Sleep(time.Millisecond * 20)
+
+	processed := make(chan bool)
+	go func() {
+		for _, ie := range []*input.InputEvent{
+			key(evdev.KEY_A, EV_KEY_PRESS), key(evdev.KEY_A, EV_KEY_RELEASE),
+			key(evdev.KEY_S, EV_KEY_PRESS), key(evdev.KEY_S, EV_KEY_RELEASE),
+		} {
+			d.processEvent(ie)
+		}
+		close(processed)
+	}()
+
+	for i := 0; i < 4; i++ {
+		select {
+		case <-midiEvents:
+		case <-time.After(time.Millisecond * 50):
+			t.Fatalf("midi event %d not emitted while OpenRGB update is blocked", i)
+		}
+	}
+	<-processed
+	assert.Equal(t, 0, updater.count())
+
+	cancel()
+	close(updater.block)
+	<-done
+}
+
+// BenchmarkKeyToMidi measures time from key event to emitted midi message with idle and slow OpenRGB server,
+// TestRenderLoopDoesNotBlockInput covers the latency guarantee itself
+func BenchmarkKeyToMidi(b *testing.B) {
+	run := func(b *testing.B, delay time.Duration) {
+		d, midiEvents := renderDevice()
+
+		if delay > 0 {
+			r := d.newKeyboardRenderer(testKeyboard)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go d.renderLoop(ctx, &fakeUpdater{delay: delay}, 0, r.render)
+		}
+
+		press, release := key(evdev.KEY_A, EV_KEY_PRESS), key(evdev.KEY_A, EV_KEY_RELEASE)
+
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			d.processEvent(press)
+			<-midiEvents
+			d.processEvent(release)
+			<-midiEvents
+		}
+	}
+
+	b.Run("idle", func(b *testing.B) { run(b, 0) })
+	b.Run("slow_openrgb", func(b *testing.B) { run(b, time.Millisecond*10) })
+}
@@ -0,0 +1,107 @@
+package device
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/gethiox/HIDI/internal/pkg/openrgbmock"
+	"github.com/holoplot/go-evdev"
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyLayout(t *testing.T) {
+	leds := []openrgb.LED{
+		{Name: "Key: Escape"},
+		{Name: "Key: A"},
+		{Name: "Key: \\ (ISO)"},
+		{Name: "Key: Fn"},
+		{Name: "Key: B"},
+	}
+
+	assert.Equal(t, map[evdev.EvCode]int{
+		evdev.KEY_ESC: 0,
+		evdev.KEY_A:   1,
+		evdev.KEY_B:   4,
+	}, keyLayout(leds, nil))
+
+	assert.Equal(t, map[evdev.EvCode]int{
+		evdev.KEY_ESC:   0,
+		evdev.KEY_A:     1,
+		evdev.KEY_102ND: 2, // new key by name
+		evdev.KEY_FN:    3, // new key by index
+		// KEY_B unbound
+	}, keyLayout(leds, map[evdev.EvCode]config.OpenRGBLED{
+		evdev.KEY_102ND: {Name: "Key: \\ (ISO)"},
+		evdev.KEY_FN:    {Index: 3},
+		evdev.KEY_B:     {Index: -1},
+		evdev.KEY_C:     {Name: "Key: C"}, // not present on the controller
+		evdev.KEY_D:     {Index: 10},
+	}))
+}
+
+func TestLedStrip(t *testing.T) {
+	dev := openrgb.Device{
+		Name: "HyperX Alloy Elite 2 (HP)",
+		LEDs: []openrgb.LED{{Name: "Key: A"}, {Name: "Top 1"}, {Name: "Top 2"}, {Name: "Top 3"}},
+	}
+
+	assert.Equal(t, HyperXAlloyElite2{}, ledStrip(dev, nil))
+
+	strip := ledStrip(dev, []config.OpenRGBLED{{Name: "Top 3"}, {Index: 2}, {Index: 1}, {Name: "Missing"}})
+	assert.Equal(t, []string{"Top 3", "Top 2", "Top 1"}, strip.LEDSequence())
+
+	assert.Equal(t, NoLeds{}, ledStrip(openrgb.Device{Name: "Other"}, nil))
+}
+
+// waitColors waits for mock server to receive expected colors of the first controller
+func waitColors(s *openrgbmock.Server, expected []openrgb.Color) []openrgb.Color {
+	timeout := time.Now().Add(time.Second * 3)
+	for time.Now().Before(timeout) {
+		if colors := s.Colors(0); assert.ObjectsAreEqual(expected, colors) {
+			return colors
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+	return s.Colors(0)
+}
+
+func TestHandleOpenrgbMock(t *testing.T) {
+	var leds []string
+	for _, led := range testKeyboard.LEDs {
+		leds = append(leds, led.Name)
+	}
+	s, err := openrgbmock.Listen("127.0.0.1:0", openrgbmock.Controller{
+		Name: "Mock", Type: openrgbmock.TypeKeyboard, Location: openrgbmock.Location, LEDs: leds,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverCtx, stopServer := context.WithCancel(context.Background())
+	defer stopServer()
+	go s.Serve(serverCtx)
+
+	d, _ := renderDevice()
+	d.openrgbPort = s.Port()
+	d.SetControllerMatcher(MatchAnyKeyboard)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := sync.WaitGroup{}
+	wg.Add(1)
+	go d.handleOpenrgb(ctx, &wg)
+
+	red := openrgb.Color{Red: 0xff}
+	assert.Equal(t, []openrgb.Color{renderWhite, renderWhite, red}, waitColors(s, []openrgb.Color{renderWhite, renderWhite, red}))
+
+	d.processEvent(key(evdev.KEY_A, EV_KEY_PRESS))
+	assert.Equal(t, []openrgb.Color{renderActive, renderWhite, red}, waitColors(s, []openrgb.Color{renderActive, renderWhite, red}))
+
+	// keyboard goes red when device exits
+	cancel()
+	wg.Wait()
+	assert.Equal(t, []openrgb.Color{red, red, red}, waitColors(s, []openrgb.Color{red, red, red}))
+}
@@ -0,0 +1,141 @@
+package device
+
+import (
+	"math"
+	"sync"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// pressureValue converts analog value into 7-bit pressure, centered axes are mapped with rest position at 64
+func pressureValue(value float64, canBeNegative bool) byte {
+	if canBeNegative {
+		value = (value + 1) / 2
+	}
+	return byte(int(127 * math.Max(0, math.Min(1, value))))
+}
+
+func (d *Device) handlePressureAnalog(analog config.Analog, value float64, canBeNegative bool) {
+	pressure := pressureValue(value, canBeNegative)
+
+	switch analog.MappingType {
+	case config.AnalogChannelPressure:
+		channel := (d.channel + analog.ChannelOffset) % 16
+		d.outputEvents <- midi.ChannelPressureEvent(channel, pressure)
+	case config.AnalogPolyPressure:
+		last := analog.PressureTarget == config.PressureLast
+		var voices [][2]byte
+		if d.heldNotes != nil {
+			voices = d.heldNotes.voices(last)
+		} else {
+			voices = d.heldVoices(last)
+		}
+
+		// the same note may be held by multiple keys
+		sent := make(map[[2]byte]bool, len(voices))
+		for _, noteAndChannel := range voices {
+			if sent[noteAndChannel] {
+				continue
+			}
+			sent[noteAndChannel] = true
+			d.outputEvents <- midi.PolyphonicKeyPressureEvent(noteAndChannel[1], noteAndChannel[0], pressure)
+		}
+	}
+}
+
+// heldVoices returns voices of keys held on the device, only of the most recently pressed one with last
+func (d *Device) heldVoices(last bool) [][2]byte {
+	codes := d.noteOrder
+	if last {
+		if len(codes) == 0 {
+			return nil
+		}
+		codes = codes[len(codes)-1:] // all voices of the key playing a chord
+	}
+	var voices [][2]byte
+	for _, code := range codes {
+		voices = append(voices, d.noteTracker[code]...)
+	}
+	return voices
+}
+
+// SetHeldNotes binds registry of notes held on all devices, poly pressure is applied to notes
+// of other devices as well then, it has to be called before ProcessEvents
+func (d *Device) SetHeldNotes(heldNotes *HeldNotes) {
+	d.heldNotes = heldNotes
+}
+
+// HeldNotes keeps voices of keys held on every device in order of pressing
+type HeldNotes struct {
+	mutex *sync.Mutex
+	keys  []heldKey
+}
+
+type heldKey struct {
+	device *Device
+	code   evdev.EvCode
+	voices [][2]byte // 1: note, 2: channel
+}
+
+func NewHeldNotes() *HeldNotes {
+	return &HeldNotes{mutex: &sync.Mutex{}}
+}
+
+// press registers voices of the key pressed on the device, replacing previously registered ones
+func (h *HeldNotes) press(d *Device, code evdev.EvCode, voices [][2]byte) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.remove(d, code)
+	if len(voices) > 0 {
+		h.keys = append(h.keys, heldKey{device: d, code: code, voices: append([][2]byte{}, voices...)})
+	}
+}
+
+// release unregisters voices of the key released on the device
+func (h *HeldNotes) release(d *Device, code evdev.EvCode) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.remove(d, code)
+}
+
+func (h *HeldNotes) remove(d *Device, code evdev.EvCode) {
+	for i, k := range h.keys {
+		if k.device == d && k.code == code {
+			h.keys = append(h.keys[:i], h.keys[i+1:]...)
+			return
+		}
+	}
+}
+
+// voices returns voices of all held keys, only of the most recently pressed one with last
+func (h *HeldNotes) voices(last bool) [][2]byte {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	keys := h.keys
+	if last {
+		if len(keys) == 0 {
+			return nil
+		}
+		keys = keys[len(keys)-1:]
+	}
+	var voices [][2]byte
+	for _, k := range keys {
+		voices = append(voices, k.voices...)
+	}
+	return voices
+}
+
+// trackNoteOrder keeps held keys in order of pressing, so the most recent note can be found
+func (d *Device) trackNoteOrder(code evdev.EvCode, pressed bool) {
+	for i, c := range d.noteOrder {
+		if c == code {
+			d.noteOrder = append(d.noteOrder[:i], d.noteOrder[i+1:]...)
+			break
+		}
+	}
+	if pressed {
+		d.noteOrder = append(d.noteOrder, code)
+	}
+}
@@ -0,0 +1,130 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func pressureDevice(analog config.Analog) (*Device, chan midi.Event) {
+	keys := map[evdev.EvCode]config.Key{
+		evdev.BTN_A: {Note: 0},
+		evdev.BTN_B: {Note: 4},
+		evdev.BTN_X: {Note: 7, ChannelOffset: 1},
+	}
+	analogs := map[evdev.EvCode]config.Analog{evdev.ABS_Z: analog}
+	return testDevice(testConfig(keys, analogs), map[evdev.EvCode]evdev.AbsInfo{
+		evdev.ABS_Z: {Minimum: 0, Maximum: 127},
+	})
+}
+
+func TestChannelPressure(t *testing.T) {
+	d, midiEvents := pressureDevice(config.Analog{MappingType: config.AnalogChannelPressure, ChannelOffset: 2})
+
+	d.processEvent(abs(evdev.ABS_Z, 127))
+	d.processEvent(abs(evdev.ABS_Z, 0))
+
+	events, err := readN(midiEvents, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.ChannelPressureEvent(2, 127),
+		midi.ChannelPressureEvent(2, 0),
+	}, events)
+}
+
+func TestPolyPressureAll(t *testing.T) {
+	d, midiEvents := pressureDevice(config.Analog{MappingType: config.AnalogPolyPressure, PressureTarget: config.PressureAll})
+
+	// no held notes, nothing to apply pressure to
+	d.processEvent(abs(evdev.ABS_Z, 64))
+	_, err := readN(midiEvents, 0)
+	assert.Nil(t, err)
+
+	d.processEvent(key(evdev.BTN_A, EV_KEY_PRESS))
+	d.processEvent(key(evdev.BTN_X, EV_KEY_PRESS))
+	_, err = readN(midiEvents, 2)
+	assert.Nil(t, err)
+
+	d.processEvent(abs(evdev.ABS_Z, 127))
+	events, err := readN(midiEvents, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.PolyphonicKeyPressureEvent(0, 0, 127),
+		midi.PolyphonicKeyPressureEvent(1, 7, 127),
+	}, events)
+}
+
+func TestPolyPressureLast(t *testing.T) {
+	d, midiEvents := pressureDevice(config.Analog{MappingType: config.AnalogPolyPressure, PressureTarget: config.PressureLast})
+
+	d.processEvent(key(evdev.BTN_A, EV_KEY_PRESS))
+	d.processEvent(key(evdev.BTN_B, EV_KEY_PRESS))
+	_, err := readN(midiEvents, 2)
+	assert.Nil(t, err)
+
+	d.processEvent(abs(evdev.ABS_Z, 127))
+	events, err := readN(midiEvents, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, midi.PolyphonicKeyPressureEvent(0, 4, 127), events[0])
+
+	// most recent note released, previous one takes over
+	d.processEvent(key(evdev.BTN_B, EV_KEY_RELEASE))
+	d.processEvent(abs(evdev.ABS_Z, 0))
+	events, err = readN(midiEvents, 2)
+	assert.Nil(t, err)
+	assert.Equal(t, midi.NoteEvent(midi.NoteOff, 0, 4, 0), events[0])
+	assert.Equal(t, midi.PolyphonicKeyPressureEvent(0, 0, 0), events[1])
+}
+
+func TestPolyPressureHeldNotes(t *testing.T) {
+	heldNotes := NewHeldNotes()
+	keyboard, keyboardEvents := pressureDevice(config.Analog{MappingType: config.AnalogPolyPressure})
+	gamepad, gamepadEvents := pressureDevice(config.Analog{MappingType: config.AnalogPolyPressure, PressureTarget: config.PressureLast})
+	keyboard.SetHeldNotes(heldNotes)
+	gamepad.SetHeldNotes(heldNotes)
+
+	keyboard.processEvent(key(evdev.BTN_A, EV_KEY_PRESS))
+	keyboard.processEvent(key(evdev.BTN_X, EV_KEY_PRESS))
+	_, err := readN(keyboardEvents, 2)
+	assert.Nil(t, err)
+
+	// trigger of one device applies pressure to notes held on another one
+	gamepad.processEvent(abs(evdev.ABS_Z, 127))
+	events, err := readN(gamepadEvents, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, midi.PolyphonicKeyPressureEvent(1, 7, 127), events[0])
+
+	gamepad.processEvent(key(evdev.BTN_B, EV_KEY_PRESS))
+	_, err = readN(gamepadEvents, 1)
+	assert.Nil(t, err)
+
+	keyboard.processEvent(abs(evdev.ABS_Z, 64))
+	events, err = readN(keyboardEvents, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, []midi.Event{
+		midi.PolyphonicKeyPressureEvent(0, 0, 64),
+		midi.PolyphonicKeyPressureEvent(1, 7, 64),
+		midi.PolyphonicKeyPressureEvent(0, 4, 64),
+	}, events)
+
+	// released notes are not affected anymore
+	keyboard.processEvent(key(evdev.BTN_A, EV_KEY_RELEASE))
+	keyboard.processEvent(key(evdev.BTN_X, EV_KEY_RELEASE))
+	_, err = readN(keyboardEvents, 2)
+	assert.Nil(t, err)
+
+	gamepad.processEvent(abs(evdev.ABS_Z, 0))
+	events, err = readN(gamepadEvents, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, midi.PolyphonicKeyPressureEvent(0, 4, 0), events[0])
+
+	gamepad.processEvent(key(evdev.BTN_B, EV_KEY_RELEASE))
+	_, err = readN(gamepadEvents, 1)
+	assert.Nil(t, err)
+	keyboard.processEvent(abs(evdev.ABS_Z, 127))
+	_, err = readN(keyboardEvents, 0)
+	assert.Nil(t, err)
+}
@@ -0,0 +1,129 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+)
+
+type rumbleRequest struct {
+	strong, weak uint16
+	duration     time.Duration
+}
+
+// rumblePlayer is a force-feedback effect player, implemented by input.ForceFeedback
+type rumblePlayer interface {
+	Rumble(strong, weak uint16, duration time.Duration) error
+	Close() error
+}
+
+func newRumbleRequest(r config.Rumble, scale float64) rumbleRequest {
+	if !r.Scale {
+		scale = 1.0
+	}
+	return rumbleRequest{
+		strong:   uint16(math.Round(r.Strong * scale * math.MaxUint16)),
+		weak:     uint16(math.Round(r.Weak * scale * math.MaxUint16)),
+		duration: r.Duration,
+	}
+}
+
+// rumble queues effect without blocking the caller, effect is dropped if the player is busy
+func (d *Device) rumble(r rumbleRequest) {
+	select {
+	case d.rumbleRequests <- r:
+	default:
+	}
+}
+
+// rumbleOnNoteOn triggers note_on rules, called for notes played on the device itself
+func (d *Device) rumbleOnNoteOn(velocity byte) {
+	for _, r := range d.config.Rumble {
+		if r.Trigger == config.RumbleNoteOn {
+			d.rumble(newRumbleRequest(r, float64(velocity)/127))
+		}
+	}
+}
+
+// rumbleOnMidiIn triggers rules related to incoming midi events
+func (d *Device) rumbleOnMidiIn(ev midi.Event) {
+	if len(d.config.Rumble) == 0 {
+		return
+	}
+
+	switch ev.Type() {
+	case midi.TimingClock:
+		// clockTicks is incremented after rules are evaluated, it points to the current tick here
+		for _, r := range d.config.Rumble {
+			if r.Trigger == config.RumbleClock && d.clockTicks%r.Every == 0 {
+				d.rumble(newRumbleRequest(r, 1.0))
+			}
+		}
+	case midi.NoteOn:
+		if len(ev) < 3 || ev[2] == 0 { // note on with zero velocity is a note off
+			return
+		}
+		for _, r := range d.config.Rumble {
+			if r.Trigger != config.RumbleMidiNote {
+				continue
+			}
+			if (r.Note == -1 || r.Note == int(ev[1])) && (r.Channel == -1 || r.Channel == int(ev.Channel())) {
+				d.rumble(newRumbleRequest(r, float64(ev[2])/127))
+			}
+		}
+	case midi.ControlChange:
+		if len(ev) < 3 {
+			return
+		}
+		for _, r := range d.config.Rumble {
+			if r.Trigger != config.RumbleMidiCC {
+				continue
+			}
+			if (r.CC == -1 || r.CC == int(ev[1])) && (r.Channel == -1 || r.Channel == int(ev.Channel())) {
+				d.rumble(newRumbleRequest(r, float64(ev[2])/127))
+			}
+		}
+	}
+}
+
+func (d *Device) openRumblePlayer() (rumblePlayer, error) {
+	handler, ok := d.InputDevice.ForceFeedbackHandler()
+	if !ok {
+		return nil, fmt.Errorf("device doesn't support force-feedback")
+	}
+	return input.OpenForceFeedback(handler.DeviceInfo.EventPath())
+}
+
+func (d *Device) handleRumble(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	if len(d.config.Rumble) == 0 {
+		return
+	}
+
+	player, err := d.openRumblePlayer()
+	if err != nil {
+		log.Info(fmt.Sprintf("rumble disabled: %s", err), d.logFields(logger.Warning)...)
+		return
+	}
+	defer player.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case r := <-d.rumbleRequests:
+			err := player.Rumble(r.strong, r.weak, r.duration)
+			if err != nil {
+				log.Info(fmt.Sprintf("rumble failed: %s", err), d.logFields(logger.Warning)...)
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+func rumbleDevice(rules []config.Rumble) *Device {
+	d, _ := testDevice(testConfig(map[evdev.EvCode]config.Key{evdev.BTN_A: {Note: 0}}, nil, func(c *config.Config) {
+		c.Defaults.Velocity = 127
+		c.Rumble = rules
+	}), nil)
+	return d
+}
+
+func readRumble(d *Device) []rumbleRequest {
+	var requests []rumbleRequest
+	for {
+		select {
+		case r := <-d.rumbleRequests:
+			requests = append(requests, r)
+		default:
+			return requests
+		}
+	}
+}
+
+func TestRumbleNoteOn(t *testing.T) {
+	d := rumbleDevice([]config.Rumble{
+		{Trigger: config.RumbleNoteOn, Strong: 1.0, Duration: time.Millisecond * 30, Note: -1, CC: -1, Channel: -1},
+	})
+
+	d.processEvent(key(evdev.BTN_A, EV_KEY_PRESS))
+	d.processEvent(key(evdev.BTN_A, EV_KEY_RELEASE))
+
+	assert.Equal(t, []rumbleRequest{{strong: 0xffff, duration: time.Millisecond * 30}}, readRumble(d))
+}
+
+func TestRumbleClock(t *testing.T) {
+	d := rumbleDevice([]config.Rumble{
+		{Trigger: config.RumbleClock, Weak: 1.0, Duration: time.Millisecond * 10, Every: 24, Note: -1, CC: -1, Channel: -1},
+	})
+
+	// two quarter notes and a bit, pulse on each beat
+	for i := 0; i < 50; i++ {
+		d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	}
+	assert.Len(t, readRumble(d), 3)
+
+	// start message realigns beat counter
+	d.handleMidiInEvent(midi.Event{midi.TimingStart})
+	d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	d.handleMidiInEvent(midi.Event{midi.TimingClock})
+	assert.Len(t, readRumble(d), 1)
+}
+
+func TestRumbleIncoming(t *testing.T) {
+	d := rumbleDevice([]config.Rumble{
+		{Trigger: config.RumbleMidiNote, Strong: 1.0, Duration: time.Millisecond * 10, Note: 36, CC: -1, Channel: 9, Scale: true},
+		{Trigger: config.RumbleMidiCC, Weak: 1.0, Duration: time.Millisecond * 20, Note: -1, CC: 64, Channel: -1},
+	})
+
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 9, 36, 127))
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 9, 36, 0))   // note off
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 0, 36, 127)) // other channel
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 9, 38, 127)) // other note
+	d.handleMidiInEvent(midi.NoteEvent(midi.NoteOn, 9, 36, 0x40))
+	d.handleMidiInEvent(midi.ControlChangeEvent(3, 64, 127))
+	d.handleMidiInEvent(midi.ControlChangeEvent(3, 1, 127))
+
+	assert.Equal(t, []rumbleRequest{
+		{strong: 0xffff, duration: time.Millisecond * 10},
+		{strong: 0x8102, duration: time.Millisecond * 10}, // scaled by velocity
+		{weak: 0xffff, duration: time.Millisecond * 20},
+	}, readRumble(d))
+}
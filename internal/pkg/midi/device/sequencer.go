@@ -0,0 +1,392 @@
+package device
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/gethiox/HIDI/internal/pkg/input"
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+)
+
+// Step sequencer plays patterns of sixteenth note steps following midi clock transport.
+// In sequencer edit mode, toggled by sequencer action, keys below edit the patterns
+// and mapped note keys toggle notes of the selected step instead of playing them.
+// Patterns are saved into sequencer pattern file when edit mode is left.
+
+const (
+	stepTicks      = midi.ClockPPQN / 4 // clock ticks per step (sixteenth note)
+	sequencerPage  = 8                  // steps selected with number row at once
+	velocityChange = 8
+	gateChange     = 0.125
+	maxChain       = 64
+)
+
+var sequencerStepKeys = map[evdev.EvCode]int{
+	evdev.KEY_1: 0, evdev.KEY_2: 1, evdev.KEY_3: 2, evdev.KEY_4: 3,
+	evdev.KEY_5: 4, evdev.KEY_6: 5, evdev.KEY_7: 6, evdev.KEY_8: 7,
+}
+
+var sequencerKeys = map[evdev.EvCode]func(*sequencer, *Device){
+	evdev.KEY_9:         func(s *sequencer, d *Device) { s.selectStep(s.selected - sequencerPage) },
+	evdev.KEY_0:         func(s *sequencer, d *Device) { s.selectStep(s.selected + sequencerPage) },
+	evdev.KEY_MINUS:     func(s *sequencer, d *Device) { s.setLength(len(s.pattern().Steps) - 1) },
+	evdev.KEY_EQUAL:     func(s *sequencer, d *Device) { s.setLength(len(s.pattern().Steps) + 1) },
+	evdev.KEY_UP:        func(s *sequencer, d *Device) { s.changeVelocity(d, velocityChange) },
+	evdev.KEY_DOWN:      func(s *sequencer, d *Device) { s.changeVelocity(d, -velocityChange) },
+	evdev.KEY_RIGHT:     func(s *sequencer, d *Device) { s.changeGate(d, gateChange) },
+	evdev.KEY_LEFT:      func(s *sequencer, d *Device) { s.changeGate(d, -gateChange) },
+	evdev.KEY_BACKSPACE: func(s *sequencer, d *Device) { s.pattern().Steps[s.selected] = config.Step{} },
+	evdev.KEY_PAGEUP:    func(s *sequencer, d *Device) { s.selectPattern(s.edited + 1) },
+	evdev.KEY_PAGEDOWN:  func(s *sequencer, d *Device) { s.selectPattern(s.edited - 1) },
+	evdev.KEY_INSERT: func(s *sequencer, d *Device) {
+		if len(s.patterns.Chain) < maxChain {
+			s.patterns.Chain = append(s.patterns.Chain, s.edited)
+		}
+	},
+	evdev.KEY_DELETE: func(s *sequencer, d *Device) { s.patterns.Chain = nil },
+}
+
+type sequencerNoteOff struct {
+	note, channel byte
+	tick          int
+}
+
+type sequencer struct {
+	patterns *config.Patterns
+	edited   int // pattern being edited
+	selected int // selected step of edited pattern
+
+	running bool
+	tick    int // clock ticks since song beginning
+	played  int // steps played since song beginning, -1 before the first one
+	offs    []sequencerNoteOff
+}
+
+func newSequencer(patterns *config.Patterns, length int) sequencer {
+	if length < 1 {
+		length = 16
+	}
+	if patterns == nil {
+		patterns = config.NewPatterns(length)
+	}
+	return sequencer{patterns: patterns, played: -1}
+}
+
+func (s *sequencer) pattern() *config.Pattern {
+	return &s.patterns.Patterns[s.edited]
+}
+
+func (s *sequencer) selectStep(step int) {
+	if step < 0 || step >= len(s.pattern().Steps) {
+		return
+	}
+	s.selected = step
+}
+
+func (s *sequencer) selectPattern(pattern int) {
+	if pattern < 0 || pattern >= config.SequencerPatterns {
+		return
+	}
+	s.edited = pattern
+	if s.selected >= len(s.pattern().Steps) {
+		s.selected = len(s.pattern().Steps) - 1
+	}
+}
+
+func (s *sequencer) setLength(length int) {
+	if length < 1 || length > config.SequencerMaxSteps {
+		return
+	}
+	p := s.pattern()
+	if length > len(p.Steps) {
+		p.Steps = append(p.Steps, make([]config.Step, length-len(p.Steps))...)
+	} else {
+		p.Steps = p.Steps[:length]
+	}
+	if s.selected >= length {
+		s.selected = length - 1
+	}
+}
+
+// selectedStep returns selected step, velocity and gate of step without notes are set to defaults
+func (s *sequencer) selectedStep(d *Device) *config.Step {
+	step := &s.pattern().Steps[s.selected]
+	if len(step.Notes) == 0 {
+		step.Velocity = d.velocity
+		step.Gate = d.config.Sequencer.Gate
+	}
+	return step
+}
+
+func (s *sequencer) changeVelocity(d *Device, change int) {
+	step := s.selectedStep(d)
+	velocity := int(step.Velocity) + change
+	if velocity < 1 {
+		velocity = 1
+	}
+	if velocity > 127 {
+		velocity = 127
+	}
+	step.Velocity = byte(velocity)
+}
+
+func (s *sequencer) changeGate(d *Device, change float64) {
+	step := s.selectedStep(d)
+	step.Gate = math.Max(gateChange, math.Min(1, step.Gate+change))
+}
+
+// toggleNote adds note to the selected step, or removes it when already there
+func (s *sequencer) toggleNote(d *Device, note byte) {
+	step := s.selectedStep(d)
+	for i, n := range step.Notes {
+		if n == note {
+			step.Notes = append(step.Notes[:i:i], step.Notes[i+1:]...)
+			return
+		}
+	}
+	step.Notes = append(step.Notes, note)
+}
+
+// locate returns pattern and its step played as given step since song beginning
+func (s *sequencer) locate(played int) (pattern, step int) {
+	if len(s.patterns.Chain) == 0 {
+		return s.edited, played % len(s.pattern().Steps)
+	}
+
+	var total int
+	for _, p := range s.patterns.Chain {
+		total += len(s.patterns.Patterns[p].Steps)
+	}
+	played %= total
+	for _, p := range s.patterns.Chain {
+		length := len(s.patterns.Patterns[p].Steps)
+		if played < length {
+			return p, played
+		}
+		played -= length
+	}
+	return 0, 0 // unreachable
+}
+
+// playhead returns step of edited pattern being played, -1 if edited pattern is not played
+func (s *sequencer) playhead() int {
+	if !s.running || s.played < 0 {
+		return -1
+	}
+	pattern, step := s.locate(s.played)
+	if pattern != s.edited {
+		return -1
+	}
+	return step
+}
+
+// swingTick returns tick of every second step within the pair of steps
+func swingTick(swing float64) int {
+	return int(math.Round(swing / 100 * stepTicks * 2))
+}
+
+// sequencerClockSource returns clock the sequencer follows
+func (d *Device) sequencerClockSource() config.SequencerClock {
+	return d.clockSource(d.config.Sequencer.Clock)
+}
+
+// effectsClockSource returns clock note repeat follows
+func (d *Device) effectsClockSource() config.SequencerClock {
+	return d.clockSource(d.config.NoteEffects.Clock)
+}
+
+// clockSource returns configured clock, internal clock is used when not configured and enabled in hidi.toml
+func (d *Device) clockSource(clock config.SequencerClock) config.SequencerClock {
+	if clock != "" {
+		return clock
+	}
+	if d.clock != nil {
+		return config.SequencerInternal
+	}
+	return config.SequencerExternal
+}
+
+// handleSequencer feeds the sequencer and note repeat with internal clock, external one is handled by handleMidiInEvent
+func (d *Device) handleSequencer(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	sequencer := d.sequencerClockSource() == config.SequencerInternal
+	effects := d.effectsClockSource() == config.SequencerInternal
+	if !sequencer && !effects {
+		return
+	}
+	if d.clock == nil {
+		log.Info("sequencer: internal clock is disabled, enable it in hidi.toml [clock] section", d.logFields(logger.Warning)...)
+		return
+	}
+
+	events := d.clock.Listen(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-events:
+			if sequencer {
+				d.sequencerClock(ev)
+			}
+			if effects {
+				d.effectsClock(ev)
+			}
+		}
+	}
+}
+
+// sequencerClock advances the sequencer with midi clock and transport messages
+func (d *Device) sequencerClock(ev midi.Event) {
+	if len(ev) == 0 {
+		return
+	}
+
+	d.eventProcessMutex.Lock()
+	defer d.eventProcessMutex.Unlock()
+
+	s := &d.seq
+	switch ev[0] {
+	case midi.TimingStart:
+		s.releaseNotes(d)
+		s.running, s.tick, s.played = true, 0, -1
+	case midi.TimingContinue:
+		s.running = true
+	case midi.TimingStop:
+		s.running = false
+		s.releaseNotes(d)
+	case midi.SongPosition:
+		if len(ev) != 3 || s.running {
+			return
+		}
+		s.tick = (int(ev[2])<<7 | int(ev[1])) * stepTicks
+		s.played = s.tick/stepTicks - 1
+	case midi.TimingClock:
+		if !s.running {
+			return
+		}
+		played := s.played
+		s.clockTick(d)
+		s.tick++
+		if s.played == played {
+			return
+		}
+	default:
+		return
+	}
+	d.publishState()
+}
+
+// clockTick releases notes with elapsed gate and plays the step starting at current tick
+func (s *sequencer) clockTick(d *Device) {
+	offs := s.offs[:0]
+	for _, off := range s.offs {
+		if off.tick > s.tick {
+			offs = append(offs, off)
+			continue
+		}
+		d.outputEvents <- midi.NoteEvent(midi.NoteOff, off.channel, off.note, 0)
+	}
+	s.offs = offs
+
+	pair, within := s.tick/(stepTicks*2), s.tick%(stepTicks*2)
+	var played int
+	switch within {
+	case 0:
+		played = pair * 2
+	case swingTick(d.config.Sequencer.Swing):
+		played = pair*2 + 1
+	default:
+		return
+	}
+
+	s.played = played
+	pattern, index := s.locate(played)
+	step := s.patterns.Patterns[pattern].Steps[index]
+	gate := int(math.Max(1, math.Round(step.Gate*stepTicks)))
+	for _, note := range step.Notes {
+		for i, off := range s.offs {
+			if off.note == note && off.channel == d.channel {
+				// retriggered note is released first
+				d.outputEvents <- midi.NoteEvent(midi.NoteOff, off.channel, off.note, 0)
+				s.offs = append(s.offs[:i], s.offs[i+1:]...)
+				break
+			}
+		}
+		d.outputEvents <- midi.NoteEvent(midi.NoteOn, d.channel, note, step.Velocity)
+		s.offs = append(s.offs, sequencerNoteOff{note: note, channel: d.channel, tick: s.tick + gate})
+	}
+}
+
+// releaseNotes sends note off for all notes being played
+func (s *sequencer) releaseNotes(d *Device) {
+	for _, off := range s.offs {
+		d.outputEvents <- midi.NoteEvent(midi.NoteOff, off.channel, off.note, 0)
+	}
+	s.offs = nil
+}
+
+// sequencerKey handles key event in sequencer edit mode, returns true if event was consumed
+func (d *Device) sequencerKey(ie *input.InputEvent) bool {
+	code := ie.Event.Code
+	if ie.Event.Value != EV_KEY_PRESS {
+		// notes and actions pressed before edit mode was enabled are released normally
+		if _, tracked := d.noteTracker[code]; tracked {
+			return false
+		}
+		if action, ok := d.config.ActionMapping[code]; ok && d.actionTracker[action] {
+			return false
+		}
+		_, step := sequencerStepKeys[code]
+		_, key := sequencerKeys[code]
+		_, note := d.config.KeyMappings[d.mapping].Midi[ie.Source.Name][code]
+		return step || key || note
+	}
+
+	s := &d.seq
+	if step, ok := sequencerStepKeys[code]; ok {
+		s.selectStep(s.selected/sequencerPage*sequencerPage + step)
+		return true
+	}
+	if f, ok := sequencerKeys[code]; ok {
+		f(s, d)
+		return true
+	}
+
+	key, ok := d.config.KeyMappings[d.mapping].Midi[ie.Source.Name][code]
+	if !ok {
+		return false
+	}
+	note := int(key.Note) + int(d.octave)*12 + int(d.semitone)
+	if note < 0 || note > 127 {
+		return true
+	}
+	s.toggleNote(d, byte(note))
+	return true
+}
+
+func (d *Device) SequencerToggle() {
+	d.sequencerMode = !d.sequencerMode
+	if d.sequencerMode {
+		if !d.noLogs {
+			log.Info("Sequencer edit mode enabled", d.logFields(logger.Action)...)
+		}
+		return
+	}
+
+	if !d.noLogs {
+		log.Info("Sequencer edit mode disabled", d.logFields(logger.Action)...)
+	}
+	if d.sequencerPath == "" {
+		return
+	}
+	err := config.SavePatterns(d.sequencerPath, d.seq.patterns)
+	if err != nil {
+		log.Info(fmt.Sprintf("Sequencer: saving \"%s\" failed: %s", d.sequencerPath, err), d.logFields(logger.Warning)...)
+	}
+}
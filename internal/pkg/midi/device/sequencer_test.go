@@ -0,0 +1,201 @@
+package device
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/gethiox/HIDI/internal/pkg/midi"
+	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
+	"github.com/holoplot/go-evdev"
+	"github.com/stretchr/testify/assert"
+)
+
+// tap sends key press followed by its release
+func tap(d *Device, codes ...evdev.EvCode) {
+	for _, code := range codes {
+		d.processEvent(key(code, EV_KEY_PRESS))
+		d.processEvent(key(code, EV_KEY_RELEASE))
+	}
+}
+
+// ticks sends given number of midi clock ticks, events emitted on every tick are returned
+func ticks(d *Device, midiEvents chan midi.Event, n int) [][]midi.Event {
+	var emitted [][]midi.Event
+	for i := 0; i < n; i++ {
+		d.sequencerClock(midi.Event{midi.TimingClock})
+		var events []midi.Event
+		for len(midiEvents) > 0 {
+			events = append(events, <-midiEvents)
+		}
+		emitted = append(emitted, events)
+	}
+	return emitted
+}
+
+func sequencerDevice(t *testing.T) (*Device, chan midi.Event) {
+	cfg, err := getFactoryKeyboardConfiguration()
+	if !assert.NoError(t, err) {
+		return nil, nil
+	}
+	cfg.Config.ActionMapping[evdev.KEY_F9] = config.StepSequencer
+
+	d, midiEvents := testDevice(cfg, nil)
+	d.sequencerPath = filepath.Join(t.TempDir(), "sequencer", "Dummy.toml")
+	return d, midiEvents
+}
+
+func TestSequencerEdit(t *testing.T) {
+	d, midiEvents := sequencerDevice(t)
+	if d == nil {
+		return
+	}
+
+	tap(d, evdev.KEY_F9)
+	assert.True(t, d.sequencerMode)
+	assert.NotNil(t, d.deviceState().sequencer)
+
+	// note keys are not played in edit mode
+	tap(d, evdev.KEY_Z, evdev.KEY_C)
+	tap(d, evdev.KEY_2, evdev.KEY_X, evdev.KEY_UP, evdev.KEY_LEFT)
+	tap(d, evdev.KEY_0, evdev.KEY_C) // second page
+	_, err := readN(midiEvents, 0)
+	assert.NoError(t, err)
+
+	steps := d.seq.pattern().Steps
+	assert.Equal(t, config.Step{Notes: []byte{24, 28}, Velocity: 64, Gate: 0.5}, steps[0])
+	assert.Equal(t, config.Step{Notes: []byte{26}, Velocity: 72, Gate: 0.375}, steps[1])
+	assert.Equal(t, config.Step{Notes: []byte{28}, Velocity: 64, Gate: 0.5}, steps[9])
+
+	state := d.deviceState().sequencer
+	assert.Equal(t, 9, state.selected)
+	assert.Equal(t, []byte{28}, state.notes)
+	assert.Len(t, state.steps, 16)
+	assert.True(t, state.steps[0] && state.steps[1] && state.steps[9])
+
+	// toggled again, removed
+	tap(d, evdev.KEY_C, evdev.KEY_BACKSPACE, evdev.KEY_1)
+	assert.Empty(t, steps[9].Notes)
+
+	tap(d, evdev.KEY_MINUS, evdev.KEY_MINUS, evdev.KEY_PAGEUP, evdev.KEY_EQUAL)
+	assert.Len(t, d.seq.patterns.Patterns[0].Steps, 14)
+	assert.Len(t, d.seq.patterns.Patterns[1].Steps, 17)
+	tap(d, evdev.KEY_INSERT, evdev.KEY_PAGEDOWN, evdev.KEY_INSERT)
+	assert.Equal(t, []int{1, 0}, d.seq.patterns.Chain)
+
+	// patterns are saved when edit mode is left, note keys play again
+	tap(d, evdev.KEY_F9)
+	assert.False(t, d.sequencerMode)
+	saved, err := config.LoadPatterns(d.sequencerPath, 16)
+	assert.NoError(t, err)
+	assert.Equal(t, d.seq.patterns, saved)
+
+	tap(d, evdev.KEY_Z)
+	_, err = readN(midiEvents, 2)
+	assert.NoError(t, err)
+}
+
+func TestSequencerNoteHeldBeforeEditMode(t *testing.T) {
+	d, midiEvents := sequencerDevice(t)
+	if d == nil {
+		return
+	}
+
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_PRESS))
+	tap(d, evdev.KEY_F9)
+	d.processEvent(key(evdev.KEY_Z, EV_KEY_RELEASE))
+	events, err := readN(midiEvents, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, midi.NoteEvent(midi.NoteOff, 0, 24, 0), events[1])
+}
+
+func TestSequencerPlayback(t *testing.T) {
+	d, midiEvents := sequencerDevice(t)
+	if d == nil {
+		return
+	}
+	d.config.Sequencer.Swing = 75
+
+	s := &d.seq
+	s.pattern().Steps = []config.Step{
+		{Notes: []byte{60, 64}, Velocity: 100, Gate: 0.5},
+		{Notes: []byte{62}, Velocity: 90, Gate: 1},
+		{},
+	}
+
+	d.sequencerClock(midi.Event{midi.TimingStart})
+	emitted := ticks(d, midiEvents, 22)
+
+	assert.Equal(t, []midi.Event{
+		midi.NoteEvent(midi.NoteOn, 0, 60, 100),
+		midi.NoteEvent(midi.NoteOn, 0, 64, 100),
+	}, emitted[0])
+	assert.Equal(t, []midi.Event{
+		midi.NoteEvent(midi.NoteOff, 0, 60, 0),
+		midi.NoteEvent(midi.NoteOff, 0, 64, 0),
+	}, emitted[3])
+	// swung step
+	assert.Equal(t, []midi.Event{midi.NoteEvent(midi.NoteOn, 0, 62, 90)}, emitted[9])
+	assert.Equal(t, []midi.Event{midi.NoteEvent(midi.NoteOff, 0, 62, 0)}, emitted[15])
+	// third step is a rest, pattern starts again with the fourth (swung) one
+	assert.Empty(t, emitted[12])
+	assert.Equal(t, []midi.Event{
+		midi.NoteEvent(midi.NoteOn, 0, 60, 100),
+		midi.NoteEvent(midi.NoteOn, 0, 64, 100),
+	}, emitted[21])
+	for i, events := range emitted {
+		switch i {
+		case 0, 3, 9, 15, 21:
+		default:
+			assert.Empty(t, events, "tick %d", i)
+		}
+	}
+
+	// held notes are released on stop
+	d.sequencerClock(midi.Event{midi.TimingStop})
+	events, err := readN(midiEvents, 2)
+	if assert.NoError(t, err) {
+		assert.Equal(t, midi.NoteEvent(midi.NoteOff, 0, 60, 0), events[0])
+	}
+	assert.Empty(t, ticks(d, midiEvents, 1)[0])
+
+	// continue from the second step
+	d.sequencerClock(midi.Event{midi.SongPosition, 1, 0})
+	d.sequencerClock(midi.Event{midi.TimingContinue})
+	emitted = ticks(d, midiEvents, 4)
+	assert.Empty(t, emitted[0])
+	assert.Equal(t, []midi.Event{midi.NoteEvent(midi.NoteOn, 0, 62, 90)}, emitted[3])
+}
+
+func TestSequencerChain(t *testing.T) {
+	d, midiEvents := sequencerDevice(t)
+	if d == nil {
+		return
+	}
+
+	s := &d.seq
+	s.patterns.Patterns[0].Steps = []config.Step{{Notes: []byte{60}, Velocity: 100, Gate: 0.5}}
+	s.patterns.Patterns[1].Steps = []config.Step{{Notes: []byte{62}, Velocity: 100, Gate: 0.5}, {}}
+	s.patterns.Chain = []int{1, 0, 0}
+	s.edited = 0
+
+	d.sequencerClock(midi.Event{midi.TimingStart})
+	var played []byte
+	var playheads []int
+	for i := 0; i < stepTicks*8; i++ {
+		for _, ev := range ticks(d, midiEvents, 1)[0] {
+			if ev.Type() == midi.NoteOn {
+				played = append(played, ev.Note())
+			}
+		}
+		playheads = append(playheads, s.playhead())
+	}
+	assert.Equal(t, []byte{62, 60, 60, 62, 60, 60}, played)
+	assert.Equal(t, -1, playheads[0])
+	assert.Equal(t, 0, playheads[stepTicks*2])
+}
+
+func TestSwingTick(t *testing.T) {
+	assert.Equal(t, 6, swingTick(50))
+	assert.Equal(t, 8, swingTick(66))
+	assert.Equal(t, 9, swingTick(75))
+}
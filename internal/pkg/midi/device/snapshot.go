@@ -0,0 +1,166 @@
+package device
+
+import (
+	"time"
+
+	"github.com/holoplot/go-evdev"
+)
+
+// deviceSnapshot is an immutable copy of device state, it is published after processed events that change it,
+// so renderers can read it without taking eventProcessMutex
+type deviceSnapshot struct {
+	octave   int8
+	semitone int8
+	channel  uint8
+	mapping  int
+
+	notes       map[evdev.EvCode][][2]byte // copy of noteTracker
+	analogNotes [][2]byte                  // values of analogNoteTracker
+	keyEffects  *keyEffects
+
+	multinote  bool
+	ccLearning bool
+	sustain    bool
+
+	meterValue     float64
+	meterLevel     float64
+	meterLevelTime time.Time
+
+	lastNoteOn time.Time
+	lastPanic  time.Time
+
+	sequencer *sequencerSnapshot // nil outside of sequencer edit mode
+}
+
+// sequencerSnapshot holds edited pattern state shown on keyboard LEDs
+type sequencerSnapshot struct {
+	selected int    // selected step
+	playhead int    // step being played, -1 if edited pattern is not played
+	steps    []bool // steps with notes, pattern length is the number of steps
+	notes    []byte // notes of selected step
+}
+
+// externalSnapshot is an immutable copy of state received on midi input
+type externalSnapshot struct {
+	notes      [16][]byte // active notes by channel
+	sustain    bool
+	clockTicks int
+	lastClock  time.Time
+}
+
+// stateKey holds published state fields that are cheap to compare, events that don't change it
+// are not published again
+type stateKey struct {
+	octave, semitone       int8
+	channel                uint8
+	mapping                int
+	noteChanges            int
+	multinote, ccLearning  bool
+	sustain                bool
+	meterValue, meterLevel float64
+	lastNoteOn, lastPanic  time.Time
+	sequencer              bool
+}
+
+func (d *Device) stateKey() stateKey {
+	return stateKey{
+		octave:      d.octave,
+		semitone:    d.semitone,
+		channel:     d.channel,
+		mapping:     d.mapping,
+		noteChanges: d.noteChanges,
+		multinote:   len(d.multiNote) > 0,
+		ccLearning:  d.ccLearning,
+		sustain:     d.sustain,
+		meterValue:  d.meterValue,
+		meterLevel:  d.meterLevel,
+		lastNoteOn:  d.lastNoteOn,
+		lastPanic:   d.lastPanic,
+		sequencer:   d.sequencerMode,
+	}
+}
+
+// publishState publishes current device state, eventProcessMutex has to be held by the caller
+func (d *Device) publishState() {
+	notes := make(map[evdev.EvCode][][2]byte, len(d.noteTracker))
+	for code, voices := range d.noteTracker {
+		notes[code] = append([][2]byte{}, voices...)
+	}
+	analogNotes := make([][2]byte, 0, len(d.analogNoteTracker))
+	for _, noteAndChannel := range d.analogNoteTracker {
+		analogNotes = append(analogNotes, noteAndChannel)
+	}
+
+	d.state.Store(&deviceSnapshot{
+		octave:         d.octave,
+		semitone:       d.semitone,
+		channel:        d.channel,
+		mapping:        d.mapping,
+		notes:          notes,
+		analogNotes:    analogNotes,
+		keyEffects:     d.keyEffects.snapshot(),
+		multinote:      len(d.multiNote) > 0,
+		ccLearning:     d.ccLearning,
+		sustain:        d.sustain,
+		meterValue:     d.meterValue,
+		meterLevel:     d.meterLevel,
+		meterLevelTime: d.meterLevelTime,
+		lastNoteOn:     d.lastNoteOn,
+		lastPanic:      d.lastPanic,
+		sequencer:      d.sequencerSnapshot(),
+	})
+	d.publishedKey = d.stateKey()
+}
+
+// publishChangedState publishes device state when it differs from the published one, used for frequent
+// analog events that rarely change what renderers show, eventProcessMutex has to be held by the caller
+func (d *Device) publishChangedState() {
+	// edited pattern may change without changing the state key
+	if d.sequencerMode || d.stateKey() != d.publishedKey {
+		d.publishState()
+	}
+}
+
+// sequencerSnapshot returns copy of edited pattern state, nil outside of sequencer edit mode
+func (d *Device) sequencerSnapshot() *sequencerSnapshot {
+	if !d.sequencerMode {
+		return nil
+	}
+	s := &d.seq
+	p := s.pattern()
+	snapshot := sequencerSnapshot{
+		selected: s.selected,
+		playhead: s.playhead(),
+		steps:    make([]bool, len(p.Steps)),
+		notes:    append([]byte{}, p.Steps[s.selected].Notes...),
+	}
+	for i, step := range p.Steps {
+		snapshot.steps[i] = len(step.Notes) > 0
+	}
+	return &snapshot
+}
+
+// publishExternal publishes current midi input state, externalTrackerMutex has to be held by the caller
+func (d *Device) publishExternal() {
+	s := externalSnapshot{
+		sustain:    d.externalSustain,
+		clockTicks: d.clockTicks,
+		lastClock:  d.lastClock,
+	}
+	for ch, notes := range d.externalNoteTracker {
+		for note := range notes {
+			s.notes[ch&0x0f] = append(s.notes[ch&0x0f], note)
+		}
+	}
+	d.external.Store(&s)
+}
+
+// deviceState returns last published device state
+func (d *Device) deviceState() *deviceSnapshot {
+	return d.state.Load().(*deviceSnapshot)
+}
+
+// externalState returns last published midi input state
+func (d *Device) externalState() *externalSnapshot {
+	return d.external.Load().(*externalSnapshot)
+}
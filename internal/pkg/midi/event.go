@@ -2,6 +2,7 @@ package midi
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gethiox/HIDI/internal/pkg/logger"
 	"github.com/gethiox/HIDI/internal/pkg/midi/device/config"
@@ -20,10 +21,18 @@ const (
 	PitchWheelChange      uint8 = 0b1110 << 4
 
 	// ControlChange
+	DataEntryMSB        uint8 = 6
+	DataEntryLSB        uint8 = 38
+	Sustain             uint8 = 64
+	NRPNLSB             uint8 = 98
+	NRPNMSB             uint8 = 99
 	AllNotesOff         uint8 = 0b01111011
 	AllSoundOff         uint8 = 0b01111000
 	ResetAllControllers uint8 = 0b01111001
 
+	// System common
+	SongPosition uint8 = 0b11110010 // song position pointer, counted in sixteenth notes
+
 	// System real-time
 	TimingClock    uint8 = 0b11111000
 	TimingStart    uint8 = 0b11111010
@@ -31,6 +40,9 @@ const (
 	TimingStop     uint8 = 0b11111100
 )
 
+// ByteTime is a transmission time of a single byte over serial MIDI link (31250 baud, 10 bits per byte)
+const ByteTime = time.Microsecond * 320
+
 var IntervalToString = map[int]string{
 	0:  "Perfect unison",
 	1:  "Minor second",
@@ -119,6 +131,8 @@ func (e Event) String() string {
 			return fmt.Sprintf("Sync Continue")
 		case TimingStop:
 			return fmt.Sprintf("Sync Stop")
+		case SongPosition:
+			return fmt.Sprintf("Song Position: %d", int(e[2])<<7|int(e[1]))
 		}
 	}
 	msg := "Oof, unexpected event format: "
@@ -137,6 +151,14 @@ func ControlChangeEvent(channel, function, value uint8) Event {
 	return Event{ControlChange | channel, function, value}
 }
 
+func ChannelPressureEvent(channel, pressure uint8) Event {
+	return Event{ChannelPressure | channel, pressure}
+}
+
+func PolyphonicKeyPressureEvent(channel, note, pressure uint8) Event {
+	return Event{PolyphonicKeyPressure | channel, note, pressure}
+}
+
 // PitchBendEvent accepts a value in range -1.0 to 1.0
 func PitchBendEvent(channel uint8, val float64) Event {
 	target := int(float64((1<<14)-1) * ((val + 1.0) / 2.0)) // valid 14-bit pitch-bend range
@@ -144,3 +166,30 @@ func PitchBendEvent(channel uint8, val float64) Event {
 	lsb := uint8(target & 0b01111111)                       // filtering out one bit of msb, feels good man
 	return Event{PitchWheelChange | channel, lsb, msb}
 }
+
+// WireSize returns number of bytes needed to transmit given events over serial MIDI link,
+// status byte of channel message is omitted when it repeats the previous one (running status)
+func WireSize(events ...Event) int {
+	var size int
+	var status byte
+
+	for _, e := range events {
+		if len(e) == 0 {
+			continue
+		}
+		switch {
+		case e[0] >= 0b11111000: // real-time messages do not affect running status
+			size += len(e)
+			continue
+		case e[0] >= 0b11110000:
+			status = 0
+			size += len(e)
+		case e[0] == status:
+			size += len(e) - 1
+		default:
+			status = e[0]
+			size += len(e)
+		}
+	}
+	return size
+}
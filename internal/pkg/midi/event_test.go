@@ -453,3 +453,24 @@ func TestEvent_String(t *testing.T) {
 		})
 	}
 }
+
+func TestWireSize(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		events   []Event
+		expected int
+	}{
+		{"single", []Event{ControlChangeEvent(0, 1, 0)}, 3},
+		{"running status", []Event{ControlChangeEvent(0, 1, 0), ControlChangeEvent(0, 33, 0)}, 5},
+		{"channel change", []Event{ControlChangeEvent(0, 1, 0), ControlChangeEvent(1, 33, 0)}, 6},
+		{"real-time in between", []Event{ControlChangeEvent(0, 1, 0), {TimingClock}, ControlChangeEvent(0, 33, 0)}, 6},
+		{"nrpn", []Event{
+			ControlChangeEvent(0, NRPNMSB, 0), ControlChangeEvent(0, NRPNLSB, 1),
+			ControlChangeEvent(0, DataEntryMSB, 2), ControlChangeEvent(0, DataEntryLSB, 3),
+		}, 9},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, WireSize(tc.events...))
+		})
+	}
+}
@@ -0,0 +1,574 @@
+package midi
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+)
+
+const LooperSlots = 8
+
+type loopState int
+
+const (
+	loopEmpty loopState = iota
+	loopRecording
+	loopPlaying
+	loopOverdubbing
+	loopStopped
+)
+
+var loopStateNames = map[loopState]string{
+	loopEmpty:       "empty",
+	loopRecording:   "recording",
+	loopPlaying:     "playing",
+	loopOverdubbing: "overdubbing",
+	loopStopped:     "stopped",
+}
+
+// loopEvent is an event recorded at given position of the loop
+type loopEvent struct {
+	at    int64
+	event Event
+}
+
+// loopNote is a note played back by given layer
+type loopNote struct {
+	layer         int
+	channel, note byte
+}
+
+// loopSlot is a single loop made of layers, the first layer is recorded with record action
+// and each overdub adds another one. Positions are counted in nanoseconds for free-running
+// loops and in clock ticks for loops following the clock.
+type loopSlot struct {
+	state     loopState
+	layers    [][]loopEvent
+	recording []loopEvent
+	start     time.Time // position 0 of free-running loop
+	origin    int64     // song tick of position 0 of loop following the clock
+	length    int64     // 0 while the first layer is recorded
+	cursor    int64     // last played position, not wrapped
+	sounding  map[loopNote]int
+}
+
+// Looper records channel messages passing to midi output into loop slots and plays them back
+// through the same output. Loops are free-running, or with barTicks set, follow the clock
+// with length rounded to whole bars. Notes played back are tracked, so stopping, clearing
+// or undoing a layer never leaves hanging notes. Methods are safe for concurrent use.
+type Looper struct {
+	out      chan<- Event
+	wake     chan struct{}
+	barTicks int64 // clock ticks per bar, 0 for free-running loops
+
+	mutex    sync.Mutex
+	slots    [LooperSlots]loopSlot
+	selected int
+	songTick int64 // clock ticks since song beginning
+	running  bool  // clock transport state
+	pending  []Event
+}
+
+func NewLooper(barTicks int, out chan<- Event) *Looper {
+	l := &Looper{
+		out:      out,
+		wake:     make(chan struct{}, 1),
+		barTicks: int64(barTicks),
+	}
+	for i := range l.slots {
+		l.slots[i].sounding = make(map[loopNote]int)
+	}
+	return l
+}
+
+func (l *Looper) notify() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run passes events from in to midi output, recording them and playing recorded loops back,
+// until in is closed. When context is done loops stop, events are only passed through then,
+// so devices shutting down can still release their notes. Clock has to deliver clock messages
+// when barTicks is set.
+func (l *Looper) Run(ctx context.Context, in <-chan Event, clock <-chan Event) {
+	timer := time.NewTimer(time.Hour)
+	timer.Stop()
+
+	for {
+		l.mutex.Lock()
+		now := time.Now()
+		l.advance(now)
+		wait, wakeUp := l.nextEvent(now)
+		pending := l.pending
+		l.pending = nil
+		l.mutex.Unlock()
+
+		for _, ev := range pending {
+			l.out <- ev
+		}
+
+		var timeout <-chan time.Time
+		if wakeUp {
+			timer.Reset(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			l.stop(in)
+			return
+		case ev, ok := <-in:
+			if !ok {
+				l.stop(nil)
+				return
+			}
+			l.mutex.Lock()
+			l.input(ev, time.Now())
+			l.mutex.Unlock()
+		case ev := <-clock:
+			l.mutex.Lock()
+			l.clockEvent(ev, time.Now())
+			l.mutex.Unlock()
+		case <-l.wake:
+		case <-timeout:
+			timeout = nil
+		}
+
+		if timeout != nil && !timer.Stop() {
+			<-timer.C
+		}
+	}
+}
+
+// stop releases notes played back by loops and passes remaining events of in through until it is closed
+func (l *Looper) stop(in <-chan Event) {
+	l.mutex.Lock()
+	l.releaseAll()
+	pending := l.pending
+	l.pending = nil
+	l.mutex.Unlock()
+
+	for _, ev := range pending {
+		l.out <- ev
+	}
+	if in == nil {
+		return
+	}
+	for ev := range in {
+		l.out <- ev
+	}
+}
+
+// position returns current position of the slot, not wrapped, mutex has to be held by the caller
+func (l *Looper) position(s *loopSlot, now time.Time) int64 {
+	if l.barTicks > 0 {
+		return l.songTick - s.origin
+	}
+	return int64(now.Sub(s.start))
+}
+
+// floorMod returns non-negative remainder of a divided by b
+func floorMod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
+
+// input passes the event to midi output and records it into recorded slots, mutex has to be held by the caller
+func (l *Looper) input(ev Event, now time.Time) {
+	l.pending = append(l.pending, ev)
+	if len(ev) == 0 || ev[0] < NoteOff || ev[0] >= 0xf0 {
+		return // only channel messages are recorded
+	}
+
+	for i := range l.slots {
+		s := &l.slots[i]
+		if s.state != loopRecording && s.state != loopOverdubbing {
+			continue
+		}
+		at := l.position(s, now)
+		if s.length > 0 {
+			at = floorMod(at, s.length)
+		}
+		s.recording = append(s.recording, loopEvent{at: at, event: append(Event{}, ev...)})
+	}
+}
+
+// clockEvent follows clock transport, mutex has to be held by the caller
+func (l *Looper) clockEvent(ev Event, now time.Time) {
+	if len(ev) == 0 || l.barTicks == 0 {
+		return
+	}
+
+	switch ev[0] {
+	case TimingStart:
+		l.running, l.songTick = true, 0
+		l.rewind(now)
+	case TimingContinue:
+		l.running = true
+		l.rewind(now)
+	case TimingStop:
+		l.running = false
+		l.releaseAll()
+	case SongPosition:
+		if len(ev) != 3 || l.running {
+			return
+		}
+		l.songTick = int64(int(ev[2])<<7|int(ev[1])) * ClockPPQN / 4
+	case TimingClock:
+		if !l.running {
+			return
+		}
+		l.songTick++
+		l.advance(now)
+	}
+}
+
+// rewind moves cursors of played slots to the current song position, mutex has to be held by the caller
+func (l *Looper) rewind(now time.Time) {
+	for i := range l.slots {
+		s := &l.slots[i]
+		s.cursor = l.position(s, now) - 1
+	}
+}
+
+// playing tells if slot plays its layers back
+func (s *loopSlot) playing() bool {
+	return s.state == loopPlaying || s.state == loopOverdubbing
+}
+
+// advance plays events of all playing slots up to now, mutex has to be held by the caller
+func (l *Looper) advance(now time.Time) {
+	if l.barTicks > 0 && !l.running {
+		return
+	}
+	for i := range l.slots {
+		s := &l.slots[i]
+		if !s.playing() {
+			continue
+		}
+		position := l.position(s, now)
+		l.playRange(s, s.cursor, position)
+		s.cursor = position
+	}
+}
+
+// playRange sends events of slot layers placed within (from, to] range of positions, mutex has to be held by the caller
+func (l *Looper) playRange(s *loopSlot, from, to int64) {
+	if to <= from {
+		return
+	}
+
+	type scheduled struct {
+		at    int64
+		layer int
+		event Event
+	}
+	var events []scheduled
+	first, last := from/s.length-1, to/s.length+1
+	for layer, recorded := range s.layers {
+		for k := first; k <= last; k++ {
+			for _, e := range recorded {
+				at := k*s.length + e.at
+				if at > from && at <= to {
+					events = append(events, scheduled{at: at, layer: layer, event: e.event})
+				}
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at < events[j].at })
+
+	for _, e := range events {
+		l.playEvent(s, e.layer, e.event)
+	}
+}
+
+// playEvent sends played back event, notes are tracked so they can be released later
+func (l *Looper) playEvent(s *loopSlot, layer int, ev Event) {
+	switch ev.Type() {
+	case NoteOn, NoteOff:
+		key := loopNote{layer: layer, channel: ev.Channel(), note: ev.Note()}
+		if ev.Type() == NoteOn && ev[2] > 0 {
+			s.sounding[key]++
+			break
+		}
+		if s.sounding[key] == 0 {
+			return // note wasn't started by the loop
+		}
+		s.sounding[key]--
+		if s.sounding[key] == 0 {
+			delete(s.sounding, key)
+		}
+	}
+	l.pending = append(l.pending, ev)
+}
+
+// nextEvent returns time left to the next event of free-running loops, mutex has to be held by the caller
+func (l *Looper) nextEvent(now time.Time) (time.Duration, bool) {
+	if l.barTicks > 0 {
+		return 0, false
+	}
+
+	var next int64 = -1
+	for i := range l.slots {
+		s := &l.slots[i]
+		if !s.playing() {
+			continue
+		}
+		position := l.position(s, now)
+		phase := floorMod(position, s.length)
+		for _, layer := range s.layers {
+			for _, e := range layer {
+				wait := e.at - phase
+				if wait <= 0 {
+					wait += s.length
+				}
+				if next < 0 || wait < next {
+					next = wait
+				}
+			}
+		}
+	}
+	if next < 0 {
+		return 0, false
+	}
+	return time.Duration(next), true
+}
+
+// release sends note off for notes played back by the slot, only notes of given layer with layer >= 0,
+// mutex has to be held by the caller
+func (l *Looper) release(s *loopSlot, layer int) {
+	var keys []loopNote
+	for key := range s.sounding {
+		if layer < 0 || key.layer == layer {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].channel != keys[j].channel {
+			return keys[i].channel < keys[j].channel
+		}
+		return keys[i].note < keys[j].note
+	})
+	for _, key := range keys {
+		l.pending = append(l.pending, NoteEvent(NoteOff, key.channel, key.note, 0))
+		delete(s.sounding, key)
+	}
+}
+
+func (l *Looper) releaseAll() {
+	for i := range l.slots {
+		l.release(&l.slots[i], -1)
+	}
+}
+
+// commit finishes recorded layer, notes left on are closed at the current position,
+// mutex has to be held by the caller
+func (l *Looper) commit(s *loopSlot, now time.Time) {
+	position := l.position(s, now)
+	if s.length == 0 {
+		s.length = position
+		if l.barTicks > 0 {
+			bars := (position + l.barTicks/2) / l.barTicks
+			if bars < 1 {
+				bars = 1
+			}
+			s.length = bars * l.barTicks
+		}
+		if s.length <= 0 {
+			s.length = 1
+		}
+		for i := range s.recording {
+			s.recording[i].at = floorMod(s.recording[i].at, s.length)
+		}
+		s.cursor = position - 1
+	}
+
+	type channelNote struct{ channel, note byte }
+	open := make(map[channelNote]int)
+	var order []channelNote
+	for _, e := range s.recording {
+		key := channelNote{e.event.Channel(), e.event.Note()}
+		switch {
+		case e.event.Type() == NoteOn && e.event[2] > 0:
+			if open[key] == 0 {
+				order = append(order, key)
+			}
+			open[key]++
+		case e.event.Type() == NoteOn || e.event.Type() == NoteOff:
+			if open[key] > 0 {
+				open[key]--
+			}
+		}
+	}
+	at := floorMod(position, s.length)
+	for _, key := range order {
+		for i := 0; i < open[key]; i++ {
+			s.recording = append(s.recording, loopEvent{at: at, event: NoteEvent(NoteOff, key.channel, key.note, 0)})
+		}
+	}
+
+	s.layers = append(s.layers, s.recording)
+	s.recording = nil
+}
+
+// startRecording begins the first layer of selected slot, mutex has to be held by the caller
+func (l *Looper) startRecording(s *loopSlot, now time.Time) {
+	l.release(s, -1)
+	*s = loopSlot{state: loopRecording, sounding: s.sounding, start: now}
+	if l.barTicks > 0 {
+		s.origin = l.songTick / l.barTicks * l.barTicks
+	}
+}
+
+// action runs f on selected slot and logs the outcome
+func (l *Looper) action(name string, now time.Time, f func(s *loopSlot, now time.Time)) {
+	l.mutex.Lock()
+	s := &l.slots[l.selected]
+	f(s, now)
+	status := l.status()
+	l.mutex.Unlock()
+	l.notify()
+	log.Info(fmt.Sprintf("looper %s: %s", name, status), logger.Action)
+}
+
+// Record starts recording of a new loop in selected slot, replacing the old one.
+// Recorded loop starts playing when recording is pressed again.
+func (l *Looper) Record() {
+	l.action("record", time.Now(), l.toggleRecord)
+}
+
+func (l *Looper) toggleRecord(s *loopSlot, now time.Time) {
+	if s.state == loopRecording {
+		l.commit(s, now)
+		s.state = loopPlaying
+		return
+	}
+	l.startRecording(s, now)
+}
+
+// Play starts or stops playback of selected slot, recording or overdubbing is finished first
+func (l *Looper) Play() {
+	l.action("play", time.Now(), l.togglePlay)
+}
+
+func (l *Looper) togglePlay(s *loopSlot, now time.Time) {
+	switch s.state {
+	case loopEmpty:
+	case loopRecording:
+		l.commit(s, now)
+		s.state = loopPlaying
+	case loopOverdubbing:
+		l.commit(s, now)
+		fallthrough
+	case loopPlaying:
+		s.state = loopStopped
+		l.release(s, -1)
+	case loopStopped:
+		s.state = loopPlaying
+		s.cursor = l.position(s, now) - 1
+	}
+}
+
+// Overdub starts or finishes recording of another layer of selected slot, stopped slot starts playing
+func (l *Looper) Overdub() {
+	l.action("overdub", time.Now(), l.toggleOverdub)
+}
+
+func (l *Looper) toggleOverdub(s *loopSlot, now time.Time) {
+	switch s.state {
+	case loopEmpty:
+	case loopRecording:
+		l.commit(s, now)
+		s.state = loopOverdubbing
+	case loopOverdubbing:
+		l.commit(s, now)
+		s.state = loopPlaying
+	case loopStopped:
+		s.cursor = l.position(s, now) - 1
+		fallthrough
+	case loopPlaying:
+		s.state = loopOverdubbing
+	}
+}
+
+// Undo discards layer being recorded, or removes the last layer of selected slot
+func (l *Looper) Undo() {
+	l.action("undo", time.Now(), l.undo)
+}
+
+func (l *Looper) undo(s *loopSlot, now time.Time) {
+	switch {
+	case s.state == loopRecording:
+		l.clear(s, now)
+	case s.state == loopOverdubbing:
+		s.recording = nil
+		s.state = loopPlaying
+	case len(s.layers) > 0:
+		l.release(s, len(s.layers)-1)
+		s.layers = s.layers[:len(s.layers)-1]
+		if len(s.layers) == 0 {
+			l.clear(s, now)
+		}
+	}
+}
+
+// Clear stops selected slot and removes its loop
+func (l *Looper) Clear() {
+	l.action("clear", time.Now(), l.clear)
+}
+
+func (l *Looper) clear(s *loopSlot, now time.Time) {
+	l.release(s, -1)
+	*s = loopSlot{sounding: s.sounding}
+}
+
+// SlotUp selects the next slot, loops of other slots keep playing
+func (l *Looper) SlotUp() {
+	l.selectSlot(1)
+}
+
+// SlotDown selects the previous slot, loops of other slots keep playing
+func (l *Looper) SlotDown() {
+	l.selectSlot(-1)
+}
+
+func (l *Looper) selectSlot(change int) {
+	l.mutex.Lock()
+	selected := l.selected + change
+	if selected >= 0 && selected < LooperSlots {
+		l.selected = selected
+	}
+	status := l.status()
+	l.mutex.Unlock()
+	log.Info(fmt.Sprintf("looper: %s", status), logger.Action)
+}
+
+// Status describes selected slot
+func (l *Looper) Status() string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.status()
+}
+
+func (l *Looper) status() string {
+	s := &l.slots[l.selected]
+	status := fmt.Sprintf("slot %d/%d %s", l.selected+1, LooperSlots, loopStateNames[s.state])
+	if s.length == 0 {
+		return status
+	}
+
+	length := time.Duration(s.length).Round(time.Millisecond).String()
+	if l.barTicks > 0 {
+		length = fmt.Sprintf("%d bars", s.length/l.barTicks)
+	}
+	return fmt.Sprintf("%s, %d layers, %s", status, len(s.layers), length)
+}
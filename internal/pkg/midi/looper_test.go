@@ -0,0 +1,244 @@
+package midi
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gethiox/HIDI/internal/pkg/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// nothing displays log messages in tests, looper actions would block on full log channel otherwise
+	go func() {
+		for range logger.Messages {
+		}
+	}()
+	os.Exit(m.Run())
+}
+
+// drain returns events waiting to be sent by the looper
+func drain(l *Looper) []Event {
+	pending := l.pending
+	l.pending = nil
+	return pending
+}
+
+// tickTo sends clock ticks up to given song position, events sent on every tick are returned by song position
+func tickTo(l *Looper, songTick int64) map[int64][]Event {
+	emitted := make(map[int64][]Event)
+	for l.songTick < songTick {
+		l.clockEvent(Event{TimingClock}, time.Time{})
+		if events := drain(l); len(events) > 0 {
+			emitted[l.songTick] = events
+		}
+	}
+	return emitted
+}
+
+func TestLooperClock(t *testing.T) {
+	l := NewLooper(8, nil)
+	s := &l.slots[0]
+	now := time.Time{}
+
+	l.clockEvent(Event{TimingStart}, now)
+	l.toggleRecord(s, now)
+	l.input(NoteEvent(NoteOn, 0, 60, 100), now)
+	assert.Equal(t, []Event{NoteEvent(NoteOn, 0, 60, 100)}, drain(l)) // recorded events are passed through
+	tickTo(l, 2)
+	l.input(NoteEvent(NoteOff, 0, 60, 0), now)
+	tickTo(l, 4)
+	l.input(NoteEvent(NoteOn, 0, 62, 90), now) // held when recording is finished
+	tickTo(l, 9)
+	assert.Len(t, s.recording, 3)
+
+	// 9 ticks are rounded to a single bar, the loop continues from its second tick
+	l.toggleRecord(s, now)
+	assert.Equal(t, loopPlaying, s.state)
+	assert.Equal(t, int64(8), s.length)
+	assert.Equal(t, map[int64][]Event{
+		12: {NoteEvent(NoteOn, 0, 62, 90)},
+		16: {NoteEvent(NoteOn, 0, 60, 100)},
+		17: {NoteEvent(NoteOff, 0, 62, 0)},
+		18: {NoteEvent(NoteOff, 0, 60, 0)},
+	}, tickTo(l, 19))
+
+	l.toggleOverdub(s, now)
+	l.input(NoteEvent(NoteOn, 1, 64, 80), now)
+	tickTo(l, 21)
+	l.toggleOverdub(s, now)
+	drain(l)
+	assert.Len(t, s.layers, 2)
+	assert.Equal(t, map[int64][]Event{
+		24: {NoteEvent(NoteOn, 0, 60, 100)},
+		25: {NoteEvent(NoteOff, 0, 62, 0)},
+		26: {NoteEvent(NoteOff, 0, 60, 0)},
+		27: {NoteEvent(NoteOn, 1, 64, 80)},
+	}, tickTo(l, 27))
+
+	// undone layer releases its notes
+	l.undo(s, now)
+	assert.Equal(t, []Event{NoteEvent(NoteOff, 1, 64, 0)}, drain(l))
+	assert.Len(t, s.layers, 1)
+	assert.Empty(t, tickTo(l, 30)[29])
+
+	// transport stop releases notes, the loop is played from the song position when started again
+	tickTo(l, 32)
+	l.clockEvent(Event{TimingStop}, now)
+	assert.Equal(t, []Event{NoteEvent(NoteOff, 0, 60, 0), NoteEvent(NoteOff, 0, 62, 0)}, drain(l))
+	l.clockEvent(Event{TimingClock}, now)
+	assert.Empty(t, drain(l))
+	assert.Equal(t, int64(32), l.songTick)
+	l.clockEvent(Event{SongPosition, 1, 0}, now) // sixth tick
+	l.clockEvent(Event{TimingContinue}, now)
+	assert.Equal(t, map[int64][]Event{
+		8:  {NoteEvent(NoteOn, 0, 60, 100)},
+		10: {NoteEvent(NoteOff, 0, 60, 0)},
+		12: {NoteEvent(NoteOn, 0, 62, 90)},
+	}, tickTo(l, 13))
+
+	l.clear(s, now)
+	assert.Equal(t, []Event{NoteEvent(NoteOff, 0, 62, 0)}, drain(l))
+	assert.Equal(t, loopEmpty, s.state)
+	assert.Empty(t, tickTo(l, 40))
+}
+
+func TestLooperFree(t *testing.T) {
+	l := NewLooper(0, nil)
+	s := &l.slots[0]
+	t0 := time.Now()
+	at := func(ms int) time.Time { return t0.Add(time.Duration(ms) * time.Millisecond) }
+
+	l.toggleRecord(s, at(0))
+	l.input(NoteEvent(NoteOn, 0, 60, 100), at(10))
+	l.input(NoteEvent(NoteOff, 0, 60, 0), at(20))
+	l.input(ControlChangeEvent(0, 1, 64), at(30))
+	l.togglePlay(s, at(100))
+	drain(l)
+	assert.Equal(t, loopPlaying, s.state)
+	assert.Equal(t, int64(time.Millisecond*100), s.length)
+
+	l.advance(at(100))
+	assert.Empty(t, drain(l))
+	wait, ok := l.nextEvent(at(100))
+	assert.True(t, ok)
+	assert.Equal(t, time.Millisecond*10, wait)
+
+	l.advance(at(115))
+	assert.Equal(t, []Event{NoteEvent(NoteOn, 0, 60, 100)}, drain(l))
+	wait, _ = l.nextEvent(at(115))
+	assert.Equal(t, time.Millisecond*5, wait)
+
+	// late advance plays all missed events
+	l.advance(at(212))
+	assert.Equal(t, []Event{
+		NoteEvent(NoteOff, 0, 60, 0),
+		ControlChangeEvent(0, 1, 64),
+		NoteEvent(NoteOn, 0, 60, 100),
+	}, drain(l))
+
+	// stopping releases notes
+	l.togglePlay(s, at(215))
+	assert.Equal(t, loopStopped, s.state)
+	assert.Equal(t, []Event{NoteEvent(NoteOff, 0, 60, 0)}, drain(l))
+	l.advance(at(500))
+	assert.Empty(t, drain(l))
+	_, ok = l.nextEvent(at(500))
+	assert.False(t, ok)
+
+	// overdub of stopped loop starts playing it
+	l.toggleOverdub(s, at(505))
+	assert.Equal(t, loopOverdubbing, s.state)
+	l.input(NoteEvent(NoteOn, 0, 67, 100), at(540))
+	l.undo(s, at(550))
+	assert.Equal(t, loopPlaying, s.state)
+	assert.Len(t, s.layers, 1)
+}
+
+func TestLooperSlots(t *testing.T) {
+	l := NewLooper(0, nil)
+
+	l.SlotDown()
+	assert.Equal(t, 0, l.selected)
+	for i := 0; i < LooperSlots+2; i++ {
+		l.SlotUp()
+	}
+	assert.Equal(t, LooperSlots-1, l.selected)
+	assert.Equal(t, "slot 8/8 empty", l.Status())
+
+	// actions on empty slot do nothing
+	l.Play()
+	l.Overdub()
+	l.Undo()
+	assert.Equal(t, loopEmpty, l.slots[LooperSlots-1].state)
+
+	l.Record()
+	l.Record()
+	assert.Contains(t, l.Status(), "slot 8/8 playing, 1 layers")
+}
+
+func TestLooperRun(t *testing.T) {
+	in := make(chan Event, 16)
+	out := make(chan Event, 16)
+	l := NewLooper(0, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx, in, nil)
+		close(done)
+	}()
+
+	l.Record()
+	in <- NoteEvent(NoteOn, 0, 60, 100)
+	assert.Equal(t, NoteEvent(NoteOn, 0, 60, 100), <-out)
+	time.Sleep(time.Millisecond * 20)
+	l.Record()
+
+	// the loop starts again right away, its note is released when input is closed
+	assert.Equal(t, NoteEvent(NoteOn, 0, 60, 100), <-out)
+	close(in)
+	<-done
+	assert.Equal(t, NoteEvent(NoteOff, 0, 60, 0), <-out)
+}
+
+func TestLooperRunCancel(t *testing.T) {
+	in := make(chan Event, 16)
+	out := make(chan Event, 16)
+	l := NewLooper(0, out)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		l.Run(ctx, in, nil)
+		close(done)
+	}()
+
+	l.Record()
+	in <- NoteEvent(NoteOn, 0, 60, 100)
+	assert.Equal(t, NoteEvent(NoteOn, 0, 60, 100), <-out)
+	time.Sleep(time.Millisecond * 200) // long enough for the loop not to repeat during the test
+	l.Record()
+	assert.Equal(t, NoteEvent(NoteOn, 0, 60, 100), <-out)
+
+	// device note held while quitting
+	in <- NoteEvent(NoteOn, 1, 64, 100)
+	assert.Equal(t, NoteEvent(NoteOn, 1, 64, 100), <-out)
+
+	// loop note is released right away, device note offs sent at its shutdown are still passed through
+	cancel()
+	assert.Equal(t, NoteEvent(NoteOff, 0, 60, 0), <-out)
+	in <- NoteEvent(NoteOff, 1, 64, 0)
+	assert.Equal(t, NoteEvent(NoteOff, 1, 64, 0), <-out)
+
+	close(in)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("looper didn't exit")
+	}
+	assert.Empty(t, out)
+}
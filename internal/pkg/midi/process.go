@@ -3,6 +3,7 @@ package midi
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/gethiox/HIDI/internal/pkg/logger"
 	"github.com/gethiox/HIDI/internal/pkg/midi/driver"
@@ -14,11 +15,19 @@ type Score struct {
 	MidiEventsEmitted uint
 }
 
-func ProcessMidiEvents(ctx context.Context, port driver.Port,
+// ProcessMidiEvents sends events to midi output and passes events received on midi input further.
+// With thru defined, input events are forwarded to midi output as well, merged with midiEventsOut
+// on the level of complete messages. Output is processed until midiEventsOut is closed, so note offs
+// sent by devices at shutdown are not lost, wg is done when it's finished.
+func ProcessMidiEvents(wg *sync.WaitGroup, ctx context.Context, port driver.Port,
 	midiEventsOut <-chan Event, midiEventsIn chan<- Event,
-	score *Score) {
+	thru *Thru, score *Score) {
 
+	thruEvents := make(chan Event, 16)
+
+	wg.Add(1)
 	go func() {
+		defer wg.Done()
 		err := port.Output.Open()
 		if err != nil {
 			panic(err)
@@ -31,18 +40,18 @@ func ProcessMidiEvents(ctx context.Context, port driver.Port,
 	root:
 		for {
 			select {
-			case <-ctx.Done():
-				break root
 			case ev, ok = <-midiEventsOut:
-				if ok { // todo: investigate
-					if ev[0]&0b11110000 == NoteOn {
-						score.Score++
-					}
+				if !ok {
+					break root
 				}
+				if ev[0]&0b11110000 == NoteOn {
+					score.Score++
+				}
+				score.MidiEventsEmitted++
+			case ev = <-thruEvents:
 			}
 
 			portOut <- ev
-			score.MidiEventsEmitted++
 		}
 
 		log.Info("Processing output midi events stopped", logger.Debug)
@@ -55,6 +64,7 @@ func ProcessMidiEvents(ctx context.Context, port driver.Port,
 		}
 		defer port.Input.Close()
 		var inEvents = make(chan []byte, 10)
+		var parser StreamParser
 		go func() {
 			for ev := range port.Input.ReceiveChannel() {
 				inEvents <- ev
@@ -72,6 +82,10 @@ func ProcessMidiEvents(ctx context.Context, port driver.Port,
 
 				msg := gomidi.Message(ev)
 				log.Info(fmt.Sprintf("input event: %s (%#v)", msg.String(), ev), logger.Debug)
+
+				if thru != nil && !forwardThru(ctx, &parser, *thru, ev, thruEvents) {
+					break root
+				}
 			}
 
 		}
@@ -79,3 +93,19 @@ func ProcessMidiEvents(ctx context.Context, port driver.Port,
 		log.Info("Processing input midi events stopped", logger.Debug)
 	}()
 }
+
+// forwardThru passes complete messages of received data to thruEvents, false is returned when context is done
+func forwardThru(ctx context.Context, parser *StreamParser, thru Thru, data []byte, thruEvents chan<- Event) bool {
+	for _, e := range parser.Parse(data) {
+		forwarded, ok := thru.Apply(e)
+		if !ok {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case thruEvents <- forwarded:
+		}
+	}
+	return true
+}
@@ -0,0 +1,177 @@
+package midi
+
+// MessageKind groups midi messages for the purpose of filtering
+type MessageKind string
+
+const (
+	KindNote            MessageKind = "note"             // note on/off
+	KindPolyPressure    MessageKind = "poly_pressure"    // polyphonic aftertouch
+	KindCC              MessageKind = "cc"               // control change
+	KindProgramChange   MessageKind = "program_change"   // program change
+	KindChannelPressure MessageKind = "channel_pressure" // channel aftertouch
+	KindPitchBend       MessageKind = "pitch_bend"       // pitch-bend
+	KindSysEx           MessageKind = "sysex"            // system exclusive
+	KindSystemCommon    MessageKind = "system_common"    // time code, song position, song select, tune request
+	KindClock           MessageKind = "clock"            // timing clock
+	KindTransport       MessageKind = "transport"        // start, continue, stop
+	KindActiveSensing   MessageKind = "active_sensing"   // active sensing
+	KindReset           MessageKind = "reset"            // system reset
+)
+
+var SupportedMessageKinds = map[MessageKind]bool{
+	KindNote:            true,
+	KindPolyPressure:    true,
+	KindCC:              true,
+	KindProgramChange:   true,
+	KindChannelPressure: true,
+	KindPitchBend:       true,
+	KindSysEx:           true,
+	KindSystemCommon:    true,
+	KindClock:           true,
+	KindTransport:       true,
+	KindActiveSensing:   true,
+	KindReset:           true,
+}
+
+// Kind returns kind of given message, empty for undefined messages
+func (e Event) Kind() MessageKind {
+	if len(e) == 0 {
+		return ""
+	}
+	switch e[0] {
+	case 0xf0:
+		return KindSysEx
+	case 0xf1, 0xf2, 0xf3, 0xf6:
+		return KindSystemCommon
+	case TimingClock:
+		return KindClock
+	case TimingStart, TimingContinue, TimingStop:
+		return KindTransport
+	case 0xfe:
+		return KindActiveSensing
+	case 0xff:
+		return KindReset
+	}
+	switch e[0] & 0xf0 {
+	case NoteOff, NoteOn:
+		return KindNote
+	case PolyphonicKeyPressure:
+		return KindPolyPressure
+	case ControlChange:
+		return KindCC
+	case ProgramChange:
+		return KindProgramChange
+	case ChannelPressure:
+		return KindChannelPressure
+	case PitchWheelChange:
+		return KindPitchBend
+	}
+	return ""
+}
+
+// dataLength returns number of data bytes following given status byte, SysEx excluded
+func dataLength(status byte) int {
+	switch {
+	case status >= 0xf0:
+		switch status {
+		case 0xf1, 0xf3:
+			return 1
+		case 0xf2:
+			return 2
+		}
+		return 0
+	case status&0xf0 == ProgramChange, status&0xf0 == ChannelPressure:
+		return 1
+	}
+	return 2
+}
+
+// StreamParser splits raw midi data into complete messages. Running status is expanded,
+// SysEx received in several chunks is reassembled and real-time messages are returned as soon
+// as they appear, even in the middle of other message.
+type StreamParser struct {
+	status  byte   // running status of channel messages
+	message []byte // message collected so far
+	sysex   bool   // SysEx in progress
+}
+
+// Parse returns messages completed by given data, incomplete message is kept for the next call
+func (p *StreamParser) Parse(data []byte) []Event {
+	var events []Event
+	for _, b := range data {
+		if b >= TimingClock {
+			events = append(events, Event{b})
+			continue
+		}
+
+		if p.sysex {
+			if b < 0x80 {
+				p.message = append(p.message, b)
+				continue
+			}
+			p.sysex = false
+			if b == 0xf7 {
+				events = append(events, append(p.message, b))
+				p.message = nil
+				continue
+			}
+			// SysEx interrupted by another status byte is dropped
+			p.message = nil
+		}
+
+		if b >= 0x80 {
+			p.message = []byte{b}
+			switch {
+			case b == 0xf0:
+				p.status = 0
+				p.sysex = true
+				continue
+			case b == 0xf7: // end of SysEx that was never started
+				p.status = 0
+				p.message = nil
+				continue
+			case b > 0xf0:
+				p.status = 0
+			default:
+				p.status = b
+			}
+		} else {
+			if len(p.message) == 0 {
+				if p.status == 0 {
+					continue // data byte without status
+				}
+				p.message = []byte{p.status}
+			}
+			p.message = append(p.message, b)
+		}
+
+		if len(p.message)-1 == dataLength(p.message[0]) {
+			events = append(events, p.message)
+			p.message = nil
+		}
+	}
+	return events
+}
+
+// Thru defines how messages received on midi input are forwarded to midi output
+type Thru struct {
+	Channels map[byte]byte        // source: destination channel (0-15), other channels are forwarded unchanged
+	Drop     map[MessageKind]bool // message kinds that are not forwarded
+}
+
+// Apply returns forwarded copy of given message, false is returned when message is dropped
+func (t Thru) Apply(e Event) (Event, bool) {
+	kind := e.Kind()
+	if kind == "" || t.Drop[kind] {
+		return nil, false
+	}
+
+	forwarded := make(Event, len(e))
+	copy(forwarded, e)
+	if e[0] < 0xf0 {
+		if channel, ok := t.Channels[e.Channel()]; ok {
+			forwarded[0] = e[0]&0xf0 | channel&0x0f
+		}
+	}
+	return forwarded, true
+}
@@ -0,0 +1,115 @@
+package midi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamParser(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		chunks   [][]byte
+		expected []Event
+	}{
+		{
+			name:   "running status",
+			chunks: [][]byte{{0x90, 60, 100, 62, 100}, {64, 0}},
+			expected: []Event{
+				{0x90, 60, 100}, {0x90, 62, 100}, {0x90, 64, 0},
+			},
+		},
+		{
+			name:     "message split into chunks",
+			chunks:   [][]byte{{0xb3}, {7}, {100, 0xc3}, {5}},
+			expected: []Event{{0xb3, 7, 100}, {0xc3, 5}},
+		},
+		{
+			name:     "sysex in chunks",
+			chunks:   [][]byte{{0xf0, 0x41, 0x10}, {0x42, 0x12}, {0xf7, 0x80, 60, 0}},
+			expected: []Event{{0xf0, 0x41, 0x10, 0x42, 0x12, 0xf7}, {0x80, 60, 0}},
+		},
+		{
+			name:     "real-time inside sysex and message",
+			chunks:   [][]byte{{0xf0, 0x7e, 0xf8, 0x09, 0xf7}, {0xe0, 0xfe, 0, 64}},
+			expected: []Event{{0xf8}, {0xf0, 0x7e, 0x09, 0xf7}, {0xfe}, {0xe0, 0, 64}},
+		},
+		{
+			name:     "interrupted sysex",
+			chunks:   [][]byte{{0xf0, 0x01, 0x02, 0x90, 60, 100}},
+			expected: []Event{{0x90, 60, 100}},
+		},
+		{
+			name:     "system common cancels running status",
+			chunks:   [][]byte{{0x90, 60, 100, 0xf3, 2, 62, 100}},
+			expected: []Event{{0x90, 60, 100}, {0xf3, 2}},
+		},
+		{
+			name:     "stray data and end of sysex",
+			chunks:   [][]byte{{10, 20, 0xf7, 0xfa}},
+			expected: []Event{{0xfa}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var parser StreamParser
+			var events []Event
+			for _, chunk := range tc.chunks {
+				events = append(events, parser.Parse(chunk)...)
+			}
+			assert.Equal(t, tc.expected, events)
+		})
+	}
+}
+
+func TestEventKind(t *testing.T) {
+	for _, tc := range []struct {
+		event    Event
+		expected MessageKind
+	}{
+		{event: Event{0x83, 60, 0}, expected: KindNote},
+		{event: Event{0x93, 60, 100}, expected: KindNote},
+		{event: Event{0xa0, 60, 10}, expected: KindPolyPressure},
+		{event: Event{0xb0, 1, 10}, expected: KindCC},
+		{event: Event{0xc0, 1}, expected: KindProgramChange},
+		{event: Event{0xd0, 1}, expected: KindChannelPressure},
+		{event: Event{0xe0, 0, 64}, expected: KindPitchBend},
+		{event: Event{0xf0, 1, 0xf7}, expected: KindSysEx},
+		{event: Event{0xf2, 0, 0}, expected: KindSystemCommon},
+		{event: Event{0xf8}, expected: KindClock},
+		{event: Event{0xfc}, expected: KindTransport},
+		{event: Event{0xfe}, expected: KindActiveSensing},
+		{event: Event{0xff}, expected: KindReset},
+		{event: Event{0xf9}, expected: ""},
+		{event: Event{}, expected: ""},
+	} {
+		assert.Equal(t, tc.expected, tc.event.Kind(), "%#v", tc.event)
+	}
+}
+
+func TestThruApply(t *testing.T) {
+	thru := Thru{
+		Channels: map[byte]byte{0: 9},
+		Drop:     map[MessageKind]bool{KindClock: true, KindSysEx: true},
+	}
+
+	e := Event{0x90, 60, 100}
+	forwarded, ok := thru.Apply(e)
+	assert.True(t, ok)
+	assert.Equal(t, Event{0x99, 60, 100}, forwarded)
+	assert.Equal(t, Event{0x90, 60, 100}, e, "source event modified")
+
+	forwarded, ok = thru.Apply(Event{0xb1, 7, 100})
+	assert.True(t, ok)
+	assert.Equal(t, Event{0xb1, 7, 100}, forwarded)
+
+	forwarded, ok = thru.Apply(Event{0xfa})
+	assert.True(t, ok)
+	assert.Equal(t, Event{0xfa}, forwarded)
+
+	_, ok = thru.Apply(Event{0xf8})
+	assert.False(t, ok)
+	_, ok = thru.Apply(Event{0xf0, 1, 0xf7})
+	assert.False(t, ok)
+	_, ok = thru.Apply(Event{0xfd})
+	assert.False(t, ok)
+}
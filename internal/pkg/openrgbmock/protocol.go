@@ -0,0 +1,131 @@
+package openrgbmock
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/realbucksavage/openrgb-go"
+)
+
+const (
+	headerSize  = 16
+	magic       = "ORGB"
+	description = "HIDI mock controller"
+
+	zoneTypeLinear = 1
+)
+
+// OpenRGB SDK commands handled by the server
+const (
+	commandRequestControllerCount = 0
+	commandRequestControllerData  = 1
+	commandSetClientName          = 50
+	commandUpdateLEDs             = 1050
+	commandUpdateZoneLEDs         = 1051
+)
+
+type header struct {
+	deviceID  uint32
+	commandID uint32
+	length    uint32
+}
+
+func decodeHeader(buf []byte) (header, error) {
+	if string(buf[:4]) != magic {
+		return header{}, fmt.Errorf("unexpected magic: %q", buf[:4])
+	}
+	return header{
+		deviceID:  binary.LittleEndian.Uint32(buf[4:]),
+		commandID: binary.LittleEndian.Uint32(buf[8:]),
+		length:    binary.LittleEndian.Uint32(buf[12:]),
+	}, nil
+}
+
+// encodeMessage returns header followed by payload, so the whole message can be sent with a single write
+func encodeMessage(deviceID, commandID uint32, payload []byte) []byte {
+	var b = bytes.NewBufferString(magic)
+	for _, v := range []uint32{deviceID, commandID, uint32(len(payload))} {
+		binary.Write(b, binary.LittleEndian, v)
+	}
+	b.Write(payload)
+	return b.Bytes()
+}
+
+// writeString writes string in OpenRGB format, length prefix counts null terminator
+func writeString(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.LittleEndian, uint16(len(s)+1))
+	b.WriteString(s)
+	b.WriteByte(0)
+}
+
+func writeColor(b *bytes.Buffer, c openrgb.Color) {
+	b.Write([]byte{c.Red, c.Green, c.Blue, 0})
+}
+
+// encodeController returns controller data as responded to RequestControllerData command
+func encodeController(c Controller, colors []openrgb.Color) []byte {
+	var b = &bytes.Buffer{}
+
+	binary.Write(b, binary.LittleEndian, c.Type)
+	for _, s := range []string{c.Name, description, "", "", c.Location} {
+		writeString(b, s)
+	}
+
+	// single "Direct" mode, clients assume the active one exists
+	binary.Write(b, binary.LittleEndian, uint16(1))
+	binary.Write(b, binary.LittleEndian, uint32(0))
+	writeString(b, "Direct")
+	binary.Write(b, binary.LittleEndian, [9]uint32{0, 0, 0, 0, 0, 0, 0, 0, 1}) // color mode: Per-LED
+	binary.Write(b, binary.LittleEndian, uint16(0))
+
+	zones := c.zones()
+	binary.Write(b, binary.LittleEndian, uint16(len(zones)))
+	for _, z := range zones {
+		writeString(b, z.Name)
+		binary.Write(b, binary.LittleEndian, [4]uint32{zoneTypeLinear, uint32(z.LEDs), uint32(z.LEDs), uint32(z.LEDs)})
+		binary.Write(b, binary.LittleEndian, uint16(0)) // no matrix map
+	}
+
+	binary.Write(b, binary.LittleEndian, uint16(len(c.LEDs)))
+	for _, led := range c.LEDs {
+		writeString(b, led)
+		binary.Write(b, binary.LittleEndian, uint32(0))
+	}
+
+	binary.Write(b, binary.LittleEndian, uint16(len(colors)))
+	for _, color := range colors {
+		writeColor(b, color)
+	}
+
+	// data size prefix includes itself
+	var data = make([]byte, 4, b.Len()+4)
+	binary.LittleEndian.PutUint32(data, uint32(b.Len()+4))
+	return append(data, b.Bytes()...)
+}
+
+func decodeColors(buf []byte, count int) []openrgb.Color {
+	var colors = make([]openrgb.Color, 0, count)
+	for i := 0; i < count && len(buf) >= (i+1)*4; i++ {
+		colors = append(colors, openrgb.Color{Red: buf[i*4], Green: buf[i*4+1], Blue: buf[i*4+2]})
+	}
+	return colors
+}
+
+// decodeUpdateLEDs returns colors of UpdateLEDs payload: data size, color count, colors.
+// Color count is derived from payload length, some clients encode size fields with a single byte only.
+func decodeUpdateLEDs(payload []byte) ([]openrgb.Color, error) {
+	if len(payload) < 6 {
+		return nil, fmt.Errorf("payload too short: %d", len(payload))
+	}
+	return decodeColors(payload[6:], (len(payload)-6)/4), nil
+}
+
+// decodeUpdateZoneLEDs returns zone index and colors of UpdateZoneLEDs payload: data size, zone, color count, colors
+func decodeUpdateZoneLEDs(payload []byte) (int, []openrgb.Color, error) {
+	if len(payload) < 10 {
+		return 0, nil, fmt.Errorf("payload too short: %d", len(payload))
+	}
+	zone := int(binary.LittleEndian.Uint32(payload[4:]))
+	return zone, decodeColors(payload[10:], (len(payload)-10)/4), nil
+}
@@ -0,0 +1,259 @@
+// Package openrgbmock implements minimal OpenRGB SDK server with fake controllers,
+// it records LED updates sent by clients, so OpenRGB support can be tested without OpenRGB and hardware.
+package openrgbmock
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/realbucksavage/openrgb-go"
+)
+
+// Location is reported by mock controllers, real controllers report hidraw path here
+const Location = "HIDI mock"
+
+// TypeKeyboard is OpenRGB device type of keyboards
+const TypeKeyboard = 5
+
+// maxFrames limits number of recorded frames, the oldest ones are dropped
+const maxFrames = 1000
+
+type Zone struct {
+	Name string
+	LEDs int
+}
+
+// Controller is a fake device advertised by the server
+type Controller struct {
+	Name     string
+	Type     uint32
+	Location string
+	LEDs     []string
+	Zones    []Zone // single zone of all LEDs if not defined
+}
+
+func (c Controller) zones() []Zone {
+	if len(c.Zones) == 0 {
+		return []Zone{{Name: c.Name, LEDs: len(c.LEDs)}}
+	}
+	return c.Zones
+}
+
+// Frame is a LED update received from a client
+type Frame struct {
+	Controller int
+	Colors     []openrgb.Color // colors of all controller LEDs after the update
+}
+
+type Server struct {
+	listener    net.Listener
+	controllers []Controller
+
+	mutex  sync.Mutex
+	colors [][]openrgb.Color
+	frames []Frame
+	conns  map[net.Conn]bool
+	closed bool
+}
+
+// Listen creates server listening on given address, "127.0.0.1:0" picks a free port
+func Listen(addr string, controllers ...Controller) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("listen failed: %w", err)
+	}
+
+	s := &Server{
+		listener:    l,
+		controllers: controllers,
+		colors:      make([][]openrgb.Color, len(controllers)),
+		conns:       make(map[net.Conn]bool),
+	}
+	for i, c := range controllers {
+		s.colors[i] = make([]openrgb.Color, len(c.LEDs))
+	}
+	return s, nil
+}
+
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Serve handles clients until given context is done
+func (s *Server) Serve(ctx context.Context) error {
+	wg := sync.WaitGroup{}
+	defer wg.Wait()
+
+	go func() {
+		<-ctx.Done()
+		s.listener.Close()
+
+		s.mutex.Lock()
+		s.closed = true
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mutex.Unlock()
+	}()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("accept failed: %w", err)
+		}
+
+		s.mutex.Lock()
+		if s.closed {
+			s.mutex.Unlock()
+			conn.Close()
+			return nil
+		}
+		s.conns[conn] = true
+		s.mutex.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.handle(conn)
+
+			s.mutex.Lock()
+			delete(s.conns, conn)
+			s.mutex.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// Colors returns current colors of given controller
+func (s *Server) Colors(controller int) []openrgb.Color {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]openrgb.Color{}, s.colors[controller]...)
+}
+
+// Frames returns recorded LED updates, oldest first
+func (s *Server) Frames() []Frame {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]Frame{}, s.frames...)
+}
+
+func (s *Server) handle(conn net.Conn) {
+	var buf = make([]byte, headerSize)
+	for {
+		_, err := io.ReadFull(conn, buf)
+		if err != nil {
+			return
+		}
+
+		h, err := decodeHeader(buf)
+		if err != nil {
+			return
+		}
+
+		payload := make([]byte, h.length)
+		_, err = io.ReadFull(conn, payload)
+		if err != nil {
+			return
+		}
+
+		response, err := s.process(h, payload)
+		if err != nil {
+			return
+		}
+		if response == nil {
+			continue
+		}
+
+		// clients read header and payload with separate reads of exact size
+		_, err = conn.Write(encodeMessage(h.deviceID, h.commandID, response))
+		if err != nil {
+			return
+		}
+	}
+}
+
+// process handles single command and returns response payload, nil if command has no response
+func (s *Server) process(h header, payload []byte) ([]byte, error) {
+	switch h.commandID {
+	case commandRequestControllerCount:
+		var count = make([]byte, 4)
+		binary.LittleEndian.PutUint32(count, uint32(len(s.controllers)))
+		return count, nil
+	case commandRequestControllerData:
+		i, err := s.controller(h.deviceID)
+		if err != nil {
+			return nil, err
+		}
+		return encodeController(s.controllers[i], s.Colors(i)), nil
+	case commandUpdateLEDs:
+		i, err := s.controller(h.deviceID)
+		if err != nil {
+			return nil, err
+		}
+		colors, err := decodeUpdateLEDs(payload)
+		if err != nil {
+			return nil, err
+		}
+		s.update(i, 0, colors)
+	case commandUpdateZoneLEDs:
+		i, err := s.controller(h.deviceID)
+		if err != nil {
+			return nil, err
+		}
+		zone, colors, err := decodeUpdateZoneLEDs(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		zones := s.controllers[i].zones()
+		if zone >= len(zones) {
+			return nil, fmt.Errorf("zone out of range: %d", zone)
+		}
+		offset := 0
+		for _, z := range zones[:zone] {
+			offset += z.LEDs
+		}
+		if offset+zones[zone].LEDs > len(s.controllers[i].LEDs) {
+			return nil, fmt.Errorf("zone exceeds controller LEDs: %d", zone)
+		}
+		if len(colors) > zones[zone].LEDs {
+			colors = colors[:zones[zone].LEDs]
+		}
+		s.update(i, offset, colors)
+	}
+
+	// client name, mode changes and other commands are accepted silently
+	return nil, nil
+}
+
+func (s *Server) controller(id uint32) (int, error) {
+	if int(id) >= len(s.controllers) {
+		return 0, errors.New("controller out of range")
+	}
+	return int(id), nil
+}
+
+// update sets colors of given controller starting from given LED and records a frame
+func (s *Server) update(controller, offset int, colors []openrgb.Color) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	copy(s.colors[controller][offset:], colors)
+
+	s.frames = append(s.frames, Frame{
+		Controller: controller,
+		Colors:     append([]openrgb.Color{}, s.colors[controller]...),
+	})
+	if len(s.frames) > maxFrames {
+		s.frames = s.frames[len(s.frames)-maxFrames:]
+	}
+}
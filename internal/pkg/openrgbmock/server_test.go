@@ -0,0 +1,136 @@
+package openrgbmock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/realbucksavage/openrgb-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(t *testing.T, controllers ...Controller) (*Server, *openrgb.Client) {
+	s, err := Listen("127.0.0.1:0", controllers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan bool)
+	go func() {
+		assert.NoError(t, s.Serve(ctx))
+		close(done)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	c, err := openrgb.Connect("127.0.0.1", s.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return s, c
+}
+
+// waitFrames waits for LED updates to be processed, client doesn't wait for any response
+func waitFrames(s *Server, n int) []Frame {
+	timeout := time.Now().Add(time.Second)
+	for time.Now().Before(timeout) {
+		if frames := s.Frames(); len(frames) >= n {
+			return frames
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return s.Frames()
+}
+
+func TestServerControllers(t *testing.T) {
+	_, c := serve(t,
+		Controller{Name: "Keyboard", Type: TypeKeyboard, Location: Location, LEDs: []string{"Key: A", "Key: S"}},
+		Controller{Name: "Strip", Type: 1, Location: "nowhere", LEDs: []string{"LED 1", "LED 2", "LED 3"},
+			Zones: []Zone{{Name: "Front", LEDs: 1}, {Name: "Back", LEDs: 2}}},
+	)
+
+	count, err := c.GetControllerCount()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	dev, err := c.GetDeviceController(0)
+	assert.NoError(t, err)
+	assert.Equal(t, "Keyboard", dev.Name)
+	assert.Equal(t, uint32(TypeKeyboard), dev.Type)
+	assert.Equal(t, Location, dev.Location)
+	assert.Equal(t, []openrgb.LED{{Name: "Key: A"}, {Name: "Key: S"}}, dev.LEDs)
+	assert.Len(t, dev.Colors, 2)
+	assert.Len(t, dev.Modes, 1)
+	assert.Equal(t, []openrgb.Zone{{Name: "Keyboard", Type: 1, MinLEDs: 2, MaxLEDs: 2, TotalLEDs: 2}}, dev.Zones)
+
+	dev, err = c.GetDeviceController(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "Strip", dev.Name)
+	assert.Len(t, dev.LEDs, 3)
+	assert.Len(t, dev.Zones, 2)
+	assert.Equal(t, "Back", dev.Zones[1].Name)
+}
+
+func TestServerUpdateLEDs(t *testing.T) {
+	leds := make([]string, 100) // more than single byte of size field used by the client
+	s, c := serve(t, Controller{Name: "Keyboard", Type: TypeKeyboard, LEDs: leds})
+
+	colors := make([]openrgb.Color, 100)
+	colors[0] = openrgb.Color{Red: 1}
+	colors[99] = openrgb.Color{Blue: 2}
+
+	assert.NoError(t, c.UpdateLEDs(0, []openrgb.Color{{Green: 3}}))
+	assert.NoError(t, c.UpdateLEDs(0, colors))
+
+	frames := waitFrames(s, 2)
+	assert.Len(t, frames, 2)
+	assert.Equal(t, openrgb.Color{Green: 3}, frames[0].Colors[0])
+	assert.Equal(t, colors, frames[1].Colors)
+	assert.Equal(t, colors, s.Colors(0))
+
+	// server keeps colors reported to clients
+	dev, err := c.GetDeviceController(0)
+	assert.NoError(t, err)
+	assert.Equal(t, colors, dev.Colors)
+}
+
+func TestServerFramesLimit(t *testing.T) {
+	s, c := serve(t, Controller{Name: "Keyboard", Type: TypeKeyboard, LEDs: []string{"Key: A"}})
+
+	var last openrgb.Color
+	for i := 0; i < maxFrames+10; i++ {
+		last = openrgb.Color{Red: byte(i), Green: byte(i >> 8)}
+		assert.NoError(t, c.UpdateLEDs(0, []openrgb.Color{last}))
+	}
+
+	// the last frame is the one to arrive last
+	timeout := time.Now().Add(time.Second)
+	for time.Now().Before(timeout) && s.Colors(0)[0] != last {
+		time.Sleep(time.Millisecond)
+	}
+	frames := s.Frames()
+	assert.Len(t, frames, maxFrames)
+	assert.Equal(t, last, frames[len(frames)-1].Colors[0])
+}
+
+func TestDecodeUpdateZoneLEDs(t *testing.T) {
+	payload := []byte{
+		18, 0, 0, 0, // data size
+		1, 0, 0, 0, // zone
+		2, 0, // color count
+		1, 2, 3, 0,
+		4, 5, 6, 0,
+	}
+
+	zone, colors, err := decodeUpdateZoneLEDs(payload)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, zone)
+	assert.Equal(t, []openrgb.Color{{Red: 1, Green: 2, Blue: 3}, {Red: 4, Green: 5, Blue: 6}}, colors)
+
+	_, _, err = decodeUpdateZoneLEDs(payload[:8])
+	assert.Error(t, err)
+}